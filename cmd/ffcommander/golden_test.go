@@ -0,0 +1,156 @@
+// (C) Mikael Hartzell 2018
+// This program is distributed under the GNU General Public License, version 3 (GPLv3)
+
+package main
+
+import (
+	"context"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mhartzel/ffcommander"
+	"github.com/mhartzel/ffcommander/encode"
+)
+
+// The commandlines generated for each option combination are compared against golden files in testdata/golden.
+// The jobs are built from the options the same way as in main, so that only option combinations the user can give are tested.
+// When a change in the commandlines is intended, regenerate the golden files with: go test -run Test_golden_commandlines -update
+// and check the changes with git diff before committing them.
+var update = flag.Bool("update", false, "rewrite the golden files in testdata/golden with the generated commandlines")
+
+var golden_test_cases = []struct {
+	name string
+	commandline string
+}{
+	{"autocrop", "-ac"},
+	{"subtitle_split", "-s fin -sp -sr 0.8 -mkv"},
+	{"subtitle_burn", "-s eng -so 40"},
+	{"subtitle_mux", "-sm fin,eng -mkv"},
+	{"parallel_sd", "-a fin -ac -psd"},
+	{"scale_to_sd", "-ssd -crf"},
+	{"split_times", "-sf 0-10:00,20:00-end"},
+	{"split_times_frame_numbers", "-sf 0f-17982f,00:20:00;00-end"},
+	{"crf", "-crf -st 10:00 -d 5:00"},
+	{"crf_smpte_timecode", "-crf -st 00:10:00;00 -et 27000f"},
+	{"lossless", "-ls -dn -it -nd -mkv"},
+	{"timecode", "-tc -gr"},
+	{"colour_adjustments", "-abk 0.1 -awh 0.9 -agm 1.1 -ach 0.8"},
+	{"audio_ac3", "-a fin -ac3"},
+	{"audio_aac", "-aac"},
+	{"audio_opus_stereo", "-opus -stereo -mkv"},
+	{"audio_flac_all", "-flac -aall -mkv"},
+	{"hevc", "-hevc -crf"},
+	{"fast", "-f -s eng"},
+	{"webm", "-webm"},
+	{"audio_offset_parallel_sd", "-ad -250 -psd"},
+}
+
+func job_from_test_commandline(t *testing.T, commandline string, input_file string) ffcommander.Job {
+
+	// Parse, check and complete the options like main does and store them in a job
+	t.Helper()
+
+	define_commandline_options()
+
+	arguments := append(strings.Fields(commandline), input_file)
+	input_filenames, option_errors := parse_options(arguments, "")
+	option_errors = append(option_errors, validate_options()...)
+	apply_implied_options()
+
+	job := job_from_commandline_options(input_filenames, append([]string{"ffcommander"}, arguments...), ffcommander.Default_config())
+	option_errors = append(option_errors, ffcommander.Check_job(context.Background(), job)...)
+
+	if len(option_errors) > 0 {
+		t.Fatalf("%s: %q", commandline, option_errors)
+	}
+
+	return job
+}
+
+func Test_golden_commandlines(t *testing.T) {
+
+	// Ffprobe output for movie.mkv is in testdata/movie.json of the library:
+	// 1920x1080 h264 video, audio 0: ac3 5.1 eng, audio 1: dts stereo fin, subtitle 0: pgs eng, subtitle 1: dvd fin
+	ffprobe_json_output, error_code := os.ReadFile(filepath.Join("..", "..", "testdata", "movie.json"))
+
+	if error_code != nil {
+		t.Fatal(error_code)
+	}
+
+	for _, test_case := range golden_test_cases {
+
+		t.Run(test_case.name, func(t *testing.T) {
+
+			temp_dir := t.TempDir()
+			input_file := filepath.Join(temp_dir, "movie.mkv")
+
+			if error_code := os.WriteFile(input_file, nil, 0644); error_code != nil {
+				t.Fatal(error_code)
+			}
+
+			// Every FFmpeg run prints a cropdetect line, only the crop detection reads it
+			runner := &encode.Recording_command_runner{Canned_outputs: map[string]encode.Canned_command_output_struct{
+				"ffprobe": {Stdout: string(ffprobe_json_output)},
+				"ffmpeg": {Stderr: "[Parsed_cropdetect_0 @ 0x1] x1:0 x2:1919 y1:140 y2:939 w:1920 h:800 x:0 y:140 pts:1 t:0.04 crop=1920:800:0:140"},
+			}}
+
+			original_runner := encode.External_command_runner
+			encode.External_command_runner = runner
+			defer func() { encode.External_command_runner = original_runner }()
+
+			// Check_job finds ffmpeg and ffprobe through the recording runner
+			job := job_from_test_commandline(t, test_case.commandline, input_file)
+
+			if error_code := ffcommander.Run(context.Background(), job); error_code != nil {
+				t.Fatal(error_code)
+			}
+
+			generated_output := "# " + test_case.commandline + "\n"
+
+			for _, command := range runner.Recorded_commands {
+				generated_output = generated_output + strings.Replace(strings.Join(command, " "), temp_dir, "TMPDIR", -1) + "\n"
+			}
+
+			golden_file := filepath.Join("testdata", "golden", test_case.name + ".txt")
+
+			if *update == true {
+
+				if error_code := os.WriteFile(golden_file, []byte(generated_output), 0644); error_code != nil {
+					t.Fatal(error_code)
+				}
+				return
+			}
+
+			golden_output, error_code := os.ReadFile(golden_file)
+
+			if error_code != nil {
+				t.Fatal(error_code, ", regenerate golden files with -update")
+			}
+
+			if generated_output != string(golden_output) {
+
+				generated_lines := strings.Split(generated_output, "\n")
+				golden_lines := strings.Split(string(golden_output), "\n")
+
+				for counter := 0; counter < len(generated_lines) || counter < len(golden_lines); counter++ {
+					var generated_line, golden_line string
+
+					if counter < len(generated_lines) {
+						generated_line = generated_lines[counter]
+					}
+
+					if counter < len(golden_lines) {
+						golden_line = golden_lines[counter]
+					}
+
+					if generated_line != golden_line {
+						t.Errorf("line %d:\n got: %s\nwant: %s", counter + 1, generated_line, golden_line)
+					}
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,1429 @@
+// (C) Mikael Hartzell 2018
+// This program is distributed under the GNU General Public License, version 3 (GPLv3)
+
+// Ffcommander is the commandline interface of the ffcommander package. The options are parsed to a ffcommander.Job that is processed with ffcommander.Run.
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/mhartzel/ffcommander"
+	"github.com/mhartzel/ffcommander/encode"
+	"github.com/mhartzel/ffcommander/plan"
+)
+
+var version_number string = "2.52" // This is the version of this program
+var helptext_categories_map = make(map[string][]string) // The key is commandline option category (video, audio, subtitle) and the slice contains commandline options that belong to this category
+var commandline_option_map = make(map[string]*commandline_struct) // The key is the commandline option and the struct contains all variables and helptext belonging to that option
+var debug_option *bool
+
+type commandline_struct struct {
+	is_turned_on bool
+	option_type string
+	user_int int
+	user_string string
+	help_text string
+	value_is_optional bool // A bool option that may be followed by a value, example: -crf or -crf 20
+	conflicts_with []string // Options that can't be used at the same time as this option
+	requires []string // This option can only be used if one of these options is also used
+	implies []string // Using this option turns on these options
+}
+
+func store_options_and_help_text_int(category string, option string, value int, help_text string) *commandline_struct {
+
+	// This function does what flag.Int does in go and extends it a little
+	// The function stores category, option name, variables and help text for a commandline option.
+	// It also returns pointer to the default value for the commandline option
+	// so that it can be assigned to a variable.
+
+	if category == "" {
+		fmt.Println("Error, help_text category is empty")
+		os.Exit(0)
+	}
+
+	if option == "" {
+		fmt.Println("Error, help_text option is empty")
+		os.Exit(0)
+	}
+
+	if help_text == "" {
+		fmt.Println("Error, help_text message is empty")
+		os.Exit(0)
+	}
+
+	commandline_option_struct := new(commandline_struct)
+	commandline_option_struct.option_type = "int"
+	commandline_option_struct.user_int = value
+	commandline_option_struct.help_text = help_text
+
+	// Store options for each category
+	if len(helptext_categories_map) == 0 {
+		// The list of options is empty, store the first option in the category
+		var temp_slice []string
+		temp_slice = append(temp_slice, option)
+		helptext_categories_map[category] = temp_slice
+	} else {
+		// There already are some options in the list, store the new one at the end of the list
+		var temp_slice = helptext_categories_map[category]
+
+		for _, item := range temp_slice {
+			if option == item {
+				fmt.Println("Error, option defined twice")
+				os.Exit(0)
+			}
+		}
+
+		temp_slice = append(temp_slice, option)
+		helptext_categories_map[category] = temp_slice
+	}
+
+	// Store helptext for the option
+	commandline_option_map[option] = commandline_option_struct
+
+	return commandline_option_struct
+}
+
+func store_options_and_help_text_bool(category string, option string, help_text string) *commandline_struct {
+
+	// This function does what flag.Bool does in go and extends it a little
+	// The function stores category, option name, variables and help text for a commandline option.
+	// It also returns pointer to the default value for the commandline option
+	// so that it can be assigned to a variable.
+
+	if category == "" {
+		fmt.Println("Error, help_text category is empty")
+		os.Exit(0)
+	}
+
+	if option == "" {
+		fmt.Println("Error, help_text option is empty")
+		os.Exit(0)
+	}
+
+	if help_text == "" {
+		fmt.Println("Error, help_text message is empty")
+		os.Exit(0)
+	}
+
+	commandline_option_struct := new(commandline_struct)
+	commandline_option_struct.option_type = "bool"
+	commandline_option_struct.help_text = help_text
+
+	// Store options for each category
+	if len(helptext_categories_map) == 0 {
+		// The list of options is empty, store the first option in the category
+		var temp_slice []string
+		temp_slice = append(temp_slice, option)
+		helptext_categories_map[category] = temp_slice
+	} else {
+		// There already are some options in the list, store the new one at the end of the list
+		var temp_slice = helptext_categories_map[category]
+
+		for _, item := range temp_slice {
+			if option == item {
+				fmt.Println("Error, option defined twice")
+				os.Exit(0)
+			}
+		}
+
+		temp_slice = append(temp_slice, option)
+		helptext_categories_map[category] = temp_slice
+	}
+
+	// Store helptext for the option
+	commandline_option_map[option] = commandline_option_struct
+
+	return commandline_option_struct
+}
+
+func store_options_and_help_text_string(category string, option string, value string, help_text string) *commandline_struct {
+
+	// This function does what flag.String does in go and extends it a little
+	// The function stores category, option name, variables and help text for a commandline option.
+	// It also returns pointer to the default value for the commandline option
+	// so that it can be assigned to a variable.
+
+	if category == "" {
+		fmt.Println("Error, help_text category is empty")
+		os.Exit(0)
+	}
+
+	if option == "" {
+		fmt.Println("Error, help_text option is empty")
+		os.Exit(0)
+	}
+
+	if help_text == "" {
+		fmt.Println("Error, help_text message is empty")
+		os.Exit(0)
+	}
+
+	commandline_option_struct := new(commandline_struct)
+	commandline_option_struct.option_type = "string"
+	commandline_option_struct.user_string = value
+	commandline_option_struct.help_text = help_text
+
+	// Store options for each category
+	if len(helptext_categories_map) == 0 {
+		// The list of options is empty, store the first option in the category
+		var temp_slice []string
+		temp_slice = append(temp_slice, option)
+		helptext_categories_map[category] = temp_slice
+	} else {
+		// There already are some options in the list, store the new one at the end of the list
+		var temp_slice = helptext_categories_map[category]
+
+		for _, item := range temp_slice {
+			if option == item {
+				fmt.Println("Error, option defined twice")
+				os.Exit(0)
+			}
+		}
+
+		temp_slice = append(temp_slice, option)
+		helptext_categories_map[category] = temp_slice
+	}
+
+	// Store helptext for the option
+	commandline_option_map[option] = commandline_option_struct
+
+	return commandline_option_struct
+}
+
+func validate_options() []string {
+
+	// Check all options the user gave on the commandline against the rules defined for each option
+	// (conflicts_with and requires) and return every violation found, so that the user can fix them all at once.
+	// The values of the options are checked by ffcommander.Check_job.
+	// Rules are checked against the options the user gave, options turned on by another options implies - list are not checked.
+	var option_errors []string
+	var option_names []string
+
+	for option_name := range commandline_option_map {
+		option_names = append(option_names, option_name)
+	}
+
+	sort.Strings(option_names)
+
+	for _, option_name := range option_names {
+
+		option := commandline_option_map[option_name]
+
+		if option.is_turned_on == false {
+			continue
+		}
+
+		if option.option_type == "string" && option.user_string == "" && option.value_is_optional == false {
+			option_errors = append(option_errors, "option -" + option_name + " requires a value")
+		}
+
+		for _, conflicting_option_name := range option.conflicts_with {
+
+			if commandline_option_map[conflicting_option_name].is_turned_on == true {
+				option_errors = append(option_errors, "options -" + option_name + " and -" + conflicting_option_name + " can't be used at the same time")
+			}
+		}
+
+		if len(option.requires) > 0 {
+
+			required_option_found := false
+
+			for _, required_option_name := range option.requires {
+
+				if commandline_option_map[required_option_name].is_turned_on == true {
+					required_option_found = true
+					break
+				}
+			}
+
+			if required_option_found == false {
+				option_errors = append(option_errors, "option -" + option_name + " can only be used with the option -" + strings.Join(option.requires, " or -"))
+			}
+		}
+	}
+
+	return option_errors
+}
+
+func apply_implied_options() {
+
+	// Turn on options that are implied by other options, for example -f turns on -fs and -fe.
+	// An implied option may imply other options, so repeat until nothing changes.
+	option_was_turned_on := true
+
+	for option_was_turned_on == true {
+
+		option_was_turned_on = false
+
+		for _, option := range commandline_option_map {
+
+			if option.is_turned_on == false {
+				continue
+			}
+
+			for _, implied_option_name := range option.implies {
+
+				if commandline_option_map[implied_option_name].is_turned_on == false {
+					commandline_option_map[implied_option_name].is_turned_on = true
+					option_was_turned_on = true
+				}
+			}
+		}
+	}
+}
+
+func check_option_rules_are_defined_correctly() {
+
+	// Catch typos in option rules. Every option name used in the rules must be a defined option.
+	for option_name, option := range commandline_option_map {
+
+		for _, rule_slice := range [][]string{option.conflicts_with, option.requires, option.implies} {
+
+			for _, other_option_name := range rule_slice {
+
+				if _, option_exists := commandline_option_map[other_option_name]; option_exists == false {
+					fmt.Println("Error, option -" + option_name + " has a rule for an undefined option: -" + other_option_name)
+					os.Exit(1)
+				}
+			}
+		}
+	}
+}
+
+func display_help_text() {
+
+	// Get terminal window dimensions
+	value_slice_int := get_terminal_window_dimensions()
+	// Don't print at the last character on the line because terminal will then automatically insert a line feed messing our print output.
+	terminal_width_int := value_slice_int[0] - 1
+	// terminal_height_int := value_slice_int[1] // We don't use terminal height on anything at this point
+
+	var second_paragraph_start, helptext_start, helptext_end int
+	var textline string
+
+	// Get map keys and sort them
+	categories := make ([]string, 0, len(helptext_categories_map))
+
+	for category := range helptext_categories_map {
+		categories = append(categories, category)
+	}
+
+	sort.Strings(categories)
+
+	///////////////////////////////////////////////////////////
+	// Find the longest option and adjust help text printing //
+	// start point in reference to that                      //
+	///////////////////////////////////////////////////////////
+	for _, category := range categories {
+		option_list := helptext_categories_map[category]
+
+		for _, option := range option_list {
+			if len(option) > second_paragraph_start {
+				second_paragraph_start = len(option)
+			}
+		}
+	}
+
+	second_paragraph_start = second_paragraph_start + 4
+
+	/////////////////////////////////////////////////////////
+	// Print sorted categories and options in each of them //
+	/////////////////////////////////////////////////////////
+	for _, category := range categories {
+		option_list := helptext_categories_map[category]
+
+		fmt.Println()
+		fmt.Println(category + ":")
+		fmt.Println(strings.Repeat("-", len(category) + 1))
+
+		for _, option := range option_list {
+
+			///////////////////////////////////////
+			// Print the first line of help text //
+			///////////////////////////////////////
+			commandline_option_struct := commandline_option_map[option]
+			textline = option + strings.Repeat(" ", second_paragraph_start - len(option))
+			helptext_start = 0
+			helptext_end = terminal_width_int - len(textline)
+
+			if helptext_end > len(commandline_option_struct.help_text) {
+				helptext_end = len(commandline_option_struct.help_text)
+			}
+
+			// Make sure we wont cut the last word in the middle at the end of the line
+			if helptext_end > 0 && helptext_end < len(commandline_option_struct.help_text) {
+
+				for commandline_option_struct.help_text[helptext_end - 1] != ' ' {
+					helptext_end--
+
+					if helptext_end <= 0 {
+						helptext_end = 0
+						break
+					}
+				}
+			}
+
+			fmt.Println("-" + option + strings.Repeat(" ", second_paragraph_start - len(option)) + commandline_option_struct.help_text[helptext_start:helptext_end])
+
+			// If this was the last line, start printing the next option help text
+			if helptext_end >= len(commandline_option_struct.help_text) {
+				fmt.Println()
+				continue
+			}
+
+			//////////////////////////////////////////////////////
+			// Print second help text line and lines after that //
+			//////////////////////////////////////////////////////
+			if helptext_end < len(commandline_option_struct.help_text) {
+
+				for {
+					helptext_start = helptext_end
+					helptext_end = helptext_start + terminal_width_int - second_paragraph_start
+
+					if helptext_end > len(commandline_option_struct.help_text) {
+						helptext_end = len(commandline_option_struct.help_text)
+					}
+
+					// If the first character on the line is a space then skip it
+					// and start from the first character of the next word instead
+					if commandline_option_struct.help_text[helptext_start] == ' ' {
+						helptext_start++
+
+						if helptext_start > len(commandline_option_struct.help_text) {
+							helptext_start = len(commandline_option_struct.help_text)
+						}
+					}
+
+					// Make sure we wont cut the last word in the middle at the end of the line
+					if helptext_end < len(commandline_option_struct.help_text) {
+
+						for commandline_option_struct.help_text[helptext_end] != ' ' {
+						helptext_end--
+
+							if helptext_end <= 0 {
+								helptext_end = 0
+								break
+							}
+						}
+					}
+
+					fmt.Println(strings.Repeat(" ", second_paragraph_start), commandline_option_struct.help_text[helptext_start:helptext_end])
+
+					// If this was the last line, start printing the next option help text
+					if helptext_end >= len(commandline_option_struct.help_text) {
+						break
+					}
+				}
+			}
+			fmt.Println()
+		}
+	}
+	os.Exit(0)
+}
+
+func get_terminal_window_dimensions() []int {
+
+	var value_slice_int []int
+
+	// Get terminal window size with the stty - command, if it does not exist return 1000,1000
+	if _, error := exec.LookPath("stty"); error == nil {
+
+		cmd := exec.Command("stty", "size")
+		cmd.Stdin = os.Stdin
+		out, err := cmd.Output()
+
+		if err != nil {
+			fmt.Println("Error getting terminal window width and height")
+			os.Exit(0)                                                                                                                                                                                                                     
+		}
+
+		first_line := strings.Split(string(out), "\n")
+		values_slice := strings.Split(first_line[0], " ")
+
+		height_string := values_slice[0]
+		width_string := values_slice[1]
+
+		height_int, error_happened := strconv.Atoi(height_string)
+
+		if error_happened != nil {                                                                                                                                                                                                             
+			fmt.Println("Error converting terminal window height to int")
+			os.Exit(0)                                                                                                                                                                                                                     
+		} 
+
+		width_int, error_happened := strconv.Atoi(width_string)
+
+		if error_happened != nil {                                                                                                                                                                                                             
+			fmt.Println("Error converting terminal window width to int")
+			os.Exit(0)                                                                                                                                                                                                                     
+		} 
+
+		value_slice_int = append(value_slice_int, width_int)
+		value_slice_int = append (value_slice_int, height_int)
+	} else {
+		value_slice_int = append(value_slice_int, 1000)
+		value_slice_int = append(value_slice_int, 1000)
+	}
+
+	return value_slice_int
+}
+
+func get_config_file_path() string {
+
+	// The config file is stored in the directory defined by the XDG Base Directory Specification.
+	// If XDG_CONFIG_HOME is not set, then the default ~/.config is used.
+	config_directory := os.Getenv("XDG_CONFIG_HOME")
+
+	if config_directory == "" {
+
+		home_directory, home_error := os.UserHomeDir()
+
+		if home_error != nil {
+			return ""
+		}
+
+		config_directory = filepath.Join(home_directory, ".config")
+	}
+
+	return filepath.Join(config_directory, "ffcommander", "config.json")
+}
+
+func check_compression_options_table(key string, compression_options []string, encoder_name string) []string {
+
+	// A compression options table must start with: "-c:v", "encoder" and consist of option and value pairs.
+	// The encoder must be the one the table is used for, because processing depends on the encoder (-preset, -tune, -crf and 2-pass options).
+	// An empty encoder_name accepts any encoder.
+	var config_errors []string
+
+	if len(compression_options) < 2 || compression_options[0] != "-c:v" {
+		return append(config_errors, key + " must start with \"-c:v\" and the encoder name")
+	}
+
+	if encoder_name != "" && compression_options[1] != encoder_name {
+		config_errors = append(config_errors, key + " must use the " + encoder_name + " encoder, not " + compression_options[1])
+	}
+
+	if len(compression_options) % 2 != 0 {
+		return append(config_errors, key + " must consist of option and value pairs, example: \"-preset\", \"medium\"")
+	}
+
+	for counter := 0; counter < len(compression_options); counter = counter + 2 {
+
+		if strings.HasPrefix(compression_options[counter], "-") == false {
+			config_errors = append(config_errors, key + ": '" + compression_options[counter] + "' is not an FFmpeg option, options start with a '-'")
+		}
+	}
+
+	return config_errors
+}
+
+func check_config_values(config ffcommander.Config_file_struct) []string {
+
+	// Check all values in the config struct and return every error found, so that the user can fix them all at once
+	var config_errors []string
+
+	if config.Default_video_processing != "2-pass" && config.Default_video_processing != "crf" {
+		config_errors = append(config_errors, "default_video_processing must be \"2-pass\" or \"crf\"")
+	}
+
+	if config.Default_audio_processing != "copy" && config.Default_audio_processing != "opus" && config.Default_audio_processing != "aac" {
+		config_errors = append(config_errors, "default_audio_processing must be \"copy\", \"opus\" or \"aac\"")
+	}
+
+	if config.Default_max_threads != "" && config.Default_max_threads != "auto" {
+
+		if number_of_threads, atoi_error := strconv.Atoi(config.Default_max_threads); atoi_error != nil || number_of_threads < 1 {
+			config_errors = append(config_errors, "default_max_threads must be \"\" (calculate automatically), \"auto\" (let FFmpeg decide) or the number of threads, example: \"12\"")
+		}
+	}
+
+	if config.Audio_bitrate_multiplier < 16 || config.Audio_bitrate_multiplier > 512 {
+		config_errors = append(config_errors, "audio_bitrate_multiplier must be between 16 and 512 (kbit/s per audio channel)")
+	}
+
+	bitrate_dividers := []struct {
+		key string
+		value int
+	}{
+		{"video_compression_bitrate_divider", config.Video_compression_bitrate_divider},
+		{"video_compression_bitrate_divider_hevc", config.Video_compression_bitrate_divider_hevc},
+		{"video_compression_bitrate_divider_vp9", config.Video_compression_bitrate_divider_vp9},
+		{"video_compression_bitrate_divider_av1", config.Video_compression_bitrate_divider_av1},
+	}
+
+	for _, divider := range bitrate_dividers {
+
+		if divider.value < 1 {
+			config_errors = append(config_errors, divider.key + " must be a number bigger than 0")
+		}
+	}
+
+	crf_values := []struct {
+		key string
+		value string
+		encoder_name string
+	}{
+		{"crf_value", config.Crf_value, "libx264"},
+		{"crf_value_hevc", config.Crf_value_hevc, "libx265"},
+		{"crf_value_vp9", config.Crf_value_vp9, "libvpx-vp9"},
+		{"crf_value_av1", config.Crf_value_av1, "libsvtav1"},
+	}
+
+	for _, crf := range crf_values {
+
+		crf_max := encode.Video_encoder_limits[crf.encoder_name].Crf_max
+
+		if crf_int, atoi_error := strconv.Atoi(crf.value); atoi_error != nil || crf_int < 0 || crf_int > crf_max {
+			config_errors = append(config_errors, crf.key + " must be a whole number between 0 and " + strconv.Itoa(crf_max) + " given as a string, example: \"20\"")
+		}
+	}
+
+	if config.Denoise_options == "" {
+		config_errors = append(config_errors, "denoise_options must be an FFmpeg filter, example: \"hqdn3d=3.0:3.0:2.0:3.0\"")
+	}
+
+	config_errors = append(config_errors, check_compression_options_table("video_compression_options_sd", config.Video_compression_options_sd, "libx264")...)
+	config_errors = append(config_errors, check_compression_options_table("video_compression_options_hd", config.Video_compression_options_hd, "libx264")...)
+	config_errors = append(config_errors, check_compression_options_table("video_compression_options_ultra_hd_4k", config.Video_compression_options_ultra_hd_4k, "libx264")...)
+	config_errors = append(config_errors, check_compression_options_table("video_compression_options_ultra_hd_8k", config.Video_compression_options_ultra_hd_8k, "libx264")...)
+	config_errors = append(config_errors, check_compression_options_table("video_compression_options_lossless", config.Video_compression_options_lossless, "")...)
+	config_errors = append(config_errors, check_compression_options_table("video_compression_options_hevc_sd", config.Video_compression_options_hevc_sd, "libx265")...)
+	config_errors = append(config_errors, check_compression_options_table("video_compression_options_hevc_hd", config.Video_compression_options_hevc_hd, "libx265")...)
+	config_errors = append(config_errors, check_compression_options_table("video_compression_options_hevc_ultra_hd_4k", config.Video_compression_options_hevc_ultra_hd_4k, "libx265")...)
+	config_errors = append(config_errors, check_compression_options_table("video_compression_options_hevc_ultra_hd_8k", config.Video_compression_options_hevc_ultra_hd_8k, "libx265")...)
+	config_errors = append(config_errors, check_compression_options_table("video_compression_options_vp9_sd", config.Video_compression_options_vp9_sd, "libvpx-vp9")...)
+	config_errors = append(config_errors, check_compression_options_table("video_compression_options_vp9_hd", config.Video_compression_options_vp9_hd, "libvpx-vp9")...)
+	config_errors = append(config_errors, check_compression_options_table("video_compression_options_vp9_ultra_hd_4k", config.Video_compression_options_vp9_ultra_hd_4k, "libvpx-vp9")...)
+	config_errors = append(config_errors, check_compression_options_table("video_compression_options_vp9_ultra_hd_8k", config.Video_compression_options_vp9_ultra_hd_8k, "libvpx-vp9")...)
+	config_errors = append(config_errors, check_compression_options_table("video_compression_options_av1_svt", config.Video_compression_options_av1_svt, "libsvtav1")...)
+	config_errors = append(config_errors, check_compression_options_table("video_compression_options_av1_aom", config.Video_compression_options_av1_aom, "libaom-av1")...)
+
+	var preset_names []string
+
+	for preset_name := range config.Presets {
+		preset_names = append(preset_names, preset_name)
+	}
+
+	sort.Strings(preset_names)
+
+	for _, preset_name := range preset_names {
+
+		preset_options := config.Presets[preset_name]
+
+		if preset_name == "" || strings.TrimSpace(preset_options) == "" {
+			config_errors = append(config_errors, "presets must have a name and options, example: \"dvd-anime\": \"-it -dn -aac\"")
+			continue
+		}
+
+		config_errors = append(config_errors, check_preset_options(preset_name, preset_options)...)
+	}
+
+	return config_errors
+}
+
+func check_preset_options(preset_name string, preset_options string) []string {
+
+	// Check that a preset contains only defined options and values for them.
+	// The options are not turned on here, values are checked by validate_options when the preset is used.
+	var preset_errors []string
+	value_expected := false
+	optional_value_expected := false
+
+	for _, preset_item := range strings.Fields(preset_options) {
+
+		if value_expected == true {
+			value_expected = false
+			continue
+		}
+
+		if optional_value_expected == true {
+
+			optional_value_expected = false
+
+			if _, float_parse_error := strconv.ParseFloat(preset_item, 64); float_parse_error == nil && strings.HasPrefix(preset_item, "-") == false {
+				continue
+			}
+		}
+
+		if strings.HasPrefix(preset_item, "-") == false {
+			preset_errors = append(preset_errors, "preset: " + preset_name + " contains '" + preset_item + "' which is not an option or a value for an option")
+			continue
+		}
+
+		option_name := strings.TrimLeft(preset_item, "-")
+		option, option_exists := commandline_option_map[option_name]
+
+		if option_exists == false {
+			preset_errors = append(preset_errors, "unknown option: -" + option_name + " in preset: " + preset_name)
+			continue
+		}
+
+		if option_name == "preset-name" {
+			preset_errors = append(preset_errors, "preset: " + preset_name + " can't select another preset with -preset-name")
+		}
+
+		value_expected = option.option_type == "string" || option.option_type == "int"
+		optional_value_expected = option.value_is_optional
+	}
+
+	return preset_errors
+}
+
+func read_config_file(config_file_path string) (config ffcommander.Config_file_struct, config_errors []string) {
+
+	// Read the config file on top of the current defaults, so that keys missing from the file keep their default values.
+	// A missing config file is not an error, the defaults are used.
+	config = ffcommander.Default_config()
+
+	config_file_content, read_error := os.ReadFile(config_file_path)
+
+	if os.IsNotExist(read_error) == true {
+		return config, config_errors
+	}
+
+	if read_error != nil {
+		return config, append(config_errors, read_error.Error())
+	}
+
+	// Unknown keys are reported, since a misspelled key would otherwise be silently ignored
+	json_decoder := json.NewDecoder(bytes.NewReader(config_file_content))
+	json_decoder.DisallowUnknownFields()
+
+	if decode_error := json_decoder.Decode(&config); decode_error != nil {
+		return config, append(config_errors, decode_error.Error())
+	}
+
+	return config, check_config_values(config)
+}
+
+func parse_options(arguments []string, preset_name string) ([]string, []string) {
+
+	// Parse options from the commandline or from a preset in the config file.
+	// A preset contains only options, the preset_name is used in error messages and is empty when parsing the commandline.
+	// Errors are returned to the caller so that they can be reported together with the errors found in validate_options.
+
+	// Debug mode for this subroutine cannot be set on the commandline
+	// because we have not parsed the commandline when we enter here
+	// Instead turn debug to true in the debug variable.
+
+	///////////////////////////////
+	// Parse commandline options //
+	///////////////////////////////
+	debug := false
+
+	if debug == true {
+
+		fmt.Println()
+		fmt.Println("Arguments:", arguments)
+		fmt.Println()
+
+		fmt.Println("Argument slice items:")
+		fmt.Println("---------------------")
+
+		for counter, item := range arguments {
+			fmt.Println(counter, item)
+		}
+		fmt.Println()
+		fmt.Println("Parsed arguments")
+		fmt.Println("----------------")
+	}
+
+	var input_filenames []string
+	var option_errors []string
+	var commandline_option_struct *commandline_struct
+	var predefined_option string
+	var option_found, string_option_found, int_option_found, optional_value_option_found, item_is_an_option bool
+	string_option_found = false
+	int_option_found = false
+	optional_value_option_found = false
+
+	for _, commandline_option := range arguments {
+
+		option_found = false
+		item_is_an_option = false
+
+		// Remove leading "-" characters from the option string. Hyphens in the middle
+		// of the option name are kept: -show-config
+		// If the previous round in the for loop has recognized that the next
+		// string on the commandline is not an option but and value for it
+		// then don't remove the - character. This is because some 
+		// options takes negative numbers as values.
+		if string_option_found == false && int_option_found == false {
+
+			if strings.HasPrefix(commandline_option, "-") == true {
+				item_is_an_option = true
+				commandline_option = strings.TrimLeft(commandline_option, "-")
+			}
+		}
+
+		// This part assigns int or string value following an option to the commandline variable 
+		// After the assingment the next option is fetched from the commandline
+		if string_option_found == true {
+			commandline_option_struct.user_string = commandline_option
+			string_option_found = false
+			continue
+		}
+
+		// A bool option with an optional value takes the next item on the commandline if it is a number: -crf 20
+		// Otherwise the item is handled as an option or a filename.
+		if optional_value_option_found == true {
+
+			optional_value_option_found = false
+
+			if _, float_parse_error := strconv.ParseFloat(commandline_option, 64); float_parse_error == nil && item_is_an_option == false {
+				commandline_option_struct.user_string = commandline_option
+				continue
+			}
+		}
+
+		if int_option_found == true {
+			temp_int, error_happened := strconv.Atoi(commandline_option)
+
+			if error_happened != nil {
+				option_errors = append(option_errors, "option -" + predefined_option + ": '" + commandline_option + "' is not an integer")
+			}
+
+			commandline_option_struct.user_int = temp_int
+
+			int_option_found = false
+			continue
+		}
+
+		// This part recognizes an option on the commandline and sets boolean values so that 
+		// the int or string following the option is handled at the next round of the for loop
+		if item_is_an_option == true {
+
+			for predefined_option, commandline_option_struct = range commandline_option_map {
+
+				if commandline_option == predefined_option {
+
+					if commandline_option_struct.option_type == "int" {
+
+						if debug == true {
+							fmt.Print("found int variable:", commandline_option, " ")
+						}
+
+						commandline_option_struct.is_turned_on = true
+						int_option_found = true
+						option_found = true
+						break
+					}
+
+					if commandline_option_struct.option_type == "bool" {
+
+						if debug == true {
+							fmt.Println("found bool variable", commandline_option, " ")
+						}
+
+						// Store value: true to this options struct and switch the variable connected
+						// to this commandline option to point to the value in struct
+						commandline_option_struct.is_turned_on = true
+						optional_value_option_found = commandline_option_struct.value_is_optional
+						option_found = true
+						break
+					}
+
+					if commandline_option_struct.option_type == "string" {
+
+						if debug == true {
+							fmt.Print("found string variable:", commandline_option, " ")
+						}
+
+						commandline_option_struct.is_turned_on = true
+						string_option_found = true
+						option_found = true
+						break
+					}
+				}
+			}
+
+			if option_found == false {
+
+				if preset_name != "" {
+					option_errors = append(option_errors, "unknown option: -" + commandline_option + " in preset: " + preset_name)
+				} else {
+					option_errors = append(option_errors, "unknown option: -" + commandline_option)
+				}
+			}
+
+		} else if preset_name != "" {
+
+			// Presets can't contain filenames
+			option_errors = append(option_errors, "preset: " + preset_name + " contains '" + commandline_option + "' which is not an option or a value for an option")
+
+		} else {
+
+			// The item on the commandline is a filename, test if files exist
+			inputfile_full_path,_ := filepath.Abs(commandline_option)
+			fileinfo, err := os.Stat(inputfile_full_path)
+
+			// Test if input files exist
+			if err != nil {
+				option_errors = append(option_errors, "file: '" + inputfile_full_path + "' does not exist")
+				continue
+			}
+
+			// Test if name is a directory
+			if fileinfo.IsDir() == true {
+				option_errors = append(option_errors, inputfile_full_path + " is not a file it is a directory")
+				continue
+			}
+
+			// Add all existing input file names to a slice
+			input_filenames = append(input_filenames, inputfile_full_path)
+		}
+	}
+
+	if debug == true {
+
+		// Print commandline options and their values in alphabetical order
+		// Print only those options that the user has turned on
+		var sorted_options []string
+		var longest_option int
+		var title_to_print string
+
+		fmt.Print("\n\n")
+		title_to_print = "All option variables and text:"
+		fmt.Println(title_to_print)
+		fmt.Println(strings.Repeat("-", len(title_to_print)))
+		print_all_commandline_variables()
+
+		fmt.Print("\n\n")
+		title_to_print = "Options that the user turned on:"
+		fmt.Println(title_to_print)
+		fmt.Println(strings.Repeat("-", len(title_to_print)))
+
+		for option := range commandline_option_map {
+			sorted_options = append(sorted_options, option)
+
+			if len(option) > longest_option {
+				longest_option = len(option)
+			}
+		}
+
+		sort.Strings(sorted_options)
+
+		for _, option := range sorted_options {
+
+			commandline_option_struct = commandline_option_map[option]
+
+			if commandline_option_struct.is_turned_on == true {
+
+				fmt.Printf("-" + option + strings.Repeat(" ", longest_option - len(option)))
+				fmt.Printf("   " + commandline_option_struct.option_type + "   ")
+
+				if commandline_option_struct.option_type == "int" {
+					fmt.Println("     ", commandline_option_struct.user_int)
+				} else if commandline_option_struct.option_type == "bool" {
+					fmt.Println("     ", commandline_option_struct.is_turned_on)
+				} else if commandline_option_struct.option_type == "string" {
+					fmt.Println("   ", commandline_option_struct.user_string)
+				}
+			}
+		}
+	}
+
+	return input_filenames, option_errors
+}
+
+func option_conflicts_with_options(option_name string, options map[string]commandline_struct) bool {
+
+	// Return true if the option conflicts with one of the turned on options. The conflict may be defined in either option.
+	for other_option_name, other_option := range options {
+
+		if other_option.is_turned_on == false {
+			continue
+		}
+
+		for _, conflicting_option_name := range other_option.conflicts_with {
+
+			if conflicting_option_name == option_name {
+				return true
+			}
+		}
+
+		for _, conflicting_option_name := range commandline_option_map[option_name].conflicts_with {
+
+			if conflicting_option_name == other_option_name {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func apply_option_preset(preset_name string, preset_options string) []string {
+
+	// Parse the options of a preset on top of the options the user gave on the commandline.
+	// The preset goes through the same parsing as the commandline and the parse errors are returned,
+	// option values are checked with all other options in validate_options.
+	// Options given on the commandline override the preset: the commandline value is kept
+	// and preset options that conflict with a commandline option are left out (-ac3 on the commandline replaces -aac in the preset).
+	if preset_errors := check_preset_options(preset_name, preset_options); len(preset_errors) > 0 {
+		return preset_errors
+	}
+
+	preset_options_slice := strings.Fields(preset_options)
+
+	commandline_options := make(map[string]commandline_struct)
+
+	for option_name, option := range commandline_option_map {
+		commandline_options[option_name] = *option
+	}
+
+	_, preset_errors := parse_options(preset_options_slice, preset_name)
+
+	for option_name, option := range commandline_option_map {
+
+		commandline_option := commandline_options[option_name]
+
+		if commandline_option.is_turned_on == true || (option.is_turned_on == true && option_conflicts_with_options(option_name, commandline_options) == true) {
+			*option = commandline_option
+		}
+	}
+
+	return preset_errors
+}
+
+func print_all_commandline_variables() {
+
+	// This subroutine is used to debug option variables and help text.
+	for option, commandline_option_struct := range commandline_option_map {
+		fmt.Println()
+		fmt.Println("Option:", option)
+
+		if commandline_option_struct.option_type == "bool" {
+			fmt.Println("\tType:", commandline_option_struct.option_type)
+			fmt.Println("\tValue:", commandline_option_struct.is_turned_on)
+			fmt.Println("\tHelp text:", commandline_option_struct.help_text)
+		}
+
+		if commandline_option_struct.option_type == "int" {
+			fmt.Println("\tType:", commandline_option_struct.option_type)
+			fmt.Println("\tValue:", commandline_option_struct.user_int)
+			fmt.Println("\tHelp text:", commandline_option_struct.help_text)
+		}
+
+		if commandline_option_struct.option_type == "string" {
+			fmt.Println("\tType:", commandline_option_struct.option_type)
+			fmt.Println("\tValue:", commandline_option_struct.user_string)
+			fmt.Println("\tHelp text:", commandline_option_struct.help_text)
+		}
+	}
+}
+
+//////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
+func define_commandline_options() {
+
+	// Store the commandline options, their help texts and the rules for the options in commandline_option_map.
+	// Options defined earlier are removed, so that all options start from their default values.
+	helptext_categories_map = make(map[string][]string)
+	commandline_option_map = make(map[string]*commandline_struct)
+
+	////////////////////////////////
+	// Define commandline options //
+	////////////////////////////////
+	// Below are variable, and helpt text definitions tied to a commandline option
+	// Definitions from left to right are:
+	// Variable that gets the default value defined later on the line, only options that have rules below need the variable
+	// Category. Help text will be printed a category at a time
+	// Commandline option name.
+	// Default value for the variable. The function "store_options_and_help_text_string()" returns this value to the variable defined at the beginning of the line
+	// Help text for the commandline option
+	// Address of the variable defined at the beginning of the line. This is used when the commandline option is followed by a value. The variable address is used to point the variable to the user defined value.
+	// Audio options
+	audio_language_option := store_options_and_help_text_string("Audio", "a", "", "Select audio with this language code, example: -a fin or -a eng or -a ita  Several audio streams can be selected by separating the language codes with commas: -a eng,fin  The audio streams are stored in the given order and the first one is marked as the default audio. Repeating a language code selects the next audio stream with the same language: -a eng,eng  Only one of the options -an, -a and -aall can be used at the a time.")
+	audio_all_option := store_options_and_help_text_bool("Audio", "aall", "Keep all audio streams of the file in their original order. The first audio stream is marked as the default audio.")
+	audio_map_option := store_options_and_help_text_string("Audio", "amap", "", "Select audio streams by number and choose the codec and the number of channels for each stream, example: -amap 0:copy,2:aac:2ch  copies audio stream 0 and compresses audio stream 2 to aac stereo. Codecs are: copy, aac, ac3, opus and flac. The codec and the number of channels can be left out: -amap 0,2:opus  Streams without a codec are processed with the other audio options (-aac, -opus, ...). The bitrate of a compressed stream is 128k for each channel. The audio streams are stored in the given order and the first one is marked as the default audio.")
+	store_options_and_help_text_string("Audio", "an", "0", "Select audio stream by number, example: -an 1. Several audio streams can be selected by separating the numbers with commas: -an 0,2  The audio streams are stored in the given order and the first one is marked as the default audio. Only one of the options -an, -a and -aall can be used at the a time.")
+	audio_compression_ac3 := store_options_and_help_text_bool("Audio", "ac3", "Compress audio as ac3. Bitrate of 128k is used for each audio channel meaning 2 channels is compressed using 256k bitrate. 6 channels uses the ac3 max bitrate of 640k.")
+	audio_compression_aac := store_options_and_help_text_bool("Audio", "aac", "Compress audio as aac. Bitrate of 128k is used for each audio channel meaning 2 channels is compressed using 256k bitrate, 6 channels uses 768k bitrate.", )
+	audio_compression_opus := store_options_and_help_text_bool("Audio", "opus", "Compress audio as opus. Opus support in mp4 container is experimental as of FFmpeg vesion 4.2.1. Bitrate of 128k is used for each audio channel meaning 2 channels is compressed using 256k bitrate, 6 channels uses 768k bitrate.")
+	store_options_and_help_text_bool("Audio", "flac", "Compress audio in lossless Flac - format")
+	no_audio := store_options_and_help_text_bool("Audio", "na", "Disable audio processing. There is no audio in the resulting file.")
+	audio_offset_option := store_options_and_help_text_string("Audio", "ad", "", "Correct audio that is out of sync with the video. Positive offset in milliseconds delays and negative offset advances the audio, example: -ad 300 or -ad -250  The offset is applied to all selected audio streams. Audio must be compressed to change the offset, audio is compressed to aac unless -ac3, -opus or -flac is used.")
+	downmix_to_stereo := store_options_and_help_text_bool("Audio", "stereo", "Downmix surround audio to stereo. 5.1 and 7.1 audio is downmixed with the ITU coefficients that keep dialog in the center channel clearly audible, other channel layouts are downmixed by FFmpeg. Downmixed audio is compressed to aac unless -ac3, -opus or -flac is used.")
+	add_stereo_downmix := store_options_and_help_text_bool("Audio", "add-stereo", "Add a stereo downmix of surround audio as a second audio stream after the surround audio. The downmix is done the same way as with the -stereo option.")
+	loudness_normalization_option := store_options_and_help_text_string("Audio", "norm", "", "Normalize audio loudness (EBU R128) to the given integrated loudness target in LUFS, example: -norm -23 for broadcast or -norm -16 for streaming. The names broadcast and streaming can be used too: -norm streaming  The loudness of every selected audio stream is measured first with the FFmpeg loudnorm filter and the volume is then changed by the same amount for the whole file, so the dynamics of the audio are kept. The measurement is done while the file is scanned for crop values. Normalized audio must be compressed, audio is compressed to aac unless -ac3, -opus or -flac is used.")
+
+	// Video options
+	store_options_and_help_text_string("Video", "abk", "", "Adjust video black point to make light video darker. This will move dark tones closer to black. Range is from -1.0 to 1.0. 0 = no change, numbers bigger than 0 makes video darker. Example: -abk 0.3")
+	store_options_and_help_text_bool("Video", "ac", "Autocrop. Find crop values automatically by doing 10 second spot checks in 10 places for the duration of the file.")
+	store_options_and_help_text_string("Video", "ach", "", "Adjust Chroma to increase or decrease the amount of color in the video. Range is 0.0 to 3.0. Value of 1 means no change. Values less than 1 decrease and values bigger than 1 increases the level of color in the video. Example -ach 0.85")
+	store_options_and_help_text_string("Video", "agm", "", "Adjust video gamma. This will make mid tones lighter or darker. Range is from 0.1 to 10. 1 = no change, numbers bigger than 1 moves mid tones towards white. Example: -agm 1.05")
+	store_options_and_help_text_string("Video", "awh", "", "Adjust video white point to make dark video lighter. This will move light tones closer to white. Range is from -1.0 to 1.0. 1 = no change, numbers smaller than 1 makes video lighter. Example: -awh 0.7")
+	abr_option := store_options_and_help_text_string("Video", "abr", "", "Adaptive bitrate ladder. Encode video in several resolutions (rungs) in one FFmpeg run for HLS and DASH streaming. Give the heights of the rungs, example: -abr 1080,720,480,360. Rungs higher than the video are left out. The bitrate of each rung is calculated with the same pixel count formula as the bitrate of the main video. A keyframe is forced every " + strconv.Itoa(ffcommander.Abr_segment_seconds) + " seconds (segment duration) in all rungs, so that players can switch between rungs at segment boundaries. The streaming files are written to directory: 00-processed_files/filename-abr. Use -abrf to choose the streaming format. Audio is compressed to aac unless -ac3 or -opus is used.")
+	abr_format_option := store_options_and_help_text_string("Video", "abrf", "hls", "Adaptive bitrate ladder (-abr) streaming format: hls (HLS with fMP4 segments and a master playlist), hlsts (HLS with TS segments and a master playlist) or dash (MPEG-DASH manifest with fMP4 segments). HLS and DASH can be combined: -abrf hls,dash creates one set of fMP4 segments with both the HLS master playlist and the DASH manifest. The default is hls.")
+	crf_option := store_options_and_help_text_bool("Video", "crf", "Use Constant Quality instead of 2-pass encoding. The default value for crf is 18, which produces the same quality as default 2-pass but a bigger file. CRF is much faster that 2-pass encoding. A different crf value can be given after the option, example: -crf 20. Smaller value gives better quality and a bigger file. The range is 0 - 51 for H.264 and HEVC and 0 - 63 for AV1 and VP9.")
+	store_options_and_help_text_bool("Video", "dn", "Denoise. Use HQDN3D - filter to remove noise from the picture. This option is equal to Hanbrakes 'medium' noise reduction settings.")
+	av1_option := store_options_and_help_text_bool("Video", "av1", "Compress video with AV1 using the libsvtav1 encoder. If FFmpeg does not have libsvtav1 then the much slower libaom-av1 encoder is used. AV1 needs less bitrate than H.264 and HEVC for the same quality. Video is encoded with 10-bit color (yuv420p10le). Automatic bitrate calculation, 2-pass encoding and -crf work the same way as with H.264, the default crf value for AV1 is 28.")
+	hevc_option := store_options_and_help_text_bool("Video", "hevc", "Compress video with HEVC (H.265) using the libx265 encoder. HEVC needs about half of the bitrate of H.264 for the same quality. Automatic bitrate calculation, 2-pass encoding and -crf work the same way as with H.264, the default crf value for HEVC is 20. Mp4 files are tagged as 'hvc1' so that they also play on Apple devices.")
+	store_options_and_help_text_bool("Video", "gr", "Convert video to Grayscale. Use this option if the original source is black and white. This results more bitrate being available for b/w information and better picture quality.")
+	store_options_and_help_text_bool("Video", "it", "Perform inverse telecine on 29.97 fps material to return it back to original 24 fps.")
+	store_options_and_help_text_string("Video", "mbr", "", "Override automatic bitrate calculation for main video and define bitrate manually.")
+	video_preset_option := store_options_and_help_text_string("Video", "preset", "", "Encoder preset. Slower presets give better quality with the same bitrate. H.264 and HEVC use the named presets: ultrafast, superfast, veryfast, faster, fast, medium, slow, slower, veryslow and placebo, the default is medium. Example: -preset slow. AV1 and VP9 use a number, smaller number is slower and gives better quality: 0 - 13 for SVT-AV1 (default 6), 0 - 8 for libaom (default 4) and 0 - 5 for VP9 (default 2).")
+	store_options_and_help_text_bool("Video", "nd", "No Deinterlace. By default deinterlace is always used. This option disables it.")
+	parallel_sd := store_options_and_help_text_bool("Video", "psd", "Parallel SD. Create SD version in parallel to HD processing. This creates an additional version of the video downconverted to SD resolution. The SD file is stored in directory: 00-processed_files/sd")
+	sd_bitrate_option := store_options_and_help_text_string("Video", "sbr", "", "Override automatic bitrate calculation for parallely created sd video and define bitrate manually.")
+	quality_report_option := store_options_and_help_text_bool("Video", "qr", "Quality report. After processing compare the processed video to the original using FFmpeg's ssim and psnr filters. The original goes through the same deinterlace, crop and scaling as the processed video so that the frames line up. The overall scores and the " + strconv.Itoa(ffcommander.Quality_report_number_of_worst_segments) + " worst " + strconv.Itoa(ffcommander.Quality_report_segment_seconds) + " second segments are written to 00-processing.log and to a json file next to the processed file. Subtitles burned on top of video are not part of the original, this lowers the scores of segments with subtitles.")
+	quality_target_option := store_options_and_help_text_string("Video", "qt", "", "Quality target. Find the highest crf value that still reaches this quality and compress the video with it. Quality is given as ssim or psnr value, example: -qt ssim:0.98 or -qt psnr:42. Samples from " + strconv.Itoa(ffcommander.Quality_search_number_of_samples) + " places of the video are compressed with different crf values and compared to the original using FFmpeg's ssim or psnr filter. This turns on the -crf option.")
+	target_size_option := store_options_and_help_text_string("Video", "size", "", "Calculate 2-pass video bitrate so that the processed file has this size. Example: -size 4.3G or -size 700M (1G = 1024M). The calculation uses the duration of the processed video (-st, -d, -et and -sf are taken into account) and subtracts the audio bitrate and " + strconv.FormatInt(plan.Target_size_container_overhead_percent, 10) + "% for container overhead. When audio is copied the bitrate of the original audio is used. With the -ssd option the size is used for the SD video.")
+	sd_target_size_option := store_options_and_help_text_string("Video", "sdsize", "", "Calculate 2-pass video bitrate for the parallely created SD video (-psd) so that the SD file has this size. Example: -sdsize 700M")
+	store_options_and_help_text_bool("Video", "ssd", "Scale to SD. Scale video down to SD resolution. Calculates resolution automatically. Video is stored in directory 'sd'")
+	video_tune_option := store_options_and_help_text_string("Video", "tune", "", "Tune encoder settings for the type of video. H.264 values are: film, animation, grain, stillimage, fastdecode, zerolatency, psnr and ssim. HEVC has the same values except film and stillimage. Example: -tune film. AV1 and VP9 encoders have no tune values.")
+	store_options_and_help_text_bool("Video", "tc", "Burn timecode on top of video. Timecode can be used for example to look for exact edit points for the file split feature.")
+
+	// Options that affect both video and audio
+	force_lossless := store_options_and_help_text_bool("Audio and Video", "ls", "Force encoding to use lossless utvideo compression for video and flac compression for audio. This also turns on -fe (1-Pass encode). This option only affects the main video if used with the -psd option.")
+
+	// Subtitle options
+	subtitle_language_option := store_options_and_help_text_string("Subtitle", "s", "", "Burn subtitle with this language code on top of video. Example: -s fin or -s eng or -s ita  Only use option -sn or -s not both.")
+	store_options_and_help_text_bool("Subtitle", "sd", "Subtitle `downscale`. When cropping video widthwise, scale subtitle down to fit on top of the cropped video. This results in a smaller subtitle font. The -sd option affects only subtitle burned on top of video.")
+	subtitle_burn_grayscale := store_options_and_help_text_bool("Subtitle", "sgr", "Subtitle Grayscale. Remove color from subtitle by converting it to grayscale. This option only works with subtitle burned on top of video. If video playback is glitchy every time a subtitle is displayed, then removing color from subtitle may help.")
+	subtitle_stream_number_option := store_options_and_help_text_string("Subtitle", "sn", "-1", "Burn subtitle with this stream number on top of video. Example: -sn 1. Only use option -sn or -s not both.")
+	store_options_and_help_text_string("Subtitle", "so", "0", "Subtitle `offset`, -so 55 (move subtitle 55 pixels down), -so -55 (move subtitle 55 pixels up). This option affects only subtitle burned on top of video. Also check the -sp option that automatically moves subtitles near the edge of the screen.")
+	subtitle_mux_language_option := store_options_and_help_text_string("Subtitle", "sm", "", "Mux subtitles with these language codes into the target file. Example: -sm eng or -sm eng,fra,fin. This only works with dvd, dvb and bluray bitmap based subtitles. Mp4 only supports DVD and DVB subtitles not Bluray. Bluray subtitles can be muxed into an mkv file using the -mkv option.")
+	store_options_and_help_text_string("Subtitle", "smn", "", "Mux subtitles with these stream numbers into the target file. Example: -smn 1 or -smn 3,1,7. This only works with dvd, dvb and bluray bitmap based subtitles. Mp4 only supports DVD and DVB subtitles not Bluray. Bluray subtitles can be muxed into an mkv file using the -mkv option.")
+	store_options_and_help_text_string("Subtitle", "palette", "", "Hack the dvd subtitle color palette. The subtitle color palette defines the individual colors used in the subtitle (border, middle, etc). This option takes 1-16 comma separated hex numbers ranging from 0 to f. Zero = black, f = white, so only shades between black -> gray -> white can be defined. If you define less than the required 16 numbers then the rest will be filled with f's. Each dvd uses color mapping differently so you need to test which numbers control the colors you want to change. Usually the first 4 numbers control the colors. Example: -palette f,0,f . This option only affects subtitle burned on top of video.")
+	subtitle_burn_split := store_options_and_help_text_bool("Subtitle", "sp", "Subtile Split. Subtitles on DVD's and Blurays often use an unnecessary large font and are positioned too far from the edge of the screen covering too much of the picture. Sometimes subtitles are also displayed on the upper part of the screen and may even cover the actors face. The -sp option detects whether the subtitle is displayed top or bottom half of the screen and then moves it towards that edge of the screen so that it covers less of the picture area. Distance from the screen edge is calculated automatically based on video resolution (picture height divided by 100 and rounded down to nearest integer. Minimum distance is 5 pixels and max 20 pixels). Subtitles are also automatically centered horizontally. Use the -sr option with -sp to resize subtitle. The -sp option affects only subtitles burned on top of video.")
+	subtitle_burn_resize := store_options_and_help_text_string("Subtitle", "sr", "", "Subtitle Resize. Values less than 1 makes subtitles smaller, values bigger than 1 makes them larger. This option can only be used with the -sp option. Example: make subtitle 25% smaller: -sr 0.75   make subtitle 50% smaller: -sr 0.50 make subtitle 75% larger: -sr 1.75. This option affects only subtitle burned on top of video.")
+
+	// Scan options
+	fast_encode_and_search := store_options_and_help_text_bool("Scan", "f", "This is the same as using options -fs and -fe at the same time.")
+	store_options_and_help_text_bool("Scan", "fe", "Fast encoding mode. Encode video using 1-pass encoding. Use this for testing to speed up processing. Video quality will be much lower than with 2-Pass encoding.")
+	store_options_and_help_text_bool("Scan", "fs", "Fast seek mode. When using the -fs option with -st do not decode all video before the point we are trying to locate, but instead try to jump directly to it. This will speed up processing but might not find the defined position accurately. Accuracy depends on file format.")
+	store_options_and_help_text_bool("Scan", "scan", "Scan input files and print video audio and subtitle stream info.")
+	split_times := store_options_and_help_text_string("Scan", "sf", "", "Split out parts of the file. Give start and stop times for the parts of the file to use. Use either commas and slashes or only commas to separate time values. The times can be given in the same formats as with -st. Example: -sf 0-10:00,01:35:12.800-01:52:14 defines that 0 secs - 10 mins from the start of the file will be used and joined to the next part that starts from 01 hours 35 mins 12 seconds and 800 milliseconds and ends at 01 hours 52 mins 14 seconds. Don't use space - characters. A zero or word 'start' can be used to mark the absolute start of the file and word 'end' the end of the file. Both start and stop times must be defined. Warning while using options -s -sn -sm and -smn: If your cut point is in the middle of a subtitle presentation time (even when muxing subtitles) you may get a video glitch.")
+	store_options_and_help_text_string("Scan", "st", "", "Start time. Start video processing from this timecode. Example -st 30:00 starts processing from 30 minutes from the start of the file. The time can also be a SMPTE timecode -st 00:30:00:00 (drop-frame -st 00:30:00;00) or a frame number -st 53946f, they are converted to time with the frame rate of the video.")
+	processing_stop_time := store_options_and_help_text_string("Scan", "et", "", "End time. Stop video processing at this timecode. Example -et 01:30:00 stops processing at 1 hour 30 minutes. You can define a time range like this: -st 10:09 -et 01:22:49.500 This results in a video file that starts at 10 minutes 9 seconds and stops at 1 hour 22 minutes, 49 seconds and 500 milliseconds. The time can be given in the same formats as with -st.")
+	store_options_and_help_text_string("Scan", "d", "", "Duration of video to process. Example -d 01:02 process 1 minutes and 2 seconds of the file. Use either -et or -d option not both. The duration can be given in the same formats as with -st.")
+
+	// Misc options
+	// If you want to print debug info then change debug to "true" below
+	debug_option := store_options_and_help_text_bool("Misc", "debug", "Turn on debug mode and show info about internal variables and the FFmpeg commandlines used.")
+	store_options_and_help_text_bool("Misc", "mkv", "Use matroska (mkv) as the output file wrapper format.")
+	use_webm_container := store_options_and_help_text_bool("Misc", "webm", "Use WebM as the output file wrapper format. Video is compressed with VP9 (libvpx-vp9) and audio with Opus. Automatic bitrate calculation, 2-pass encoding and -crf work the same way as with H.264, the default crf value for VP9 is 31. WebM does not support bitmap subtitles, subtitles can only be burned on top of video (-s, -sn).")
+	store_options_and_help_text_bool("Misc", "print", "Print FFmpeg commands that would be used for processing, don't process any files.")
+	store_options_and_help_text_bool("Misc", "list-presets", "List the presets defined in the config file and the options each preset expands to.")
+	store_options_and_help_text_string("Misc", "preset-name", "", "Use the options of this preset defined in the config file, example: -preset-name dvd-anime. Options given on the commandline override the preset options. Use -list-presets to see the presets.")
+	store_options_and_help_text_bool("Misc", "show-config", "Show the effective default values after the config file and the commandline options have been applied. The values are printed in the config file format, they can be copied to the config file: " + get_config_file_path() + " and edited there. Keys left out of the config file keep their default values.")
+	store_options_and_help_text_bool("Misc", "v", "Show the version of FFcommander.")
+	store_options_and_help_text_bool("Misc", "version", "Show the version of FFcommander.")
+	store_options_and_help_text_string("Misc", "td", "", "Path to directory for temporary files, example_ -td PathToDir. This option directs temporary files created with 2-pass encoding and subtitle processing (-sp) to a separate directory. Processing with the -sp switch goes much faster when temporary files are created on a ram or ssd - disk. The -sp switch extracts every frame of a movie as a tiff image, so you need to have lots of free space in the temp directory. For a FullHD movie you need 20 GB or more storage for temporary files. Subtitle extraction with the -sp switch fails silently if you run out of storage space. If this happens then some of the last subtitles won't be available when the video is compressed and this results the last available subtitle being 'stuck' on top of video until the end of the movie. This is a limitation in how FFmpeg works and cannot be worked around.")
+	store_options_and_help_text_bool("Misc", "h", "Display help text.")
+
+	//////////////////
+	// Option rules //
+	//////////////////
+	// Conflicts_with lists options that can't be used at the same time, requires lists options of which at least one
+	// must be used with the option and implies lists options that are turned on by the option.
+	// The rules are checked in validate_options after the commandline has been parsed, the values of the options are checked in ffcommander.Check_job.
+	// Options that turn on other options stored in the job (for example -ls turns on -fe) are turned on by the ffcommander package,
+	// only -f that is not stored in the job has an implies rule here.
+
+	// Audio options
+	audio_language_option.conflicts_with = []string{"an"}
+	audio_all_option.conflicts_with = []string{"a", "an", "na"}
+	audio_map_option.conflicts_with = []string{"a", "an", "aall", "na", "ls"}
+	audio_compression_ac3.conflicts_with = []string{"aac", "opus", "flac"}
+	audio_compression_aac.conflicts_with = []string{"opus", "flac"}
+	audio_compression_opus.conflicts_with = []string{"flac"}
+	no_audio.conflicts_with = []string{"a", "an"}
+	audio_offset_option.conflicts_with = []string{"na"}
+	downmix_to_stereo.conflicts_with = []string{"add-stereo", "na"}
+	add_stereo_downmix.conflicts_with = []string{"na"}
+	loudness_normalization_option.conflicts_with = []string{"na"}
+
+	// Video options
+	abr_option.conflicts_with = []string{"psd", "ssd", "mbr", "sbr", "size", "sdsize", "crf", "qt", "qr", "ls", "flac", "mkv", "webm", "sm", "smn"} // Streaming needs a known bitrate for each rung
+	abr_format_option.requires = []string{"abr"}
+	crf_option.value_is_optional = true
+	crf_option.conflicts_with = []string{"f", "fe", "mbr"}
+	av1_option.conflicts_with = []string{"hevc", "ls"}
+	hevc_option.conflicts_with = []string{"ls"} // Lossless encoding uses utvideo
+	parallel_sd.conflicts_with = []string{"ssd"}
+	sd_bitrate_option.requires = []string{"psd", "ssd"}
+	video_preset_option.conflicts_with = []string{"ls"} // Lossless encoding uses utvideo
+	video_tune_option.conflicts_with = []string{"ls", "av1", "webm"}
+	quality_report_option.conflicts_with = []string{"ls"} // Lossless video is identical to the original
+	quality_target_option.conflicts_with = []string{"f", "fe", "mbr", "size", "ls"}
+	target_size_option.conflicts_with = []string{"crf", "mbr", "ls", "flac"} // The size of lossless audio can't be predicted
+	sd_target_size_option.conflicts_with = []string{"crf", "sbr", "ls", "flac"}
+	sd_target_size_option.requires = []string{"psd"}
+
+	// Options that affect both video and audio
+	force_lossless.conflicts_with = []string{"ac3", "aac", "opus", "flac"}
+
+	// Subtitle options
+	subtitle_language_option.conflicts_with = []string{"sn", "sm", "smn"} // Subtitle can be burned on video or muxed into the file, not both at the same time
+	subtitle_burn_grayscale.requires = []string{"s", "sn"}
+	subtitle_stream_number_option.conflicts_with = []string{"sm", "smn"}
+	subtitle_mux_language_option.conflicts_with = []string{"smn"}
+	subtitle_burn_split.conflicts_with = []string{"so"}
+	subtitle_burn_resize.requires = []string{"sp"}
+
+	// Scan options
+	fast_encode_and_search.implies = []string{"fs", "fe"}
+	split_times.conflicts_with = []string{"st", "et"}
+	processing_stop_time.conflicts_with = []string{"d"}
+	processing_stop_time.requires = []string{"st"}
+
+	// Misc options
+	debug_option.conflicts_with = []string{"print"}
+	use_webm_container.conflicts_with = []string{"mkv", "ls", "hevc", "av1", "ac3", "aac", "flac"}
+
+	check_option_rules_are_defined_correctly()
+}
+
+func job_from_commandline_options(input_filenames []string, commandline []string, config ffcommander.Config_file_struct) ffcommander.Job {
+
+	// Store the options in the processing job. The options have been parsed and checked with validate_options
+	// and the options implied by other options have been turned on.
+	return ffcommander.Job{
+		Input_files: input_filenames,
+		Commandline: commandline,
+		Preset_name: commandline_option_map["preset-name"].user_string,
+		Preset_options: config.Presets[commandline_option_map["preset-name"].user_string],
+		Config: config,
+		Audio_language: commandline_option_map["a"].user_string,
+		Audio_all: commandline_option_map["aall"].is_turned_on,
+		Audio_map: commandline_option_map["amap"].user_string,
+		Audio_stream_number: commandline_option_map["an"].user_string,
+		Audio_compression_ac3: commandline_option_map["ac3"].is_turned_on,
+		Audio_compression_aac: commandline_option_map["aac"].is_turned_on,
+		Audio_compression_opus: commandline_option_map["opus"].is_turned_on,
+		Audio_compression_flac: commandline_option_map["flac"].is_turned_on,
+		No_audio: commandline_option_map["na"].is_turned_on,
+		Audio_offset: commandline_option_map["ad"].user_string,
+		Downmix_to_stereo: commandline_option_map["stereo"].is_turned_on,
+		Add_stereo_downmix: commandline_option_map["add-stereo"].is_turned_on,
+		Loudness_normalization: commandline_option_map["norm"].user_string,
+		Adjust_black_point: commandline_option_map["abk"].user_string,
+		Autocrop: commandline_option_map["ac"].is_turned_on,
+		Adjust_chroma: commandline_option_map["ach"].user_string,
+		Adjust_gamma: commandline_option_map["agm"].user_string,
+		Adjust_white_point: commandline_option_map["awh"].user_string,
+		Abr: commandline_option_map["abr"].user_string,
+		Abr_format: commandline_option_map["abrf"].user_string,
+		Crf: commandline_option_map["crf"].is_turned_on,
+		Crf_value: commandline_option_map["crf"].user_string,
+		Denoise: commandline_option_map["dn"].is_turned_on,
+		Av1: commandline_option_map["av1"].is_turned_on,
+		Hevc: commandline_option_map["hevc"].is_turned_on,
+		Grayscale: commandline_option_map["gr"].is_turned_on,
+		Inverse_telecine: commandline_option_map["it"].is_turned_on,
+		Main_bitrate: commandline_option_map["mbr"].user_string,
+		Video_preset: commandline_option_map["preset"].user_string,
+		No_deinterlace: commandline_option_map["nd"].is_turned_on,
+		Parallel_sd: commandline_option_map["psd"].is_turned_on,
+		Sd_bitrate: commandline_option_map["sbr"].user_string,
+		Quality_report: commandline_option_map["qr"].is_turned_on,
+		Quality_target: commandline_option_map["qt"].user_string,
+		Target_size: commandline_option_map["size"].user_string,
+		Sd_target_size: commandline_option_map["sdsize"].user_string,
+		Scale_to_sd: commandline_option_map["ssd"].is_turned_on,
+		Video_tune: commandline_option_map["tune"].user_string,
+		Burn_timecode: commandline_option_map["tc"].is_turned_on,
+		Force_lossless: commandline_option_map["ls"].is_turned_on,
+		Subtitle_language: commandline_option_map["s"].user_string,
+		Subtitle_burn_downscale: commandline_option_map["sd"].is_turned_on,
+		Subtitle_burn_grayscale: commandline_option_map["sgr"].is_turned_on,
+		Subtitle_stream_number: commandline_option_map["sn"].user_string,
+		Subtitle_vertical_offset: commandline_option_map["so"].user_string,
+		Subtitle_mux_languages: commandline_option_map["sm"].user_string,
+		Subtitle_mux_numbers: commandline_option_map["smn"].user_string,
+		Subtitle_burn_palette: commandline_option_map["palette"].user_string,
+		Subtitle_burn_split: commandline_option_map["sp"].is_turned_on,
+		Subtitle_burn_resize: commandline_option_map["sr"].user_string,
+		Fast_encode: commandline_option_map["fe"].is_turned_on,
+		Fast_search: commandline_option_map["fs"].is_turned_on,
+		Scan_mode_only: commandline_option_map["scan"].is_turned_on,
+		Split_times: commandline_option_map["sf"].user_string,
+		Search_start: commandline_option_map["st"].user_string,
+		Processing_stop_time: commandline_option_map["et"].user_string,
+		Processing_duration: commandline_option_map["d"].user_string,
+		Debug: commandline_option_map["debug"].is_turned_on,
+		Use_matroska_container: commandline_option_map["mkv"].is_turned_on,
+		Use_webm_container: commandline_option_map["webm"].is_turned_on,
+		Only_print_commands: commandline_option_map["print"].is_turned_on,
+		Temp_file_directory: commandline_option_map["td"].user_string,
+	}
+}
+
+func main() {
+
+	// Print executable name and version
+	fmt.Println(filepath.Base(os.Args[0]), "version", version_number)
+
+	define_commandline_options()
+
+	///////////////////////////////
+	// Parse commandline options //
+	///////////////////////////////
+	// Errors in options are collected to this slice and printed all at once after all options have been checked
+	input_filenames, option_errors := parse_options(os.Args[1:], "")
+
+	if commandline_option_map["h"].is_turned_on == true {
+		display_help_text()
+	}
+
+	// Print program version and license info. The version is printed before the options are checked,
+	// so that it can be printed on a machine that does not have FFmpeg.
+	if commandline_option_map["v"].is_turned_on == true || commandline_option_map["version"].is_turned_on == true {
+		fmt.Println()
+		fmt.Println("(C) Mikael Hartzell 2018.")
+		fmt.Println()
+		fmt.Println("FFmpeg version 3 or higher is required to use this program.")
+		fmt.Println("Subtitle processing with the -sp option requires ImageMagick.")
+		fmt.Println()
+		fmt.Println("This program is distributed under the GNU General Public License, version 3 (GPLv3)")
+		fmt.Println("Check the license here: http://www.gnu.org/licenses/gpl.txt")
+		fmt.Println("Basically this license gives you full freedom to do what ever you want with this program.")
+		fmt.Println("You are free to use, modify, distribute it any way you like.")
+		fmt.Println("The only restriction is that if you make derivate works of this program AND distribute those,")
+		fmt.Println("the derivate works must also be licensed under GPL 3.")
+		fmt.Println()
+		fmt.Println("This program is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY;")
+		fmt.Println("without even the implied warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.")
+		fmt.Println("See the GNU General Public License for more details")
+		fmt.Println()
+		os.Exit(0)
+	}
+
+	////////////////////////////////////////////////////////////////////
+	// Read the config file. Config file values replace the defaults  //
+	// and commandline options override the config file values.       //
+	////////////////////////////////////////////////////////////////////
+	config_file_path := get_config_file_path()
+	config_values, config_errors := read_config_file(config_file_path)
+
+	if len(config_errors) > 0 {
+		fmt.Println()
+		fmt.Println("Error in config file: " + config_file_path)
+
+		for _, config_error := range config_errors {
+			fmt.Println("Error: " + config_error + ".")
+		}
+
+		fmt.Println()
+		os.Exit(1)
+	}
+
+	if commandline_option_map["list-presets"].is_turned_on == true {
+
+		var preset_names []string
+
+		for preset_name := range config_values.Presets {
+			preset_names = append(preset_names, preset_name)
+		}
+
+		sort.Strings(preset_names)
+
+		if len(preset_names) == 0 {
+			fmt.Println("There are no presets in the config file: " + config_file_path)
+		}
+
+		for _, preset_name := range preset_names {
+			fmt.Println(preset_name + ": " + strings.Join(strings.Fields(config_values.Presets[preset_name]), " "))
+		}
+
+		os.Exit(0)
+	}
+
+	// Add the options of the preset the user selected. Options given on the commandline override the preset.
+	if commandline_option_map["preset-name"].user_string != "" {
+
+		preset_options, preset_found := config_values.Presets[commandline_option_map["preset-name"].user_string]
+
+		if preset_found == false {
+			fmt.Println()
+			fmt.Println("Error, preset: " + commandline_option_map["preset-name"].user_string + " is not defined in the config file: " + config_file_path + ". Use -list-presets to see the presets.")
+			fmt.Println()
+			os.Exit(1)
+		}
+
+		option_errors = append(option_errors, apply_option_preset(commandline_option_map["preset-name"].user_string, preset_options)...)
+	}
+
+	// Check option values and combinations. Report all errors at once so that the user can fix them in one go.
+	option_errors = append(option_errors, validate_options()...)
+	apply_implied_options()
+
+	/////////////////////////////////////////////
+	// Store the options in the processing job //
+	/////////////////////////////////////////////
+	job := job_from_commandline_options(input_filenames, os.Args, config_values)
+
+	// -show-config only prints the config, the job is not checked so that the config can be printed on a machine that does not have FFmpeg
+	if commandline_option_map["show-config"].is_turned_on == false {
+		option_errors = append(option_errors, ffcommander.Check_job(context.Background(), job)...)
+	}
+
+	// All options have now been checked, print the errors and stop if there were any
+	if len(option_errors) > 0 {
+		fmt.Println()
+
+		for _, option_error := range option_errors {
+			fmt.Println("Error: " + option_error + ".")
+		}
+
+		if commandline_option_map["preset-name"].user_string != "" {
+			fmt.Println()
+			fmt.Println("Options from preset " + commandline_option_map["preset-name"].user_string + " were used: " + strings.Join(strings.Fields(config_values.Presets[commandline_option_map["preset-name"].user_string]), " "))
+		}
+
+		fmt.Println()
+		os.Exit(1)
+	}
+
+	// Print the effective defaults after the commandline options have been applied
+	if commandline_option_map["show-config"].is_turned_on == true {
+
+		effective_config, _ := ffcommander.Effective_config(job)
+		config_json, _ := json.MarshalIndent(effective_config, "", "\t")
+
+		if _, err := os.Stat(config_file_path); err == nil {
+			fmt.Println("Config file: " + config_file_path)
+		} else {
+			fmt.Println("Config file: " + config_file_path + " (not found, using defaults)")
+		}
+
+		fmt.Println()
+		fmt.Println(string(config_json))
+		os.Exit(0)
+	}
+
+	/////////////////////////////////////////////////////////////////////////////////
+	// Stop processing cleanly when the user presses Ctrl-C or the system sends us //
+	// SIGTERM. Cancelling the context stops running FFmpeg and ImageMagick       //
+	// processes and the subtitle processing goroutines.                           //
+	/////////////////////////////////////////////////////////////////////////////////
+	processing_context, cancel_processing := context.WithCancel(context.Background())
+	signal_channel := make(chan os.Signal, 1)
+	signal.Notify(signal_channel, os.Interrupt, syscall.SIGTERM)
+
+	go func() {
+		<- signal_channel
+		fmt.Println("\n\nInterrupted, stopping processing and removing temporary files. Press Ctrl-C again to quit immediately.")
+
+		// Restore default signal handling so that the next Ctrl-C quits the program immediately
+		signal.Stop(signal_channel)
+		cancel_processing()
+	}()
+
+	if ffcommander.Run(processing_context, job) != nil {
+		os.Exit(1)
+	}
+}
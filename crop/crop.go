@@ -0,0 +1,139 @@
+// (C) Mikael Hartzell 2018
+// This program is distributed under the GNU General Public License, version 3 (GPLv3)
+
+// Package crop finds the black borders of the picture with the FFmpeg cropdetect filter.
+package crop
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/mhartzel/ffcommander/encode"
+)
+
+//////////////////////////////////////////////////////////////////////////
+// Find the crop values of the picture by scanning the file with FFmpeg //
+//////////////////////////////////////////////////////////////////////////
+
+func Detect_crop(ctx context.Context, input_file string, video_duration_int int, search_start_seconds_int int, processing_duration_seconds_int int, debug_option bool) (crop_value_map map[string]int, error_code error) {
+
+	// FFmpeg cropdetect scans the file and tries to guess where the black bars are.
+	// Long videos are scanned with 10 second spot checks in 10 places for the duration of the video,
+	// short videos are scanned from start to end. The times are whole seconds.
+	// The returned map stores how many times cropdetect measured each crop value.
+	crop_value_map = make(map[string]int)
+	crop_start_seconds_int := 0
+	crop_scan_duration_int := video_duration_int
+	crop_scan_stop_time_int := video_duration_int
+
+	if search_start_seconds_int > 0 {
+		crop_start_seconds_int = search_start_seconds_int
+		crop_scan_duration_int = video_duration_int - crop_start_seconds_int
+	}
+
+	if processing_duration_seconds_int > 0 {
+		crop_scan_duration_int = processing_duration_seconds_int
+		crop_scan_stop_time_int = processing_duration_seconds_int + search_start_seconds_int
+	}
+
+	if debug_option == true {
+		fmt.Println("user_defined_search_start_seconds_int:", search_start_seconds_int)
+		fmt.Println("user_defined_video_duration_seconds_int:", processing_duration_seconds_int)
+		fmt.Println("video_duration_int:", video_duration_int)
+		fmt.Println("crop_start_seconds_int:", crop_start_seconds_int)
+		fmt.Println("crop_scan_duration_int:", crop_scan_duration_int)
+		fmt.Println("crop_scan_stop_time_int:", crop_scan_stop_time_int)
+		fmt.Println("spotcheck_interval:", crop_scan_duration_int / 10)
+	}
+
+	// For long videos take short snapshots of crop values spanning the whole file. This is "quick scan mode".
+	if crop_scan_duration_int > 300 {
+
+		spotcheck_interval := crop_scan_duration_int / 10 // How many spot checks will be made across the duration of the video (default = 10)
+		scan_duration_str := "10"                     // How many seconds of video to scan for each spot (default = 10 seconds)
+		scan_duration_int, _ := strconv.Atoi(scan_duration_str)
+
+		// Repeat spot checks
+		for time_to_jump_to := crop_start_seconds_int + scan_duration_int ; time_to_jump_to + scan_duration_int < crop_scan_stop_time_int ; time_to_jump_to = time_to_jump_to + spotcheck_interval {
+
+			// Create the ffmpeg command to scan for crop values
+			command_to_run_str_slice := []string{"ffmpeg", "-ss", strconv.Itoa(time_to_jump_to), "-t", scan_duration_str, "-i", input_file, "-f", "matroska", "-sn", "-an", "-filter_complex", "cropdetect=24:8:250", "-y", "-crf", "51", "-preset", "ultrafast", "/dev/null"}
+
+			if debug_option == true {
+				fmt.Println()
+				fmt.Println("FFmpeg crop command:", command_to_run_str_slice)
+				fmt.Println()
+			}
+
+			ffmpeg_crop_output, ffmpeg_crop_error_output, error_code := encode.Run_external_command(ctx, command_to_run_str_slice)
+
+			if error_code != nil {
+				return crop_value_map, encode.External_command_error("FFmpeg", ffmpeg_crop_output, ffmpeg_crop_error_output, error_code)
+			}
+
+			count_crop_values(ffmpeg_crop_error_output, crop_value_map)
+		}
+	}
+
+	// Scan the file for crop values.
+	if crop_scan_duration_int < 300 || len(crop_value_map) == 0 {
+
+		command_to_run_str_slice := []string{"ffmpeg"}
+
+		if crop_start_seconds_int != 0 {
+			command_to_run_str_slice = append(command_to_run_str_slice, "-ss", strconv.Itoa(crop_start_seconds_int))
+		}
+
+		command_to_run_str_slice = append(command_to_run_str_slice, "-t", strconv.Itoa(crop_scan_duration_int), "-i", input_file, "-f", "matroska", "-sn", "-an", "-filter_complex", "cropdetect=24:8:250", "-y", "-crf", "51", "-preset", "ultrafast", "/dev/null")
+
+		if debug_option == true {
+			fmt.Println()
+			fmt.Println("FFmpeg crop command:", command_to_run_str_slice)
+			fmt.Println()
+		}
+
+		ffmpeg_crop_output, ffmpeg_crop_error_output, error_code := encode.Run_external_command(ctx, command_to_run_str_slice)
+
+		if error_code != nil {
+			return crop_value_map, encode.External_command_error("FFmpeg", ffmpeg_crop_output, ffmpeg_crop_error_output, error_code)
+		}
+
+		count_crop_values(ffmpeg_crop_error_output, crop_value_map)
+	}
+
+	return crop_value_map, nil
+}
+
+func count_crop_values(ffmpeg_output []string, crop_value_map map[string]int) {
+
+	// FFmpeg returns a bunch of measurements like this: crop=1472:1080:224:0
+	// Count how many times each crop value was measured.
+	for _, slice_item := range ffmpeg_output {
+
+		for _, item := range strings.Split(slice_item, "\n") {
+
+			if strings.Contains(item, "crop=") {
+				crop_value_map[strings.Split(item, "crop=")[1]]++
+			}
+		}
+	}
+}
+
+func Most_frequent_crop_value(crop_value_map map[string]int) (final_crop_string string) {
+
+	// The value that is most frequent is the value that can be applied without cropping too much or too little.
+	// If several values are measured as many times then the smallest one in string order is used, so that the result does not depend on the map order.
+	last_crop_value := 0
+
+	for crop_value, crop_value_count := range crop_value_map {
+
+		if crop_value_count > last_crop_value || (crop_value_count == last_crop_value && crop_value < final_crop_string) {
+			last_crop_value = crop_value_count
+			final_crop_string = crop_value
+		}
+	}
+
+	return final_crop_string
+}
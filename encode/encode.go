@@ -0,0 +1,516 @@
+// (C) Mikael Hartzell 2018
+// This program is distributed under the GNU General Public License, version 3 (GPLv3)
+
+// Package encode runs FFmpeg and the other external programs and builds FFmpeg commandlines.
+// The limits of the -preset, -tune and -crf options of each video encoder are defined here.
+package encode
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Values accepted by the -preset, -tune and -crf options for each video encoder.
+// x264 and x265 use named presets, SVT-AV1, libaom and libvpx use a number where a smaller number is slower and gives better quality.
+// Libaom and libvpx set the speed with -cpu-used instead of -preset. The number range for libvpx is the one used with '-deadline good'.
+type Video_encoder_limits_struct struct {
+	Preset_option string
+	Presets []string
+	Preset_min int
+	Preset_max int
+	Tunes []string
+	Crf_max int
+}
+
+var x264_presets = []string{"ultrafast", "superfast", "veryfast", "faster", "fast", "medium", "slow", "slower", "veryslow", "placebo"}
+
+var Video_encoder_limits = map[string]Video_encoder_limits_struct{
+	"libx264": {Preset_option: "-preset", Presets: x264_presets, Tunes: []string{"film", "animation", "grain", "stillimage", "fastdecode", "zerolatency", "psnr", "ssim"}, Crf_max: 51},
+	"libx265": {Preset_option: "-preset", Presets: x264_presets, Tunes: []string{"animation", "grain", "fastdecode", "zerolatency", "psnr", "ssim"}, Crf_max: 51},
+	"libsvtav1": {Preset_option: "-preset", Preset_min: 0, Preset_max: 13, Crf_max: 63},
+	"libaom-av1": {Preset_option: "-cpu-used", Preset_min: 0, Preset_max: 8, Crf_max: 63},
+	"libvpx-vp9": {Preset_option: "-cpu-used", Preset_min: 0, Preset_max: 5, Crf_max: 63},
+}
+
+// FFmpeg commandlines are built in these structs and rendered to an argument list with Render_ffmpeg_commandline.
+// Options in Ffmpeg_input_struct are placed before the -i of the input file, options in Ffmpeg_output_struct are placed before the output file path.
+type Ffmpeg_input_struct struct {
+	Seek_start string // -ss before -i is fast but inaccurate
+	Input_options []string // For example: -f concat -safe 0 or -palette
+	File_path string
+}
+
+type Ffmpeg_output_struct struct {
+	Seek_start string // -ss after -i is slow but frame accurate
+	Duration string
+	Maps []string // Stream specifiers for -map, in the order the streams appear in the output
+	Codec_options []string // Video, audio and subtitle codec options
+	Options []string // Other output options, for example: -passlogfile, -f mp4, -pass 1
+	File_path string
+}
+
+type Ffmpeg_commandline_struct struct {
+	Global_options []string
+	Inputs []Ffmpeg_input_struct
+	Filter_complex []string // Filter chains that are joined with ';' for the -filter_complex option
+	Outputs []Ffmpeg_output_struct
+}
+
+// External programs (ffmpeg, ffprobe, magick, mogrify) are run through the Command_runner interface.
+// The default exec_command_runner runs the programs. Tests replace External_command_runner with Recording_command_runner that only records the commandlines
+// and returns canned output, so that the processing logic can be run without the external programs.
+// Cancelling the context (Ctrl-C or SIGTERM) stops the running program.
+type Command_runner interface {
+	Run(ctx context.Context, command_to_run_str_slice []string) (stdout_output []string, stderr_output []string, error_code error)
+	Look_path(filename string) (file_path string, error_code error)
+}
+
+type exec_command_runner struct{}
+
+var External_command_runner Command_runner = &exec_command_runner{}
+
+func (runner *exec_command_runner) Run(ctx context.Context, command_to_run_str_slice []string) (stdout_output []string, stderr_output []string, error_code error) {
+
+	// Create the struct needed for running the external command.
+	// When the context is cancelled ask the program to stop with SIGTERM so that FFmpeg can close its files, kill it if it has not stopped in 10 seconds.
+	command_struct := exec.CommandContext(ctx, command_to_run_str_slice[0], command_to_run_str_slice[1:]...)
+	command_struct.Cancel = func() error { return command_struct.Process.Signal(syscall.SIGTERM) }
+	command_struct.WaitDelay = 10 * time.Second
+
+	// Run external command
+	var stdout, stderr bytes.Buffer
+	command_struct.Stdout = &stdout
+	command_struct.Stderr = &stderr
+
+	error_code = command_struct.Run()
+
+	return Split_command_output(string(stdout.Bytes())), Split_command_output(string(stderr.Bytes())), error_code
+}
+
+func (runner *exec_command_runner) Look_path(filename string) (file_path string, error_code error) {
+	return exec.LookPath(filename)
+}
+
+// Recording_command_runner is used in the tests of the library and the commandline program.
+type Canned_command_output_struct struct {
+	Stdout string
+	Stderr string
+	Error_code error
+}
+
+type Recording_command_runner struct {
+	mutex sync.Mutex // Subtitle processing runs commands in many goroutines simultaneously
+	Recorded_commands [][]string
+	Canned_outputs map[string]Canned_command_output_struct // The key is the complete commandline joined with spaces or only the program name
+}
+
+func (runner *Recording_command_runner) Run(ctx context.Context, command_to_run_str_slice []string) (stdout_output []string, stderr_output []string, error_code error) {
+
+	if ctx.Err() != nil {
+		return nil, nil, ctx.Err()
+	}
+
+	runner.mutex.Lock()
+	defer runner.mutex.Unlock()
+
+	runner.Recorded_commands = append(runner.Recorded_commands, append([]string{}, command_to_run_str_slice...))
+
+	// Use output defined for the complete commandline first and then output defined for the program.
+	canned_output, item_found := runner.Canned_outputs[strings.Join(command_to_run_str_slice, " ")]
+
+	if item_found == false {
+		canned_output = runner.Canned_outputs[command_to_run_str_slice[0]]
+	}
+
+	return Split_command_output(canned_output.Stdout), Split_command_output(canned_output.Stderr), canned_output.Error_code
+}
+
+func (runner *Recording_command_runner) Look_path(filename string) (file_path string, error_code error) {
+	return filename, nil
+}
+
+func Split_command_output(command_output_str string) (output_lines []string) {
+
+	// Split the output of the command to lines and store in a slice
+	for _, line := range strings.Split(command_output_str, "\n") {
+		output_lines = append(output_lines, line)
+	}
+
+	return output_lines
+}
+
+func Run_external_command(ctx context.Context, command_to_run_str_slice []string) (stdout_output []string, stderr_output []string, error_code error) {
+	return External_command_runner.Run(ctx, command_to_run_str_slice)
+}
+
+func Find_executable_path(filename string) (file_path string, error_code error) {
+
+	/////////////////////////////////////////////////
+	// Test if executable can be found in the path //
+	/////////////////////////////////////////////////
+
+	file_path, error_code = External_command_runner.Look_path(filename)
+
+	if error_code != nil {
+
+		if filename == "magick" || filename == "mogrify" {
+			return "", errors.New("cant find program: " + filename + " in path. " + filename + " is part of package ImageMagick and is needed for the -sp functionality")
+		}
+
+		return "", errors.New("cant find program: " + filename + " in path")
+	}
+
+	return file_path, nil
+}
+
+func Get_ffmpeg_encoders(ctx context.Context) (encoders map[string]bool, error_code error) {
+
+	// Find out which encoders the installed FFmpeg has been compiled with.
+	// The encoder list printed by FFmpeg starts after a line of dashes and looks like this:
+	//  V....D libx264              libx264 H.264 / AVC / MPEG-4 AVC / MPEG-4 part 10 (codec h264)
+	encoders = make(map[string]bool)
+
+	stdout_output, stderr_output, error_code := Run_external_command(ctx, []string{"ffmpeg", "-hide_banner", "-encoders"})
+
+	if error_code != nil {
+		return encoders, External_command_error("ffmpeg", stdout_output, stderr_output, error_code)
+	}
+
+	encoder_list_started := false
+
+	for _, line := range stdout_output {
+
+		fields := strings.Fields(line)
+
+		if len(fields) == 0 {
+			continue
+		}
+
+		if strings.HasPrefix(fields[0], "---") {
+			encoder_list_started = true
+			continue
+		}
+
+		if encoder_list_started == true && len(fields) > 1 {
+			encoders[fields[1]] = true
+		}
+	}
+
+	return encoders, nil
+}
+
+func Render_ffmpeg_commandline(ffmpeg_commandline Ffmpeg_commandline_struct) (commandline []string) {
+
+	// Create the argument list that is passed to exec.Command from the Ffmpeg_commandline_struct.
+	commandline = append(commandline, "ffmpeg")
+	commandline = append(commandline, ffmpeg_commandline.Global_options...)
+
+	for _, input := range ffmpeg_commandline.Inputs {
+
+		if input.Seek_start != "" {
+			commandline = append(commandline, "-ss", input.Seek_start)
+		}
+
+		commandline = append(commandline, input.Input_options...)
+		commandline = append(commandline, "-i", input.File_path)
+	}
+
+	if len(ffmpeg_commandline.Filter_complex) > 0 {
+		commandline = append(commandline, "-filter_complex", strings.Join(ffmpeg_commandline.Filter_complex, ";"))
+	}
+
+	for _, output := range ffmpeg_commandline.Outputs {
+
+		if output.Seek_start != "" {
+			commandline = append(commandline, "-ss", output.Seek_start)
+		}
+
+		if output.Duration != "" {
+			commandline = append(commandline, "-t", output.Duration)
+		}
+
+		for _, stream_specifier := range output.Maps {
+			commandline = append(commandline, "-map", stream_specifier)
+		}
+
+		commandline = append(commandline, output.Codec_options...)
+		commandline = append(commandline, output.Options...)
+		commandline = append(commandline, output.File_path)
+	}
+
+	return commandline
+}
+
+func shell_quote_string(text string) (quoted_text string) {
+
+	// Put text in single quotes if it contains characters that the shell would interpret.
+	// A single quote in the text is written as: '\''
+	if text == "" {
+		return "''"
+	}
+
+	for _, character := range text {
+
+		if strings.ContainsRune("abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789_-+=.,:/@%", character) == false {
+			return "'" + strings.Replace(text, "'", "'\\''", -1) + "'"
+		}
+	}
+
+	return text
+}
+
+func Ffmpeg_commandline_to_shell_string(ffmpeg_commandline Ffmpeg_commandline_struct) (commandline_str string) {
+
+	// Create a commandline for the processing log that the user can copy and paste into a shell and run.
+	var quoted_arguments []string
+
+	for _, argument := range Render_ffmpeg_commandline(ffmpeg_commandline) {
+		quoted_arguments = append(quoted_arguments, shell_quote_string(argument))
+	}
+
+	return strings.Join(quoted_arguments, " ")
+}
+
+func Copy_ffmpeg_commandline(ffmpeg_commandline Ffmpeg_commandline_struct) (commandline_copy Ffmpeg_commandline_struct) {
+
+	// Create a copy of the commandline that does not share any slices with the original,
+	// so that the copy can be modified without changing the original (pass 1 is created from a copy of pass 2).
+	commandline_copy.Global_options = append([]string{}, ffmpeg_commandline.Global_options...)
+	commandline_copy.Filter_complex = append([]string{}, ffmpeg_commandline.Filter_complex...)
+
+	for _, input := range ffmpeg_commandline.Inputs {
+		input.Input_options = append([]string{}, input.Input_options...)
+		commandline_copy.Inputs = append(commandline_copy.Inputs, input)
+	}
+
+	for _, output := range ffmpeg_commandline.Outputs {
+		output.Maps = append([]string{}, output.Maps...)
+		output.Codec_options = append([]string{}, output.Codec_options...)
+		output.Options = append([]string{}, output.Options...)
+		commandline_copy.Outputs = append(commandline_copy.Outputs, output)
+	}
+
+	return commandline_copy
+}
+
+func Add_x265_parameters(codec_options []string, parameters string) []string {
+
+	// FFmpeg only uses the last -x265-params option on the commandline, so all x265 parameters must be joined together with ':'.
+	// Add parameters to the existing -x265-params option or create the option if it does not exist yet.
+	// An output with several video streams (-abr) has one -x265-params:v:N option for each stream, the parameters are added to all of them.
+	// A new slice is returned, the original codec options are not changed.
+	new_codec_options := append([]string{}, codec_options...)
+	x265_parameters_found := false
+
+	for counter := 0; counter < len(new_codec_options) - 1; counter++ {
+
+		if new_codec_options[counter] == "-x265-params" || strings.HasPrefix(new_codec_options[counter], "-x265-params:") == true {
+			new_codec_options[counter + 1] = new_codec_options[counter + 1] + ":" + parameters
+			x265_parameters_found = true
+		}
+	}
+
+	if x265_parameters_found == true {
+		return new_codec_options
+	}
+
+	return append(new_codec_options, "-x265-params", parameters)
+}
+
+func Add_stream_specifier(codec_options []string, stream_type string, stream_number int) []string {
+
+	// Make codec options apply to only one stream of an output that has several video (-abr) or audio (-a eng,fin) streams.
+	// -c:v libx264 becomes -c:v:0 libx264 and -preset slow becomes -preset:v:0 slow. Codec options are option and value pairs.
+	// The FFmpeg -vcodec and -acodec options don't take a stream specifier, they are changed to -c:v and -c:a.
+	new_codec_options := append([]string{}, codec_options...)
+
+	for counter := 0; counter < len(new_codec_options) - 1; counter = counter + 2 {
+
+		if new_codec_options[counter] == "-" + stream_type + "codec" {
+			new_codec_options[counter] = "-c:" + stream_type
+		}
+
+		if strings.HasSuffix(new_codec_options[counter], ":" + stream_type) == true {
+			new_codec_options[counter] = new_codec_options[counter] + ":" + strconv.Itoa(stream_number)
+		} else {
+			new_codec_options[counter] = new_codec_options[counter] + ":" + stream_type + ":" + strconv.Itoa(stream_number)
+		}
+	}
+
+	return new_codec_options
+}
+
+func Get_number_of_physical_processors () (int, error) {
+
+	/////////////////////////////////
+	// This is Linux specific code //
+	/////////////////////////////////
+
+	last_physical_id_int := -1
+	physical_id_int := -1
+	physical_id_found := false
+	cpu_cores_int := 0
+
+	// Read in /proc/cpuinfo
+	file_handle, err := os.Open("/proc/cpuinfo")
+
+	if err != nil {
+		return 0, err
+	}
+
+	defer file_handle.Close()
+
+	scanner := bufio.NewScanner(file_handle)
+	scanner.Split(bufio.ScanLines)
+
+	for scanner.Scan() {
+
+		if strings.HasPrefix(scanner.Text(), "physical id") {
+			temp_list  := strings.Split(scanner.Text(), ":")
+			physical_id_int, err = strconv.Atoi(strings.TrimSpace(temp_list[1]))
+
+			if physical_id_int != last_physical_id_int {
+				physical_id_found = true
+				last_physical_id_int = physical_id_int
+				continue
+			}
+		}
+
+		if err != nil {
+			break
+		}
+
+		if physical_id_found == true && strings.HasPrefix(scanner.Text(), "cpu cores") {
+			temp_int := -1
+			temp_list  := strings.Split(scanner.Text(), ":")
+			temp_int, err = strconv.Atoi(strings.TrimSpace(temp_list[1]))
+			cpu_cores_int = cpu_cores_int + temp_int
+			physical_id_found = false
+		}
+
+		if err != nil {
+			break
+		}
+	}
+
+	return cpu_cores_int, err
+}
+
+func External_command_error(program_name string, stdout_output []string, stderr_output []string, error_code error) error {
+
+	// Combine the error code and everything the external program printed into one error message
+	error_message := program_name + " reported error: " + error_code.Error()
+
+	for _, textline := range stdout_output {
+		error_message = error_message + "\n" + textline
+	}
+
+	for _, textline := range stderr_output {
+		error_message = error_message + "\n" + textline
+	}
+
+	return errors.New(error_message)
+}
+
+func Check_video_encoder_options(encoder_name string, preset string, tune string, crf string) []string {
+
+	// Check the -preset, -tune and -crf values against the values the encoder accepts.
+	// Empty strings mean that the user did not give the option.
+	var option_errors []string
+	encoder_limits := Video_encoder_limits[encoder_name]
+
+	if preset != "" {
+
+		if len(encoder_limits.Presets) > 0 {
+
+			preset_found := false
+
+			for _, encoder_preset := range encoder_limits.Presets {
+
+				if preset == encoder_preset {
+					preset_found = true
+					break
+				}
+			}
+
+			if preset_found == false {
+				option_errors = append(option_errors, "option -preset: '" + preset + "' is not a " + encoder_name + " preset. Use one of: " + strings.Join(encoder_limits.Presets, ", "))
+			}
+
+		} else if preset_int, atoi_error := strconv.Atoi(preset); atoi_error != nil || preset_int < encoder_limits.Preset_min || preset_int > encoder_limits.Preset_max {
+			option_errors = append(option_errors, "option -preset: " + encoder_name + " preset must be a number between " + strconv.Itoa(encoder_limits.Preset_min) + " and " + strconv.Itoa(encoder_limits.Preset_max) + ", smaller number is slower and gives better quality")
+		}
+	}
+
+	if tune != "" {
+
+		tune_found := false
+
+		for _, encoder_tune := range encoder_limits.Tunes {
+
+			if tune == encoder_tune {
+				tune_found = true
+				break
+			}
+		}
+
+		if len(encoder_limits.Tunes) == 0 {
+			option_errors = append(option_errors, "option -tune can't be used with the " + encoder_name + " encoder")
+
+		} else if tune_found == false {
+			option_errors = append(option_errors, "option -tune: '" + tune + "' is not a " + encoder_name + " tune. Use one of: " + strings.Join(encoder_limits.Tunes, ", "))
+		}
+	}
+
+	if crf != "" {
+
+		if crf_int, atoi_error := strconv.Atoi(crf); atoi_error != nil {
+			option_errors = append(option_errors, "option -crf: '" + crf + "' is not a whole number")
+
+		} else if crf_int < 0 || crf_int > encoder_limits.Crf_max {
+			option_errors = append(option_errors, "option -crf: " + encoder_name + " crf value must be between 0 and " + strconv.Itoa(encoder_limits.Crf_max))
+		}
+	}
+
+	return option_errors
+}
+
+func Set_encoder_option(compression_options []string, option string, value string) []string {
+
+	// Return a copy of the compression options where the value following the option is replaced with the new value.
+	// If the option is not in the list it is added to the end.
+	// A copy is returned because the same table may be used for several resolutions.
+	new_compression_options := append([]string{}, compression_options...)
+
+	for counter := 0; counter < len(new_compression_options) - 1; counter++ {
+
+		if new_compression_options[counter] == option {
+			new_compression_options[counter + 1] = value
+			return new_compression_options
+		}
+	}
+
+	return append(new_compression_options, option, value)
+}
+
+func Get_encoder_option(compression_options []string, option string) string {
+
+	// Return the value following the option in the compression options or an empty string if the option is not in the list
+	for counter := 0; counter < len(compression_options) - 1; counter++ {
+
+		if compression_options[counter] == option {
+			return compression_options[counter + 1]
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,41 @@
+// (C) Mikael Hartzell 2018
+// This program is distributed under the GNU General Public License, version 3 (GPLv3)
+
+package encode
+
+import (
+	"reflect"
+	"testing"
+)
+
+func Test_check_video_encoder_options(t *testing.T) {
+
+	test_cases := []struct {
+		encoder_name string
+		preset string
+		tune string
+		crf string
+		expected_errors []string
+	}{
+		{"libx264", "slow", "film", "18", nil},
+		{"libx264", "", "", "0", nil},
+		{"libx264", "", "", "51", nil},
+		{"libsvtav1", "8", "", "63", nil},
+		{"libx264", "", "", "52", []string{"option -crf: libx264 crf value must be between 0 and 51"}},
+		{"libx265", "", "", "-1", []string{"option -crf: libx265 crf value must be between 0 and 51"}},
+		{"libaom-av1", "", "", "64", []string{"option -crf: libaom-av1 crf value must be between 0 and 63"}},
+		{"libx264", "", "", "abc", []string{"option -crf: 'abc' is not a whole number"}},
+		{"libx264", "", "", "18.5", []string{"option -crf: '18.5' is not a whole number"}},
+		{"libx264", "fastest", "", "", []string{"option -preset: 'fastest' is not a libx264 preset. Use one of: ultrafast, superfast, veryfast, faster, fast, medium, slow, slower, veryslow, placebo"}},
+		{"libvpx-vp9", "6", "film", "", []string{"option -preset: libvpx-vp9 preset must be a number between 0 and 5, smaller number is slower and gives better quality", "option -tune can't be used with the libvpx-vp9 encoder"}},
+	}
+
+	for _, test_case := range test_cases {
+
+		option_errors := Check_video_encoder_options(test_case.encoder_name, test_case.preset, test_case.tune, test_case.crf)
+
+		if reflect.DeepEqual(option_errors, test_case.expected_errors) == false {
+			t.Errorf("%s -preset %q -tune %q -crf %q:\n got: %q\nwant: %q", test_case.encoder_name, test_case.preset, test_case.tune, test_case.crf, option_errors, test_case.expected_errors)
+		}
+	}
+}
@@ -1,38 +1,74 @@
 // (C) Mikael Hartzell 2018
 // This program is distributed under the GNU General Public License, version 3 (GPLv3)
 
-package main
+// Package ffcommander processes video files with FFmpeg. A Job holds the same settings as the ffcommander commandline options
+// and Run processes the files of the job: the files are probed, crop values are detected, subtitles are repositioned and the video and audio are compressed.
+package ffcommander
 
 import (
-	"bytes"
 	"bufio"
-	"crypto/md5"
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
-	"log"
+	"math"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"sort"
 	"strconv"
 	"strings"
 	"time"
-)
 
+	"github.com/mhartzel/ffcommander/crop"
+	"github.com/mhartzel/ffcommander/encode"
+	"github.com/mhartzel/ffcommander/plan"
+	"github.com/mhartzel/ffcommander/probe"
+	"github.com/mhartzel/ffcommander/subtitle"
+)
 
 // Global variable definitions
 
 //////////////////////////////////////////////////////////////////////////////////////////
 // Defaults. Edit these to change program behavior                                      //
+// Most of these can also be changed in the config file without recompiling the program //
+// ($XDG_CONFIG_HOME/ffcommander/config.json), see: Config_file_struct and -show-config //
 //////////////////////////////////////////////////////////////////////////////////////////
 // Define default x264 processing profiles for different resolutions
 // Profiles are automatically selected based on video resolution
+// The preset can be changed on the commandline with -preset and -tune is added to the profiles with -tune
 var video_compression_options_sd = []string{"-c:v", "libx264", "-preset", "medium", "-profile:v", "main", "-level", "4.0"}
 var video_compression_options_hd = []string{"-c:v", "libx264", "-preset", "medium", "-profile:v", "high", "-level", "4.1"}
 var video_compression_options_ultra_hd_4k = []string{"-c:v", "libx264", "-preset", "medium", "-profile:v", "high", "-level", "6.1"}
 var video_compression_options_ultra_hd_8k = []string{"-c:v", "libx264", "-preset", "medium", "-profile:v", "high", "-level", "6.2"}
 var video_compression_options_lossless = []string{"-c:v", "utvideo"}
+
+// Define default x265 (HEVC) processing profiles for different resolutions. These are used with the -hevc option.
+// The libx265 encoder does not use the FFmpeg -level option, the level is defined in -x265-params instead.
+var video_compression_options_hevc_sd = []string{"-c:v", "libx265", "-preset", "medium", "-profile:v", "main", "-x265-params", "level-idc=3.1"}
+var video_compression_options_hevc_hd = []string{"-c:v", "libx265", "-preset", "medium", "-profile:v", "main", "-x265-params", "level-idc=4.1"}
+var video_compression_options_hevc_ultra_hd_4k = []string{"-c:v", "libx265", "-preset", "medium", "-profile:v", "main", "-x265-params", "level-idc=5.1"}
+var video_compression_options_hevc_ultra_hd_8k = []string{"-c:v", "libx265", "-preset", "medium", "-profile:v", "main", "-x265-params", "level-idc=6.1"}
+
+// Define VP9 processing profiles for different resolutions. These are used with the -webm option.
+// Tile columns are given as log2 of the number of columns. A tile must be at least 256 pixels wide,
+// so the number of tile columns is chosen based on the video resolution.
+var video_compression_options_vp9_sd = []string{"-c:v", "libvpx-vp9", "-deadline", "good", "-cpu-used", "2", "-row-mt", "1", "-tile-columns", "1"}
+var video_compression_options_vp9_hd = []string{"-c:v", "libvpx-vp9", "-deadline", "good", "-cpu-used", "2", "-row-mt", "1", "-tile-columns", "2"}
+var video_compression_options_vp9_ultra_hd_4k = []string{"-c:v", "libvpx-vp9", "-deadline", "good", "-cpu-used", "2", "-row-mt", "1", "-tile-columns", "3"}
+var video_compression_options_vp9_ultra_hd_8k = []string{"-c:v", "libvpx-vp9", "-deadline", "good", "-cpu-used", "2", "-row-mt", "1", "-tile-columns", "4"}
+
+// Define AV1 compression options. These are used with the -av1 option for all resolutions.
+// SVT-AV1 is used if FFmpeg has it, since it is many times faster than libaom. Libaom is used if SVT-AV1 is not available.
+var video_compression_options_av1_svt = []string{"-c:v", "libsvtav1", "-preset", "6"}
+var video_compression_options_av1_aom = []string{"-c:v", "libaom-av1", "-cpu-used", "4", "-row-mt", "1"}
+
+// AV1 is encoded with 10-bit color even when the source is 8-bit, this prevents banding in dark gradients
+var color_subsampling_options_av1 = []string{"-pix_fmt", "yuv420p10le"}
+
+// FFmpeg tags HEVC in mp4 as 'hev1' by default, Apple devices only play HEVC tagged as 'hvc1'
+var video_hevc_mp4_tag_options = []string{"-tag:v", "hvc1"}
 var audio_compression_options = []string{"-acodec", "copy"}
 var audio_compression_options_lossless = []string{"-acodec", "flac"}
 var denoise_options = []string{"hqdn3d=3.0:3.0:2.0:3.0"}
@@ -43,6 +79,18 @@ var color_subsampling_options = []string{"-pix_fmt", "yuv420p"}
 // For example: 1920 x 1080 = 2 073 600 pixels / 256 = bitrate 8100k
 var video_compression_bitrate_divider = 256
 
+// HEVC needs about half of the bitrate of H.264 for the same quality.
+// For example: 1920 x 1080 = 2 073 600 pixels / 512 = bitrate 4050k
+var video_compression_bitrate_divider_hevc = 512
+
+// VP9 needs about the same bitrate as HEVC.
+// For example: 1920 x 1080 = 2 073 600 pixels / 512 = bitrate 4050k
+var video_compression_bitrate_divider_vp9 = 512
+
+// AV1 needs less bitrate than HEVC for the same quality.
+// For example: 1920 x 1080 = 2 073 600 pixels / 640 = bitrate 3240k
+var video_compression_bitrate_divider_av1 = 640
+
 // Constant Quality CRF uses as much bitrate as is needed
 // to have the video quality be constant throughout the video.
 // CRF compression is much faster than 2-pass but it creates a larger file.
@@ -53,6 +101,32 @@ var video_compression_bitrate_divider = 256
 // 17 and 18 look the same to me and both seem equal to 2-pass with the default compression bitrates used in this program
 var crf_value = "18"
 
+// x265 CRF values are not the same as x264 values. x265 CRF 20 looks about the same as x264 CRF 18.
+var crf_value_hevc = "20"
+
+// VP9 CRF range is 0 - 63. 31 is the value recommended by Google for 1080p video.
+var crf_value_vp9 = "31"
+
+// AV1 CRF range is 0 - 63. Both SVT-AV1 and libaom produce good quality with 28.
+var crf_value_av1 = "28"
+
+// Quality target (-qt) finds the highest crf value that still reaches the quality the user wants.
+// Short sample windows spread evenly across the video are compressed with different crf values
+// and compared to the original with FFmpeg's ssim or psnr filter. The crf values are searched between min and max.
+var Quality_search_number_of_samples = 10
+var quality_search_sample_duration = plan.Timestamp_struct{Microseconds: 5 * 1000000}
+var quality_search_crf_min = 14
+var quality_search_crf_max = 30
+
+// Quality report (-qr) compares the processed video to the original frame by frame.
+// The frames are grouped to segments of this many seconds and the worst segments are listed in the report.
+var Quality_report_segment_seconds = 10
+var Quality_report_number_of_worst_segments = 10
+
+// Adaptive bitrate ladder (-abr) segment duration in seconds. A keyframe is forced at the start of every segment in all rungs,
+// so that players can switch between rungs at segment boundaries.
+var Abr_segment_seconds = 6
+
 // Default video processing. Possible values are "2-pass" and "crf"
 var default_video_processing = "2-pass" 
 // var default_video_processing = "crf" 
@@ -86,2411 +160,1015 @@ var default_max_threads = ""
 // Defaults ends here                                                                   //
 //////////////////////////////////////////////////////////////////////////////////////////
 
-
-
-var version_number string = "2.52" // This is the version of this program
-var Complete_stream_info_map = make(map[int][]string)
-var video_stream_info_map = make(map[string]string)
-var audio_stream_info_map = make(map[string]string)
-var subtitle_stream_info_map = make(map[string]string)
-var wrapper_info_map = make(map[string]string)
-var helptext_categories_map = make(map[string][]string) // The key is commandline option category (video, audio, subtitle) and the slice contains commandline options that belong to this category
-var commandline_option_map = make(map[string]*commandline_struct) // The key is the commandline option and the struct contains all variables and helptext belonging to that option
-var debug_option *bool
-
-type commandline_struct struct {
-	is_turned_on bool
-	option_type string
-	user_int int
-	user_string string
-	help_text string
+// Processing result of one input file. Files that fail are recorded here and processing continues with the next file.
+type file_processing_result_struct struct {
+	file_name string
+	processing_error error
+	elapsed_time time.Duration
 }
 
-// Create a slice for storing all video, audio and subtitle stream infos for each input file.
-// There can be many audio and subtitle streams in a file.
-var Complete_file_info_slice [][][][]string
-
-func run_external_command(command_to_run_str_slice []string) (stdout_output []string, stderr_output []string, error_code error) {
-
-	command_output_str := ""
-	stderror_output_str := ""
+type quality_report_struct struct {
+	File string `json:"file"`
+	Source string `json:"source"`
+	Ssim float64 `json:"ssim"`
+	Psnr float64 `json:"psnr"`
+	Segment_seconds int `json:"segment_seconds"`
+	Worst_segments []plan.Quality_segment_struct `json:"worst_segments"`
+}
 
-	// Create the struct needed for running the external command
-	command_struct := exec.Command(command_to_run_str_slice[0], command_to_run_str_slice[1:]...)
+// The config file ($XDG_CONFIG_HOME/ffcommander/config.json) changes the defaults defined at the beginning of this file.
+// The json keys are the names of the global variables. Keys missing from the file keep their default values.
+type Config_file_struct struct {
+	Default_video_processing string `json:"default_video_processing"`
+	Default_audio_processing string `json:"default_audio_processing"`
+	Default_max_threads string `json:"default_max_threads"`
+	Audio_bitrate_multiplier int `json:"audio_bitrate_multiplier"`
+	Video_compression_bitrate_divider int `json:"video_compression_bitrate_divider"`
+	Video_compression_bitrate_divider_hevc int `json:"video_compression_bitrate_divider_hevc"`
+	Video_compression_bitrate_divider_vp9 int `json:"video_compression_bitrate_divider_vp9"`
+	Video_compression_bitrate_divider_av1 int `json:"video_compression_bitrate_divider_av1"`
+	Crf_value string `json:"crf_value"`
+	Crf_value_hevc string `json:"crf_value_hevc"`
+	Crf_value_vp9 string `json:"crf_value_vp9"`
+	Crf_value_av1 string `json:"crf_value_av1"`
+	Denoise_options string `json:"denoise_options"`
+	Video_compression_options_sd []string `json:"video_compression_options_sd"`
+	Video_compression_options_hd []string `json:"video_compression_options_hd"`
+	Video_compression_options_ultra_hd_4k []string `json:"video_compression_options_ultra_hd_4k"`
+	Video_compression_options_ultra_hd_8k []string `json:"video_compression_options_ultra_hd_8k"`
+	Video_compression_options_lossless []string `json:"video_compression_options_lossless"`
+	Video_compression_options_hevc_sd []string `json:"video_compression_options_hevc_sd"`
+	Video_compression_options_hevc_hd []string `json:"video_compression_options_hevc_hd"`
+	Video_compression_options_hevc_ultra_hd_4k []string `json:"video_compression_options_hevc_ultra_hd_4k"`
+	Video_compression_options_hevc_ultra_hd_8k []string `json:"video_compression_options_hevc_ultra_hd_8k"`
+	Video_compression_options_vp9_sd []string `json:"video_compression_options_vp9_sd"`
+	Video_compression_options_vp9_hd []string `json:"video_compression_options_vp9_hd"`
+	Video_compression_options_vp9_ultra_hd_4k []string `json:"video_compression_options_vp9_ultra_hd_4k"`
+	Video_compression_options_vp9_ultra_hd_8k []string `json:"video_compression_options_vp9_ultra_hd_8k"`
+	Video_compression_options_av1_svt []string `json:"video_compression_options_av1_svt"`
+	Video_compression_options_av1_aom []string `json:"video_compression_options_av1_aom"`
+	Presets map[string]string `json:"presets"` // Named option sets selected with -preset-name, example: "dvd-anime": "-it -dn -sp -sr 0.8 -sgr -aac"
+}
 
-	// Run external command
-	var stdout, stderr bytes.Buffer
-	command_struct.Stdout = &stdout
-	command_struct.Stderr = &stderr
+func write_processing_log(log_file_absolute_path string, log_messages_str_slice []string) error {
 
-	error_code = command_struct.Run()
+	// Append to the logfile or if it does not exist create a new one.
+	if _, err := os.Stat(filepath.Dir(log_file_absolute_path)); os.IsNotExist(err) {
+		os.MkdirAll(filepath.Dir(log_file_absolute_path), 0777)
+	}
 
-	command_output_str = string(stdout.Bytes())
-	stderror_output_str = string(stderr.Bytes())
+	logfile_pointer, err := os.OpenFile(log_file_absolute_path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0755)
 
-	// Split the output of the command to lines and store in a slice
-	for _, line := range strings.Split(command_output_str, "\n") {
-		stdout_output = append(stdout_output, line)
+	if err != nil {
+		return errors.New("could not open logfile: " + log_file_absolute_path + " for writing: " + err.Error())
 	}
 
-	// Split the output of the stderr to lines and store in a slice
-	for _, line := range strings.Split(stderror_output_str, "\n") {
-		stderr_output = append(stderr_output, line)
+	defer logfile_pointer.Close()
+
+	// Write processing info to the file
+	if _, err = logfile_pointer.WriteString(strings.Join(log_messages_str_slice, "\n")); err != nil {
+		return errors.New("could not write to logfile: " + log_file_absolute_path + ": " + err.Error())
 	}
 
-	return stdout_output, stderr_output, error_code
+	return nil
 }
 
-func find_executable_path(filename string) (file_path string) {
+func print_processing_summary(processing_results []file_processing_result_struct) (number_of_failed_files int) {
 
-	/////////////////////////////////////////////////
-	// Test if executable can be found in the path //
-	/////////////////////////////////////////////////
+	// Print a table of files that were processed successfully and files that failed and return the number of failed files.
+	fmt.Println()
+	fmt.Println("Processing summary:")
+	fmt.Println("-------------------")
 
-	if _, error := exec.LookPath(filename); error != nil {
-		fmt.Println()
-		fmt.Println("Error, cant find program: " + filename + " in path, can't continue.")
+	for _, result := range processing_results {
 
+		status := "OK"
+		error_message := ""
 
-		if filename == "magick" || filename == "mogrify" {
-			fmt.Println(filename, "is part of package ImageMagick and is needed for the -sp functionality.")
+		if result.processing_error != nil {
+			status = "FAILED"
+			error_message = ": " + strings.Split(result.processing_error.Error(), "\n")[0]
+			number_of_failed_files++
 		}
 
-		fmt.Println()
-		os.Exit(1)
+		fmt.Printf("%-7s %12s  %s%s\n", status, result.elapsed_time.Round(time.Second), result.file_name, error_message)
 	}
 
-	return file_path
-}
+	fmt.Println()
+	fmt.Println(len(processing_results) - number_of_failed_files, "files processed successfully,", number_of_failed_files, "files failed.")
 
-func sort_raw_ffprobe_information(unsorted_ffprobe_information_str_slice []string) {
+	if number_of_failed_files > 0 {
+		fmt.Println("See 00-processing.log in the output directory of each failed file for details.")
+	}
 
-	// Parse ffprobe output, find video- and audiostream information in it,
-	// and store this info in the global map: Complete_stream_info_map
+	fmt.Println()
 
-	var stream_info_str_slice []string
-	var text_line_str_slice []string
-	var wrapper_info_str_slice []string
+	return number_of_failed_files
+}
 
-	var stream_number_int int
-	var stream_data_str string
-	var string_to_remove_str_slice []string
-	var error error // a variable named error of type error
+func Default_config() Config_file_struct {
+
+	// Store the current values of the global default variables in a config struct
+	var config Config_file_struct
+
+	config.Default_video_processing = default_video_processing
+	config.Default_audio_processing = default_audio_processing
+	config.Default_max_threads = default_max_threads
+	config.Audio_bitrate_multiplier = audio_bitrate_multiplier
+	config.Video_compression_bitrate_divider = video_compression_bitrate_divider
+	config.Video_compression_bitrate_divider_hevc = video_compression_bitrate_divider_hevc
+	config.Video_compression_bitrate_divider_vp9 = video_compression_bitrate_divider_vp9
+	config.Video_compression_bitrate_divider_av1 = video_compression_bitrate_divider_av1
+	config.Crf_value = crf_value
+	config.Crf_value_hevc = crf_value_hevc
+	config.Crf_value_vp9 = crf_value_vp9
+	config.Crf_value_av1 = crf_value_av1
+	config.Denoise_options = strings.Join(denoise_options, "")
+	config.Video_compression_options_sd = video_compression_options_sd
+	config.Video_compression_options_hd = video_compression_options_hd
+	config.Video_compression_options_ultra_hd_4k = video_compression_options_ultra_hd_4k
+	config.Video_compression_options_ultra_hd_8k = video_compression_options_ultra_hd_8k
+	config.Video_compression_options_lossless = video_compression_options_lossless
+	config.Video_compression_options_hevc_sd = video_compression_options_hevc_sd
+	config.Video_compression_options_hevc_hd = video_compression_options_hevc_hd
+	config.Video_compression_options_hevc_ultra_hd_4k = video_compression_options_hevc_ultra_hd_4k
+	config.Video_compression_options_hevc_ultra_hd_8k = video_compression_options_hevc_ultra_hd_8k
+	config.Video_compression_options_vp9_sd = video_compression_options_vp9_sd
+	config.Video_compression_options_vp9_hd = video_compression_options_vp9_hd
+	config.Video_compression_options_vp9_ultra_hd_4k = video_compression_options_vp9_ultra_hd_4k
+	config.Video_compression_options_vp9_ultra_hd_8k = video_compression_options_vp9_ultra_hd_8k
+	config.Video_compression_options_av1_svt = video_compression_options_av1_svt
+	config.Video_compression_options_av1_aom = video_compression_options_av1_aom
+	config.Presets = make(map[string]string)
+
+	return config
+}
 
-	// Collect information about all streams in the media file.
-	// The info is collected to stream specific slices and stored in map: Complete_stream_info_map
-	// The stream number is used as the map key when saving info slice to map
+// Job contains everything needed to process a set of files, it is the library equivalent of the commandline options.
+// The values are the same as the values of the commandline options: an empty string means that the option is not used.
+// Start from Default_job(), it has the default values of the options and the defaults from the config file.
+// Options turn on other options the same way as on the commandline, for example -sf turns on -mkv and -ls turns on -fe.
+type Job struct {
+	Input_files []string // Absolute paths of the files to process
+	Commandline []string // Written to the processing log of each file
+	Preset_name string // Written to the processing log when the options come from a preset
+	Preset_options string
+	Config Config_file_struct // Defaults that can be changed in the config file
 
-	for _, text_line := range unsorted_ffprobe_information_str_slice {
-		stream_number_int = -1
-		stream_info_str_slice = nil
+	// Audio options
+	Audio_language string // -a
+	Audio_all bool // -aall
+	Audio_map string // -amap
+	Audio_stream_number string // -an
+	Audio_compression_ac3 bool // -ac3
+	Audio_compression_aac bool // -aac
+	Audio_compression_opus bool // -opus
+	Audio_compression_flac bool // -flac
+	No_audio bool // -na
+	Audio_offset string // -ad
+	Downmix_to_stereo bool // -stereo
+	Add_stereo_downmix bool // -add-stereo
+	Loudness_normalization string // -norm
 
-		// If there are many programs in the file, then the stream information is listed twice by ffprobe,
-		// discard duplicate data.
-		if strings.HasPrefix(text_line, "programs.program") {
-			continue
-		}
+	// Video options
+	Adjust_black_point string // -abk
+	Autocrop bool // -ac
+	Adjust_chroma string // -ach
+	Adjust_gamma string // -agm
+	Adjust_white_point string // -awh
+	Abr string // -abr
+	Abr_format string // -abrf
+	Crf bool // -crf
+	Crf_value string // Value given after -crf
+	Denoise bool // -dn
+	Av1 bool // -av1
+	Hevc bool // -hevc
+	Grayscale bool // -gr
+	Inverse_telecine bool // -it
+	Main_bitrate string // -mbr
+	Video_preset string // -preset
+	No_deinterlace bool // -nd
+	Parallel_sd bool // -psd
+	Sd_bitrate string // -sbr
+	Quality_report bool // -qr
+	Quality_target string // -qt
+	Target_size string // -size
+	Sd_target_size string // -sdsize
+	Scale_to_sd bool // -ssd
+	Video_tune string // -tune
+	Burn_timecode bool // -tc
 
-		if strings.HasPrefix(text_line, "streams.stream") {
+	// Options that affect both video and audio
+	Force_lossless bool // -ls
 
-			text_line_str_slice = strings.Split(strings.Replace(text_line, "streams.stream.", "", 1), ".")
+	// Subtitle options
+	Subtitle_language string // -s
+	Subtitle_burn_downscale bool // -sd
+	Subtitle_burn_grayscale bool // -sgr
+	Subtitle_stream_number string // -sn
+	Subtitle_vertical_offset string // -so
+	Subtitle_mux_languages string // -sm
+	Subtitle_mux_numbers string // -smn
+	Subtitle_burn_palette string // -palette
+	Subtitle_burn_split bool // -sp
+	Subtitle_burn_resize string // -sr
 
-			// Convert stream number from string to int
-			error = nil
+	// Scan options
+	Fast_encode bool // -fe
+	Fast_search bool // -fs
+	Scan_mode_only bool // -scan
+	Split_times string // -sf
+	Search_start string // -st
+	Processing_stop_time string // -et
+	Processing_duration string // -d
 
-			if stream_number_int, error = strconv.Atoi(text_line_str_slice[0]); error != nil {
-				// Stream number could not be understood, skip the stream
-				continue
-			}
+	// Misc options
+	Debug bool // -debug
+	Use_matroska_container bool // -mkv
+	Use_webm_container bool // -webm
+	Only_print_commands bool // -print
+	Temp_file_directory string // -td
+}
 
-			// Remove the text "streams.stream." from the beginning of each text line
-			string_to_remove_str_slice = string_to_remove_str_slice[:0] // Clear the slice so that allocated slice ram space remains and is not garbage collected.
-			string_to_remove_str_slice = append(string_to_remove_str_slice, "streams.stream.", strconv.Itoa(stream_number_int), ".")
-			stream_data_str = strings.Replace(text_line, strings.Join(string_to_remove_str_slice, ""), "", 1) // Remove the unwanted string in front of the text line.
-			stream_data_str = strings.Replace(stream_data_str, "\"", "", -1)                                  // Remove " characters from the data.
+// Encoder and the compression options used for the video. The tables of the config file are replaced with the ones of the encoder selected with -hevc, -av1 or -webm.
+type video_encoder_struct struct {
+	name string
+	compression_options_sd []string
+	compression_options_hd []string
+	compression_options_ultra_hd_4k []string
+	compression_options_ultra_hd_8k []string
+	bitrate_divider int
+	crf_value string
+	quality_search_crf_min int
+	quality_search_crf_max int
+	color_subsampling_options []string
+}
 
-			// Add found stream info line to a slice with previously stored info
-			// and store it in a map. The stream number acts as the map key.
-			if _, item_found := Complete_stream_info_map[stream_number_int]; item_found == true {
-				stream_info_str_slice = Complete_stream_info_map[stream_number_int]
-			}
-			stream_info_str_slice = append(stream_info_str_slice, stream_data_str)
-			Complete_stream_info_map[stream_number_int] = stream_info_str_slice
-		}
+func Default_job() Job {
 
-		// Get media file wrapper information and store it in a slice.
-		if strings.HasPrefix(text_line, "format") {
-			wrapper_info_str_slice = strings.Split(strings.Replace(text_line, "format.", "", 1), "=")
-			wrapper_key := strings.TrimSpace(wrapper_info_str_slice[0])
-			wrapper_value := strings.TrimSpace(strings.Replace(wrapper_info_str_slice[1], "\"", "", -1)) // Remove whitespace and " charcters from the data
-			wrapper_info_map[wrapper_key] = wrapper_value
-		}
+	// Return a job with the default values of the commandline options and the defaults defined at the beginning of this file
+	return Job{
+		Config: Default_config(),
+		Audio_stream_number: "0",
+		Abr_format: "hls",
+		Subtitle_stream_number: "-1",
+		Subtitle_vertical_offset: "0",
 	}
 }
 
-func get_video_and_audio_stream_information(file_name string) {
-
-	// Find video and audio stream information and store it as key value pairs in video_stream_info_map and audio_stream_info_map.
-	// Discard info about streams that are not audio or video
-	var stream_info_slice [][][]string
-	var single_video_stream_info_slice []string
-	var all_video_streams_info_slice [][]string
-	var single_audio_stream_info_slice []string
-	var all_audio_streams_info_slice [][]string
-	var single_subtitle_stream_info_slice []string
-	var all_subtitle_streams_info_slice [][]string
-	var stream_type_is_video bool = false
-	var stream_type_is_audio bool = false
-	var stream_type_is_subtitle = false
-	var stream_info_str_slice []string
-
-	// Find text lines in FFprobe info that indicates if this stream is: video, audio or subtitle
-	// and store each stream info in a type specific (video, audio and subtitle) slice that
-	// in turn gets stored in a slice containing all video, audio or subtitle specific info.
-
-	// First get dictionary keys and sort them
-	var dictionary_keys []int
-
-	for key := range Complete_stream_info_map {
-		dictionary_keys = append(dictionary_keys, key)
-	}
-
-	sort.Ints(dictionary_keys)
+func apply_implied_options(job Job) Job {
 
-	for _, dictionary_key := range dictionary_keys {
-		stream_info_str_slice = Complete_stream_info_map[dictionary_key]
-
-		stream_type_is_video = false
-		stream_type_is_audio = false
-		stream_type_is_subtitle = false
-		single_video_stream_info_slice = nil
-		single_audio_stream_info_slice = nil
-		single_subtitle_stream_info_slice = nil
-
-		// Find a line in FFprobe output that indicates this is a video stream
-		for _, text_line := range stream_info_str_slice {
-
-			if strings.Contains(text_line, "codec_type=video") {
-				stream_type_is_video = true
-			}
-		}
+	// Turn on the options that are implied by other options on the commandline
+	if job.Force_lossless == true {
+		job.Fast_encode = true // Always use 1-pass encoding with lossless encoding
+	}
 
-		// Find a line in FFprobe output that indicates this is a audio stream
-		for _, text_line := range stream_info_str_slice {
+	if job.Parallel_sd == true || job.Scale_to_sd == true {
+		job.Fast_search = true // SD processing requires placing the seek before the inputfile. This results using the fast but sometimes inaccurate FFmpeg seek.
+	}
 
-			if strings.Contains(text_line, "codec_type=audio") {
-				stream_type_is_audio = true
-			}
-		}
+	if job.Quality_target != "" {
+		job.Crf = true
+	}
 
-		// Find a line in FFprobe output that indicates this is a subtitle stream
-		for _, text_line := range stream_info_str_slice {
+	if job.Split_times != "" {
+		job.Use_matroska_container = true // Split parts are joined in a matroska file
+	}
 
-			if strings.Contains(text_line, "codec_type=subtitle") {
-				stream_type_is_subtitle = true
-			}
-		}
+	if job.Use_webm_container == true {
+		job.Audio_compression_opus = true // WebM only supports Opus and Vorbis audio
+	}
 
-		// Store each video stream info text line in a slice and these slices in a slice that collects info for every video stream in the file.
-		if stream_type_is_video == true {
+	return job
+}
 
-			for _, text_line := range stream_info_str_slice {
+func apply_default_video_processing(job Job) Job {
 
-				temp_slice := strings.Split(text_line, "=")
-				video_key := strings.TrimSpace(temp_slice[0])
-				video_value := strings.TrimSpace(temp_slice[1])
-				video_stream_info_map[video_key] = video_value
-			}
+	// A bitrate, file size or adaptive bitrate ladder given on the commandline overrides the default crf processing
+	if job.Config.Default_video_processing == "crf" && job.Main_bitrate == "" && job.Target_size == "" && job.Force_lossless == false && job.Abr == "" {
+		job.Crf = true
+	}
 
-			devidend_str := ""
-			devisor_str := ""
-			devidend_int := 0
-			devisor_int := 0
-			var error_happened error
-			var result float64
+	return job
+}
 
-			frame_rate_str := video_stream_info_map["r_frame_rate"]
-			frame_rate_average_str := video_stream_info_map["avg_frame_rate"]
+func select_video_encoder(job Job, ffmpeg_encoders map[string]bool) (video_encoder video_encoder_struct, error_code error) {
+
+	// Start with the x264 tables and replace them with the tables of the encoder selected with -hevc, -av1 or -webm.
+	// The user given preset, tune and crf value are applied to the tables. The values must have been checked with Check_video_encoder_options.
+	// ffmpeg_encoders is the encoder list from encode.Get_ffmpeg_encoders, it is only needed with -av1. When it is nil SVT-AV1 is selected.
+	video_encoder = video_encoder_struct{
+		name: "libx264",
+		compression_options_sd: job.Config.Video_compression_options_sd,
+		compression_options_hd: job.Config.Video_compression_options_hd,
+		compression_options_ultra_hd_4k: job.Config.Video_compression_options_ultra_hd_4k,
+		compression_options_ultra_hd_8k: job.Config.Video_compression_options_ultra_hd_8k,
+		bitrate_divider: job.Config.Video_compression_bitrate_divider,
+		crf_value: job.Config.Crf_value,
+		quality_search_crf_min: quality_search_crf_min,
+		quality_search_crf_max: quality_search_crf_max,
+		color_subsampling_options: color_subsampling_options,
+	}
+
+	// Replace x264 profiles, bitrate divider and crf value with HEVC ones
+	if job.Hevc == true {
+		video_encoder.name = "libx265"
+		video_encoder.compression_options_sd = job.Config.Video_compression_options_hevc_sd
+		video_encoder.compression_options_hd = job.Config.Video_compression_options_hevc_hd
+		video_encoder.compression_options_ultra_hd_4k = job.Config.Video_compression_options_hevc_ultra_hd_4k
+		video_encoder.compression_options_ultra_hd_8k = job.Config.Video_compression_options_hevc_ultra_hd_8k
+		video_encoder.bitrate_divider = job.Config.Video_compression_bitrate_divider_hevc
+		video_encoder.crf_value = job.Config.Crf_value_hevc
+		video_encoder.quality_search_crf_min = 16
+		video_encoder.quality_search_crf_max = 32
+	}
+
+	// Replace x264 profiles, bitrate divider, crf value and color subsampling with AV1 ones.
+	// Use SVT-AV1 if FFmpeg has it, otherwise fall back to libaom.
+	// SVT-AV1 is returned with the error if no AV1 encoder is found, so that the other options can be checked against its limits.
+	if job.Av1 == true {
+
+		av1_compression_options := job.Config.Video_compression_options_av1_svt
+		video_encoder.name = "libsvtav1"
+
+		if ffmpeg_encoders == nil || ffmpeg_encoders["libsvtav1"] == true {
+			// SVT-AV1 is the default
+
+		} else if ffmpeg_encoders["libaom-av1"] == true {
+			video_encoder.name = "libaom-av1"
+			av1_compression_options = job.Config.Video_compression_options_av1_aom
 
-			// Frame rate may be displayed by ffprobe in the form of a division like: 300000/1001. Do the calculation to get the human redable frame rate.
-			index := strings.Index(frame_rate_str, "/")
+		} else {
+			error_code = errors.New("FFmpeg does not have an AV1 encoder (libsvtav1 or libaom-av1), can't use the -av1 option")
+		}
 
-			if index > 0 {
-				temp_slice := strings.Split(frame_rate_str, "/")
-				devidend_str = temp_slice[0]
-				devisor_str = temp_slice[1]
+		video_encoder.compression_options_sd = av1_compression_options
+		video_encoder.compression_options_hd = av1_compression_options
+		video_encoder.compression_options_ultra_hd_4k = av1_compression_options
+		video_encoder.compression_options_ultra_hd_8k = av1_compression_options
+		video_encoder.bitrate_divider = job.Config.Video_compression_bitrate_divider_av1
+		video_encoder.crf_value = job.Config.Crf_value_av1
+		video_encoder.quality_search_crf_min = 20
+		video_encoder.quality_search_crf_max = 50
+		video_encoder.color_subsampling_options = color_subsampling_options_av1
+	}
 
-				devidend_int, error_happened = strconv.Atoi(devidend_str)
+	// Replace x264 profiles, bitrate divider and crf value with VP9 ones
+	if job.Use_webm_container == true {
+		video_encoder.name = "libvpx-vp9"
+		video_encoder.compression_options_sd = job.Config.Video_compression_options_vp9_sd
+		video_encoder.compression_options_hd = job.Config.Video_compression_options_vp9_hd
+		video_encoder.compression_options_ultra_hd_4k = job.Config.Video_compression_options_vp9_ultra_hd_4k
+		video_encoder.compression_options_ultra_hd_8k = job.Config.Video_compression_options_vp9_ultra_hd_8k
+		video_encoder.bitrate_divider = job.Config.Video_compression_bitrate_divider_vp9
+		video_encoder.crf_value = job.Config.Crf_value_vp9
+		video_encoder.quality_search_crf_min = 20
+		video_encoder.quality_search_crf_max = 50
+	}
 
-				if error_happened == nil {
-					devisor_int, error_happened = strconv.Atoi(devisor_str)
-				}
+	////////////////////////////////////////////////////////////////////////
+	// Apply user given preset, tune and crf value to the encoder options //
+	////////////////////////////////////////////////////////////////////////
+	encoder_preset_option := encode.Video_encoder_limits[video_encoder.name].Preset_option
 
-				if error_happened == nil {
-					result = float64(devidend_int) / float64(devisor_int)
-					frame_rate_str = strconv.FormatFloat(result, 'f', 3, 64)
-				}
-			}
+	if job.Video_preset != "" {
+		video_encoder.compression_options_sd = encode.Set_encoder_option(video_encoder.compression_options_sd, encoder_preset_option, job.Video_preset)
+		video_encoder.compression_options_hd = encode.Set_encoder_option(video_encoder.compression_options_hd, encoder_preset_option, job.Video_preset)
+		video_encoder.compression_options_ultra_hd_4k = encode.Set_encoder_option(video_encoder.compression_options_ultra_hd_4k, encoder_preset_option, job.Video_preset)
+		video_encoder.compression_options_ultra_hd_8k = encode.Set_encoder_option(video_encoder.compression_options_ultra_hd_8k, encoder_preset_option, job.Video_preset)
+	}
 
-			if error_happened != nil {
-				fmt.Println("Info: could not convert frame rate info from ffprobe's output to integer")
-			}
+	if job.Video_tune != "" {
+		video_encoder.compression_options_sd = encode.Set_encoder_option(video_encoder.compression_options_sd, "-tune", job.Video_tune)
+		video_encoder.compression_options_hd = encode.Set_encoder_option(video_encoder.compression_options_hd, "-tune", job.Video_tune)
+		video_encoder.compression_options_ultra_hd_4k = encode.Set_encoder_option(video_encoder.compression_options_ultra_hd_4k, "-tune", job.Video_tune)
+		video_encoder.compression_options_ultra_hd_8k = encode.Set_encoder_option(video_encoder.compression_options_ultra_hd_8k, "-tune", job.Video_tune)
+	}
 
-			// Average frame rate may be displayed by ffprobe in the form of a division like: 300000/1001. Do the calculation to get the human redable frame rate.
-			devidend_str = ""
-			devisor_str = ""
-			devidend_int = 0
-			devisor_int = 0
-			result = 0.0
-			error_happened = nil
+	if job.Crf_value != "" {
+		video_encoder.crf_value = job.Crf_value
+	}
 
-			index = strings.Index(frame_rate_average_str, "/")
+	return video_encoder, error_code
+}
 
-			if index > 0 {
-				temp_slice := strings.Split(frame_rate_average_str, "/")
-				devidend_str = temp_slice[0]
-				devisor_str = temp_slice[1]
+// An option validator checks the value of an option in the job.
+// The validator returns the value in the form it is used in processing, or an error describing what is wrong with the value.
+type option_validator func(value string) (string, error)
 
-				devidend_int, error_happened = strconv.Atoi(devidend_str)
+func float_between_limits_validator(min_value_str string, max_value_str string) option_validator {
 
-				if error_happened == nil {
-					devisor_int, error_happened = strconv.Atoi(devisor_str)
-				}
+	// Return a validator that accepts a decimal number between min and max values (limits included).
+	// The value is returned in its shortest form without extra zeros: .50 becomes 0.5
+	min_value, _ := strconv.ParseFloat(min_value_str, 64)
+	max_value, _ := strconv.ParseFloat(max_value_str, 64)
 
-				if error_happened == nil {
-					result = float64(devidend_int) / float64(devisor_int)
-					frame_rate_average_str = strconv.FormatFloat(result, 'f', 3, 64)
-				}
-			}
+	return func(value string) (string, error) {
 
-			if error_happened != nil {
-				fmt.Println("Info: could not convert average frame rate info from ffprobe's output to integer")
-			}
+		value_float, float_parse_error := strconv.ParseFloat(value, 64)
 
-			// Add also duration from wrapper information to the video info.
-			single_video_stream_info_slice = append(single_video_stream_info_slice, file_name, video_stream_info_map["width"], video_stream_info_map["height"], wrapper_info_map["duration"], video_stream_info_map["codec_name"], video_stream_info_map["pix_fmt"], video_stream_info_map["color_space"], frame_rate_str, frame_rate_average_str)
-			all_video_streams_info_slice = append(all_video_streams_info_slice, single_video_stream_info_slice)
+		if float_parse_error != nil || math.IsNaN(value_float) == true {
+			return value, errors.New("'" + value + "' is not a number")
 		}
 
-		// Store each audio stream info text line in a slice and these slices in a slice that collects info for every audio stream in the file.
-		if stream_type_is_audio == true {
-
-			for _, text_line := range stream_info_str_slice {
-
-				temp_slice := strings.Split(text_line, "=")
-				audio_key := strings.TrimSpace(temp_slice[0])
-				audio_value := strings.TrimSpace(temp_slice[1])
-				audio_stream_info_map[audio_key] = audio_value
-			}
-
-			single_audio_stream_info_slice = append(single_audio_stream_info_slice, audio_stream_info_map["tags.language"])
-			single_audio_stream_info_slice = append(single_audio_stream_info_slice, audio_stream_info_map["disposition.visual_impaired"])
-			single_audio_stream_info_slice = append(single_audio_stream_info_slice, audio_stream_info_map["channels"])
-			single_audio_stream_info_slice = append(single_audio_stream_info_slice, audio_stream_info_map["sample_rate"])
-			single_audio_stream_info_slice = append(single_audio_stream_info_slice, audio_stream_info_map["codec_name"])
-			all_audio_streams_info_slice = append(all_audio_streams_info_slice, single_audio_stream_info_slice)
+		if value_float < min_value || value_float > max_value {
+			return value, errors.New("value must be between " + min_value_str + " and " + max_value_str)
 		}
 
-		// Store each subtitle stream info text line in a slice and these slices in a slice that collects info for every subtitle stream in the file.
-		if stream_type_is_subtitle == true {
+		return strconv.FormatFloat(value_float, 'f', -1, 64), nil
+	}
+}
 
-			for _, text_line := range stream_info_str_slice {
+func bitrate_validator(value string) (string, error) {
 
-				temp_slice := strings.Split(text_line, "=")
-				subtitle_key := strings.TrimSpace(temp_slice[0])
-				subtitle_value := strings.TrimSpace(temp_slice[1])
-				subtitle_stream_info_map[subtitle_key] = subtitle_value
+	// Bitrate must be given in kilobits with the 'k' suffix: 8000k
+	if len(value) < 2 || strings.ToLower(value[len(value)-1:]) != "k" {
+		return value, errors.New("bitrate must be given in the form of: 8000k don't forget the 'k' at the end of the value")
+	}
 
-			}
+	number_int, atoi_error := strconv.Atoi(value[0:len(value)-1])
 
-			single_subtitle_stream_info_slice = append(single_subtitle_stream_info_slice, subtitle_stream_info_map["tags.language"])
-			single_subtitle_stream_info_slice = append(single_subtitle_stream_info_slice, subtitle_stream_info_map["disposition.hearing_impaired"])
-			single_subtitle_stream_info_slice = append(single_subtitle_stream_info_slice, subtitle_stream_info_map["codec_name"])
-			all_subtitle_streams_info_slice = append(all_subtitle_streams_info_slice, single_subtitle_stream_info_slice)
-		}
+	if atoi_error != nil {
+		return value, errors.New("cannot convert bitrate " + value + " to a number")
 	}
 
-	// If the input file does not have any video streams in it, store dummy information about a video stream with with and height set to 0 pixels.
-	// This will trigger an error message about the file in the main routine (we can't process a file without video)
-	if len(all_video_streams_info_slice) == 0 {
-		single_video_stream_info_slice = append(single_video_stream_info_slice, file_name, "0", "0")
-		all_video_streams_info_slice = append(all_video_streams_info_slice, single_video_stream_info_slice)
+	if number_int < 1 || number_int > 1000000 {
+		return value, errors.New("bitrate must be between 1k and 1000000k")
 	}
 
-	stream_info_slice = append(stream_info_slice, all_video_streams_info_slice, all_audio_streams_info_slice, all_subtitle_streams_info_slice)
-	Complete_file_info_slice = append(Complete_file_info_slice, stream_info_slice)
-	Complete_stream_info_map = make(map[int][]string) // Clear out stream info map by creating a new one with the same name. We collect information to this map for one input file and need to clear it between processing files.
-
-	// Complete_file_info_slice contains one slice for each input file.
-	//
-	// The contents is when info for one file is stored: [ [ [/home/mika/Downloads/dvb_stream.ts 720 576 64.123411, h264, yuv420p, bt709, 25, 24]]  [[eng 0 2 48000 ac3]  [dut 1 2 48000 pcm_s16le]]  [[fin 0 dvb_subtitle]  [fin 0 dvb_teletext] ] ]
-	//
-	// The file path is: /home/mika/Downloads/dvb_stream.ts
-	// Video width is: 720 pixels and height is: 576 pixels and the duration is: 64.123411 seconds.
-	// Video codec is: h264
-	// Color subsampling is: yuv420p
-	// Color space: bt709
-	// Frame rate is 25
-	// Average frame rate is 24
-	//
-	// The input file has two audio streams (languages: eng and dut)
-	// Audio stream 0: language is: english, audio is for for visually impared = 0 (false), there are 2 audio channels in the stream and sample rate is 48000 and audio codec is ac3.
-	// Audio stream 1: language is: dutch, audio is for visually impared = 1 (true), there are 2 audio channels in the stream and sample rate is 48000 and audio codec is pcm_s16le.
-	//
-	// The input file has two subtitle streams
-	// Subtitle stream 0: language is: finnish, subtitle is for hearing impared = 0 (false), the subtitle codec is: dvb (bitmap)
-	// Subtitle stream 1: language is: finnish, subtitle is for hearing impared = 0 (false), the subtitle codec is: teletext
-	//
-
-	return
+	return value, nil
 }
 
-func convert_timecode_to_seconds(timestring string) (string, string) {
-	var hours_int, minutes_int, seconds_int, seconds_total_int int
-	var hours_str, minutes_str, seconds_str, milliseconds_str string
-	var seconds_total_str, error_happened string
-
-	if strings.ContainsAny(timestring, ".") {
-		milliseconds_str = strings.Split(timestring, ".")[1]
-		timestring = strings.Replace(timestring, "." + milliseconds_str, "", 1)
+func language_code_validator(value string) (string, error) {
 
-		// Truncate milliseconds to 3 digits
-		if len(milliseconds_str) > 3 {
-			milliseconds_str = milliseconds_str[0:3]
-		}
+	// Language codes are 2 or 3 letters long (ISO 639): en, eng, fin, ita
+	if len(value) < 2 || len(value) > 3 || strings.Trim(strings.ToLower(value), "abcdefghijklmnopqrstuvwxyz") != "" {
+		return value, errors.New("'" + value + "' is not a language code. Use a language code like: eng, fin, ita")
 	}
 
-	temp_str_slice := strings.Split(timestring, ":")
-
-	if len(temp_str_slice) == 3 {
-		hours_str = temp_str_slice[0]
-		hours_int, _ = strconv.Atoi(hours_str)
-		minutes_str = temp_str_slice[1]
-		minutes_int, _ = strconv.Atoi(minutes_str)
-		seconds_str = temp_str_slice[2]
-		seconds_int, _ = strconv.Atoi(seconds_str)
-	} else if len(temp_str_slice) == 2 {
-		minutes_str = temp_str_slice[0]
-		minutes_int, _ = strconv.Atoi(minutes_str)
-		seconds_str = temp_str_slice[1]
-		seconds_int, _ = strconv.Atoi(seconds_str)
-	} else if len(temp_str_slice) == 1 {
-		seconds_str = temp_str_slice[0]
-		seconds_int, _ = strconv.Atoi(seconds_str)
-	} else if len(temp_str_slice) == 0 {
-		error_happened = "Could not interpret file split values"
-	}
+	return value, nil
+}
+
+func language_code_list_validator(value string) (string, error) {
 
-	if len(error_happened) == 0 {
-		seconds_total_int = (hours_int * 60 * 60) + (minutes_int * 60) + seconds_int
-		seconds_total_str = strconv.Itoa(seconds_total_int)
+	// Comma separated list of language codes: eng,fra,fin
+	for _, language_code := range strings.Split(value, ",") {
 
-		if milliseconds_str != "" {
-			seconds_total_str = seconds_total_str + "." + milliseconds_str
+		if _, validation_error := language_code_validator(language_code); validation_error != nil {
+			return value, validation_error
 		}
 	}
 
-	return seconds_total_str, error_happened
+	return value, nil
 }
 
-func convert_cut_positions_to_timecode(cut_positions_after_processing_seconds []string) []string {
-
-	var cut_positions_as_timecodes []string
-
-	for counter, item := range cut_positions_after_processing_seconds {
-
-		// Remove the first edit point if it is zero, as this really is no edit point
-		if counter == 0 && item == "0" {
-			continue
-		}
+func integer_validator(value string) (string, error) {
 
-		timecode := convert_seconds_to_timecode(item)
-
-		cut_positions_as_timecodes = append(cut_positions_as_timecodes, timecode)
+	if _, atoi_error := strconv.Atoi(value); atoi_error != nil {
+		return value, errors.New("'" + value + "' is not a whole number")
 	}
 
-	return cut_positions_as_timecodes
+	return value, nil
 }
 
-func convert_seconds_to_timecode(item string) string {
-
-	item_str := item
-	milliseconds_str := ""
+func abr_rungs_validator(value string) (string, error) {
 
-	if strings.ContainsAny(item, ".") {
-		item_str = strings.Split(item, ".")[0]
-		milliseconds_str = strings.Split(item, ".")[1]
-	}
+	// Comma separated list of rung heights for the adaptive bitrate ladder: 1080,720,480,360
+	// The list is returned sorted from the highest to the lowest rung.
+	var rung_heights []int
 
-	item_int, _ := strconv.Atoi(item_str)
-	hours_int := 0
-	minutes_int := 0
-	seconds_int := 0
-	timecode := ""
+	for _, rung_height_str := range strings.Split(value, ",") {
 
-	if item_int/3600 > 0 {
-		hours_int = item_int / 3600
-		item_int = item_int - (hours_int * 3600)
-	}
+		rung_height, atoi_error := strconv.Atoi(rung_height_str)
 
-	if item_int/60 > 0 {
-		minutes_int = item_int / 60
-		item_int = item_int - (minutes_int * 60)
-	}
-	seconds_int = item_int
+		if atoi_error != nil {
+			return value, errors.New("'" + rung_height_str + "' in '" + value + "' is not a whole number")
+		}
 
-	hours_str := strconv.Itoa(hours_int)
+		if rung_height < 144 || rung_height > 4320 || rung_height % 2 != 0 {
+			return value, errors.New("rung height " + rung_height_str + " must be an even number between 144 and 4320")
+		}
 
-	if len(hours_str) < 2 {
-		hours_str = "0" + hours_str
-	}
+		for _, previous_rung_height := range rung_heights {
 
-	minutes_str := strconv.Itoa(minutes_int)
+			if rung_height == previous_rung_height {
+				return value, errors.New("rung height " + rung_height_str + " is given twice")
+			}
+		}
 
-	if len(minutes_str) < 2 {
-		minutes_str = "0" + minutes_str
+		rung_heights = append(rung_heights, rung_height)
 	}
 
-	seconds_str := strconv.Itoa(seconds_int)
+	sort.Sort(sort.Reverse(sort.IntSlice(rung_heights)))
+
+	var rung_heights_str []string
 
-	if len(seconds_str) < 2 {
-		seconds_str = "0" + seconds_str
+	for _, rung_height := range rung_heights {
+		rung_heights_str = append(rung_heights_str, strconv.Itoa(rung_height))
 	}
 
-	timecode = hours_str + ":" + minutes_str + ":" + seconds_str
+	return strings.Join(rung_heights_str, ","), nil
+}
+
+func loudness_target_validator(value string) (string, error) {
 
-	if len(milliseconds_str) > 0 {
-		timecode = timecode + "." + milliseconds_str
+	// Integrated loudness target in LUFS: -23 (EBU R128 broadcast) or -16 (streaming). The names broadcast and streaming can be used too.
+	switch value {
+	case "broadcast":
+		return "-23", nil
+	case "streaming":
+		return "-16", nil
 	}
 
-	return timecode
+	return float_between_limits_validator("-70", "-5")(value)
 }
 
-func process_split_times(split_times string, debug_option bool) ([]string, []string) {
+func audio_offset_validator(value string) (string, error) {
 
-	var cut_list_seconds_str_slice, cut_list_positions_and_durations_seconds, cut_positions_after_processing_seconds, cut_positions_as_timecodes []string
-	var seconds_total_str, error_happened string
+	// Audio offset in milliseconds, positive delays and negative advances the audio: 300, -250
+	offset_milliseconds, atoi_error := strconv.Atoi(value)
 
-	split_times = strings.ReplaceAll(split_times, "-", ",")
-	cut_list_string_slice := strings.Split(split_times, ",")
-
-	if len(cut_list_string_slice)%2 != 0 {
-		fmt.Println("\nError: Split timecodes must be given in pairs (start_time, stop_time). There are:", len(cut_list_string_slice), "times on the commandline\n")
-		os.Exit(1)
+	if atoi_error != nil {
+		return value, errors.New("'" + value + "' is not a whole number of milliseconds")
 	}
 
-	if debug_option == true {
-		fmt.Println("")
-		fmt.Println("process_split_times: cut_list_string_slice:", cut_list_string_slice, "len(cut_list_string_slice):", len(cut_list_string_slice))
+	if offset_milliseconds < -60000 || offset_milliseconds > 60000 {
+		return value, errors.New("offset must be between -60000 and 60000 milliseconds")
 	}
 
-	//////////////////////////////////////////////////////////////
-	// Convert time values (01:20:25) to seconds (4825 seconds) //
-	//////////////////////////////////////////////////////////////
-	for _, temp_string := range cut_list_string_slice {
-
-		if strings.ToLower(temp_string) == "start" {
-			temp_string = "0"
-		}
+	return strconv.Itoa(offset_milliseconds), nil
+}
 
-		if strings.ToLower(temp_string) == "end" {
-			cut_list_seconds_str_slice = append(cut_list_seconds_str_slice, strings.ToLower(temp_string))
-			break
-		}
+func abr_format_validator(value string) (string, error) {
 
-		temp_string = sanitize_float(temp_string, debug_option)
+	// Comma separated list of adaptive bitrate streaming formats: hls, hlsts and dash.
+	// HLS and DASH can share fMP4 segments, TS segments can only be used with HLS.
+	formats := make(map[string]bool)
 
-		seconds_total_str, error_happened = convert_timecode_to_seconds(temp_string)
+	for _, format := range strings.Split(value, ",") {
 
-		if error_happened != "" {
-			fmt.Println("\nError when converting times to seconds: " + error_happened + "\n")
-			os.Exit(1)
+		if format != "hls" && format != "hlsts" && format != "dash" {
+			return value, errors.New("'" + format + "' is not a streaming format. Use hls, hlsts or dash")
 		}
-		cut_list_seconds_str_slice = append(cut_list_seconds_str_slice, seconds_total_str)
+
+		formats[format] = true
+	}
+
+	if formats["hlsts"] == true && (formats["hls"] == true || formats["dash"] == true) {
+		return value, errors.New("hlsts (HLS with TS segments) can't be combined with hls or dash, they use fMP4 segments")
 	}
 
-	///////////////////////////////////////////////////////////
-	// Test that all times are ascending and not overlapping //
-	///////////////////////////////////////////////////////////
+	return value, nil
+}
 
-	var previous_item string
+func audio_map_validator(value string) (string, error) {
 
-	if debug_option == true {
-		fmt.Println("")
-		fmt.Println("process_split_times: cut_list_seconds_str_slice:", cut_list_seconds_str_slice)
+	if _, parse_error := plan.Parse_audio_map(value); parse_error != nil {
+		return value, parse_error
 	}
 
-	for _, current_item := range cut_list_seconds_str_slice {
-
-		if current_item == "end" {
-			break
-		}
-		_, remaining_float := custom_float_substraction(current_item, previous_item)
+	return value, nil
+}
 
-		if remaining_float < 0.0 {
-			var temp_str_slice []string
-			temp_str_slice = append(temp_str_slice, previous_item, current_item)
-			temp_2_str_slice := convert_cut_positions_to_timecode(temp_str_slice)
+func integer_list_validator(value string) (string, error) {
 
-			if debug_option == true {
-				fmt.Println("process_split_times: temp_str_slice:", temp_str_slice, "process_split_times: temp_2_str_slice:", temp_2_str_slice)
-			}
+	// Comma separated list of whole numbers: 3,1,7
+	for _, number := range strings.Split(value, ",") {
 
-			fmt.Println("\nError: times " + temp_2_str_slice[0] + " and " + temp_2_str_slice[1] + " are not in ascending order. Timecodes must be ascending and not overlap\n")
-			os.Exit(1)
+		if _, atoi_error := strconv.Atoi(number); atoi_error != nil {
+			return value, errors.New("'" + number + "' in '" + value + "' is not a whole number")
 		}
-		previous_item = current_item
 	}
 
-	///////////////////////////////////////////////////////////////////////////////////////////
-	// Convert odd time values to duration. Even values are start times and used as they are //
-	///////////////////////////////////////////////////////////////////////////////////////////
+	return value, nil
+}
 
-	for counter := 0; counter < len(cut_list_seconds_str_slice); counter = counter + 2 {
+func hex_list_validator(value string) (string, error) {
 
-		start_time_string := ""
-		stop_time_string := ""
+	// Comma separated list of 1 to 16 hex characters ranging from 0 to f: f,0,f
+	hex_list := strings.Split(value, ",")
 
-		// Store the start time as it is
-		cut_list_positions_and_durations_seconds = append(cut_list_positions_and_durations_seconds, cut_list_seconds_str_slice[counter])
-		start_time_string = cut_list_seconds_str_slice[counter]
+	if len(hex_list) > 16 {
+		return value, errors.New("too many (" + strconv.Itoa(len(hex_list)) + ") hex characters. Please give 1 to 16 characters")
+	}
 
-		if len(cut_list_seconds_str_slice)-1 > counter {
-			stop_time_string = cut_list_seconds_str_slice[counter+1]
+	for _, character := range hex_list {
 
+		if character == "" {
+			return value, errors.New("illegal character: 'empty'. Values must be hex ranging from 0 to f")
 		}
 
-		// If word 'end' is used to mark the end of file, then remove it, FFmpeg automatically processes to the end of file if the last duration is left out
-		if strings.ToLower(stop_time_string) == "end" {
-			break
+		if len(character) != 1 || strings.Trim(strings.ToLower(character), "0123456789abcdef") != "" {
+			return value, errors.New("illegal character: " + character + ". Values must be hex ranging from 0 to f")
 		}
+	}
 
-		duration_str, remaining_float := custom_float_substraction(stop_time_string, start_time_string)
+	return value, nil
+}
 
-		if remaining_float < 0.0 {
-			fmt.Println("\nError: Stop time:", stop_time_string, "cannot be less than start time:", start_time_string)
-			fmt.Println("All times must be absolute timecode positions NOT start times and durations\n")
-			os.Exit(1)
-		}
+func timestamp_validator(value string) (string, error) {
 
-		// Store the duration
-		cut_list_positions_and_durations_seconds = append(cut_list_positions_and_durations_seconds, duration_str)
+	// SMPTE timecodes and frame numbers are converted in Run with the frame rate of each file
+	if timestamp_error := plan.Check_timestamp(value); timestamp_error != nil {
+		return value, timestamp_error
 	}
 
-	//////////////////////////////////////////////////////////////////////////////////////////////////
-	// Calculate where edit points are in the processed file so that the user can check them easily //
-	//////////////////////////////////////////////////////////////////////////////////////////////////
+	return value, nil
+}
 
-	if len(cut_list_seconds_str_slice) > 2 {
-		duration_of_a_used_file_part_str := ""
-		duration_of_all_used_file_parts_str := ""
-		duration_of_all_removed_file_parts_str := ""
-		duration_of_a_removed_file_part_str := ""
-		previous_stop_time_str := "0"
+func quality_target_validator(value string) (string, error) {
 
-		for counter := 0; counter < len(cut_list_seconds_str_slice); counter = counter + 2 {
-			start_time_str := cut_list_seconds_str_slice[counter]
+	if _, _, quality_target_error := plan.Parse_quality_target(strings.ToLower(value)); quality_target_error != nil {
+		return value, quality_target_error
+	}
 
-			duration_of_a_removed_file_part_str, _ = custom_float_substraction(start_time_str, previous_stop_time_str)
-			duration_of_all_removed_file_parts_str = custom_float_addition(duration_of_all_removed_file_parts_str, duration_of_a_removed_file_part_str)
+	return strings.ToLower(value), nil
+}
 
-			if counter+1 < len(cut_list_seconds_str_slice) {
-				stop_time_str := cut_list_seconds_str_slice[counter+1]
+func file_size_validator(value string) (string, error) {
 
-				new_edit_position, _ := custom_float_substraction(start_time_str, duration_of_all_removed_file_parts_str)
-				cut_positions_after_processing_seconds = append(cut_positions_after_processing_seconds, new_edit_position)
-				previous_stop_time_str = stop_time_str
+	if _, size_error := plan.Parse_file_size(value); size_error != nil {
+		return value, size_error
+	}
 
-				// If word 'end' is used to mark the end of file, then remove it, FFmpeg automatically process to the end of file if the last duration is left out
-				if strings.ToLower(stop_time_str) == "end" {
-					break
-				}
+	return value, nil
+}
 
-				duration_of_a_used_file_part_str, _ = custom_float_substraction(stop_time_str, start_time_str)
-				duration_of_all_used_file_parts_str = custom_float_addition(duration_of_all_used_file_parts_str, duration_of_a_used_file_part_str)
-			}
+func check_option_values(job Job) (Job, []string) {
+
+	// Check the values of the options and store them in the form used in processing, for example -abr 720,1080 is sorted to 1080,720.
+	// An empty value means that the option is not used. Every error found is returned.
+	// The crf value is checked against the range of the selected encoder with encode.Check_video_encoder_options.
+	var job_errors []string
+
+	options_to_check := []struct {
+		option_name string
+		value *string
+		validator option_validator
+	}{
+		{"a", &job.Audio_language, language_code_list_validator},
+		{"abk", &job.Adjust_black_point, float_between_limits_validator("-1.0", "1.0")},
+		{"abr", &job.Abr, abr_rungs_validator},
+		{"abrf", &job.Abr_format, abr_format_validator},
+		{"ach", &job.Adjust_chroma, float_between_limits_validator("0.0", "3.0")},
+		{"ad", &job.Audio_offset, audio_offset_validator},
+		{"agm", &job.Adjust_gamma, float_between_limits_validator("0.1", "10.0")},
+		{"amap", &job.Audio_map, audio_map_validator},
+		{"an", &job.Audio_stream_number, integer_list_validator},
+		{"awh", &job.Adjust_white_point, float_between_limits_validator("-1.0", "1.0")},
+		{"d", &job.Processing_duration, timestamp_validator},
+		{"et", &job.Processing_stop_time, timestamp_validator},
+		{"mbr", &job.Main_bitrate, bitrate_validator},
+		{"norm", &job.Loudness_normalization, loudness_target_validator},
+		{"palette", &job.Subtitle_burn_palette, hex_list_validator},
+		{"qt", &job.Quality_target, quality_target_validator},
+		{"s", &job.Subtitle_language, language_code_validator},
+		{"sbr", &job.Sd_bitrate, bitrate_validator},
+		{"sdsize", &job.Sd_target_size, file_size_validator},
+		{"size", &job.Target_size, file_size_validator},
+		{"sm", &job.Subtitle_mux_languages, language_code_list_validator},
+		{"smn", &job.Subtitle_mux_numbers, integer_list_validator},
+		{"sn", &job.Subtitle_stream_number, integer_validator},
+		{"so", &job.Subtitle_vertical_offset, integer_validator},
+		{"sr", &job.Subtitle_burn_resize, float_between_limits_validator("0.01", "100.0")},
+		{"st", &job.Search_start, timestamp_validator},
+	}
+
+	for _, option := range options_to_check {
+
+		if *option.value == "" {
+			continue
 		}
-	}
 
-	// Convert seconds to timecode values
-	cut_positions_as_timecodes = convert_cut_positions_to_timecode(cut_positions_after_processing_seconds)
+		validated_value, validation_error := option.validator(*option.value)
+
+		if validation_error != nil {
+			job_errors = append(job_errors, "option -" + option.option_name + ": " + validation_error.Error())
+			continue
+		}
 
-	if debug_option == true {
-		fmt.Println("process_split_times: split_times:", split_times)
-		fmt.Println("process_split_times: cut_list_positions_and_durations_seconds:", cut_list_positions_and_durations_seconds)
-		fmt.Println("process_split_times: cut_positions_after_processing_seconds:", cut_positions_after_processing_seconds)
-		fmt.Println("process_split_times: cut_positions_as_timecodes:", cut_positions_as_timecodes)
+		*option.value = validated_value
 	}
 
-	return cut_list_positions_and_durations_seconds, cut_positions_as_timecodes
+	return job, job_errors
 }
 
-func custom_float_addition(value_1_str string, value_2_str string) (remaining_str string) {
-
-	// Add two floats losslessly without using the unprecise float type
-	var value_1_whole_int, value_1_fractions_int, value_2_whole_int, value_2_fractions_int, remaining_int, remaining_milliseconds_int int
-	var value_1_fractions_str, value_2_fractions_str string
-	var remaining_float float64
+func check_job(check_context context.Context, job Job) (checked_job Job, video_encoder video_encoder_struct, job_errors []string) {
 
-	temp_1_str := strings.Split(value_1_str, ".")
-	value_1_whole_str := temp_1_str[0]
+	// Check the values of the options, the needed programs, split times, option combinations and the values that depend on the selected video encoder.
+	// Every error found is returned. The returned job has the implied options turned on and the option values in the form used in processing.
+	// With -av1 the encoder list of FFmpeg is read here, cancelling check_context stops FFmpeg.
+	job = apply_implied_options(job)
+	job, job_errors = check_option_values(job)
+	needed_programs := []string{"ffmpeg", "ffprobe"}
 
-	if len(temp_1_str) > 1 {
-		value_1_fractions_str = temp_1_str[1]
+	if job.Subtitle_burn_split == true {
+		needed_programs = append(needed_programs, "magick", "mogrify") // Starting from ImageMagick 7 the "magick" command should be used instead of the "convert" - command.
 	}
 
-	value_1_whole_int, _ = strconv.Atoi(value_1_whole_str)
-
-	// If user gave a value .8 covert it to .800
-	if len(value_1_fractions_str) > 1 {
-		for len(value_1_fractions_str) < 3 {
-			value_1_fractions_str = value_1_fractions_str + "0"
-		}
-	}
+	ffmpeg_found := true
 
-	value_1_fractions_int, _ = strconv.Atoi(value_1_fractions_str)
+	for _, program_name := range needed_programs {
 
-	temp_2_str := strings.Split(value_2_str, ".")
-	value_2_whole_str := temp_2_str[0]
+		if _, path_error := encode.Find_executable_path(program_name); path_error != nil {
+			job_errors = append(job_errors, path_error.Error())
 
-	if len(temp_2_str) > 1 {
-		value_2_fractions_str = temp_2_str[1]
+			if program_name == "ffmpeg" {
+				ffmpeg_found = false
+			}
+		}
 	}
 
-	value_2_whole_int, _ = strconv.Atoi(value_2_whole_str)
-
-	if len(value_2_fractions_str) > 1 {
-		for len(value_2_fractions_str) < 3 {
-			value_2_fractions_str = value_2_fractions_str + "0"
-		}
+	if job.Subtitle_burn_split == true && job.Search_start != "" && job.Fast_encode == false && job.Crf == false {
+		job_errors = append(job_errors, "options -st -sp and 2-pass encoding won't work correctly together. Disable 2-pass encoding with the -f option, don't use the -st and -et options or use the -crf option (Constant Quality)")
 	}
 
-	value_2_fractions_int, _ = strconv.Atoi(value_2_fractions_str)
+	if job.Quality_target != "" && job.Crf_value != "" {
+		job_errors = append(job_errors, "a crf value can't be given with the -qt option, -qt searches for the crf value")
+	}
 
-	remaining_int = value_1_whole_int + value_2_whole_int
-	remaining_milliseconds_int = value_1_fractions_int + value_2_fractions_int
+	if job.Split_times != "" {
 
-	// Add 1000 milliseconds from the whole numbers
-	if remaining_milliseconds_int >= 1000 {
-		remaining_milliseconds_int = remaining_milliseconds_int - 1000
-		remaining_int++
+		if split_times_error := plan.Check_split_times(job.Split_times); split_times_error != nil {
+			job_errors = append(job_errors, "option -sf: " + split_times_error.Error())
+		}
 	}
 
-	remaining_str = strconv.Itoa(remaining_int)
+	// Times that are SMPTE timecodes or frame numbers are compared in Run with the frame rate of each file
+	if job.Processing_stop_time != "" && plan.Timestamp_needs_frame_rate(job.Search_start) == false && plan.Timestamp_needs_frame_rate(job.Processing_stop_time) == false {
 
-	if remaining_milliseconds_int > 0 {
-		remaining_milliseconds_str := strconv.Itoa(remaining_milliseconds_int)
+		search_start_timestamp, _ := plan.Parse_timestamp(job.Search_start, probe.Frame_rate_struct{})
+		processing_stop_timestamp, processing_stop_time_error := plan.Parse_timestamp(job.Processing_stop_time, probe.Frame_rate_struct{})
 
-		// Fill the start of the milliseconds string with zeroes
-		for len(remaining_milliseconds_str) < 3 {
-			remaining_milliseconds_str = "0" + remaining_milliseconds_str
+		if processing_stop_time_error == nil && plan.Timestamp_compare(processing_stop_timestamp, search_start_timestamp) <= 0 {
+			job_errors = append(job_errors, "end time " + job.Processing_stop_time + " must be after start time " + job.Search_start)
 		}
-
-		remaining_str = remaining_str + "." + remaining_milliseconds_str
 	}
 
-	remaining_float, _ = strconv.ParseFloat(remaining_str, 64)
-
-	if remaining_float < 0.0 {
-		fmt.Println("\nError: Time addition rolled over and produced a negative number:", remaining_str, "\n")
-		os.Exit(1)
-	}
+	// Check if user given path to temp folder exists and if it is writable
+	if job.Temp_file_directory != "" {
 
-	return remaining_str
-}
+		// Get directory info from filesystem
+		dir_stat, err := os.Stat(job.Temp_file_directory)
 
-func custom_float_substraction(value_1_str string, value_2_str string) (remaining_str string, remaining_float float64) {
+		if os.IsNotExist(err) {
+			job_errors = append(job_errors, "path to temp file dir: " + job.Temp_file_directory + " does not exist")
 
-	// Subtract two floats losslessly without using the unprecise float type
-	// The first value (value_1_str) needs to be the bigger one, since we subtract the second from the first
-	var value_1_whole_int, value_1_fractions_int, value_2_whole_int, value_2_fractions_int, remaining_int, remaining_milliseconds_int int
-	var value_1_fractions_str, value_2_fractions_str string
+		} else if err != nil || dir_stat.IsDir() == false {
+			job_errors = append(job_errors, "path to -td option: " + job.Temp_file_directory + " is not a directory")
 
-	temp_1_str := strings.Split(value_1_str, ".")
-	value_1_whole_str := temp_1_str[0]
+		} else {
 
-	if len(temp_1_str) > 1 {
-		value_1_fractions_str = temp_1_str[1]
-	}
+			// Test if temp dir is writable
+			testfile_path := filepath.Join(job.Temp_file_directory, "Testfile.txt")
 
-	value_1_whole_int, _ = strconv.Atoi(value_1_whole_str)
+			if err = os.WriteFile(testfile_path, []byte("This is a test file and can be deleted\n"), 0644); err != nil {
+				job_errors = append(job_errors, "tempfile path " + job.Temp_file_directory + " is not writable")
+			}
 
-	// If user gave a value .8 covert it to .800
-	if len(value_1_fractions_str) > 1 {
-		for len(value_1_fractions_str) < 3 {
-			value_1_fractions_str = value_1_fractions_str + "0"
+			os.Remove(testfile_path)
 		}
 	}
 
-	value_1_fractions_int, _ = strconv.Atoi(value_1_fractions_str)
-
-	temp_2_str := strings.Split(value_2_str, ".")
-	value_2_whole_str := temp_2_str[0]
+	// The AV1 encoder depends on the encoders FFmpeg has been compiled with
+	var ffmpeg_encoders map[string]bool
 
-	if len(temp_2_str) > 1 {
-		value_2_fractions_str = temp_2_str[1]
-	}
+	if job.Av1 == true && ffmpeg_found == true {
 
-	value_2_whole_int, _ = strconv.Atoi(value_2_whole_str)
+		var encoders_error error
+		ffmpeg_encoders, encoders_error = encode.Get_ffmpeg_encoders(check_context)
 
-	if len(value_2_fractions_str) > 1 {
-		for len(value_2_fractions_str) < 3 {
-			value_2_fractions_str = value_2_fractions_str + "0"
+		if encoders_error != nil {
+			job_errors = append(job_errors, "could not get the list of encoders from FFmpeg: " + encoders_error.Error())
+			ffmpeg_encoders = nil
 		}
 	}
 
-	value_2_fractions_int, _ = strconv.Atoi(value_2_fractions_str)
-
-	// Borrow 1000 milliseconds from the whole numbers
-	if value_2_fractions_int > value_1_fractions_int {
-		value_1_fractions_int = value_1_fractions_int + 1000
-		value_1_whole_int--
-	}
-
-	remaining_int = value_1_whole_int - value_2_whole_int
-	remaining_milliseconds_int = value_1_fractions_int - value_2_fractions_int
-	remaining_str = strconv.Itoa(remaining_int)
-
-	if remaining_milliseconds_int > 0 {
-		remaining_milliseconds_str := strconv.Itoa(remaining_milliseconds_int)
-
-		// Fill the start of the milliseconds string with zeroes
-		for len(remaining_milliseconds_str) < 3 {
-			remaining_milliseconds_str = "0" + remaining_milliseconds_str
-		}
+	// Check the preset, tune and crf values against the limits of the selected encoder
+	video_encoder, encoder_error := select_video_encoder(job, ffmpeg_encoders)
 
-		remaining_str = remaining_str + "." + remaining_milliseconds_str
+	if encoder_error != nil {
+		job_errors = append(job_errors, encoder_error.Error())
 	}
 
-	remaining_float, _ = strconv.ParseFloat(remaining_str, 64)
+	job_errors = append(job_errors, encode.Check_video_encoder_options(video_encoder.name, job.Video_preset, job.Video_tune, job.Crf_value)...)
 
-	return remaining_str, remaining_float
+	return job, video_encoder, job_errors
 }
 
-func sanitize_float(input_string string, debug_option bool) (string) {
-
-	if debug_option == true {
-		fmt.Println()
-		fmt.Println("sanitize_float Before:", input_string)
-	}
+func Check_job(check_context context.Context, job Job) []string {
 
-	if strings.Index(input_string, ".") == -1 {
-		// There is no decimal part, sanitize a whole number
+	// Check the job before calling Run. Run checks the job again and does not process any files if there are errors.
+	_, _, job_errors := check_job(check_context, job)
 
-		// Remove unnecessary leading zeros if there are any
-		for input_string[0] == '0' && len(input_string) > 1 {
-			input_string = input_string[1:]
-		}
+	return job_errors
+}
 
+func Effective_config(job Job) (config Config_file_struct, error_code error) {
+
+	// Return the defaults the job uses. The options of the job have replaced the tables and the crf value of the selected encoder,
+	// store them back to the config values of the encoder.
+	// FFmpeg is not run, with -av1 the SVT-AV1 values are returned even if FFmpeg only has libaom.
+	job = apply_default_video_processing(apply_implied_options(job))
+	video_encoder, error_code := select_video_encoder(job, nil)
+	config = job.Config
+
+	switch video_encoder.name {
+	case "libx264":
+		config.Video_compression_options_sd = video_encoder.compression_options_sd
+		config.Video_compression_options_hd = video_encoder.compression_options_hd
+		config.Video_compression_options_ultra_hd_4k = video_encoder.compression_options_ultra_hd_4k
+		config.Video_compression_options_ultra_hd_8k = video_encoder.compression_options_ultra_hd_8k
+		config.Crf_value = video_encoder.crf_value
+	case "libx265":
+		config.Video_compression_options_hevc_sd = video_encoder.compression_options_sd
+		config.Video_compression_options_hevc_hd = video_encoder.compression_options_hd
+		config.Video_compression_options_hevc_ultra_hd_4k = video_encoder.compression_options_ultra_hd_4k
+		config.Video_compression_options_hevc_ultra_hd_8k = video_encoder.compression_options_ultra_hd_8k
+		config.Crf_value_hevc = video_encoder.crf_value
+	case "libvpx-vp9":
+		config.Video_compression_options_vp9_sd = video_encoder.compression_options_sd
+		config.Video_compression_options_vp9_hd = video_encoder.compression_options_hd
+		config.Video_compression_options_vp9_ultra_hd_4k = video_encoder.compression_options_ultra_hd_4k
+		config.Video_compression_options_vp9_ultra_hd_8k = video_encoder.compression_options_ultra_hd_8k
+		config.Crf_value_vp9 = video_encoder.crf_value
+	case "libsvtav1":
+		config.Video_compression_options_av1_svt = video_encoder.compression_options_hd
+		config.Crf_value_av1 = video_encoder.crf_value
+	case "libaom-av1":
+		config.Video_compression_options_av1_aom = video_encoder.compression_options_hd
+		config.Crf_value_av1 = video_encoder.crf_value
+	}
+
+	if job.Crf == true {
+		config.Default_video_processing = "crf"
 	} else {
+		config.Default_video_processing = "2-pass"
+	}
 
-		// There is a decimal part in the float
+	return config, error_code
+}
 
-		// Add leading zero if it is missing
-		if input_string[0] == '.' {
-			input_string = "0" + input_string
-		}
+func Run(processing_context context.Context, job Job) error {
 
-		// Add trailing zero if it is missing
-		if input_string[len(input_string) -1] == '.' {
-			input_string = input_string + "0"
-		}
+	// Process the files of the job. Messages are printed while processing and the result of each file is written to its processing log.
+	// The returned error tells that some of the files could not be processed, the errors have already been printed.
 
-		// Remove unnecessary leading zeros if there are any
-		for input_string[0] == '0' && input_string[1] != '.' {
-			input_string = input_string[1:]
-		}
+	// Check the job again, the caller may not have called Check_job
+	job, video_encoder, job_errors := check_job(processing_context, job)
 
-		// Remove unnecessary trailing zeros if there are any
-		for input_string[len(input_string) - 1] == '0' && input_string[len(input_string) - 2] != '.' {
-			input_string = input_string[0:len(input_string) - 1]
-		}
+	if len(job_errors) > 0 {
+		return errors.New(strings.Join(job_errors, ", "))
 	}
 
-	if debug_option == true {
-		fmt.Println("sanitize_float After:", input_string)
-		fmt.Println()
+	if job.Subtitle_burn_split == true {
+		os.Setenv("MAGICK_THREAD_LIMIT", "1") // Disable ImageMagick multithreading it only makes processing slower. This sets an environment variable in the os.
 	}
 
-	return input_string
-}
+	job = apply_default_video_processing(job)
+	input_filenames := job.Input_files
+
+	// Defaults from the config file and the compression options of the selected encoder
+	default_audio_processing := job.Config.Default_audio_processing
+	default_max_threads := job.Config.Default_max_threads
+	audio_bitrate_multiplier := job.Config.Audio_bitrate_multiplier
+	denoise_options := []string{job.Config.Denoise_options}
+	video_compression_options_lossless := job.Config.Video_compression_options_lossless
+	video_encoder_name := video_encoder.name
+	video_compression_options_sd := video_encoder.compression_options_sd
+	video_compression_options_hd := video_encoder.compression_options_hd
+	video_compression_options_ultra_hd_4k := video_encoder.compression_options_ultra_hd_4k
+	video_compression_options_ultra_hd_8k := video_encoder.compression_options_ultra_hd_8k
+	video_compression_bitrate_divider := video_encoder.bitrate_divider
+	crf_value := video_encoder.crf_value
+	quality_search_crf_min := video_encoder.quality_search_crf_min
+	quality_search_crf_max := video_encoder.quality_search_crf_max
+	color_subsampling_options := video_encoder.color_subsampling_options
+	encoder_preset_option := encode.Video_encoder_limits[video_encoder_name].Preset_option
 
-func float_is_between_limits(value_to_compare_str string, min_value_str string, max_value_str string, debug_option bool) (float_is_between_limits bool) {
+	//////////////////////
+	// Define variables //
+	//////////////////////
 
-	// Check that a float is between lower and upper limits.
-	// Don't use real floats since I don't trust them to do the job correctly.
-	// Instead use strings and remove the decimal component and compare values as ints.
+	var deinterlace_options string
+	var grayscale_options string
+	var subtitle_processing_options string
+	var timecode_burn_options string
+	var ffmpeg_pass_1_commandline encode.Ffmpeg_commandline_struct
+	var ffmpeg_pass_2_commandline encode.Ffmpeg_commandline_struct
+	var ffmpeg_subtitle_extract_commandline encode.Ffmpeg_commandline_struct
+	var ffmpeg_file_split_commandline encode.Ffmpeg_commandline_struct
+	var final_crop_string string
+	var file_to_process, video_codec_name, color_subsampling, color_space string
+	var video_width, video_height int
+	var video_duration float64
+	var video_height_int int
+	var main_video_2_pass_bitrate_str string
+	var audio_language, audio_codec string
+	var for_visually_impared, number_of_audio_channels int
+	var subtitle_language, subtitle_codec_name string
+	var for_hearing_impared int
+	var crop_values_picture_width int
+	var crop_values_picture_height int
+	var crop_values_width_offset int
+	var crop_values_height_offset int
+	var error_code error
+	var error_messages_map = make (map[string][]string)
+	var file_counter int
+	var file_counter_str string
+	var files_to_process_str string
+	var subtitle_horizontal_offset_int int
+	var subtitle_horizontal_offset_str string
+	var cut_list_timestamps []plan.Timestamp_struct
+	var search_start_timestamp, processing_duration_timestamp plan.Timestamp_struct
+	var split_video bool
+	var split_info_filename string
+	var split_info_file_absolute_path string
+	var list_of_splitfiles []string
+	var cut_positions_as_timecodes []string
+	var timecode_font_size int
+	var orig_subtitle_path, cropped_subtitle_path string
+	var selected_streams = make(map[string][]string)
+	var media_file_info_slice []probe.Media_file_struct
 
-	var value_to_compare_int, min_value_int, max_value_int, biggest_decimal_part, value_to_compare_decimal_lenght, min_value_decimal_lenght, max_value_decimal_lenght int
-	var error_happened error
+	start_time := time.Now()
+	file_split_start_time := time.Now()
+	file_split_elapsed_time := time.Since(file_split_start_time)
+	pass_1_start_time := time.Now()
+	pass_1_elapsed_time := time.Since(pass_1_start_time)
+	pass_2_start_time := time.Now()
+	pass_2_elapsed_time := time.Since(pass_2_start_time)
+	subtitle_extract_start_time := time.Now()
+	subtitle_extract_elapsed_time := time.Since(subtitle_extract_start_time)
+	subtitle_processing_start_time := time.Now()
+	subtitle_processing_elapsed_time := time.Since(subtitle_extract_start_time)
 
-	// Find index of decimal point in the number
-	value_to_compare_index := strings.Index(value_to_compare_str, ".")
-	min_value_index := strings.Index(min_value_str, ".")
-	max_value_index := strings.Index(max_value_str, ".")
+	output_directory_name := "00-processed_files"
+	sd_directory_name := "sd"
+	subtitle_extract_dir := "subtitles"
+	original_subtitles_dir := "original_subtitles"
+	fixed_subtitles_dir := "fixed_subtitles"
 
-	if value_to_compare_index == 0 {
-		fmt.Println("Error: illegal number:", value_to_compare_str)
-		os.Exit(0)
-	}
+	// Adaptive bitrate ladder rung heights have been checked and sorted from the highest to the lowest in check_job
+	var abr_rung_heights []int
 
-	if min_value_index == 0 {
-		fmt.Println("Error: illegal number:", min_value_str)
-		os.Exit(0)
-	}
+	if job.Abr != "" {
 
-	if max_value_index == 0 {
-		fmt.Println("Error: illegal number:", max_value_str)
-		os.Exit(0)
+		for _, rung_height_str := range strings.Split(job.Abr, ",") {
+			rung_height, _ := strconv.Atoi(rung_height_str)
+			abr_rung_heights = append(abr_rung_heights, rung_height)
+		}
 	}
 
-	if value_to_compare_index < 0 { value_to_compare_index = 0 }
-	if min_value_index < 0 { min_value_index = 0 }
-	if max_value_index < 0 { max_value_index = 0 }
-
-	// How many numbers are there after the decimal point
-	if value_to_compare_index > 0 {
+	// Audio map has been checked in check_job
+	var audio_map []plan.Audio_map_struct
 
-		value_to_compare_decimal_lenght = len(value_to_compare_str[value_to_compare_index + 1:])
+	if job.Audio_map != "" {
+		audio_map, _ = plan.Parse_audio_map(job.Audio_map)
 	}
 
-	if min_value_index > 0 {
+	// Audio offset has been checked in check_job
+	var audio_offset_milliseconds int
 
-		min_value_decimal_lenght = len(min_value_str[min_value_index + 1:])
+	if job.Audio_offset != "" {
+		audio_offset_milliseconds, _ = strconv.Atoi(job.Audio_offset)
 	}
 
-	if max_value_index > 0 {
-
-		max_value_decimal_lenght = len(max_value_str[max_value_index + 1:])
+	// The times of -sf, -st, -d and -et are converted to timestamps for each file, since SMPTE timecodes and frame numbers depend on the frame rate.
+	// The -st, -d and -et options are not used with the -sf option.
+	if job.Split_times != "" {
+		split_video = true
 	}
 
-	if value_to_compare_decimal_lenght > biggest_decimal_part {
-		biggest_decimal_part = value_to_compare_decimal_lenght
-	}
+	// Convert the dvd palette hacking option string to the form FFmpeg uses. The string has been checked in check_job.
+	if job.Subtitle_burn_palette != "" {
+		temp_slice := strings.Split(job.Subtitle_burn_palette, ",")
+		job.Subtitle_burn_palette = ""
 
-	if min_value_decimal_lenght > biggest_decimal_part {
-		biggest_decimal_part = min_value_decimal_lenght
-	}
+		// Prepare -palette option string for FFmpeg. It requires 16 hex strings where each consists of 6 hex numbers. Of these every 2 numbers control RBG color.
+		// The user is limited here to use only shades between black -> gray -> white.
+		for counter, character := range temp_slice {
 
-	if max_value_decimal_lenght > biggest_decimal_part {
-		biggest_decimal_part = max_value_decimal_lenght
-	}
+			job.Subtitle_burn_palette = job.Subtitle_burn_palette + strings.Repeat(strings.ToLower(character), 6)
 
-	///////////
-	// Value //
-	///////////
-	value_to_compare_str = strings.Replace(value_to_compare_str, ".", "", 1)
+			if counter < len(temp_slice)-1 {
+				job.Subtitle_burn_palette = job.Subtitle_burn_palette + ","
+			}
 
-	for x := 1 ; x <= biggest_decimal_part - value_to_compare_decimal_lenght ; x++ {
-		value_to_compare_str = value_to_compare_str + "0"
-	}
+		}
 
-	value_to_compare_int, error_happened = strconv.Atoi(value_to_compare_str)
+		if len(temp_slice) < 16 {
 
-	if error_happened != nil {
-		fmt.Println("Custom float Comparison: Error converting string to int:", value_to_compare_str)
-		os.Exit(0)
-	}
+			job.Subtitle_burn_palette = job.Subtitle_burn_palette + ","
 
-	///////////////
-	// Min Limit //
-	///////////////
-	min_value_str = strings.Replace(min_value_str, ".", "", 1)
+			for counter := len(temp_slice); counter < 16; counter++ {
+				job.Subtitle_burn_palette = job.Subtitle_burn_palette + "ffffff"
 
-	for x := 1 ; x <= biggest_decimal_part - min_value_decimal_lenght ; x++ {
-		min_value_str = min_value_str + "0"
-	}
-
-	min_value_int, error_happened = strconv.Atoi(min_value_str)
-
-	if error_happened != nil {
-		fmt.Println("Custom float Comparison: Error converting string to int:", min_value_str)
-		os.Exit(0)
-	}
-
-	///////////////
-	// Max Limit //
-	///////////////
-	max_value_str = strings.Replace(max_value_str, ".", "", 1)
-
-	for x := 1 ; x <= biggest_decimal_part - max_value_decimal_lenght ; x++ {
-		max_value_str = max_value_str + "0"
-	}
-
-	max_value_int, error_happened = strconv.Atoi(max_value_str)
-
-	if error_happened != nil {
-		fmt.Println("Custom float Comparison: Error converting string to int:", max_value_str)
-		os.Exit(0)
-	}
-
-	//////////////////
-	// Compare Ints //
-	//////////////////
-	if value_to_compare_int >= min_value_int && value_to_compare_int <= max_value_int {
-		float_is_between_limits = true
-	} else {
-		float_is_between_limits = false
-	}
-
-	if debug_option == true {
-		fmt.Println("")
-		fmt.Println("value_to_compare_str", value_to_compare_str)
-		fmt.Println("value_to_compare_index", value_to_compare_index)
-		fmt.Println("value_to_compare_decimal_lenght", value_to_compare_decimal_lenght)
-		fmt.Println("value_to_compare_int", value_to_compare_int)
-		fmt.Println("")
-
-		fmt.Println("min_value_str", min_value_str)
-		fmt.Println("min_value_index", min_value_index)
-		fmt.Println("min_value_decimal_lenght", min_value_decimal_lenght)
-		fmt.Println("min_value_int", min_value_int)
-		fmt.Println("")
-
-		fmt.Println("max_value_str", max_value_str)
-		fmt.Println("max_value_index", max_value_index)
-		fmt.Println("max_value_decimal_lenght", max_value_decimal_lenght)
-		fmt.Println("max_value_int", max_value_int)
-		fmt.Println("")
-
-		fmt.Println("biggest_decimal_part", biggest_decimal_part)
-		fmt.Println("float_is_between_limits", float_is_between_limits)
-		fmt.Println("")
-	}
-
-	return float_is_between_limits
-}
-
-func read_filenames_in_a_dir(source_dir string) (files_str_slice []string) {
-
-	files, err := ioutil.ReadDir(source_dir)
-
-	if err != nil {
-		log.Fatal(err)
-	}
-
-	for _, entry := range files {
-
-		if entry.IsDir() == false {
-			files_str_slice = append(files_str_slice, entry.Name())
-		}
-	}
-
-	return files_str_slice
-}
-
-func subtitle_trim(original_subtitles_absolute_path string, fixed_subtitles_absolute_path string, files_str_slice []string, video_width string, video_height string, process_number int, return_channel chan int, subtitle_burn_resize string, subtitle_burn_grayscale bool) {
-
-	var subtitle_dimension_info []string
-	var subtitle_resize_info []string
-	var subtitles_dimension_map = make(map[string][]string)
-	var subtitle_resize_commandline []string
-	var subtitle_trim_commandline []string
-
-	///////////////////////////////////////////////////////////////////
-	// Trim subtitles, removing empty space around the subtitle text //
-	///////////////////////////////////////////////////////////////////
-
-	for _, subtitle_name := range files_str_slice {
-
-		subtitle_trim_commandline = nil
-
-		if subtitle_burn_grayscale == true {
-			// Convert subtitle to grayscale and trim
-			subtitle_trim_commandline = append(subtitle_trim_commandline, "magick", filepath.Join(original_subtitles_absolute_path, subtitle_name), "-trim", "-print", "%[W],%[H],%[fx:w],%[fx:h],%[fx:page.x],%[fx:page.y]", "-compress", "rle", "-set", "colorspace", "Gray", filepath.Join(fixed_subtitles_absolute_path, subtitle_name))
-		} else {
-			// Trim subtitle
-			subtitle_trim_commandline = append(subtitle_trim_commandline, "magick", filepath.Join(original_subtitles_absolute_path, subtitle_name), "-trim", "-print", "%[W],%[H],%[fx:w],%[fx:h],%[fx:page.x],%[fx:page.y]", "-compress", "rle", filepath.Join(fixed_subtitles_absolute_path, subtitle_name))
-		}
-
-		subtitle_trim_output, subtitle_trim_error, trim_error_code := run_external_command(subtitle_trim_commandline)
-
-		///////////////////////////////////////////////////////////////////////////////////////////////////
-		// If there is no subtitle in the image, then create a subtitle file with an empty alpha channel //
-		///////////////////////////////////////////////////////////////////////////////////////////////////
-		if trim_error_code != nil {
-
-			fmt.Println()
-			fmt.Println("ImageMagick trim reported error: ", trim_error_code, subtitle_trim_error)
-			fmt.Println()
-
-			continue
-		}
-
-		subtitle_resize_commandline = nil
-
-		subtitle_resize_commandline = append(subtitle_resize_commandline, "mogrify", "+distort", "SRT", subtitle_burn_resize + ",0", "+repage", "-print", "%[fx:w],%[fx:h]", "-compress", "rle", filepath.Join(fixed_subtitles_absolute_path, subtitle_name))
-		subtitle_resize_output, subtitle_resize_error, resize_error_code := run_external_command(subtitle_resize_commandline)
-
-		if resize_error_code != nil {
-			fmt.Println("Subtitle resize reported error:", subtitle_resize_error)
-		}
-
-		// Take image properties before and after crop and store them in a map.
-		//
-		// Image info in 'subtitle_dimension_info' is:
-		// Original width before crop (not used at the moment)
-		// Original height before crop (not used at the moment)
-		// Cropped width
-		// Cropped height
-		// Start of crop on x axis
-		// Start of crop on y axis (not used at the moment)
-		// Subtitle width after resize
-		// Subtitle height after resize
-
-		subtitle_dimension_info = strings.Split(subtitle_trim_output[0], ",")
-
-		if subtitle_burn_resize != "" {
-
-			subtitle_resize_info = strings.Split(subtitle_resize_output[0], ",")
-			subtitle_dimension_info = append(subtitle_dimension_info, subtitle_resize_info...)
-
-		} else {
-
-			subtitle_dimension_info = append(subtitle_dimension_info, "0", "0")
-		}
-
-		subtitles_dimension_map[subtitle_name] = subtitle_dimension_info
-	}
-
-	/////////////////////////////////////////////////////////////////////////
-	// Overlay cropped subtitles on a new position on a transparent canvas //
-	/////////////////////////////////////////////////////////////////////////
-
-	video_height_int, _ := strconv.Atoi(video_height)
-	video_width_int, _ := strconv.Atoi(video_width)
-	var subtitle_adjust_commandline []string
-	var subtitle_new_y int
-	counter := 0
-
-	// Define the position of the subtitle to be 5 - 20 pixels from the top / bottom of picture depending on the video height.
-	var subtitle_margin int = video_height_int / 100
-
-	if subtitle_margin < 5 {
-		subtitle_margin = 5
-	}
-
-	if subtitle_margin > 20 {
-		subtitle_margin = 20
-	}
-
-	for subtitle_name := range subtitles_dimension_map {
-
-		counter++
-		// orig_width ,_ := strconv.Atoi(subtitles_dimension_map[subtitle_name][0])
-		// orig_height ,_:= strconv.Atoi(subtitles_dimension_map[subtitle_name][1])
-		cropped_width, _ := strconv.Atoi(subtitles_dimension_map[subtitle_name][2])
-		cropped_height, _ := strconv.Atoi(subtitles_dimension_map[subtitle_name][3])
-		// cropped_start_x ,_:= strconv.Atoi(subtitles_dimension_map[subtitle_name][4])
-		cropped_start_y, _ := strconv.Atoi(subtitles_dimension_map[subtitle_name][5])
-
-		if subtitle_burn_resize != "" {
-			cropped_width, _ = strconv.Atoi(subtitles_dimension_map[subtitle_name][6])
-			cropped_height, _ = strconv.Atoi(subtitles_dimension_map[subtitle_name][7])
-		}
-
-		picture_center := video_height_int / 2 // Divider to find out if the subtitle is located above or below this line at the center of the picture
-		subtitle_new_x := (video_width_int / 2) - (cropped_width / 2) // This centers cropped subtitle on the x axis
-
-		if cropped_start_y > picture_center {
-			// Center subtitle on the bottom of the picure
-			subtitle_new_y = video_height_int - cropped_height - subtitle_margin
-
-		} else {
-			// Center subtitle on top of the picture
-			subtitle_new_y = subtitle_margin
-		}
-
-		subtitle_adjust_commandline = nil
-		subtitle_adjust_commandline = append(subtitle_adjust_commandline, "magick", "-size", video_width + "x" + video_height, "canvas:transparent", filepath.Join(fixed_subtitles_absolute_path, subtitle_name), "-geometry", "+" + strconv.Itoa(subtitle_new_x) + "+" + strconv.Itoa(subtitle_new_y), "-composite", "-compose", "over", "-compress", "rle", filepath.Join(fixed_subtitles_absolute_path, subtitle_name))
-
-		_, subtitle_trim_error, error_code := run_external_command(subtitle_adjust_commandline)
-
-		if error_code != nil {
-			fmt.Println("Repositioning subtitle generated an error:", subtitle_trim_error)
-		}
-	}
-	return_channel <- process_number
-}
-
-func get_number_of_physical_processors () (int, error) {
-
-	/////////////////////////////////
-	// This is Linux specific code //
-	/////////////////////////////////
-
-	last_physical_id_int := -1
-	physical_id_int := -1
-	physical_id_found := false
-	cpu_cores_int := 0
-
-	// Read in /proc/cpuinfo
-	file_handle, err := os.Open("/proc/cpuinfo")
-
-	if err != nil {
-		return 0, err
-	}
-
-	defer file_handle.Close()
-
-	scanner := bufio.NewScanner(file_handle)
-	scanner.Split(bufio.ScanLines)
-
-	for scanner.Scan() {
-
-		if strings.HasPrefix(scanner.Text(), "physical id") {
-			temp_list  := strings.Split(scanner.Text(), ":")
-			physical_id_int, err = strconv.Atoi(strings.TrimSpace(temp_list[1]))
-
-			if physical_id_int != last_physical_id_int {
-				physical_id_found = true
-				last_physical_id_int = physical_id_int
-				continue
-			}
-		}
-
-		if err != nil {
-			break
-		}
-
-		if physical_id_found == true && strings.HasPrefix(scanner.Text(), "cpu cores") {
-			temp_int := -1
-			temp_list  := strings.Split(scanner.Text(), ":")
-			temp_int, err = strconv.Atoi(strings.TrimSpace(temp_list[1]))
-			cpu_cores_int = cpu_cores_int + temp_int
-			physical_id_found = false
-		}
-
-		if err != nil {
-			break
-		}
-	}
-
-	return cpu_cores_int, err
-}
-
-func remove_duplicate_subtitle_images (original_subtitles_absolute_path string, fixed_subtitles_absolute_path string, files_str_slice []string, video_width string, video_height string) (files_remaining []string) {
-
-	var subtitle_md5sum_map  = make(map[string][]string)
-	var subtitle_copies []string
-
-	// Calculate md5 for each file
-	for _, subtitle_name := range files_str_slice {
-
-		subtitle_path := filepath.Join(original_subtitles_absolute_path, subtitle_name)
-		filehandle, err := os.Open(subtitle_path)
-
-		if err != nil {
-			log.Fatal(err)
-		}
-
-		md5_handler := md5.New()
-
-		if _, err := io.Copy(md5_handler, filehandle); err != nil {
-			log.Fatal(err)
-		}
-
-		// Caculate md5 for the subtitle file
-		md5sum := fmt.Sprintf("%x", md5_handler.Sum(nil))
-		filehandle.Close()
-
-		// If we have not stored this md5 before then store it and the name of the picture to map.
-		// If we have stored the md5 before, add the picture name to list for this md5.
-		if _, val := subtitle_md5sum_map[md5sum] ; val == false {
-			subtitle_copies = nil
-			subtitle_copies = append(subtitle_copies, subtitle_name)
-			subtitle_md5sum_map[md5sum] = subtitle_copies
-
-		} else {
-			subtitle_copies = nil
-			subtitle_copies = subtitle_md5sum_map[md5sum]
-			subtitle_copies = append(subtitle_copies, subtitle_name)
-			subtitle_md5sum_map[md5sum] = subtitle_copies
-		}
-	}
-
-	// Trim images until we find one where there is no subtitle.
-	// Create temp directory for trimmed images
-	var empty_subtitle_creation_commandline_start []string
-	empty_subtitle_creation_commandline_start = append(empty_subtitle_creation_commandline_start, "magick", "-size", video_width + "x" + video_height, "canvas:transparent", "-alpha", "on", "-compress", "rle")
-	var empty_subtitle_creation_commandline []string
-
-	var subtitle_trim_commandline []string
-	var empty_subtitle_path string
-	var empty_subtitle_md5 string
-
-	temp_path := filepath.Join(original_subtitles_absolute_path, "00-temp_path")
-
-	if _, err := os.Stat(temp_path); os.IsNotExist(err) {
-		os.MkdirAll(temp_path, 0777)
-	}
-
-	for _, subtitle_name := range files_str_slice {
-
-		subtitle_trim_commandline = nil
-		subtitle_trim_commandline = append(subtitle_trim_commandline, "magick", filepath.Join(original_subtitles_absolute_path, subtitle_name), "-trim", "-print", "%[W],%[H],%[fx:w],%[fx:h],%[fx:page.x],%[fx:page.y]", "-compress", "rle", filepath.Join(temp_path, subtitle_name))
-		_, subtitle_trim_error, trim_error_code := run_external_command(subtitle_trim_commandline)
-
-		///////////////////////////////////////////////////////////////////////////////////////////////////
-		// If there is no subtitle in the image, then create a subtitle file with an empty alpha channel //
-		///////////////////////////////////////////////////////////////////////////////////////////////////
-		// Imagemagick telling that there is no image is only a warning so the error code will be 0,
-		// but there is a warning string: "geometry does not contain image".
-
-		if strings.Contains(subtitle_trim_error[0], "does not contain image") == true && trim_error_code == nil {
-
-			// Get md5 of the empty image
-			subtitle_path := filepath.Join(original_subtitles_absolute_path, subtitle_name)
-			filehandle, err := os.Open(subtitle_path)
-
-			if err != nil {
-				log.Fatal(err)
-			}
-
-			defer filehandle.Close()
-
-			md5_handler := md5.New()
-
-			if _, err := io.Copy(md5_handler, filehandle); err != nil {
-				log.Fatal(err)
-			}
-
-			// Caculate md5 for the subtitle file
-			empty_subtitle_md5 = fmt.Sprintf("%x", md5_handler.Sum(nil))
-
-			// Create an empty picture with nothing but transparency in it and write it overwrinting the original.
-			// This is needed to get this image and the ones later manipulated with ImageMagick to have the same bit depth and other properties.
-			empty_subtitle_path = filepath.Join(fixed_subtitles_absolute_path, subtitle_name)
-			empty_subtitle_creation_commandline = append(empty_subtitle_creation_commandline_start, empty_subtitle_path)
-			_, _, error_code := run_external_command(empty_subtitle_creation_commandline)
-
-			if error_code != nil {
-				fmt.Println("\n\nCreating an empty subtitle image generated an error:", subtitle_trim_error)
-			}
-
-			break // Jump out of the loop when the first image without subtitle has been found
-		}
-
-		if trim_error_code != nil {
-
-			fmt.Println()
-			fmt.Println("ImageMagick trim reported error: ", trim_error_code, subtitle_trim_error)
-			fmt.Println()
-
-			continue
-		}
-	}
-
-	// Create soft links for empty image duplicates
-	var new_empty_subtitle string
-	subtitle_copies = nil
-	subtitle_copies = subtitle_md5sum_map[empty_subtitle_md5]
-
-	for counter, filename := range subtitle_copies {
-
-		if counter == 0 {
-			new_empty_subtitle = filename
-			continue
-		}
-
-		err := os.Symlink(filepath.Join(fixed_subtitles_absolute_path, new_empty_subtitle), filepath.Join(fixed_subtitles_absolute_path, filename))
-
-		if err != nil {
-			log.Fatal(err)
-		}
-
-	}
-	delete (subtitle_md5sum_map, empty_subtitle_md5)
-
-	// Create soft links for the rest of subtitle image duplicates
-	for _, subtitle_copies := range subtitle_md5sum_map {
-
-		for counter, filename := range subtitle_copies {
-
-			if counter == 0 {
-				new_empty_subtitle = filename
-				files_remaining = append(files_remaining, filename)
-				continue
-			}
-
-			err := os.Symlink(filepath.Join(fixed_subtitles_absolute_path, new_empty_subtitle), filepath.Join(fixed_subtitles_absolute_path, filename))
-
-			if err != nil {
-				log.Fatal(err)
-			}
-		}
-	}
-
-	return files_remaining
-}
-
-func store_options_and_help_text_int(category string, option string, value int, help_text string) *commandline_struct {
-
-	// This function does what flag.Int does in go and extends it a little
-	// The function stores category, option name, variables and help text for a commandline option.
-	// It also returns pointer to the default value for the commandline option
-	// so that it can be assigned to a variable.
-
-	if category == "" {
-		fmt.Println("Error, help_text category is empty")
-		os.Exit(0)
-	}
-
-	if option == "" {
-		fmt.Println("Error, help_text option is empty")
-		os.Exit(0)
-	}
-
-	if help_text == "" {
-		fmt.Println("Error, help_text message is empty")
-		os.Exit(0)
-	}
-
-	commandline_option_struct := new(commandline_struct)
-	commandline_option_struct.option_type = "int"
-	commandline_option_struct.user_int = value
-	commandline_option_struct.help_text = help_text
-
-	// Store options for each category
-	if len(helptext_categories_map) == 0 {
-		// The list of options is empty, store the first option in the category
-		var temp_slice []string
-		temp_slice = append(temp_slice, option)
-		helptext_categories_map[category] = temp_slice
-	} else {
-		// There already are some options in the list, store the new one at the end of the list
-		var temp_slice = helptext_categories_map[category]
-
-		for _, item := range temp_slice {
-			if option == item {
-				fmt.Println("Error, option defined twice")
-				os.Exit(0)
-			}
-		}
-
-		temp_slice = append(temp_slice, option)
-		helptext_categories_map[category] = temp_slice
-	}
-
-	// Store helptext for the option
-	commandline_option_map[option] = commandline_option_struct
-
-	return commandline_option_struct
-}
-
-func store_options_and_help_text_bool(category string, option string, help_text string) *commandline_struct {
-
-	// This function does what flag.Bool does in go and extends it a little
-	// The function stores category, option name, variables and help text for a commandline option.
-	// It also returns pointer to the default value for the commandline option
-	// so that it can be assigned to a variable.
-
-	if category == "" {
-		fmt.Println("Error, help_text category is empty")
-		os.Exit(0)
-	}
-
-	if option == "" {
-		fmt.Println("Error, help_text option is empty")
-		os.Exit(0)
-	}
-
-	if help_text == "" {
-		fmt.Println("Error, help_text message is empty")
-		os.Exit(0)
-	}
-
-	commandline_option_struct := new(commandline_struct)
-	commandline_option_struct.option_type = "bool"
-	commandline_option_struct.help_text = help_text
-
-	// Store options for each category
-	if len(helptext_categories_map) == 0 {
-		// The list of options is empty, store the first option in the category
-		var temp_slice []string
-		temp_slice = append(temp_slice, option)
-		helptext_categories_map[category] = temp_slice
-	} else {
-		// There already are some options in the list, store the new one at the end of the list
-		var temp_slice = helptext_categories_map[category]
-
-		for _, item := range temp_slice {
-			if option == item {
-				fmt.Println("Error, option defined twice")
-				os.Exit(0)
-			}
-		}
-
-		temp_slice = append(temp_slice, option)
-		helptext_categories_map[category] = temp_slice
-	}
-
-	// Store helptext for the option
-	commandline_option_map[option] = commandline_option_struct
-
-	return commandline_option_struct
-}
-
-func store_options_and_help_text_string(category string, option string, value string, help_text string) *commandline_struct {
-
-	// This function does what flag.String does in go and extends it a little
-	// The function stores category, option name, variables and help text for a commandline option.
-	// It also returns pointer to the default value for the commandline option
-	// so that it can be assigned to a variable.
-
-	if category == "" {
-		fmt.Println("Error, help_text category is empty")
-		os.Exit(0)
-	}
-
-	if option == "" {
-		fmt.Println("Error, help_text option is empty")
-		os.Exit(0)
-	}
-
-	if help_text == "" {
-		fmt.Println("Error, help_text message is empty")
-		os.Exit(0)
-	}
-
-	commandline_option_struct := new(commandline_struct)
-	commandline_option_struct.option_type = "string"
-	commandline_option_struct.user_string = value
-	commandline_option_struct.help_text = help_text
-
-	// Store options for each category
-	if len(helptext_categories_map) == 0 {
-		// The list of options is empty, store the first option in the category
-		var temp_slice []string
-		temp_slice = append(temp_slice, option)
-		helptext_categories_map[category] = temp_slice
-	} else {
-		// There already are some options in the list, store the new one at the end of the list
-		var temp_slice = helptext_categories_map[category]
-
-		for _, item := range temp_slice {
-			if option == item {
-				fmt.Println("Error, option defined twice")
-				os.Exit(0)
-			}
-		}
-
-		temp_slice = append(temp_slice, option)
-		helptext_categories_map[category] = temp_slice
-	}
-
-	// Store helptext for the option
-	commandline_option_map[option] = commandline_option_struct
-
-	return commandline_option_struct
-}
-
-func display_help_text() {
-
-	// Get terminal window dimensions
-	value_slice_int := get_terminal_window_dimensions()
-	// Don't print at the last character on the line because terminal will then automatically insert a line feed messing our print output.
-	terminal_width_int := value_slice_int[0] - 1
-	// terminal_height_int := value_slice_int[1] // We don't use terminal height on anything at this point
-
-	var second_paragraph_start, helptext_start, helptext_end int
-	var textline string
-
-	// Get map keys and sort them
-	categories := make ([]string, 0, len(helptext_categories_map))
-
-	for category := range helptext_categories_map {
-		categories = append(categories, category)
-	}
-
-	sort.Strings(categories)
-
-	///////////////////////////////////////////////////////////
-	// Find the longest option and adjust help text printing //
-	// start point in reference to that                      //
-	///////////////////////////////////////////////////////////
-	for _, category := range categories {
-		option_list := helptext_categories_map[category]
-
-		for _, option := range option_list {
-			if len(option) > second_paragraph_start {
-				second_paragraph_start = len(option)
-			}
-		}
-	}
-
-	second_paragraph_start = second_paragraph_start + 4
-
-	/////////////////////////////////////////////////////////
-	// Print sorted categories and options in each of them //
-	/////////////////////////////////////////////////////////
-	for _, category := range categories {
-		option_list := helptext_categories_map[category]
-
-		fmt.Println()
-		fmt.Println(category + ":")
-		fmt.Println(strings.Repeat("-", len(category) + 1))
-
-		for _, option := range option_list {
-
-			///////////////////////////////////////
-			// Print the first line of help text //
-			///////////////////////////////////////
-			commandline_option_struct := commandline_option_map[option]
-			textline = option + strings.Repeat(" ", second_paragraph_start - len(option))
-			helptext_start = 0
-			helptext_end = terminal_width_int - len(textline)
-
-			if helptext_end > len(commandline_option_struct.help_text) {
-				helptext_end = len(commandline_option_struct.help_text)
-			}
-
-			// Make sure we wont cut the last word in the middle at the end of the line
-			if helptext_end > 0 && helptext_end < len(commandline_option_struct.help_text) {
-
-				for commandline_option_struct.help_text[helptext_end - 1] != ' ' {
-					helptext_end--
-
-					if helptext_end <= 0 {
-						helptext_end = 0
-						break
-					}
-				}
-			}
-
-			fmt.Println("-" + option + strings.Repeat(" ", second_paragraph_start - len(option)) + commandline_option_struct.help_text[helptext_start:helptext_end])
-
-			// If this was the last line, start printing the next option help text
-			if helptext_end >= len(commandline_option_struct.help_text) {
-				fmt.Println()
-				continue
-			}
-
-			//////////////////////////////////////////////////////
-			// Print second help text line and lines after that //
-			//////////////////////////////////////////////////////
-			if helptext_end < len(commandline_option_struct.help_text) {
-
-				for {
-					helptext_start = helptext_end
-					helptext_end = helptext_start + terminal_width_int - second_paragraph_start
-
-					if helptext_end > len(commandline_option_struct.help_text) {
-						helptext_end = len(commandline_option_struct.help_text)
-					}
-
-					// If the first character on the line is a space then skip it
-					// and start from the first character of the next word instead
-					if commandline_option_struct.help_text[helptext_start] == ' ' {
-						helptext_start++
-
-						if helptext_start > len(commandline_option_struct.help_text) {
-							helptext_start = len(commandline_option_struct.help_text)
-						}
-					}
-
-					// Make sure we wont cut the last word in the middle at the end of the line
-					if helptext_end < len(commandline_option_struct.help_text) {
-
-						for commandline_option_struct.help_text[helptext_end] != ' ' {
-						helptext_end--
-
-							if helptext_end <= 0 {
-								helptext_end = 0
-								break
-							}
-						}
-					}
-
-					fmt.Println(strings.Repeat(" ", second_paragraph_start), commandline_option_struct.help_text[helptext_start:helptext_end])
-
-					// If this was the last line, start printing the next option help text
-					if helptext_end >= len(commandline_option_struct.help_text) {
-						break
-					}
-				}
-			}
-			fmt.Println()
-		}
-	}
-	os.Exit(0)
-}
-
-func get_terminal_window_dimensions() []int {
-
-	var value_slice_int []int
-
-	// Get terminal window size with the stty - command, if it does not exist return 1000,1000
-	if _, error := exec.LookPath("stty"); error == nil {
-
-		cmd := exec.Command("stty", "size")
-		cmd.Stdin = os.Stdin
-		out, err := cmd.Output()
-
-		if err != nil {
-			fmt.Println("Error getting terminal window width and height")
-			os.Exit(0)                                                                                                                                                                                                                     
-		}
-
-		first_line := strings.Split(string(out), "\n")
-		values_slice := strings.Split(first_line[0], " ")
-
-		height_string := values_slice[0]
-		width_string := values_slice[1]
-
-		height_int, error_happened := strconv.Atoi(height_string)
-
-		if error_happened != nil {                                                                                                                                                                                                             
-			fmt.Println("Error converting terminal window height to int")
-			os.Exit(0)                                                                                                                                                                                                                     
-		} 
-
-		width_int, error_happened := strconv.Atoi(width_string)
-
-		if error_happened != nil {                                                                                                                                                                                                             
-			fmt.Println("Error converting terminal window width to int")
-			os.Exit(0)                                                                                                                                                                                                                     
-		} 
-
-		value_slice_int = append(value_slice_int, width_int)
-		value_slice_int = append (value_slice_int, height_int)
-	} else {
-		value_slice_int = append(value_slice_int, 1000)
-		value_slice_int = append(value_slice_int, 1000)
-	}
-
-	return value_slice_int
-}
-
-func parse_options() []string {
-
-	// Debug mode for this subroutine cannot be set on the commandline
-	// because we have not parsed the commandline when we enter here
-	// Instead turn debug to true in the debug variable.
-
-	///////////////////////////////
-	// Parse commandline options //
-	///////////////////////////////
-	debug := false
-
-	if debug == true {
-
-		var arguments []string
-		arguments = os.Args
-		fmt.Println()
-		fmt.Println("Arguments:", arguments)
-		fmt.Println()
-
-		fmt.Println("Argument slice items:")
-		fmt.Println("---------------------")
-
-		for counter, item := range arguments {
-			fmt.Println(counter, item)
-		}
-		fmt.Println()
-		fmt.Println("Parsed arguments")
-		fmt.Println("----------------")
-	}
-
-	var input_filenames []string
-	var commandline_option_struct *commandline_struct
-	var predefined_option string
-	var option_found, string_option_found, int_option_found, item_is_an_option bool
-	string_option_found = false
-	int_option_found = false
-
-	for _, commandline_option := range os.Args[1:] {
-
-		option_found = false
-		item_is_an_option = false
-
-		// Remove leading "-" characters from the option string
-		// If the previous round in the for loop has recognized that the next
-		// string on the commandline is not an option but and value for it
-		// then don't remove the - character. This is because some 
-		// options takes negative numbers as values.
-		if string_option_found == false && int_option_found == false {
-
-			var option_as_runes []rune
-
-			if []rune(commandline_option)[0] == rune('-') {
-				item_is_an_option = true
-
-				for _, item := range commandline_option {
-
-					if item != rune('-') {
-						option_as_runes = append(option_as_runes, item)
-					}
-				}
-				commandline_option = string(option_as_runes)
-			}
-		}
-
-		// This part assigns int or string value following an option to the commandline variable 
-		// After the assingment the next option is fetched from the commandline
-		if string_option_found == true {
-			commandline_option_struct.user_string = commandline_option
-			string_option_found = false
-			continue
-		}
-
-		if int_option_found == true {
-			temp_int, error_happened := strconv.Atoi(commandline_option)
-
-			if error_happened != nil {
-				fmt.Println("Error could not convert option to integer:", commandline_option)
-				os.Exit(0)
-			}
-
-			commandline_option_struct.user_int = temp_int
-
-			int_option_found = false
-			continue
-		}
-
-		// This part recognizes an option on the commandline and sets boolean values so that 
-		// the int or string following the option is handled at the next round of the for loop
-		if item_is_an_option == true {
-
-			for predefined_option, commandline_option_struct = range commandline_option_map {
-
-				if commandline_option == predefined_option {
-
-					if commandline_option_struct.option_type == "int" {
-
-						if debug == true {
-							fmt.Print("found int variable:", commandline_option, " ")
-						}
-
-						commandline_option_struct.is_turned_on = true
-						int_option_found = true
-						option_found = true
-						break
-					}
-
-					if commandline_option_struct.option_type == "bool" {
-
-						if debug == true {
-							fmt.Println("found bool variable", commandline_option, " ")
-						}
-
-						// Store value: true to this options struct and switch the variable connected
-						// to this commandline option to point to the value in struct
-						commandline_option_struct.is_turned_on = true
-						option_found = true
-						break
-					}
-
-					if commandline_option_struct.option_type == "string" {
-
-						if debug == true {
-							fmt.Print("found string variable:", commandline_option, " ")
-						}
-
-						commandline_option_struct.is_turned_on = true
-						string_option_found = true
-						option_found = true
-						break
-					}
-				}
-			}
-
-			if option_found == false {
-
-				fmt.Println()
-				fmt.Println("Error, unknown option: -" + commandline_option)
-				fmt.Println()
-
-				os.Exit(0)
-			}
-
-		} else {
-
-			// The item on the commandline is a filename, test if files exist
-			inputfile_full_path,_ := filepath.Abs(commandline_option)
-			fileinfo, err := os.Stat(inputfile_full_path)
-
-			// Test if input files exist
-			if os.IsNotExist(err) == true {
-
-				fmt.Println()
-				fmt.Println("Error !!!!!!!")
-				fmt.Println("File: '" + inputfile_full_path + "' does not exist")
-				fmt.Println()
-
-				os.Exit(1)
-			}
-
-			// Test if name is a directory
-			if fileinfo.IsDir() == true {
-
-				fmt.Println()
-				fmt.Println("Error !!!!!!!")
-				fmt.Println(inputfile_full_path + " is not a file it is a directory.")
-				fmt.Println()
-
-				os.Exit(1)
-			}
-
-			// Add all existing input file names to a slice
-			input_filenames = append(input_filenames, inputfile_full_path)
-		}
-	}
-
-	if debug == true {
-
-		// Print commandline options and their values in alphabetical order
-		// Print only those options that the user has turned on
-		var sorted_options []string
-		var longest_option int
-		var title_to_print string
-
-		fmt.Println("\n")
-		title_to_print = "All option variables and text:"
-		fmt.Println(title_to_print)
-		fmt.Println(strings.Repeat("-", len(title_to_print)))
-		print_all_commandline_variables()
-
-		fmt.Println("\n")
-		title_to_print = "Options that the user turned on:"
-		fmt.Println(title_to_print)
-		fmt.Println(strings.Repeat("-", len(title_to_print)))
-
-		for option := range commandline_option_map {
-			sorted_options = append(sorted_options, option)
-
-			if len(option) > longest_option {
-				longest_option = len(option)
-			}
-		}
-
-		sort.Strings(sorted_options)
-
-		for _, option := range sorted_options {
-
-			commandline_option_struct = commandline_option_map[option]
-
-			if commandline_option_struct.is_turned_on == true {
-
-				fmt.Printf("-" + option + strings.Repeat(" ", longest_option - len(option)))
-				fmt.Printf("   " + commandline_option_struct.option_type + "   ")
-
-				if commandline_option_struct.option_type == "int" {
-					fmt.Println("     ", commandline_option_struct.user_int)
-				} else if commandline_option_struct.option_type == "bool" {
-					fmt.Println("     ", commandline_option_struct.is_turned_on)
-				} else if commandline_option_struct.option_type == "string" {
-					fmt.Println("   ", commandline_option_struct.user_string)
-				}
-			}
-		}
-	}
-
-	return input_filenames
-}
-
-func print_all_commandline_variables() {
-
-	// This subroutine is used to debug option variables and help text.
-	for option, commandline_option_struct := range commandline_option_map {
-		fmt.Println()
-		fmt.Println("Option:", option)
-
-		if commandline_option_struct.option_type == "bool" {
-			fmt.Println("\tType:", commandline_option_struct.option_type)
-			fmt.Println("\tValue:", commandline_option_struct.is_turned_on)
-			fmt.Println("\tHelp text:", commandline_option_struct.help_text)
-		}
-
-		if commandline_option_struct.option_type == "int" {
-			fmt.Println("\tType:", commandline_option_struct.option_type)
-			fmt.Println("\tValue:", commandline_option_struct.user_int)
-			fmt.Println("\tHelp text:", commandline_option_struct.help_text)
-		}
-
-		if commandline_option_struct.option_type == "string" {
-			fmt.Println("\tType:", commandline_option_struct.option_type)
-			fmt.Println("\tValue:", commandline_option_struct.user_string)
-			fmt.Println("\tHelp text:", commandline_option_struct.help_text)
-		}
-	}
-}
-
-//////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
-
-func main() {
-
-	// Print executable name and version
-	fmt.Println(filepath.Base(os.Args[0]), "version", version_number)
-
-	////////////////////////////////
-	// Define commandline options //
-	////////////////////////////////
-	// Below are variable, and helpt text definitions tied to a commandline option
-	// Definitions from left to right are:
-	// Variable that gets the default value defined later on the line
-	// Category. Help text will be printed a category at a time
-	// Commandline option name.
-	// Default value for the variable. The function "store_options_and_help_text_string()" returns this value to the variable defined at the beginning of the line
-	// Help text for the commandline option
-	// Address of the variable defined at the beginning of the line. This is used when the commandline option is followed by a value. The variable address is used to point the variable to the user defined value.
-	// Audio options
-	audio_language_option := store_options_and_help_text_string("Audio", "a", "", "Select audio with this language code, example: -a fin or -a eng or -a ita  Only one audio stream can be selected. Only one of the options -an and -a can be used at the a time.")
-	audio_stream_number_option := store_options_and_help_text_string("Audio", "an", "0", "Select audio stream by number, example: -an 1. Only one audio stream can be selected. Only one of the options -an and -a can be used at the a time.")
-	audio_compression_ac3 := store_options_and_help_text_bool("Audio", "ac3", "Compress audio as ac3. Bitrate of 128k is used for each audio channel meaning 2 channels is compressed using 256k bitrate. 6 channels uses the ac3 max bitrate of 640k.")
-	audio_compression_aac := store_options_and_help_text_bool("Audio", "aac", "Compress audio as aac. Bitrate of 128k is used for each audio channel meaning 2 channels is compressed using 256k bitrate, 6 channels uses 768k bitrate.", )
-	audio_compression_opus := store_options_and_help_text_bool("Audio", "opus", "Compress audio as opus. Opus support in mp4 container is experimental as of FFmpeg vesion 4.2.1. Bitrate of 128k is used for each audio channel meaning 2 channels is compressed using 256k bitrate, 6 channels uses 768k bitrate.")
-	audio_compression_flac := store_options_and_help_text_bool("Audio", "flac", "Compress audio in lossless Flac - format")
-	no_audio := store_options_and_help_text_bool("Audio", "na", "Disable audio processing. There is no audio in the resulting file.")
-
-	// Video options
-	adjust_black_point := store_options_and_help_text_string("Video", "abk", "", "Adjust video black point to make light video darker. This will move dark tones closer to black. Range is from -1.0 to 1.0. 0 = no change, numbers bigger than 0 makes video darker. Example: -abk 0.3")
-	autocrop_option := store_options_and_help_text_bool("Video", "ac", "Autocrop. Find crop values automatically by doing 10 second spot checks in 10 places for the duration of the file.")
-	adjust_chroma := store_options_and_help_text_string("Video", "ach", "", "Adjust Chroma to increase or decrease the amount of color in the video. Range is 0.0 to 3.0. Value of 1 means no change. Values less than 1 decrease and values bigger than 1 increases the level of color in the video. Example -ach 0.85")
-	adjust_gamma := store_options_and_help_text_string("Video", "agm", "", "Adjust video gamma. This will make mid tones lighter or darker. Range is from 0.1 to 10. 1 = no change, numbers bigger than 1 moves mid tones towards white. Example: -agm 1.05")
-	adjust_white_point := store_options_and_help_text_string("Video", "awh", "", "Adjust video white point to make dark video lighter. This will move light tones closer to white. Range is from -1.0 to 1.0. 1 = no change, numbers smaller than 1 makes video lighter. Example: -awh 0.7")
-	crf_option := store_options_and_help_text_bool("Video", "crf", "Use Constant Quality instead of 2-pass encoding. The default value for crf is 18, which produces the same quality as default 2-pass but a bigger file. CRF is much faster that 2-pass encoding.")
-	denoise_option := store_options_and_help_text_bool("Video", "dn", "Denoise. Use HQDN3D - filter to remove noise from the picture. This option is equal to Hanbrakes 'medium' noise reduction settings.")
-	grayscale_option := store_options_and_help_text_bool("Video", "gr", "Convert video to Grayscale. Use this option if the original source is black and white. This results more bitrate being available for b/w information and better picture quality.")
-	inverse_telecine := store_options_and_help_text_bool("Video", "it", "Perform inverse telecine on 29.97 fps material to return it back to original 24 fps.")
-	main_bitrate_option := store_options_and_help_text_string("Video", "mbr", "", "Override automatic bitrate calculation for main video and define bitrate manually.")
-	no_deinterlace := store_options_and_help_text_bool("Video", "nd", "No Deinterlace. By default deinterlace is always used. This option disables it.")
-	parallel_sd := store_options_and_help_text_bool("Video", "psd", "Parallel SD. Create SD version in parallel to HD processing. This creates an additional version of the video downconverted to SD resolution. The SD file is stored in directory: 00-processed_files/sd")
-	sd_bitrate_option := store_options_and_help_text_string("Video", "sbr", "", "Override automatic bitrate calculation for parallely created sd video and define bitrate manually.")
-	scale_to_sd := store_options_and_help_text_bool("Video", "ssd", "Scale to SD. Scale video down to SD resolution. Calculates resolution automatically. Video is stored in directory 'sd'")
-	burn_timecode := store_options_and_help_text_bool("Video", "tc", "Burn timecode on top of video. Timecode can be used for example to look for exact edit points for the file split feature.")
-
-	// Options that affect both video and audio
-	force_lossless := store_options_and_help_text_bool("Audio and Video", "ls", "Force encoding to use lossless utvideo compression for video and flac compression for audio. This also turns on -fe (1-Pass encode). This option only affects the main video if used with the -psd option.")
-
-	// Subtitle options
-	subtitle_language_option := store_options_and_help_text_string("Subtitle", "s", "", "Burn subtitle with this language code on top of video. Example: -s fin or -s eng or -s ita  Only use option -sn or -s not both.")
-	subtitle_burn_downscale := store_options_and_help_text_bool("Subtitle", "sd", "Subtitle `downscale`. When cropping video widthwise, scale subtitle down to fit on top of the cropped video. This results in a smaller subtitle font. The -sd option affects only subtitle burned on top of video.")
-	subtitle_burn_grayscale := store_options_and_help_text_bool("Subtitle", "sgr", "Subtitle Grayscale. Remove color from subtitle by converting it to grayscale. This option only works with subtitle burned on top of video. If video playback is glitchy every time a subtitle is displayed, then removing color from subtitle may help.")
-	subtitle_stream_number_option := store_options_and_help_text_string("Subtitle", "sn", "-1", "Burn subtitle with this stream number on top of video. Example: -sn 1. Only use option -sn or -s not both.")
-	subtitle_vertical_offset := store_options_and_help_text_string("Subtitle", "so", "0", "Subtitle `offset`, -so 55 (move subtitle 55 pixels down), -so -55 (move subtitle 55 pixels up). This option affects only subtitle burned on top of video. Also check the -sp option that automatically moves subtitles near the edge of the screen.")
-	subtitle_mux_language_option := store_options_and_help_text_string("Subtitle", "sm", "", "Mux subtitles with these language codes into the target file. Example: -sm eng or -sm eng,fra,fin. This only works with dvd, dvb and bluray bitmap based subtitles. Mp4 only supports DVD and DVB subtitles not Bluray. Bluray subtitles can be muxed into an mkv file using the -mkv option.")
-	subtitle_mux_numbers_option := store_options_and_help_text_string("Subtitle", "smn", "", "Mux subtitles with these stream numbers into the target file. Example: -smn 1 or -smn 3,1,7. This only works with dvd, dvb and bluray bitmap based subtitles. Mp4 only supports DVD and DVB subtitles not Bluray. Bluray subtitles can be muxed into an mkv file using the -mkv option.")
-	subtitle_burn_palette := store_options_and_help_text_string("Subtitle", "palette", "", "Hack the dvd subtitle color palette. The subtitle color palette defines the individual colors used in the subtitle (border, middle, etc). This option takes 1-16 comma separated hex numbers ranging from 0 to f. Zero = black, f = white, so only shades between black -> gray -> white can be defined. If you define less than the required 16 numbers then the rest will be filled with f's. Each dvd uses color mapping differently so you need to test which numbers control the colors you want to change. Usually the first 4 numbers control the colors. Example: -palette f,0,f . This option only affects subtitle burned on top of video.")
-	subtitle_burn_split := store_options_and_help_text_bool("Subtitle", "sp", "Subtile Split. Subtitles on DVD's and Blurays often use an unnecessary large font and are positioned too far from the edge of the screen covering too much of the picture. Sometimes subtitles are also displayed on the upper part of the screen and may even cover the actors face. The -sp option detects whether the subtitle is displayed top or bottom half of the screen and then moves it towards that edge of the screen so that it covers less of the picture area. Distance from the screen edge is calculated automatically based on video resolution (picture height divided by 100 and rounded down to nearest integer. Minimum distance is 5 pixels and max 20 pixels). Subtitles are also automatically centered horizontally. Use the -sr option with -sp to resize subtitle. The -sp option affects only subtitles burned on top of video.")
-	subtitle_burn_resize := store_options_and_help_text_string("Subtitle", "sr", "", "Subtitle Resize. Values less than 1 makes subtitles smaller, values bigger than 1 makes them larger. This option can only be used with the -sp option. Example: make subtitle 25% smaller: -sr 0.75   make subtitle 50% smaller: -sr 0.50 make subtitle 75% larger: -sr 1.75. This option affects only subtitle burned on top of video.")
-
-	// Scan options
-	fast_encode_and_search := store_options_and_help_text_bool("Scan", "f", "This is the same as using options -fs and -fe at the same time.")
-	fast_encode := store_options_and_help_text_bool("Scan", "fe", "Fast encoding mode. Encode video using 1-pass encoding. Use this for testing to speed up processing. Video quality will be much lower than with 2-Pass encoding.")
-	fast_search := store_options_and_help_text_bool("Scan", "fs", "Fast seek mode. When using the -fs option with -st do not decode all video before the point we are trying to locate, but instead try to jump directly to it. This will speed up processing but might not find the defined position accurately. Accuracy depends on file format.")
-	scan_mode_only := store_options_and_help_text_bool("Scan", "scan", "Scan input files and print video audio and subtitle stream info.")
-	split_times := store_options_and_help_text_string("Scan", "sf", "", "Split out parts of the file. Give start and stop times for the parts of the file to use. Use either commas and slashes or only commas to separate time values. Example: -sf 0-10:00,01:35:12.800-01:52:14 defines that 0 secs - 10 mins from the start of the file will be used and joined to the next part that starts from 01 hours 35 mins 12 seconds and 800 milliseconds and ends at 01 hours 52 mins 14 seconds. Don't use space - characters. A zero or word 'start' can be used to mark the absolute start of the file and word 'end' the end of the file. Both start and stop times must be defined. Warning while using options -s -sn -sm and -smn: If your cut point is in the middle of a subtitle presentation time (even when muxing subtitles) you may get a video glitch.")
-	search_start_option := store_options_and_help_text_string("Scan", "st", "", "Start time. Start video processing from this timecode. Example -st 30:00 starts processing from 30 minutes from the start of the file.")
-	processing_stop_time := store_options_and_help_text_string("Scan", "et", "", "End time. Stop video processing at this timecode. Example -et 01:30:00 stops processing at 1 hour 30 minutes. You can define a time range like this: -st 10:09 -et 01:22:49.500 This results in a video file that starts at 10 minutes 9 seconds and stops at 1 hour 22 minutes, 49 seconds and 500 milliseconds.")
-	processing_duration := store_options_and_help_text_string("Scan", "d", "", "Duration of video to process. Example -d 01:02 process 1 minutes and 2 seconds of the file. Use either -et or -d option not both.")
-
-	// Misc options
-	// If you want to print debug info then change debug to "true" below
-	debug_option := store_options_and_help_text_bool("Misc", "debug", "Turn on debug mode and show info about internal variables and the FFmpeg commandlines used.")
-	use_matroska_container := store_options_and_help_text_bool("Misc", "mkv", "Use matroska (mkv) as the output file wrapper format.")
-	only_print_commands := store_options_and_help_text_bool("Misc", "print", "Print FFmpeg commands that would be used for processing, don't process any files.")
-	show_program_version_short := store_options_and_help_text_bool("Misc", "v", "Show the version of FFcommander.")
-	show_program_version_long := store_options_and_help_text_bool("Misc", "version", "Show the version of FFcommander.")
-	temp_file_directory := store_options_and_help_text_string("Misc", "td", "", "Path to directory for temporary files, example_ -td PathToDir. This option directs temporary files created with 2-pass encoding and subtitle processing (-sp) to a separate directory. Processing with the -sp switch goes much faster when temporary files are created on a ram or ssd - disk. The -sp switch extracts every frame of a movie as a tiff image, so you need to have lots of free space in the temp directory. For a FullHD movie you need 20 GB or more storage for temporary files. Subtitle extraction with the -sp switch fails silently if you run out of storage space. If this happens then some of the last subtitles won't be available when the video is compressed and this results the last available subtitle being 'stuck' on top of video until the end of the movie. This is a limitation in how FFmpeg works and cannot be worked around.")
-	help := store_options_and_help_text_bool("Misc", "h", "Display help text.")
-
-	//////////////////////
-	// Define variables //
-	//////////////////////
-
-	var input_filenames []string
-	var deinterlace_options string
-	var grayscale_options string
-	var subtitle_processing_options string
-	var timecode_burn_options string
-	var ffmpeg_pass_1_commandline []string
-	var ffmpeg_pass_2_commandline []string
-	var ffmpeg_subtitle_extract_commandline []string
-	var ffmpeg_file_split_commandline []string
-	var final_crop_string string
-	var command_to_run_str_slice []string
-	var file_to_process, video_width, video_height, video_duration, video_codec_name, color_subsampling, color_space string
-	var video_height_int int
-	var main_video_2_pass_bitrate_str string
-	var audio_language, for_visually_impared, number_of_audio_channels, audio_codec string
-	var subtitle_language, for_hearing_impared, subtitle_codec_name string
-	var crop_values_picture_width int
-	var crop_values_picture_height int
-	var crop_values_width_offset int
-	var crop_values_height_offset int
-	var unsorted_ffprobe_information_str_slice []string
-	var ffprobe_error_message []string
-	var error_code error
-	var error_messages_map = make (map[string][]string)
-	var file_counter int
-	var file_counter_str string
-	var files_to_process_str string
-	var subtitle_horizontal_offset_int int
-	var subtitle_horizontal_offset_str string
-	var cut_list_seconds_str_slice []string
-	var split_video bool
-	var split_info_filename string
-	var split_info_file_absolute_path string
-	var list_of_splitfiles []string
-	var cut_positions_as_timecodes []string
-	var timecode_font_size int
-	var orig_subtitle_path, cropped_subtitle_path string
-	var selected_streams = make(map[string][]string)
-
-	start_time := time.Now()
-	file_split_start_time := time.Now()
-	file_split_elapsed_time := time.Since(file_split_start_time)
-	pass_1_start_time := time.Now()
-	pass_1_elapsed_time := time.Since(pass_1_start_time)
-	pass_2_start_time := time.Now()
-	pass_2_elapsed_time := time.Since(pass_2_start_time)
-	subtitle_extract_start_time := time.Now()
-	subtitle_extract_elapsed_time := time.Since(subtitle_extract_start_time)
-	subtitle_processing_start_time := time.Now()
-	subtitle_processing_elapsed_time := time.Since(subtitle_extract_start_time)
-
-	output_directory_name := "00-processed_files"
-	sd_directory_name := "sd"
-	subtitle_extract_dir := "subtitles"
-	original_subtitles_dir := "original_subtitles"
-	fixed_subtitles_dir := "fixed_subtitles"
-
-	///////////////////////////////
-	// Parse commandline options //
-	///////////////////////////////
-	input_filenames = parse_options()
-
-	if help.is_turned_on == true {
-		display_help_text()
-	}
-	/////////////////////////////////////////////////////////
-	// Test if needed executables can be found in the path //
-	/////////////////////////////////////////////////////////
-	find_executable_path("ffmpeg")
-	find_executable_path("ffprobe")
-
-	if subtitle_burn_split.is_turned_on == true {
-		find_executable_path("magick") // Starting from ImageMagick 7 the "magick" command should be used instead of the "convert" - command.
-		find_executable_path("mogrify")
-		os.Setenv("MAGICK_THREAD_LIMIT", "1") // Disable ImageMagick multithreading it only makes processing slower. This sets an environment variable in the os.
-	}
-
-	// Test that user gave a string not a number for options -a and -s
-	if _, err := strconv.Atoi(audio_language_option.user_string); err == nil {
-		fmt.Println()
-		fmt.Println("The option -a requires a language code like: eng, fin, ita not a number.")
-		fmt.Println()
-		os.Exit(0)
-	}
-
-	if _, err := strconv.Atoi(subtitle_language_option.user_string); err == nil {
-		fmt.Println()
-		fmt.Println("The option -s requires a language code like: eng, fin, ita not a number.")
-		fmt.Println()
-		os.Exit(0)
-	}
-
-	if subtitle_burn_resize.user_string != "" && subtitle_burn_split.is_turned_on == false {
-		fmt.Println()
-		fmt.Println("Subtitle resize can only be used with the -sp option, not alone.")
-		fmt.Println()
-		os.Exit(0)
-	}
-
-	// Test if user gave a valid float on the commandline
-	if subtitle_burn_resize.user_string != "" {
-
-		subtitle_burn_resize.user_string = sanitize_float(subtitle_burn_resize.user_string, debug_option.is_turned_on)
-		subtitle_resize_float, float_parse_error := strconv.ParseFloat(subtitle_burn_resize.user_string, 64)
-
-		if  float_parse_error != nil || subtitle_resize_float == 0.0 {
-
-			fmt.Println("Error:", subtitle_burn_resize.user_string, "is not a valid number.")
-			os.Exit(1)
-		}
-	}
-
-	// Convert time values used in splitting the inputfile to seconds
-	if split_times.user_string != "" {
-		split_video = true
-		use_matroska_container.is_turned_on = true
-		cut_list_seconds_str_slice, cut_positions_as_timecodes = process_split_times(split_times.user_string, debug_option.is_turned_on)
-	}
-
-	// Parallel SD processing requires defining inputfile seek for each output file or placing the seek before the inputfile.
-	// We choose to place the seek before the inputfile. This results using the fast but sometimes inaccurate FFmpeg seek.
-	if parallel_sd.is_turned_on == true || scale_to_sd.is_turned_on == true {
-		fast_search.is_turned_on = true
-	}
-
-	// Test if user gave more than one audio compression option
-	var audio_compression_slice []string
-
-	if audio_compression_ac3.is_turned_on == true {
-		audio_compression_slice = append(audio_compression_slice, "-ac3")
-	}
-
-	if audio_compression_aac.is_turned_on == true {
-		audio_compression_slice = append(audio_compression_slice, "-aac")
-	}
-
-	if audio_compression_opus.is_turned_on == true {
-		audio_compression_slice = append(audio_compression_slice, "-opus")
-	}
-
-	if audio_compression_flac.is_turned_on == true {
-		audio_compression_slice = append(audio_compression_slice, "-flac")
-	}
-
-	if len(audio_compression_slice) > 1 {
-		fmt.Println()
-		fmt.Printf("Error: more than one audio compression options on the commandline: ")
-
-		for _, item := range(audio_compression_slice) {
-			fmt.Printf(item + " ")
-		}
-
-		fmt.Println("\n")
-		os.Exit(0)
-	}
-
-	if force_lossless.is_turned_on == true && len(audio_compression_slice) >= 1 {
-		fmt.Println()
-		fmt.Printf("Error: -ls (lossless processing) cannot be used at the same time as audio compression options: ")
-
-			for _, item := range(audio_compression_slice) {
-				fmt.Printf(item + " ")
-			}
-
-		fmt.Println("\n")
-		os.Exit(0)
-	}
-
-	if audio_language_option.is_turned_on == true && audio_stream_number_option.is_turned_on == true {
-		fmt.Println()
-		fmt.Println("Error: options -a and -an can't be used at the same time.")
-		fmt.Println()
-		os.Exit(0)
-	}
-
-	if no_audio.is_turned_on == true {
-
-		if audio_language_option.is_turned_on == true || audio_stream_number_option.is_turned_on == true {
-			fmt.Println()
-			fmt.Printf("Error: -na (no audio) cannot be used at the same time as audio selection options.")
-			fmt.Println()
-			os.Exit(0)
-		}
-	}
-
-	if crf_option.is_turned_on == true {
-
-		if fast_encode_and_search.is_turned_on == true || fast_encode.is_turned_on == true {
-			fmt.Println()
-			fmt.Println("Error: option -crf can't be used at the same time as options -f or -fe.")
-			fmt.Println()
-			os.Exit(0)
-		}
-
-		if main_bitrate_option.is_turned_on == true {
-			fmt.Println()
-			fmt.Println("Error: options -crf and -mbr can't be used at the same time.")
-			fmt.Println()
-			os.Exit(0)
-		}
-	}
-
-	if processing_stop_time.is_turned_on == true && processing_duration.is_turned_on == true {
-		fmt.Println()
-		fmt.Println("Error: options -et and -d can't be used at the same time.")
-		fmt.Println()
-		os.Exit(0)
-	}
-
-	if subtitle_language_option.is_turned_on == true && subtitle_stream_number_option.is_turned_on == true {
-		fmt.Println()
-		fmt.Println("Error: options -s and -sn can't be used at the same time.")
-		fmt.Println()
-		os.Exit(0)
-	}
-
-	if subtitle_burn_split.is_turned_on == true && subtitle_vertical_offset.is_turned_on == true {
-		fmt.Println()
-		fmt.Println("Error: options -sp and -so can't be used at the same time.")
-		fmt.Println()
-		os.Exit(0)
-	}
-
-	if split_times.is_turned_on == true {
-
-		if search_start_option.is_turned_on == true || processing_stop_time.is_turned_on == true {
-			fmt.Println()
-			fmt.Println("Error: options -sp can't be used at the same time as -st or -et.")
-			fmt.Println()
-			os.Exit(0)
-		}
-	}
-
-	// -f option turns on both options -fs and -fe
-	if fast_encode_and_search.is_turned_on == true {
-		fast_search.is_turned_on = true
-		fast_encode.is_turned_on = true
-	}
-
-	if search_start_option.user_string == "" && processing_stop_time.user_string != "" {
-			fmt.Println()
-			fmt.Println("Error, start time missing")
-			fmt.Println()
-			os.Exit(0)
-	}
-
-	// Convert processing end time to duration and store it in variable used with -d option (duration).
-	// FFmpeg does not understarnd end times, only start time + duration.
-	if processing_stop_time.user_string != "" {
-
-		start_time := ""
-		end_time := ""
-		error_happened := ""
-
-		if search_start_option.user_string != "" {
-			start_time, error_happened = convert_timecode_to_seconds(search_start_option.user_string)
-
-			if error_happened != "" {
-				fmt.Println("\nError when converting start time to seconds: " + error_happened + "\n")
-				os.Exit(1)
-			}
-		}
-
-		start_time_int, atoi_error := strconv.Atoi(start_time)
-
-		if atoi_error != nil {
-			fmt.Println()
-			fmt.Println("Error converting start time", search_start_option.user_string, "to integer")
-			fmt.Println()
-			os.Exit(0)
-		}
-
-		end_time, error_happened = convert_timecode_to_seconds(processing_stop_time.user_string)
-
-		if error_happened != "" {
-			fmt.Println("\nError when converting end time to seconds: " + error_happened + "\n")
-			os.Exit(1)
-		}
-
-		end_time_int, atoi_error := strconv.Atoi(end_time)
-
-		if atoi_error != nil {
-			fmt.Println()
-			fmt.Println("Error converting end time", processing_stop_time.user_string, "to integer")
-			fmt.Println()
-			os.Exit(0)
-		}
-
-		duration_int := end_time_int - start_time_int
-
-		if duration_int <= 0 {
-			fmt.Println()
-			fmt.Println("Error duration cannot be", duration_int)
-			fmt.Println()
-			os.Exit(0)
-		}
-
-		processing_duration.user_string = convert_seconds_to_timecode(strconv.Itoa(duration_int))
-	}
-
-	// Disable -st and -d options if user did use the -sf option and input some edit times
-	if split_video == true {
-		search_start_option.user_string = ""
-		processing_duration.user_string = ""
-	}
-
-	// Always use 1-pass encoding with lossless encoding. Turn on option -fe.
-	if force_lossless.is_turned_on == true {
-		fast_encode.is_turned_on = true
-	}
-
-	if subtitle_burn_split.is_turned_on == true && search_start_option.user_string != "" && fast_encode_and_search.is_turned_on == false && crf_option.is_turned_on == false {
-		fmt.Println("\nOptions -st -sp and 2-pass encoding won't work correctly together.")
-		fmt.Println("You options are:")
-		fmt.Println("disable 2-pass encoding with the -f option")
-		fmt.Println("don't use the -st and -et options")
-		fmt.Println("use the -crf option (Constant Quality).\n")
-		os.Exit(1)
-	}
-
-	// Check dvd palette hacking option string correctness.
-	if subtitle_burn_palette.user_string != "" {
-		temp_slice := strings.Split(subtitle_burn_palette.user_string, ",")
-		subtitle_burn_palette.user_string = ""
-		hex_characters := [17]string{"0", "1", "2", "3", "4", "5", "6", "7", "8", "9", "a", "b", "c", "d", "e", "f"}
-
-		// Test that all characters are valid hex
-		for _, character := range temp_slice {
-
-			hex_match_found := false
-
-			if character == "" {
-				fmt.Println("")
-				fmt.Println("Illegal character: 'empty' in -palette option string. Values must be hex ranging from 0 to f.")
-				fmt.Println("")
-				os.Exit(0)
-			}
-			for _, hex_value := range hex_characters {
-
-				if strings.ToLower(character) == hex_value {
-					hex_match_found = true
-					break
-				}
-			}
-
-			if hex_match_found == false {
-				fmt.Println("")
-				fmt.Println("Illegal character:", character, "in -palette option string. Values must be hex ranging from 0 to f.")
-				fmt.Println("")
-				os.Exit(0)
-			}
-		}
-
-		// Test that user gave between 1 to 16 characters
-		if len(temp_slice) < 1 {
-			fmt.Println("")
-			fmt.Println("Too few (", len(temp_slice), ") hex characters in -palette option string. Please give 1 to 16 characters.")
-			fmt.Println("")
-			os.Exit(0)
-		}
-
-		if len(temp_slice) > 16 {
-			fmt.Println("")
-			fmt.Println("Too many (", len(temp_slice), ") hex characters in -palette option string. Please give 1 to 16 characters.")
-			fmt.Println("")
-			os.Exit(0)
-		}
-
-		// Prepare -palette option string for FFmpeg. It requires 16 hex strings where each consists of 6 hex numbers. Of these every 2 numbers control RBG color.
-		// The user is limited here to use only shades between black -> gray -> white.
-		for counter, character := range temp_slice {
-
-			subtitle_burn_palette.user_string = subtitle_burn_palette.user_string + strings.Repeat(strings.ToLower(character), 6)
-
-			if counter < len(temp_slice)-1 {
-				subtitle_burn_palette.user_string = subtitle_burn_palette.user_string + ","
-			}
-
-		}
-
-		if len(temp_slice) < 16 {
-
-			subtitle_burn_palette.user_string = subtitle_burn_palette.user_string + ","
-
-			for counter := len(temp_slice); counter < 16; counter++ {
-				subtitle_burn_palette.user_string = subtitle_burn_palette.user_string + "ffffff"
-
-				if counter < 15 {
-					subtitle_burn_palette.user_string = subtitle_burn_palette.user_string + ","
-				}
-			}
-		}
+				if counter < 15 {
+					job.Subtitle_burn_palette = job.Subtitle_burn_palette + ","
+				}
+			}
+		}
 	}
 
 	// Parse subtitle list
@@ -2500,35 +1178,15 @@ func main() {
 	subtitle_burn_bool := false
 	highest_subtitle_number_int := -1
 
-	if subtitle_mux_numbers_option.user_string != "" && subtitle_mux_language_option.user_string != "" {
-		fmt.Println()
-		fmt.Println("Error, -sm and -smn cannot be used at the same time.")
-		fmt.Println()
-		os.Exit(0)
-	}
-
-	// Parse subtitle numbers list
-	if subtitle_mux_numbers_option.user_string != "" {
+	// Parse subtitle numbers list. The numbers have already been checked in check_job.
+	if job.Subtitle_mux_numbers != "" {
 
-		user_subtitle_mux_numbers_slice = strings.Split(subtitle_mux_numbers_option.user_string, ",")
-
-		if len(user_subtitle_mux_numbers_slice) == 0 {
-			fmt.Println()
-			fmt.Println("Error parsing subtitle numbers from: ", subtitle_mux_numbers_option.user_string)
-			fmt.Println()
-			os.Exit(0)
-		}
+		user_subtitle_mux_numbers_slice = strings.Split(job.Subtitle_mux_numbers, ",")
 
-		// Check that user gave only numbers for the option and store highest subtitle number
+		// Store highest subtitle number
 		for _, number := range user_subtitle_mux_numbers_slice {
 
-			if number_int, atoi_error := strconv.Atoi(number) ; atoi_error != nil {
-				fmt.Println()
-				fmt.Println("Error parsing subtitle number:", number, "in:", subtitle_mux_numbers_option.user_string)
-				fmt.Println()
-				os.Exit(0)
-
-			} else if number_int > highest_subtitle_number_int {
+			if number_int, _ := strconv.Atoi(number) ; number_int > highest_subtitle_number_int {
 				highest_subtitle_number_int = number_int
 			}
 		}
@@ -2537,203 +1195,51 @@ func main() {
 	}
 
 	// Parse subtitle language code list
-	if subtitle_mux_language_option.user_string != "" {
-
-		user_subtitle_mux_languages_slice = strings.Split(subtitle_mux_language_option.user_string, ",")
-
-		if len(user_subtitle_mux_languages_slice) == 0 {
-			fmt.Println()
-			fmt.Println("Error parsing subtitle languages from: ", subtitle_mux_language_option.user_string)
-			fmt.Println()
-			os.Exit(0)
-		}
-
+	if job.Subtitle_mux_languages != "" {
+		user_subtitle_mux_languages_slice = strings.Split(job.Subtitle_mux_languages, ",")
 		subtitle_mux_bool = true
 	}
 
-	// Check that user given subtitle numer is a number
-	var subtitle_burn_number int
-	var atoi_error error
-	subtitle_burn_vertical_offset_int := 0
-
-	if subtitle_burn_number, atoi_error = strconv.Atoi(subtitle_stream_number_option.user_string) ; atoi_error != nil {
-		fmt.Println()
-		fmt.Println("Error parsing subtitle number:", subtitle_stream_number_option.user_string)
-		fmt.Println()
-		os.Exit(0)
-	}
+	// Subtitle number and offset have already been checked to be numbers in check_job
+	subtitle_burn_number, _ := strconv.Atoi(job.Subtitle_stream_number)
+	subtitle_burn_vertical_offset_int, _ := strconv.Atoi(job.Subtitle_vertical_offset)
 
-	if subtitle_language_option.user_string != "" || subtitle_burn_number  != -1 {
+	if job.Subtitle_language != "" || subtitle_burn_number  != -1 {
 		subtitle_burn_bool = true
 	}
 
-	if subtitle_burn_bool == false && subtitle_burn_grayscale.is_turned_on == true {
-		fmt.Println()
-		fmt.Println("Error, you need to define what subtitle to burn grayscale on top of video.")
-		fmt.Println()
-		os.Exit(0)
-	}
-
-	if subtitle_mux_bool == true && subtitle_burn_bool == true {
-		fmt.Println()
-		fmt.Println("Error, you can only burn a subtitle on video or mux subtitles to the file, not both at the same time.")
-		fmt.Println()
-		os.Exit(0)
-	}
-
 	// Use the first subtitle if user wants subtitle split but did not specify subtitle number
-	if subtitle_burn_split.is_turned_on == true && subtitle_burn_number == -1 {
+	if job.Subtitle_burn_split == true && subtitle_burn_number == -1 {
 		subtitle_burn_number = 0
 	}
 
-	if subtitle_burn_vertical_offset_int, atoi_error = strconv.Atoi(subtitle_vertical_offset.user_string) ; atoi_error != nil {
-		fmt.Println()
-		fmt.Println("Error parsing subtitle offset:", subtitle_vertical_offset.user_string)
-		fmt.Println()
-		os.Exit(0)
-	}
-
 	user_main_bitrate_bool := false
 	user_sd_bitrate_bool := false
 
+	// Encoder settings are written to the processing log of each file
+	video_encoder_settings_str := "Video encoder: " + video_encoder_name + ", preset: " + encode.Get_encoder_option(video_compression_options_hd, encoder_preset_option)
 
-	if default_video_processing == "crf" {
-		crf_option.is_turned_on = true
+	if job.Video_tune != "" {
+		video_encoder_settings_str = video_encoder_settings_str + ", tune: " + job.Video_tune
 	}
 
-	// Check the validity of the user given main video bitrate
-	if len(main_bitrate_option.user_string) > 0  && crf_option.is_turned_on == false{
-
-		if strings.ToLower( string( main_bitrate_option.user_string )[len(main_bitrate_option.user_string) - 1 : ]) != "k" {
-			fmt.Println()
-			fmt.Println("The -mbr option takes a bitrate in the form of: 8000k don't forget the 'k' at the end of the value")
-			fmt.Println()
-			os.Exit(0)
-		}
-
-		number_str := string(main_bitrate_option.user_string)[0:len(main_bitrate_option.user_string) - 1]
-		number_int,err := strconv.Atoi(number_str)
+	if job.Crf == true && job.Quality_target == "" {
+		video_encoder_settings_str = video_encoder_settings_str + ", crf: " + crf_value
+	}
 
-		if err != nil {
-			fmt.Println()
-			fmt.Println("Error cannot convert value", main_bitrate_option.user_string, "to a number.")
-			fmt.Println()
-			os.Exit(0)
-		}
+	// Libaom and libvpx only use constant quality mode when bitrate is set to zero
+	crf_needs_zero_bitrate := video_encoder_name == "libaom-av1" || job.Use_webm_container == true
 
-		if number_int < 1 || number_int > 1000000 {
-			fmt.Println()
-			fmt.Println("Error the bitrate must be between 1 and 1 000 000, for example: 8000k")
-			fmt.Println()
-			os.Exit(0)
-		}
+	// The user given bitrates have already been checked in check_job
+	if job.Main_bitrate != "" && job.Crf == false {
 		user_main_bitrate_bool = true
 	}
 
-	// Check the validity of the user given sd - video bitrate
-	if len(sd_bitrate_option.user_string) > 0  && crf_option.is_turned_on == false {
-
-		if strings.ToLower( string( sd_bitrate_option.user_string )[len( sd_bitrate_option.user_string) - 1 : ]) != "k" {
-			fmt.Println()
-			fmt.Println("The -sbr option takes a bitrate in the form of: 1600k don't forget the 'k' at the end of the value")
-			fmt.Println()
-			os.Exit(0)
-		}
-
-		number_str := string(sd_bitrate_option.user_string)[0:len(sd_bitrate_option.user_string) - 1]
-		number_int,err := strconv.Atoi(number_str)
-
-		if err != nil {
-			fmt.Println()
-			fmt.Println("Error cannot convert value", sd_bitrate_option.user_string, "to a number.")
-			fmt.Println()
-			os.Exit(0)
-		}
-
-		if number_int < 1 || number_int > 1000000 {
-			fmt.Println()
-			fmt.Println("Error the bitrate must be between 1 and 1 000 000, for example: 1600k")
-			fmt.Println()
-			os.Exit(0)
-		}
+	if job.Sd_bitrate != "" && job.Crf == false {
 		user_sd_bitrate_bool = true
 	}
 
-	if parallel_sd.is_turned_on == false && scale_to_sd.is_turned_on == false && user_sd_bitrate_bool == true {
-		fmt.Println()
-		fmt.Println("Error: the -sbr option can be used only with the -psd or -ssd options.")
-		fmt.Println()
-		os.Exit(0)
-	}
-
-	if scale_to_sd.is_turned_on == true && parallel_sd.is_turned_on == true {
-		fmt.Println()
-		fmt.Println("Error: options -psd and -ssd can't be used at the same time.")
-		fmt.Println()
-		os.Exit(0)
-	}
-
-	// Check that the number given for the -abk option is between -1.0 and 1.0
-	// First adjust black, then white, and then gamma.
-	if adjust_black_point.is_turned_on == true {
-
-		adjust_black_point.user_string = sanitize_float(adjust_black_point.user_string, debug_option.is_turned_on)
-
-		if float_is_between_limits(adjust_black_point.user_string, "-1.0", "1.0", debug_option.is_turned_on) == false {
-			fmt.Println()
-			fmt.Println("Error: value for option -abk must be between -1.0 and 1.0")
-			fmt.Println()
-			os.Exit(0)
-		}
-	}
-
-	// Check that the number given for the -awh option is between -1.0 and 1.0
-	if adjust_white_point.is_turned_on == true {
-
-		adjust_white_point.user_string = sanitize_float(adjust_white_point.user_string, debug_option.is_turned_on)
-
-		if float_is_between_limits(adjust_white_point.user_string, "-1.0", "1.0", debug_option.is_turned_on) == false {
-			fmt.Println()
-			fmt.Println("Error: value for option -awh must be between -1.0 and 1.0")
-			fmt.Println()
-			os.Exit(0)
-		}
-	}
-
-	// Check that the number given for the -agm option is between 0.1 and 10
-	if adjust_gamma.is_turned_on == true {
-
-		adjust_gamma.user_string = sanitize_float(adjust_gamma.user_string, debug_option.is_turned_on)
-
-		if float_is_between_limits(adjust_gamma.user_string, "0.1", "10.0", debug_option.is_turned_on) == false {
-			fmt.Println()
-			fmt.Println("Error: value for option -agm must be between 0.1 and 10")
-			fmt.Println()
-			os.Exit(0)
-		}
-	}
-
-	// Check that the number given for the -ach option is between 0.0 and 3.0
-	if adjust_chroma.is_turned_on == true {
-
-		adjust_chroma.user_string = sanitize_float(adjust_chroma.user_string, debug_option.is_turned_on)
-
-		if float_is_between_limits(adjust_chroma.user_string, "0.0", "3.0", debug_option.is_turned_on) == false {
-			fmt.Println()
-			fmt.Println("Error: value for option -ach must be between 0.0 and 3.0")
-			fmt.Println()
-			os.Exit(0)
-		}
-	}
-
-	if debug_option.is_turned_on == true && only_print_commands.is_turned_on == true {
-		fmt.Println()
-		fmt.Println("Error: options -debug and -print can't be used at the same time.")
-		fmt.Println()
-		os.Exit(0)
-	}
-
-	if debug_option.is_turned_on == true {
+	if job.Debug == true {
 
 		fmt.Println()
 		fmt.Println("Subtitle numbers:")
@@ -2757,63 +1263,7 @@ func main() {
 		fmt.Println()
 	}
 
-	// Check if user given path to temp folder exists and if it is writable
-	if temp_file_directory.user_string != "" {
-
-		// Get directory info from filesystem
-		dir_stat, err := os.Stat(temp_file_directory.user_string)
-
-		if os.IsNotExist(err) {
-			fmt.Println()
-			fmt.Println("Path to temp file dir:", temp_file_directory.user_string, "does not exist.")
-			fmt.Println()
-			os.Exit(0)
-		}
-
-		if dir_stat.IsDir() == false {
-			fmt.Println()
-			fmt.Println("Path to -td option:", temp_file_directory.user_string, "is not a directory.")
-			fmt.Println()
-			os.Exit(0)
-		}
-
-		// Test if temp dir is writable
-		testfile_path := filepath.Join(temp_file_directory.user_string, "Testfile.txt")
-		err = os.WriteFile(testfile_path, []byte("This is a test file and can be deleted\n"), 0644)
-
-		if err != nil {
-			fmt.Println()
-			fmt.Println("Tempfile path", temp_file_directory.user_string, "is not writable.")
-			fmt.Println()
-			os.Exit(0)
-		}
-
-		os.Remove(testfile_path)
-	}
-
-	// Print program version and license info.
-	if show_program_version_short.is_turned_on == true || show_program_version_long.is_turned_on == true {
-		fmt.Println()
-		fmt.Println("(C) Mikael Hartzell 2018.")
-		fmt.Println()
-		fmt.Println("FFmpeg version 3 or higher is required to use this program.")
-		fmt.Println("Subtitle processing with the -sp option requires ImageMagick.")
-		fmt.Println()
-		fmt.Println("This program is distributed under the GNU General Public License, version 3 (GPLv3)")
-		fmt.Println("Check the license here: http://www.gnu.org/licenses/gpl.txt")
-		fmt.Println("Basically this license gives you full freedom to do what ever you want with this program.")
-		fmt.Println("You are free to use, modify, distribute it any way you like.")
-		fmt.Println("The only restriction is that if you make derivate works of this program AND distribute those,")
-		fmt.Println("the derivate works must also be licensed under GPL 3.")
-		fmt.Println()
-		fmt.Println("This program is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY;")
-		fmt.Println("without even the implied warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.")
-		fmt.Println("See the GNU General Public License for more details")
-		fmt.Println()
-		os.Exit(0)
-	}
-
-	var ffmpeg_commandline_start []string
+	var ffmpeg_global_options []string
 	subtitle_stream_image_format := "tiff" // FFmpeg png extract is 30x slower than tiff, thats why we default to tiff.
 
 	// Determine output file container
@@ -2823,14 +1273,20 @@ func main() {
 	output_filename_extension := output_mp4_filename_extension
 	output_matroska_wrapper_format := "matroska"
 
-	if force_lossless.is_turned_on == true || use_matroska_container.is_turned_on == true {
+	if job.Force_lossless == true || job.Use_matroska_container == true {
 		// Use matroska as the output file wrapper format
 		output_video_format = nil
 		output_video_format = append(output_video_format, "-f", output_matroska_wrapper_format)
 		output_filename_extension = output_matroska_filename_extension
 	}
 
-	if no_deinterlace.is_turned_on == true {
+	// WebM is checked after matroska, since the -sf option turns on -mkv
+	if job.Use_webm_container == true {
+		output_video_format = []string{"-f", "webm"}
+		output_filename_extension = ".webm"
+	}
+
+	if job.No_deinterlace == true {
 		deinterlace_options = "copy"
 	} else {
 		// Deinterlacing options used to be: "idet,yadif=0:deint=interlaced"  which tries to detect
@@ -2840,7 +1296,7 @@ func main() {
 		deinterlace_options = "idet,yadif=0:deint=all"
 	}
 
-	number_of_physical_processors, err := get_number_of_physical_processors()
+	number_of_physical_processors, err := encode.Get_number_of_physical_processors()
 
 	if number_of_physical_processors < 1 || err != nil {
 		number_of_physical_processors = 2
@@ -2891,7 +1347,7 @@ func main() {
 		}
 
 		// For parallel HD and SD compression use max 16 cores
-		if parallel_sd.is_turned_on == true {
+		if job.Parallel_sd == true {
 
 			if number_of_physical_processors >= 16 {
 				number_of_threads_to_use_for_video_compression = "16"
@@ -2899,14 +1355,14 @@ func main() {
 		}
 	}
 
-	if debug_option.is_turned_on == true {
-		ffmpeg_commandline_start = append(ffmpeg_commandline_start, "ffmpeg", "-y", "-hide_banner", "-threads", number_of_threads_to_use_for_video_compression)
+	if job.Debug == true {
+		ffmpeg_global_options = append(ffmpeg_global_options, "-y", "-hide_banner", "-threads", number_of_threads_to_use_for_video_compression)
 	} else {
-		ffmpeg_commandline_start = append(ffmpeg_commandline_start, "ffmpeg", "-y", "-loglevel", "level+error", "-threads", number_of_threads_to_use_for_video_compression)
+		ffmpeg_global_options = append(ffmpeg_global_options, "-y", "-loglevel", "level+error", "-threads", number_of_threads_to_use_for_video_compression)
 	}
 
-	if subtitle_burn_split.is_turned_on == true && search_start_option.user_string != "" {
-		ffmpeg_commandline_start = append(ffmpeg_commandline_start, "-fflags", "+genpts")
+	if job.Subtitle_burn_split == true && job.Search_start != "" && split_video == false {
+		ffmpeg_global_options = append(ffmpeg_global_options, "-fflags", "+genpts")
 	}
 
 	///////////////////////////////
@@ -2915,53 +1371,40 @@ func main() {
 
 	for _, inputfile_full_path := range input_filenames {
 
-		// Get video info with: ffprobe -loglevel level+error -show_entries format:stream -print_format flat -i InputFile
-		command_to_run_str_slice = nil
-		command_to_run_str_slice = append(command_to_run_str_slice, "ffprobe", "-loglevel", "level+error", "-show_entries", "format:stream", "-print_format", "flat", "-i")
-
-		if debug_option.is_turned_on == true {
+		if job.Debug == true {
 			fmt.Println()
-			fmt.Println("command_to_run_str_slice:", command_to_run_str_slice, inputfile_full_path)
+			fmt.Println("Scanning file:", inputfile_full_path)
 		}
 
-		command_to_run_str_slice = append(command_to_run_str_slice, inputfile_full_path)
-
-		unsorted_ffprobe_information_str_slice, ffprobe_error_message, error_code = run_external_command(command_to_run_str_slice)
-
-		if error_code != nil {
-
-			fmt.Println("\n\nFFprobe reported error:", "\n")
+		// Get specific video, audio and subtitle stream information and store it in: media_file_info_slice
+		media_file, probe_error := probe.Probe(processing_context, inputfile_full_path)
 
-			if len(unsorted_ffprobe_information_str_slice) != 0 {
-				for _, textline := range unsorted_ffprobe_information_str_slice {
-					fmt.Println(textline)
-				}
-			}
-
-			if len(ffprobe_error_message) != 0 {
-				for _, textline := range ffprobe_error_message {
-					fmt.Println(textline)
-				}
-			}
-
-			os.Exit(1)
+		// A file that can't be scanned is reported as failed and the rest of the files are processed.
+		if probe_error != nil {
+			fmt.Println()
+			fmt.Println("Error, could not scan file:", inputfile_full_path)
+			fmt.Println(probe_error)
+			error_messages_map[inputfile_full_path] = append(error_messages_map[inputfile_full_path], probe_error.Error())
+			continue
 		}
 
-		// Sort info about video and audio streams in the file to a map. This funtion stores data in global variable: Complete_stream_info_map
-		sort_raw_ffprobe_information(unsorted_ffprobe_information_str_slice)
-
-		// Get specific video and audio stream information. This function stores data in global variable: Complete_file_info_slice
-		get_video_and_audio_stream_information(inputfile_full_path)
+		media_file_info_slice = append(media_file_info_slice, media_file)
+	}
 
+	if processing_context.Err() != nil {
+		fmt.Println()
+		fmt.Println("Processing was interrupted.")
+		fmt.Println()
+		return processing_context.Err()
 	}
 
-	if debug_option.is_turned_on == true {
+	if job.Debug == true {
 
 		fmt.Println()
-		fmt.Println("Complete_file_info_slices:")
+		fmt.Println("media_file_info_slice:")
 
-		for _, temp_slice := range Complete_file_info_slice {
-			fmt.Println(temp_slice)
+		for _, media_file := range media_file_info_slice {
+			fmt.Printf("%+v\n", media_file)
 		}
 	}
 
@@ -2970,22 +1413,24 @@ func main() {
 	//////////////////////
 
 	// Only scan the input files, display their stream properties and exit.
-	if scan_mode_only.is_turned_on == true {
-
-		for _, file_info_slice := range Complete_file_info_slice {
-			video_slice_temp := file_info_slice[0]
-			video_slice := video_slice_temp[0]
-			audio_slice := file_info_slice[1]
-			subtitle_slice := file_info_slice[2]
-
-			file_to_process = video_slice[0]
-			video_width = video_slice[1]
-			video_height = video_slice[2]
-			video_codec_name = video_slice[4]
-			color_subsampling = video_slice[5]
-			color_space = video_slice[6]
-			frame_rate_str := video_slice[7]
-			frame_rate_average_str := video_slice[8]
+	if job.Scan_mode_only == true {
+
+		for _, media_file := range media_file_info_slice {
+
+			var video_stream probe.Video_stream_struct
+
+			if len(media_file.Video_streams) > 0 {
+				video_stream = media_file.Video_streams[0]
+			}
+
+			file_to_process = media_file.File_name
+			video_width = video_stream.Width
+			video_height = video_stream.Height
+			video_codec_name = video_stream.Codec_name
+			color_subsampling = video_stream.Color_subsampling
+			color_space = video_stream.Color_space
+			frame_rate_str := probe.Format_frame_rate(video_stream.Frame_rate)
+			frame_rate_average_str := probe.Format_frame_rate(video_stream.Average_frame_rate)
 
 			fmt.Println()
 			subtitle_text := "File name '" + file_to_process + "'"
@@ -2997,36 +1442,41 @@ func main() {
 				fmt.Println("\033[7mWarning: Video frame rate is 29.970. You may need to pullup (Inverse Telecine) this video with option -it\033[0m")
 			}
 
-			fmt.Printf("Video width: %s, height: %s, codec: %s, color subsampling: %s, color space: %s, fps: %s, average fps: %s\n", video_width, video_height, video_codec_name, color_subsampling, color_space, frame_rate_str, frame_rate_average_str)
+			fmt.Printf("Video width: %d, height: %d, codec: %s, color subsampling: %s, color space: %s, fps: %s, average fps: %s\n", video_width, video_height, video_codec_name, color_subsampling, color_space, frame_rate_str, frame_rate_average_str)
 
 			fmt.Println()
 
-			for audio_stream_number, audio_info := range audio_slice {
+			for audio_stream_number, audio_stream := range media_file.Audio_streams {
 
-				audio_language = audio_info[0]
-				for_visually_impared = audio_info[1]
-				number_of_audio_channels = audio_info[2]
-				audio_codec = audio_info[4]
+				audio_language = audio_stream.Language
+				for_visually_impared = audio_stream.Disposition["visual_impaired"]
+				number_of_audio_channels = audio_stream.Number_of_channels
+				audio_codec = audio_stream.Codec_name
 
-				fmt.Printf("Audio stream number: %d, language: %s, for visually impared: %s, number of channels: %s, audio codec: %s\n", audio_stream_number, audio_language, for_visually_impared, number_of_audio_channels, audio_codec)
+				fmt.Printf("Audio stream number: %d, language: %s, for visually impared: %d, number of channels: %d, audio codec: %s\n", audio_stream_number, audio_language, for_visually_impared, number_of_audio_channels, audio_codec)
 			}
 
 			fmt.Println()
 
-			for subtitle_stream_number, subtitle_info := range subtitle_slice {
+			for subtitle_stream_number, subtitle_stream := range media_file.Subtitle_streams {
 
-				subtitle_language = subtitle_info[0]
-				for_hearing_impared = subtitle_info[1]
-				subtitle_codec_name = subtitle_info[2]
+				subtitle_language = subtitle_stream.Language
+				for_hearing_impared = subtitle_stream.Disposition["hearing_impaired"]
+				subtitle_codec_name = subtitle_stream.Codec_name
 
-				fmt.Printf("Subtitle stream number: %d, language: %s, for hearing impared: %s, codec name: %s\n", subtitle_stream_number, subtitle_language, for_hearing_impared, subtitle_codec_name)
+				fmt.Printf("Subtitle stream number: %d, language: %s, for hearing impared: %d, codec name: %s\n", subtitle_stream_number, subtitle_language, for_hearing_impared, subtitle_codec_name)
 			}
 
 			fmt.Println()
 		}
 
 		fmt.Println()
-		os.Exit(0)
+
+		if len(error_messages_map) > 0 {
+			return errors.New(strconv.Itoa(len(error_messages_map)) + " files could not be scanned")
+		}
+
+		return nil
 	}
 
 	///////////////////////////////////////////////////////////////////////////////////////////////////////////////////
@@ -3035,59 +1485,240 @@ func main() {
 
 	var subtitles_selected_for_muxing_map = make(map[string][]string)
 
-	for _, file_info_slice := range Complete_file_info_slice {
+	for _, media_file := range media_file_info_slice {
+
+		var audio_stream_numbers []int
+		inputfile_full_path := media_file.File_name
+
+		if len(media_file.Video_streams) == 0 || media_file.Video_streams[0].Width == 0 || media_file.Video_streams[0].Height == 0 {
+
+			var error_messages []string
+
+			if _, item_found := error_messages_map[inputfile_full_path]; item_found == true {
+				error_messages = error_messages_map[inputfile_full_path]
+			}
+
+			error_messages = append(error_messages, "File does not have a video stream.")
+			error_messages_map[inputfile_full_path] = error_messages
+
+			continue
+		}
+
+		//////////////////////////////////////////////////////////////////////////////////////////////////
+		// If user gave us audio languages (fin, eng, ita), find the corresponding audio stream numbers //
+		// If no matching audio is found stop the program.                                              //
+		//////////////////////////////////////////////////////////////////////////////////////////////////
+		if job.Audio_all == true {
+
+			// Keep all audio streams in their original order
+			for audio_stream_number := range media_file.Audio_streams {
+				audio_stream_numbers = append(audio_stream_numbers, audio_stream_number)
+			}
+
+			if len(audio_stream_numbers) == 0 {
+
+				var error_messages []string
+
+				if _, item_found := error_messages_map[inputfile_full_path]; item_found == true {
+					error_messages = error_messages_map[inputfile_full_path]
+				}
+
+				error_messages = append(error_messages, "Error, file does not have any audio streams")
+				error_messages_map[inputfile_full_path] = error_messages
+			}
+
+		} else if job.Audio_language != "" {
+
+			for _, user_audio_language := range strings.Split(job.Audio_language, ",") {
+
+				audio_stream_found := false
+
+				for audio_stream_number, audio_stream := range media_file.Audio_streams {
+					audio_language = audio_stream.Language
+
+					// The same language may be given many times (-a eng,eng), then the next audio stream with the language is selected
+					if user_audio_language == audio_language && plan.Int_is_in_slice(audio_stream_number, audio_stream_numbers) == false {
+						audio_stream_numbers = append(audio_stream_numbers, audio_stream_number)
+						audio_stream_found = true
+						break
+					}
+				}
+
+				if audio_stream_found == false {
+
+					var error_messages []string
+
+					if _, item_found := error_messages_map[inputfile_full_path]; item_found == true {
+						error_messages = error_messages_map[inputfile_full_path]
+					}
+
+					error_messages = append(error_messages, "Error, file does not have audio language: " + user_audio_language)
+					error_messages_map[inputfile_full_path] = error_messages
+
+				} else if job.Debug == true {
+					fmt.Println()
+					fmt.Printf("Audio: %s was found in audio stream number: %d\n", user_audio_language, audio_stream_numbers[len(audio_stream_numbers) - 1])
+					fmt.Println()
+				}
+			}
+
+		} else {
+
+			// User did not give audio language code (fin, eng, ita). Find the wanted audio streams by number (starts from 0).
+			// Either user defined audio stream numbers on the commandline (-an or -amap) or we use the audio stream number 0.
+			user_audio_stream_numbers := strings.Split(job.Audio_stream_number, ",")
+
+			if job.Audio_map != "" {
+
+				user_audio_stream_numbers = nil
+
+				for _, audio_map_entry := range audio_map {
+					user_audio_stream_numbers = append(user_audio_stream_numbers, strconv.Itoa(audio_map_entry.Stream_number))
+				}
+			}
+
+			for _, audio_stream_number_str := range user_audio_stream_numbers {
+
+				audio_stream_number_int, _ := strconv.Atoi(audio_stream_number_str)
+
+				var error_messages []string
+
+				if _, item_found := error_messages_map[inputfile_full_path]; item_found == true {
+					error_messages = error_messages_map[inputfile_full_path]
+				}
+
+				if audio_stream_number_int < 0 || audio_stream_number_int > len(media_file.Audio_streams) - 1 {
+
+					// The audio stream number is higher than any stream number in the input file.
+					error_messages = append(error_messages, "Error, file does not have an audio stream number: " + strconv.Itoa(audio_stream_number_int))
+					error_messages_map[inputfile_full_path] = error_messages
+
+				} else if plan.Int_is_in_slice(audio_stream_number_int, audio_stream_numbers) == true {
+
+					error_messages = append(error_messages, "Error, audio stream number: " + strconv.Itoa(audio_stream_number_int) + " is selected more than once")
+					error_messages_map[inputfile_full_path] = error_messages
+
+				} else {
+
+					// There is a audio stream for the audio stream number we have.
+					// Either user defined the number on the commandline or we use the default value of 0 (first audio in source file).
+					audio_stream_numbers = append(audio_stream_numbers, audio_stream_number_int)
+				}
+			}
+		}
+
+		// Audio compression options given on the commandline override the default audio processing
+		user_audio_compression_bool := job.Audio_compression_ac3 == true || job.Audio_compression_aac == true || job.Audio_compression_opus == true || job.Audio_compression_flac == true || job.Force_lossless == true
+
+		// Adaptive bitrate streams are compressed to aac by default, since copied audio may be in a format that streaming players can't play.
+		// Loudness normalized audio and audio with an offset can't be copied either.
+		if (default_audio_processing == "aac" || (default_audio_processing == "copy" && (job.Abr != "" || job.Loudness_normalization != "" || job.Audio_offset != ""))) && user_audio_compression_bool == false {
+			job.Audio_compression_aac = true
+		} else if default_audio_processing == "opus" && user_audio_compression_bool == false {
+			job.Audio_compression_opus = true
+		}
+
+
+		// Check every selected audio stream against the limits of the audio codec it is stored with
+		for _, audio_stream_number := range audio_stream_numbers {
+
+			audio_stream := media_file.Audio_streams[audio_stream_number]
+			number_of_audio_channels = audio_stream.Number_of_channels
+			source_audio_codec := strings.ToLower(audio_stream.Codec_name)
+			audio_stream_number_str := strconv.Itoa(audio_stream_number)
+
+			if source_audio_codec == "ac-3" {
+				source_audio_codec = "ac3"
+			}
+
+			audio_codec = source_audio_codec
+
+			if job.Audio_compression_aac == true {
+				audio_codec = "aac"
+			}
+
+			if job.Audio_compression_opus == true {
+				audio_codec = "opus"
+			}
+
+			if job.Audio_compression_ac3 == true {
+				audio_codec = "ac3"
+			}
+
+			if job.Force_lossless == true {
+				audio_codec = "flac"
+			}
+
+			if job.Audio_compression_flac == true {
+				audio_codec = "flac"
+			}
+
+			audio_stream_is_copied := job.Audio_compression_aac == false && job.Audio_compression_opus == false && job.Audio_compression_ac3 == false && job.Audio_compression_flac == false && job.Force_lossless == false
+			user_defined_number_of_channels := false
+
+			// The codec and number of channels given to the stream with -amap override the other audio options
+			for _, audio_map_entry := range audio_map {
+
+				if audio_map_entry.Stream_number != audio_stream_number {
+					continue
+				}
 
-		var audio_stream_number_int int
-		video_slice_temp := file_info_slice[0]
-		video_slice := video_slice_temp[0]
-		inputfile_full_path := video_slice[0]
-		video_width := video_slice[1]
-		video_height := video_slice[2]
+				if audio_map_entry.Codec == "copy" && job.Loudness_normalization != "" {
 
-		audio_slice := file_info_slice[1]
-		audio_stream_found := false
+					var error_messages []string
 
-		subtitle_slice := file_info_slice[2]
+					if _, item_found := error_messages_map[inputfile_full_path]; item_found == true {
+						error_messages = error_messages_map[inputfile_full_path]
+					}
 
-		if video_width == "0" || video_height == "0" {
+					error_messages = append(error_messages, "Error, audio stream " + audio_stream_number_str + " is copied with -amap, but loudness normalization (-norm) needs the audio to be compressed")
+					error_messages_map[inputfile_full_path] = error_messages
+				}
 
-			var error_messages []string
+				if audio_map_entry.Codec == "copy" && job.Audio_offset != "" {
 
-			if _, item_found := error_messages_map[inputfile_full_path]; item_found == true {
-				error_messages = error_messages_map[inputfile_full_path]
-			}
+					var error_messages []string
 
-			error_messages = append(error_messages, "File does not have a video stream.")
-			error_messages_map[inputfile_full_path] = error_messages
+					if _, item_found := error_messages_map[inputfile_full_path]; item_found == true {
+						error_messages = error_messages_map[inputfile_full_path]
+					}
 
-			break
-		}
+					error_messages = append(error_messages, "Error, audio stream " + audio_stream_number_str + " is copied with -amap, but audio offset (-ad) needs the audio to be compressed")
+					error_messages_map[inputfile_full_path] = error_messages
+				}
 
-		////////////////////////////////////////////////////////////////////////////////////////////////////
-		// If user gave us the audio language (fin, eng, ita), find the corresponding audio stream number //
-		// If no matching audio is found stop the program.                                                //
-		////////////////////////////////////////////////////////////////////////////////////////////////////
-		if audio_stream_number_int, atoi_error = strconv.Atoi(audio_stream_number_option.user_string) ; atoi_error != nil {
+				// Copied audio keeps its channels
+				if audio_map_entry.Codec == "copy" {
+					audio_codec = source_audio_codec
+					audio_stream_is_copied = true
+					user_defined_number_of_channels = true
+				} else if audio_map_entry.Codec != "" {
+					audio_codec = audio_map_entry.Codec
+					audio_stream_is_copied = false
+				}
 
-			// If user did not give us a audio number use 0 as the default
-			audio_stream_number_int = 0
-		}
+				if audio_map_entry.Number_of_channels > 0 {
+					number_of_audio_channels = audio_map_entry.Number_of_channels
+					user_defined_number_of_channels = true
+				}
+			}
 
-		if audio_language_option.user_string != "" {
+			// Surround audio is downmixed to stereo with -stereo. Downmixed audio can't be copied and is compressed to aac instead.
+			if job.Downmix_to_stereo == true && number_of_audio_channels > 2 && user_defined_number_of_channels == false {
 
-			for audio_stream_number, audio_info := range audio_slice {
-				audio_language = audio_info[0]
+				number_of_audio_channels = 2
 
-				if audio_language_option.user_string == audio_language {
-					audio_stream_number_int = audio_stream_number
-					number_of_audio_channels = audio_info[2]
-					audio_codec = audio_info[4]
-					audio_stream_found = true
-					break
+				if audio_stream_is_copied == true {
+					audio_codec = "aac"
 				}
 			}
 
-			if audio_stream_found == false {
+			// AC3 can carry max 6 channels, audio with more channels is remapped to 5.1 unless the number of channels is given with -amap
+			if audio_codec == "ac3" && number_of_audio_channels > 6 && user_defined_number_of_channels == false {
+				number_of_audio_channels = 6
+			}
+
+			if audio_codec == "ac3" && number_of_audio_channels > 6 {
 
 				var error_messages []string
 
@@ -3095,131 +1726,85 @@ func main() {
 					error_messages = error_messages_map[inputfile_full_path]
 				}
 
-				error_messages = append(error_messages, "Error, file does not have audio language: " + audio_language_option.user_string)
+				error_messages = append(error_messages, "Error, audio stream " + audio_stream_number_str + " has " + strconv.Itoa(number_of_audio_channels) + " audio channels, but AC3 supports max 6 channels")
 				error_messages_map[inputfile_full_path] = error_messages
 			}
 
-			if debug_option.is_turned_on == true {
-				fmt.Println()
-				fmt.Printf("Audio: %s was found in audio stream number: %d\n", audio_language_option.user_string, audio_stream_number_int)
-				fmt.Println()
-			}
-
-		} else {
-
-			// User did not give audio language code (fin, eng, ita). Find the wanted audio stream by number (starts from 0).
-			// Either user defined a audio stream number on the commandline or we use the audio stream number 0.
-
-			if audio_stream_number_int  > len(audio_slice) - 1 {
+			if audio_codec == "flac" && number_of_audio_channels > 8 {
 
-				// The audio stream number is higher than any stream number in the input file.
 				var error_messages []string
 
 				if _, item_found := error_messages_map[inputfile_full_path]; item_found == true {
 					error_messages = error_messages_map[inputfile_full_path]
 				}
 
-				error_messages = append(error_messages, "Error, file does not have an audio stream number: " + strconv.Itoa(audio_stream_number_int))
+				error_messages = append(error_messages, "Error, audio stream " + audio_stream_number_str + " has " + strconv.Itoa(number_of_audio_channels) + " audio channels, but FLAC supports max 8 channels")
 				error_messages_map[inputfile_full_path] = error_messages
-
-			} else {
-
-				// There is a audio stream for the audio stream number we have.
-				// Either user defined the number on the commandline or we use the default value of 0 (first audio in source file).
-				audio_info := audio_slice[audio_stream_number_int]
-				number_of_audio_channels = audio_info[2]
-				audio_codec = strings.ToLower(audio_info[4])
-				audio_stream_found = true
 			}
-		}
-
-		if default_audio_processing == "aac" {
-			audio_compression_aac.is_turned_on = true
-		} else if default_audio_processing == "opus" {
-			audio_compression_opus.is_turned_on = true
-		}
-
-
-		if audio_codec == "ac-3" {
-			audio_codec = "ac3"
-		}
-
-		if audio_compression_aac.is_turned_on == true {
-			audio_codec = "aac"
-		}
-
-		if audio_compression_opus.is_turned_on == true {
-			audio_codec = "opus"
-		}
-
-		if audio_compression_ac3.is_turned_on == true {
-			audio_codec = "ac3"
-		}
-
-		if force_lossless.is_turned_on == true {
-			audio_codec = "flac"
-		}
 
-		if audio_compression_flac.is_turned_on == true {
-			audio_codec = "flac"
-		}
+			if audio_codec == "aac" && number_of_audio_channels > 48 {
 
-		number_of_audio_channels_int, _ := strconv.Atoi(number_of_audio_channels)
-
-		if audio_codec == "ac3" && number_of_audio_channels_int > 6 {
+				var error_messages []string
 
-			var error_messages []string
+				if _, item_found := error_messages_map[inputfile_full_path]; item_found == true {
+					error_messages = error_messages_map[inputfile_full_path]
+				}
 
-			if _, item_found := error_messages_map[inputfile_full_path]; item_found == true {
-				error_messages = error_messages_map[inputfile_full_path]
+				error_messages = append(error_messages, "Error, audio stream " + audio_stream_number_str + " has " + strconv.Itoa(number_of_audio_channels) + " audio channels, but AAC supports max 48 channels")
+				error_messages_map[inputfile_full_path] = error_messages
 			}
 
-			error_messages = append(error_messages, "Error, file has " + number_of_audio_channels + " audio channels, but AC3 supports max 6 channels")
-			error_messages_map[inputfile_full_path] = error_messages
-		}
+			if audio_codec == "opus" && number_of_audio_channels > 255 {
 
-		if audio_codec == "flac" && number_of_audio_channels_int > 8 {
+				var error_messages []string
 
-			var error_messages []string
+				if _, item_found := error_messages_map[inputfile_full_path]; item_found == true {
+					error_messages = error_messages_map[inputfile_full_path]
+				}
 
-			if _, item_found := error_messages_map[inputfile_full_path]; item_found == true {
-				error_messages = error_messages_map[inputfile_full_path]
+				error_messages = append(error_messages, "Error, audio stream " + audio_stream_number_str + " has " + strconv.Itoa(number_of_audio_channels) + " audio channels, but Opus supports max 255 channels")
+				error_messages_map[inputfile_full_path] = error_messages
 			}
 
-			error_messages = append(error_messages, "Error, file has " + number_of_audio_channels + " audio channels, but FLAC supports max 8 channels")
-			error_messages_map[inputfile_full_path] = error_messages
-		}
+			// Test if output audio codec is compatible with the mp4 wrapper format
+			// MP4 supported audio formats: https://en.wikipedia.org/wiki/Comparison_of_video_container_formats
+			// Amr, mp1, mp2, mp3. aac, ac3, e-ac3, dts, opus, alac, mlp, Dolby TrueHD, DTS-HD, als, sls, lpcm, DV Audio.
+			if job.Use_matroska_container == false && job.Use_webm_container == false {
 
-		if audio_codec == "aac" && number_of_audio_channels_int > 48 {
+				if audio_codec != "aac" && audio_codec != "ac3" && audio_codec != "mp2" && audio_codec != "mp3" && audio_codec != "dts" && audio_codec != "opus" {
 
-			var error_messages []string
+					var error_messages []string
 
-			if _, item_found := error_messages_map[inputfile_full_path]; item_found == true {
-				error_messages = error_messages_map[inputfile_full_path]
+					if _, item_found := error_messages_map[inputfile_full_path]; item_found == true {
+						error_messages = error_messages_map[inputfile_full_path]
+					}
+
+					error_messages = append(error_messages, "Error, audio codec: " + audio_codec + " in audio stream " + audio_stream_number_str + " is not compatible with the mp4 wrapper format.")
+					error_messages = append(error_messages, "Compatible formats are: aac, ac3, mp2, mp3, dts, opus.")
+					error_messages = append(error_messages, "Your options are: use the -mkv switch to export to a matroska file, the -aac or -opus switches to convert audio to aac or opus formats.")
+					error_messages = append(error_messages, "")
+					error_messages_map[inputfile_full_path] = error_messages
+				}
 			}
 
-			error_messages = append(error_messages, "Error, file has " + number_of_audio_channels + " audio channels, but AAC supports max 48 channels")
-			error_messages_map[inputfile_full_path] = error_messages
-		}
+			// Test if output audio is compatible with the WebM wrapper format. Audio is always compressed to opus in WebM,
+			// but WebM players only support opus with the standard channel layouts of up to 8 channels (7.1).
+			if job.Use_webm_container == true && job.No_audio == false && number_of_audio_channels > 8 {
 
-		if audio_codec == "opus" && number_of_audio_channels_int > 255 {
+				var error_messages []string
 
-			var error_messages []string
+				if _, item_found := error_messages_map[inputfile_full_path]; item_found == true {
+					error_messages = error_messages_map[inputfile_full_path]
+				}
 
-			if _, item_found := error_messages_map[inputfile_full_path]; item_found == true {
-				error_messages = error_messages_map[inputfile_full_path]
+				error_messages = append(error_messages, "Error, audio stream " + audio_stream_number_str + " has " + strconv.Itoa(number_of_audio_channels) + " audio channels, but opus in the WebM wrapper format supports max 8 channels.")
+				error_messages = append(error_messages, "Use the -mkv switch to export to a matroska file.")
+				error_messages = append(error_messages, "")
+				error_messages_map[inputfile_full_path] = error_messages
 			}
 
-			error_messages = append(error_messages, "Error, file has " + number_of_audio_channels + " audio channels, but Opus supports max 255 channels")
-			error_messages_map[inputfile_full_path] = error_messages
-		}
-
-		// Test if output audio codec is compatible with the mp4 wrapper format
-		// MP4 supported audio formats: https://en.wikipedia.org/wiki/Comparison_of_video_container_formats
-		// Amr, mp1, mp2, mp3. aac, ac3, e-ac3, dts, opus, alac, mlp, Dolby TrueHD, DTS-HD, als, sls, lpcm, DV Audio.
-		if use_matroska_container.is_turned_on == false && audio_stream_found == true {
-
-			if audio_codec != "aac" && audio_codec != "ac3" && audio_codec != "mp2" && audio_codec != "mp3" && audio_codec != "dts" && audio_codec != "opus" {
+			// A codec given with -amap may replace opus, the only audio format we store in WebM
+			if job.Use_webm_container == true && job.No_audio == false && audio_codec != "opus" {
 
 				var error_messages []string
 
@@ -3227,9 +1812,8 @@ func main() {
 					error_messages = error_messages_map[inputfile_full_path]
 				}
 
-				error_messages = append(error_messages, "Error, audio codec: " + audio_codec + " in file is not compatible with the mp4 wrapper format.")
-				error_messages = append(error_messages, "Compatible formats are: aac, ac3, mp2, mp3, dts, opus.")
-				error_messages = append(error_messages, "Your options are: use the -mkv switch to export to a matroska file, the -aac or -opus switches to convert audio to aac or opus formats.")
+				error_messages = append(error_messages, "Error, audio codec: " + audio_codec + " in audio stream " + audio_stream_number_str + " is not compatible with the WebM wrapper format.")
+				error_messages = append(error_messages, "Use opus for the stream in -amap or the -mkv switch to export to a matroska file.")
 				error_messages = append(error_messages, "")
 				error_messages_map[inputfile_full_path] = error_messages
 			}
@@ -3239,28 +1823,28 @@ func main() {
 		// If user gave us the subtitle language (fin, eng, ita) to burn on top of video, find the corresponding subtitle stream number //
 		// If no matching subtitle is found stop the program.                                                                           //
 		//////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
-		if subtitle_burn_bool == true && subtitle_language_option.user_string != "" {
+		if subtitle_burn_bool == true && job.Subtitle_language != "" {
 
 			subtitle_found := false
 			subtitle_palette_supported := true
 			subtitle_burn_supported := true
 			subtitle_format := ""
 
-			for counter, subtitle_info := range subtitle_slice {
+			for counter, subtitle_stream := range media_file.Subtitle_streams {
 				// Subtitle found
-				subtitle_language = subtitle_info[0]
+				subtitle_language = subtitle_stream.Language
 
-				if subtitle_language_option.user_string == subtitle_language {
+				if job.Subtitle_language == subtitle_language {
 					subtitle_burn_number = counter
 					subtitle_found = true
-					subtitle_format = subtitle_info[2]
+					subtitle_format = subtitle_stream.Codec_name
 
 					if subtitle_format != "dvd_subtitle" && subtitle_format != "dvb_subtitle" && subtitle_format != "hdmv_pgs_subtitle" {
 						subtitle_burn_supported = false
 					}
 
-					if subtitle_burn_palette.user_string != "" {
-						if subtitle_format != "dvd_subtitle" || subtitle_format != "dvb_subtitle" {
+					if job.Subtitle_burn_palette != "" {
+						if subtitle_format != "dvd_subtitle" && subtitle_format != "dvb_subtitle" {
 							subtitle_palette_supported = false
 						}
 					}
@@ -3276,7 +1860,7 @@ func main() {
 					error_messages = error_messages_map[inputfile_full_path]
 				}
 
-				error_messages = append(error_messages, "Error, file does not have subtitle language: " + subtitle_language_option.user_string)
+				error_messages = append(error_messages, "Error, file does not have subtitle language: " + job.Subtitle_language)
 				error_messages_map[inputfile_full_path] = error_messages
 			}
 
@@ -3291,7 +1875,7 @@ func main() {
 				error_messages_map[inputfile_full_path] = error_messages
 			}
 
-			if subtitle_burn_palette.user_string != "" && subtitle_palette_supported == false {
+			if job.Subtitle_burn_palette != "" && subtitle_palette_supported == false {
 				var error_messages []string
 
 				if _, item_found := error_messages_map[inputfile_full_path]; item_found == true {
@@ -3302,16 +1886,16 @@ func main() {
 				error_messages_map[inputfile_full_path] = error_messages
 			}
 
-			if debug_option.is_turned_on == true {
+			if job.Debug == true {
 				fmt.Println()
-				fmt.Printf("Subtitle: %s was found in file %s as number %s\n", subtitle_language_option.user_string, inputfile_full_path, strconv.Itoa(subtitle_burn_number))
+				fmt.Printf("Subtitle: %s was found in file %s as number %s\n", job.Subtitle_language, inputfile_full_path, strconv.Itoa(subtitle_burn_number))
 				fmt.Println()
 			}
 
 		} else if subtitle_burn_bool == true && subtitle_burn_number != -1 {
 
 			// If user gave subtitle stream number, check that we have at least that much subtitle streams in the source file.
-			if subtitle_burn_number > len(subtitle_slice) - 1 {
+			if subtitle_burn_number > len(media_file.Subtitle_streams) - 1 {
 
 				// The subtitle number was not found
 				var error_messages []string
@@ -3326,8 +1910,7 @@ func main() {
 			} else {
 
 				// The subtitle number was found
-				temp_slice := subtitle_slice[subtitle_burn_number]
-				subtitle_format := temp_slice[2]
+				subtitle_format := media_file.Subtitle_streams[subtitle_burn_number].Codec_name
 
 				if  subtitle_format != "dvd_subtitle" && subtitle_format != "dvb_subtitle" && subtitle_format != "hdmv_pgs_subtitle" {
 
@@ -3342,9 +1925,9 @@ func main() {
 
 				}
 
-				if subtitle_burn_palette.user_string != "" {
+				if job.Subtitle_burn_palette != "" {
 
-					if subtitle_format != "dvd_subtitle" || subtitle_format != "dvb_subtitle" {
+					if subtitle_format != "dvd_subtitle" && subtitle_format != "dvb_subtitle" {
 
 						var error_messages []string
 
@@ -3373,11 +1956,11 @@ func main() {
 
 				for _, user_sub_language := range user_subtitle_mux_languages_slice {
 
-					for counter, subtitle_info := range subtitle_slice {
+					for counter, subtitle_stream := range media_file.Subtitle_streams {
 
 						subtitle_found = false
-						subtitle_language = subtitle_info[0]
-						subtitle_type = subtitle_info[2]
+						subtitle_language = subtitle_stream.Language
+						subtitle_type = subtitle_stream.Codec_name
 
 						if user_sub_language == subtitle_language {
 
@@ -3399,14 +1982,14 @@ func main() {
 						error_messages_map[inputfile_full_path] = error_messages
 					}
 
-					if debug_option.is_turned_on == true {
+					if job.Debug == true {
 						fmt.Println()
 						fmt.Printf("Subtitle: %s was found in file %s\n", user_sub_language, inputfile_full_path)
 						fmt.Println()
 					}
 
 					// Test if output subtitle type is compatible with the mp4 wrapper format
-					if use_matroska_container.is_turned_on == false && subtitle_type == "hdmv_pgs_subtitle" {
+					if job.Use_matroska_container == false && job.Use_webm_container == false && subtitle_type == "hdmv_pgs_subtitle" {
 
 						var error_messages []string
 
@@ -3424,7 +2007,7 @@ func main() {
 			}
 
 			// Check that we have at least as much subtitle streams in the source file as the highest subtitle number user gave us.
-			if highest_subtitle_number_int > len(subtitle_slice) - 1 {
+			if highest_subtitle_number_int > len(media_file.Subtitle_streams) - 1 {
 
 				// The subtitle number was not found
 				var error_messages []string
@@ -3436,12 +2019,34 @@ func main() {
 				error_messages = append(error_messages, "Error, file does not have an subtitle stream number: " + strconv.Itoa(highest_subtitle_number_int))
 				error_messages_map[inputfile_full_path] = error_messages
 			}
+
+			// Test if subtitles are compatible with the WebM wrapper format. WebM only supports text subtitles (WebVTT)
+			// and all subtitles FFcommander can mux are bitmaps (dvd, dvb and bluray).
+			if job.Use_webm_container == true {
+
+				var error_messages []string
+
+				if _, item_found := error_messages_map[inputfile_full_path]; item_found == true {
+					error_messages = error_messages_map[inputfile_full_path]
+				}
+
+				error_messages = append(error_messages, "Error, bitmap subtitles (dvd, dvb and bluray) are not compatible with the WebM wrapper format.")
+				error_messages = append(error_messages, "Your options are: burn the subtitle on top of video with the -s or -sn switch, or use the -mkv switch to export to a matroska file.")
+				error_messages = append(error_messages, "")
+				error_messages_map[inputfile_full_path] = error_messages
+			}
 		}
 
 		// Store info about selected video  always stream 0), audio and subtitle streams.
-		if len(error_messages_map) == 0 {
+		if _, item_found := error_messages_map[inputfile_full_path]; item_found == false {
 			var selected_streams_temp []string
-			selected_streams_temp = append(selected_streams_temp, "0", strconv.Itoa(audio_stream_number_int), strconv.Itoa(subtitle_burn_number))
+			var audio_stream_numbers_str []string
+
+			for _, audio_stream_number := range audio_stream_numbers {
+				audio_stream_numbers_str = append(audio_stream_numbers_str, strconv.Itoa(audio_stream_number))
+			}
+
+			selected_streams_temp = append(selected_streams_temp, "0", strings.Join(audio_stream_numbers_str, ","), strconv.Itoa(subtitle_burn_number))
 			selected_streams[inputfile_full_path] = selected_streams_temp
 		}
 
@@ -3452,7 +2057,10 @@ func main() {
 		}
 	}
 
-	// If there were error messages then we can't process all files that the user gave on the commandline, inform the user and exit.
+	// If there were error messages then we can't process all files that the user gave on the commandline.
+	// Inform the user, write the errors to the processing log and continue with the files that can be processed.
+	var processing_results []file_processing_result_struct
+
 	if len(error_messages_map) > 0 {
 
 		// Sort file names
@@ -3476,95 +2084,294 @@ func main() {
 			for _, text_line := range error_messages {
 				fmt.Println(text_line)
 			}
+
+			var log_messages_str_slice []string
+			log_messages_str_slice = append(log_messages_str_slice, "", "Filename: " + inputfile_full_path, strings.Repeat("-", len(inputfile_full_path) + len("Filename: ") + 1), "")
+			log_messages_str_slice = append(log_messages_str_slice, "Commandline options:", "---------------------", strings.Join(job.Commandline, " "), "")
+			log_messages_str_slice = append(log_messages_str_slice, "File was not processed:")
+			log_messages_str_slice = append(log_messages_str_slice, error_messages...)
+			log_messages_str_slice = append(log_messages_str_slice, "", strings.Repeat("#", 120), "")
+
+			log_file_absolute_path := filepath.Join(filepath.Dir(inputfile_full_path), output_directory_name, "00-processing.log")
+
+			if log_error := write_processing_log(log_file_absolute_path, log_messages_str_slice); log_error != nil {
+				fmt.Println("Error,", log_error)
+			}
+
+			processing_results = append(processing_results, file_processing_result_struct{file_name: inputfile_full_path, processing_error: errors.New(strings.Join(error_messages, "\n"))})
 		}
 
 		fmt.Println()
-		os.Exit(1)
+		fmt.Println("The files above will be skipped.")
+		fmt.Println()
 	}
 
 	/////////////////////////////////////////
 	// Main loop that processess all files //
 	/////////////////////////////////////////
 
-	if len(Complete_file_info_slice) == 0 {
+	if len(media_file_info_slice) == 0 && len(error_messages_map) == 0 {
 		fmt.Println()
 		fmt.Println("No files to process")
 		fmt.Println()
-		os.Exit(0)
+		return nil
 	}
 
-	files_to_process_str = strconv.Itoa(len(Complete_file_info_slice))
+	files_to_process_str = strconv.Itoa(len(media_file_info_slice) - len(processing_results))
+
+file_loop:
+	for _, media_file := range media_file_info_slice {
+
+		// Skip files that did not pass the checks above
+		if _, item_found := error_messages_map[media_file.File_name]; item_found == true {
+			continue
+		}
+
+		// Don't start processing new files after the user has interrupted processing
+		if processing_context.Err() != nil {
+			processing_results = append(processing_results, file_processing_result_struct{file_name: media_file.File_name, processing_error: errors.New("not processed, processing was interrupted")})
+			continue
+		}
+
+		subtitle_horizontal_offset_int = 0
+		subtitle_horizontal_offset_str = "0"
+		start_time = time.Now()
+		inputfile_full_path := media_file.File_name
+
+		// Commands that run in the background while the file is processed use the context of the file,
+		// it is cancelled when processing of the file fails or the user interrupts processing
+		file_processing_context, cancel_file_processing := context.WithCancel(processing_context)
+		video_width = media_file.Video_streams[0].Width
+		video_height = media_file.Video_streams[0].Height
+		video_duration = media_file.Duration
+		video_codec_name = media_file.Video_streams[0].Codec_name
+		color_subsampling = media_file.Video_streams[0].Color_subsampling
+		color_space = media_file.Video_streams[0].Color_space
+		frame_rate_str := probe.Format_frame_rate(media_file.Video_streams[0].Frame_rate)
+
+		// Create input + output filenames and paths
+		inputfile_path := filepath.Dir(inputfile_full_path)
+		inputfile_name := filepath.Base(inputfile_full_path)
+		input_filename_extension := filepath.Ext(inputfile_name)
+		output_file_absolute_path := filepath.Join(inputfile_path, output_directory_name, strings.TrimSuffix(inputfile_name, input_filename_extension) + output_filename_extension)
+		subtitle_extract_base_path := filepath.Join(inputfile_path, output_directory_name, subtitle_extract_dir)
+		sd_directory_path := filepath.Join(inputfile_path, output_directory_name, sd_directory_name)
+		sd_output_file_absolute_path := filepath.Join(sd_directory_path, strings.TrimSuffix(inputfile_name, input_filename_extension) + output_filename_extension)
+		abr_directory_path := filepath.Join(inputfile_path, output_directory_name, strings.TrimSuffix(inputfile_name, input_filename_extension) + "-abr")
+
+		ffmpeg_2_pass_logfile_path := filepath.Join(inputfile_path, output_directory_name, strings.TrimSuffix(inputfile_name, input_filename_extension))
+		ffmpeg_sd_2_pass_logfile_path := filepath.Join(inputfile_path, output_directory_name, strings.TrimSuffix(inputfile_name, input_filename_extension) + "-sd")
+
+		if job.Temp_file_directory != "" {
+			subtitle_extract_base_path = filepath.Join(job.Temp_file_directory, output_directory_name, subtitle_extract_dir)
+			ffmpeg_2_pass_logfile_path = filepath.Join(job.Temp_file_directory, output_directory_name, strings.TrimSuffix(inputfile_name, input_filename_extension))
+			ffmpeg_sd_2_pass_logfile_path = filepath.Join(job.Temp_file_directory, output_directory_name, strings.TrimSuffix(inputfile_name, input_filename_extension) + "-sd")
+		}
+
+		original_subtitles_absolute_path := filepath.Join(subtitle_extract_base_path, inputfile_name + "-" + original_subtitles_dir)
+		fixed_subtitles_absolute_path := filepath.Join(subtitle_extract_base_path, inputfile_name + "-" + fixed_subtitles_dir)
+
+		if job.Debug == true {
+			fmt.Println("inputfile_path:", inputfile_path)
+			fmt.Println("inputfile_name:", inputfile_name)
+			fmt.Println("output_file_absolute_path:", output_file_absolute_path)
+			fmt.Println("video_width:", video_width)
+			fmt.Println("video_height:", video_height)
+			fmt.Println("adjust_black_point.user_string:", job.Adjust_black_point)
+			fmt.Println("adjust_white_point.user_string:", job.Adjust_white_point)
+			fmt.Println("adjust_gamma.user_string:", job.Adjust_gamma)
+			fmt.Println("adjust_chroma.user_string:", job.Adjust_chroma)
+			fmt.Println("orig_subtitle_path", orig_subtitle_path)
+			fmt.Println("cropped_subtitle_path", cropped_subtitle_path)
+			fmt.Println("subtitle_extract_base_path", subtitle_extract_base_path)
+			fmt.Println("original_subtitles_absolute_path", original_subtitles_absolute_path)
+			fmt.Println("fixed_subtitles_absolute_path", fixed_subtitles_absolute_path)
+			fmt.Println("number_of_physical_processors", number_of_physical_processors)
+		}
+
+		// Get selected subtitles to mux from map
+		user_subtitle_mux_numbers_slice = subtitles_selected_for_muxing_map[inputfile_full_path]
+
+		// Add messages to processing log.
+		var log_messages_str_slice []string
+		log_messages_str_slice = append(log_messages_str_slice, "")
+		log_messages_str_slice = append(log_messages_str_slice, "Filename: "+inputfile_full_path)
+		underline_length := len(inputfile_full_path) + len("Filename: ") + 1
+		log_messages_str_slice = append(log_messages_str_slice, strings.Repeat("-", underline_length))
+		log_messages_str_slice = append(log_messages_str_slice, "")
+		log_messages_str_slice = append(log_messages_str_slice, "Commandline options:")
+		log_messages_str_slice = append(log_messages_str_slice, "---------------------")
+		log_messages_str_slice = append(log_messages_str_slice, strings.Join(job.Commandline, " "))
+
+		if job.Preset_name != "" {
+			log_messages_str_slice = append(log_messages_str_slice, "Preset " + job.Preset_name + ": " + strings.Join(strings.Fields(job.Preset_options), " "))
+		}
+
+		if job.Force_lossless == false {
+			log_messages_str_slice = append(log_messages_str_slice, "", video_encoder_settings_str)
+		}
+
+		log_file_absolute_path := filepath.Join(inputfile_path, output_directory_name, "00-processing.log")
+
+		// Remove 2-pass logfiles, splitfiles and extracted subtitle images. This is done after successful processing,
+		// when processing fails and when the user interrupts processing. Temporary files are kept in debug mode.
+		remove_temporary_files := func() {
+
+			if _, err := os.Stat(ffmpeg_2_pass_logfile_path + "-0.log"); err == nil {
+				os.Remove(ffmpeg_2_pass_logfile_path + "-0.log")
+			}
+
+			if _, err := os.Stat(ffmpeg_2_pass_logfile_path + "-0.log.mbtree"); err == nil {
+				os.Remove(ffmpeg_2_pass_logfile_path + "-0.log.mbtree")
+			}
+
+			if _, err := os.Stat(ffmpeg_sd_2_pass_logfile_path + "-0.log"); err == nil {
+				os.Remove(ffmpeg_sd_2_pass_logfile_path + "-0.log")
+			}
+
+			if _, err := os.Stat(ffmpeg_sd_2_pass_logfile_path + "-0.log.mbtree"); err == nil {
+				os.Remove(ffmpeg_sd_2_pass_logfile_path + "-0.log.mbtree")
+			}
+
+			for _, quality_temp_file := range []string{ffmpeg_2_pass_logfile_path + "-quality_sample.mkv", ffmpeg_2_pass_logfile_path + "-ssim.log", ffmpeg_2_pass_logfile_path + "-psnr.log"} {
+
+				if _, err := os.Stat(quality_temp_file); err == nil {
+					os.Remove(quality_temp_file)
+				}
+			}
+
+			// Adaptive bitrate ladder 2-pass logfiles. There is one logfile for each rung.
+			for counter := range abr_rung_heights {
+
+				rung_logfile_path := ffmpeg_2_pass_logfile_path + "-" + strconv.Itoa(counter)
+
+				for _, abr_logfile := range []string{rung_logfile_path + ".log", rung_logfile_path + ".log.mbtree", rung_logfile_path + "-x265.log", rung_logfile_path + "-x265.log.cutree"} {
+
+					if _, err := os.Stat(abr_logfile); err == nil {
+						os.Remove(abr_logfile)
+					}
+				}
+			}
+
+			// x265 2-pass stats files
+			for _, x265_stats_file := range []string{ffmpeg_2_pass_logfile_path + "-x265.log", ffmpeg_2_pass_logfile_path + "-x265.log.cutree",
+				ffmpeg_sd_2_pass_logfile_path + "-x265.log", ffmpeg_sd_2_pass_logfile_path + "-x265.log.cutree"} {
+
+				if _, err := os.Stat(x265_stats_file); err == nil {
+					os.Remove(x265_stats_file)
+				}
+			}
+
+			if job.Debug == true {
+
+				fmt.Print("\nSplitfiles are not deleted in debug - mode.\n\n")
+
+			} else {
+
+				for _, splitfile_name := range list_of_splitfiles {
+					if _, err := os.Stat(splitfile_name); err == nil {
+						os.Remove(splitfile_name)
+					} else {
+						fmt.Println("Could not delete splitfile:", splitfile_name)
+					}
+				}
+
+				if _, err := os.Stat(split_info_file_absolute_path); !os.IsNotExist(err) {
+					if err = os.Remove(split_info_file_absolute_path); err != nil {
+						fmt.Println("Could not delete split_info_file:", split_info_file_absolute_path)
+					}
+				}
+
+			}
+
+			if job.Subtitle_burn_split == true {
+				if job.Debug == true {
+					fmt.Print("\nExtracted subtitle images are not deleted in debug - mode.\n\n")
+				} else {
+
+					// Remove subtitle directories.
+					if _, err := os.Stat(original_subtitles_absolute_path); err == nil {
+						os.RemoveAll(original_subtitles_absolute_path)
+					}
+
+					if _, err := os.Stat(fixed_subtitles_absolute_path); err == nil {
+						os.RemoveAll(fixed_subtitles_absolute_path)
+					}
+
+					// Delete subtitle dir if it is empty
+					file_handle, err := os.Open(subtitle_extract_base_path)
+
+					if err == nil {
+						_, dir_empty := file_handle.Readdirnames(1)
+						file_handle.Close()
+
+						if dir_empty == io.EOF {
+							os.Remove(subtitle_extract_base_path)
+						}
+					}
+				}
+			}
+
+			// Delete the temp file directory created under -td if it is empty
+			if job.Temp_file_directory != "" && job.Debug == false {
+				os.Remove(filepath.Join(job.Temp_file_directory, output_directory_name))
+			}
+		}
 
-	for _, file_info_slice := range Complete_file_info_slice {
+		// When processing of the file fails, the error is printed and written to the processing log and processing continues with the next file.
+		// If the user interrupted processing, the incomplete output files are removed and the rest of the files are skipped.
+		file_processing_failed := func(processing_error error) {
 
-		subtitle_horizontal_offset_int = 0
-		subtitle_horizontal_offset_str = "0"
-		start_time = time.Now()
-		video_slice_temp := file_info_slice[0]
-		video_slice := video_slice_temp[0]
-		inputfile_full_path := video_slice[0]
-		video_width = video_slice[1]
-		video_height = video_slice[2]
-		video_duration = video_slice[3]
-		video_codec_name = video_slice[4]
-		color_subsampling = video_slice[5]
-		color_space = video_slice[6]
-		frame_rate_str := video_slice[7]
+			// Stop commands still running in the background for this file, like the loudness analysis
+			cancel_file_processing()
 
-		// Create input + output filenames and paths
-		inputfile_path := filepath.Dir(inputfile_full_path)
-		inputfile_name := filepath.Base(inputfile_full_path)
-		input_filename_extension := filepath.Ext(inputfile_name)
-		output_file_absolute_path := filepath.Join(inputfile_path, output_directory_name, strings.TrimSuffix(inputfile_name, input_filename_extension) + output_filename_extension)
-		subtitle_extract_base_path := filepath.Join(inputfile_path, output_directory_name, subtitle_extract_dir)
-		sd_directory_path := filepath.Join(inputfile_path, output_directory_name, sd_directory_name)
-		sd_output_file_absolute_path := filepath.Join(sd_directory_path, strings.TrimSuffix(inputfile_name, input_filename_extension) + output_filename_extension)
+			if processing_context.Err() != nil {
+				processing_error = errors.New("interrupted")
+				os.Remove(output_file_absolute_path)
+				os.Remove(sd_output_file_absolute_path)
 
-		if temp_file_directory.user_string != "" {
-			subtitle_extract_base_path = filepath.Join(temp_file_directory.user_string, output_directory_name, subtitle_extract_dir)
-		}
+				if job.Abr != "" {
+					os.RemoveAll(abr_directory_path)
+				}
+			} else {
+				fmt.Println()
+				fmt.Println("Error, processing file", inputfile_name, "failed:")
+				fmt.Println(processing_error)
+				fmt.Println()
+			}
 
-		original_subtitles_absolute_path := filepath.Join(subtitle_extract_base_path, inputfile_name + "-" + original_subtitles_dir)
-		fixed_subtitles_absolute_path := filepath.Join(subtitle_extract_base_path, inputfile_name + "-" + fixed_subtitles_dir)
+			remove_temporary_files()
 
-		if debug_option.is_turned_on == true {
-			fmt.Println("inputfile_path:", inputfile_path)
-			fmt.Println("inputfile_name:", inputfile_name)
-			fmt.Println("output_file_absolute_path:", output_file_absolute_path)
-			fmt.Println("video_width:", video_width)
-			fmt.Println("video_height:", video_height)
-			fmt.Println("adjust_black_point.user_string:", adjust_black_point.user_string)
-			fmt.Println("adjust_white_point.user_string:", adjust_white_point.user_string)
-			fmt.Println("adjust_gamma.user_string:", adjust_gamma.user_string)
-			fmt.Println("adjust_chroma.user_string:", adjust_chroma.user_string)
-			fmt.Println("orig_subtitle_path", orig_subtitle_path)
-			fmt.Println("cropped_subtitle_path", cropped_subtitle_path)
-			fmt.Println("subtitle_extract_base_path", subtitle_extract_base_path)
-			fmt.Println("original_subtitles_absolute_path", original_subtitles_absolute_path)
-			fmt.Println("fixed_subtitles_absolute_path", fixed_subtitles_absolute_path)
-			fmt.Println("number_of_physical_processors", number_of_physical_processors)
-		}
+			log_messages_str_slice = append(log_messages_str_slice, "", "Processing failed:", processing_error.Error(), "")
+			log_messages_str_slice = append(log_messages_str_slice, strings.Repeat("#", 120), "")
 
-		// Get selected subtitles to mux from map
-		user_subtitle_mux_numbers_slice = subtitles_selected_for_muxing_map[inputfile_full_path]
+			if log_error := write_processing_log(log_file_absolute_path, log_messages_str_slice); log_error != nil {
+				fmt.Println("Error,", log_error)
+			}
 
-		// Add messages to processing log.
-		var log_messages_str_slice []string
-		log_messages_str_slice = append(log_messages_str_slice, "")
-		log_messages_str_slice = append(log_messages_str_slice, "Filename: "+inputfile_full_path)
-		underline_length := len(inputfile_full_path) + len("Filename: ") + 1
-		log_messages_str_slice = append(log_messages_str_slice, strings.Repeat("-", underline_length))
-		log_messages_str_slice = append(log_messages_str_slice, "")
-		log_messages_str_slice = append(log_messages_str_slice, "Commandline options:")
-		log_messages_str_slice = append(log_messages_str_slice, "---------------------")
-		log_messages_str_slice = append(log_messages_str_slice, strings.Join(os.Args, " "))
+			processing_results = append(processing_results, file_processing_result_struct{file_name: inputfile_full_path, processing_error: processing_error, elapsed_time: time.Since(start_time)})
+		}
 
 		// If output directory does not exist path then create it.
 		if _, err := os.Stat(filepath.Join(inputfile_path, output_directory_name)); os.IsNotExist(err) {
 			os.Mkdir(filepath.Join(inputfile_path, output_directory_name), 0777)
 		}
 
-		if parallel_sd.is_turned_on == true || scale_to_sd.is_turned_on == true {
+		// Temporary files (2-pass logfiles, quality measurement files) are written to the -td directory
+		if job.Temp_file_directory != "" {
+			if _, err := os.Stat(filepath.Dir(ffmpeg_2_pass_logfile_path)); os.IsNotExist(err) {
+				os.MkdirAll(filepath.Dir(ffmpeg_2_pass_logfile_path), 0777)
+			}
+		}
+
+		// HLS and DASH files are written to their own directory. FFmpeg creates the subdirectories of the HLS variant streams.
+		if job.Abr != "" {
+			if _, err := os.Stat(abr_directory_path); os.IsNotExist(err) {
+				os.MkdirAll(abr_directory_path, 0777)
+			}
+		}
+
+		if job.Parallel_sd == true || job.Scale_to_sd == true {
 			// If SD output directory does not exist path then create it.
 			if _, err := os.Stat(sd_directory_path); os.IsNotExist(err) {
 				os.Mkdir(sd_directory_path, 0777)
@@ -3580,12 +2387,75 @@ func main() {
 		fmt.Println("")
 		fmt.Println("Processing file " + file_counter_str + "/" + files_to_process_str + "  '" + inputfile_name + "'")
 
-		audio_slice := file_info_slice[1]
+		// Convert -sf, -st, -d and -et times to timestamps. SMPTE timecodes and frame numbers are converted with the frame rate of the file.
+		// The times are given to FFmpeg in the form HH:MM:SS.mmm
+		var search_start_str, processing_duration_str string
+		var time_error error
+		search_start_timestamp = plan.Timestamp_struct{}
+		processing_duration_timestamp = plan.Timestamp_struct{}
+
+		if split_video == true {
+
+			cut_list_timestamps, cut_positions_as_timecodes, time_error = plan.Process_split_times(job.Split_times, media_file.Video_streams[0].Frame_rate, job.Debug)
+
+			if time_error != nil {
+				file_processing_failed(errors.New("option -sf: " + time_error.Error()))
+				continue file_loop
+			}
+		}
+
+		if job.Search_start != "" && split_video == false {
+
+			if search_start_timestamp, time_error = plan.Parse_timestamp(job.Search_start, media_file.Video_streams[0].Frame_rate); time_error != nil {
+				file_processing_failed(errors.New("option -st: " + time_error.Error()))
+				continue file_loop
+			}
+
+			search_start_str = plan.Format_timestamp(search_start_timestamp)
+		}
+
+		if job.Processing_duration != "" && split_video == false {
+
+			if processing_duration_timestamp, time_error = plan.Parse_timestamp(job.Processing_duration, media_file.Video_streams[0].Frame_rate); time_error != nil {
+				file_processing_failed(errors.New("option -d: " + time_error.Error()))
+				continue file_loop
+			}
+
+			processing_duration_str = plan.Format_timestamp(processing_duration_timestamp)
+		}
+
+		// Convert processing end time to duration. FFmpeg does not understand end times, only start time + duration.
+		if job.Processing_stop_time != "" && split_video == false {
+
+			processing_stop_timestamp, time_error := plan.Parse_timestamp(job.Processing_stop_time, media_file.Video_streams[0].Frame_rate)
+
+			if time_error != nil {
+				file_processing_failed(errors.New("option -et: " + time_error.Error()))
+				continue file_loop
+			}
+
+			if plan.Timestamp_compare(processing_stop_timestamp, search_start_timestamp) <= 0 {
+				file_processing_failed(errors.New("end time " + job.Processing_stop_time + " must be after start time " + job.Search_start))
+				continue file_loop
+			}
+
+			processing_duration_timestamp = plan.Timestamp_subtract(processing_stop_timestamp, search_start_timestamp)
+			processing_duration_str = plan.Format_timestamp(processing_duration_timestamp)
+		}
+
 		selected_streams_slice := selected_streams[inputfile_full_path]
-		audio_stream_number_int, _ := strconv.Atoi(selected_streams_slice[1])
-		audio_info := audio_slice[audio_stream_number_int] 
-		number_of_audio_channels = audio_info[2]
-		audio_codec = audio_info[4]
+		// Selected audio stream numbers are stored as a comma separated list: 0,2
+		var audio_stream_numbers []int
+		var selected_audio_streams []probe.Audio_stream_struct
+
+		for _, audio_stream_number_str := range strings.Split(selected_streams_slice[1], ",") {
+			audio_stream_number_int, _ := strconv.Atoi(audio_stream_number_str)
+			audio_stream_numbers = append(audio_stream_numbers, audio_stream_number_int)
+			selected_audio_streams = append(selected_audio_streams, media_file.Audio_streams[audio_stream_number_int])
+		}
+
+		number_of_audio_channels = selected_audio_streams[0].Number_of_channels
+		audio_codec = selected_audio_streams[0].Codec_name
 		subtitle_burn_number, _ = strconv.Atoi(selected_streams_slice[2])
 
 		////////////////////////////////////////////////////
@@ -3606,14 +2476,12 @@ func main() {
 			}
 
 			// Create a new split info file
+			// The file is closed explicitly, a deferred Close would keep one file open for every processed file until the program ends
 			split_info_file_pointer, err := os.OpenFile(split_info_file_absolute_path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0755)
-			defer split_info_file_pointer.Close()
 
 			if err != nil {
-				fmt.Println("")
-				fmt.Println("Error, could not open split info file:", split_info_filename, "for writing.")
-				log.Fatal(err)
-				os.Exit(0)
+				file_processing_failed(errors.New("could not open split info file: " + split_info_filename + " for writing: " + err.Error()))
+				continue file_loop
 			}
 
 			log_messages_str_slice = append(log_messages_str_slice, "\n")
@@ -3627,104 +2495,109 @@ func main() {
 
 			audio_codec = "flac"
 
-			for counter := 0; counter < len(cut_list_seconds_str_slice); counter = counter + 2 {
+			for counter := 0; counter < len(cut_list_timestamps); counter = counter + 2 {
 				file_index_counter++
 				splitfile_name := strings.TrimSuffix(strings.Replace(inputfile_name, "'", "", -1), input_filename_extension) + "-splitfile-" + strconv.Itoa(file_index_counter) + output_matroska_filename_extension
 				split_file_path := filepath.Join(inputfile_path, output_directory_name, splitfile_name)
 				list_of_splitfiles = append(list_of_splitfiles, split_file_path)
 
-				ffmpeg_file_split_commandline = nil
-				ffmpeg_file_split_commandline = append(ffmpeg_file_split_commandline, ffmpeg_commandline_start...)
-				ffmpeg_file_split_commandline = append(ffmpeg_file_split_commandline, "-i", inputfile_full_path, "-ss", cut_list_seconds_str_slice[counter])
+				var split_output encode.Ffmpeg_output_struct
+
+				ffmpeg_file_split_commandline = encode.Ffmpeg_commandline_struct{Global_options: ffmpeg_global_options}
+				ffmpeg_file_split_commandline.Inputs = append(ffmpeg_file_split_commandline.Inputs, encode.Ffmpeg_input_struct{File_path: inputfile_full_path})
+				split_output.Seek_start = plan.Format_timestamp_seconds(cut_list_timestamps[counter])
 
 				// There is no timecode if the user wants to process to the end of file. Skip the -t FFmpeg option since FFmpeg processes to the end of file without it.
-				if len(cut_list_seconds_str_slice)-1 > counter {
-					ffmpeg_file_split_commandline = append(ffmpeg_file_split_commandline, "-t", cut_list_seconds_str_slice[counter+1])
+				if len(cut_list_timestamps)-1 > counter {
+					split_output.Duration = plan.Format_timestamp_seconds(cut_list_timestamps[counter+1])
 				}
 
 				// Put video and subtitle options on FFmpeg commandline
+				split_output.Maps = append(split_output.Maps, "0:v:0")
+				split_output.Codec_options = append(split_output.Codec_options, "-vcodec", "utvideo")
+
 				if subtitle_burn_bool == true {
 					// Subtitle burn
-					ffmpeg_file_split_commandline = append(ffmpeg_file_split_commandline, "-vcodec", "utvideo", "-map", "0:v:0", "-scodec", "copy", "-map", "0:s:" + strconv.Itoa(subtitle_burn_number))
+					split_output.Maps = append(split_output.Maps, "0:s:" + strconv.Itoa(subtitle_burn_number))
+					split_output.Codec_options = append(split_output.Codec_options, "-scodec", "copy")
 
 				} else if subtitle_mux_bool == true {
 					// Subtitle mux
-					ffmpeg_file_split_commandline = append(ffmpeg_file_split_commandline, "-vcodec", "utvideo", "-map", "0:v:0", "-scodec", "copy")
+					split_output.Codec_options = append(split_output.Codec_options, "-scodec", "copy")
 
 					for _, subtitle_mux_number := range user_subtitle_mux_numbers_slice {
-						ffmpeg_file_split_commandline = append(ffmpeg_file_split_commandline, "-map", "0:s:"+ subtitle_mux_number)
+						split_output.Maps = append(split_output.Maps, "0:s:"+ subtitle_mux_number)
 					}
 
 				} else {
 					// No subtitle
-					ffmpeg_file_split_commandline = append(ffmpeg_file_split_commandline, "-vcodec", "utvideo", "-map", "0:v:0", "-sn")
+					split_output.Codec_options = append(split_output.Codec_options, "-sn")
 				}
 
 				// Put audio options on FFmpeg commandline
-				var audio_options []string
-
-				if no_audio.is_turned_on == true {
-					audio_options = append(audio_options, "-an")
+				// With -ad the audio is read from a second input that has the offset, so that the offset is applied before the file is cut
+				// and the audio at the cut points is the same as in the plain encode.
+				if job.No_audio == true {
+					split_output.Codec_options = append(split_output.Codec_options, "-an")
 				} else {
-					audio_options = append(audio_options, "-acodec", "flac", "-map", "0:a:" + strconv.Itoa(audio_stream_number_int))
-				}
+					split_audio_input_number := "0"
+
+					if job.Audio_offset != "" {
+						ffmpeg_file_split_commandline.Inputs = append(ffmpeg_file_split_commandline.Inputs, plan.Audio_offset_input(inputfile_full_path, plan.Timestamp_struct{}, audio_offset_milliseconds))
+						split_audio_input_number = "1"
+					}
+
+					for _, audio_stream_number := range audio_stream_numbers {
+						split_output.Maps = append(split_output.Maps, split_audio_input_number + ":a:" + strconv.Itoa(audio_stream_number))
+					}
 
-				for _, item := range audio_options {
-					ffmpeg_file_split_commandline = append(ffmpeg_file_split_commandline, item)
+					split_output.Codec_options = append(split_output.Codec_options, "-acodec", "flac")
 				}
 
 				// Put target file path on FFmpeg commandline
-				ffmpeg_file_split_commandline = append(ffmpeg_file_split_commandline, split_file_path)
+				split_output.File_path = split_file_path
+				ffmpeg_file_split_commandline.Outputs = append(ffmpeg_file_split_commandline.Outputs, split_output)
 
-				if only_print_commands.is_turned_on == false {
+				if job.Only_print_commands == false {
 					fmt.Println("Creating splitfile: " + splitfile_name)
 
 				}
 
-				log_messages_str_slice = append(log_messages_str_slice, strings.Join(ffmpeg_file_split_commandline, " "))
+				log_messages_str_slice = append(log_messages_str_slice, encode.Ffmpeg_commandline_to_shell_string(ffmpeg_file_split_commandline))
 
 				// Write split file names to a text file
 				if _, err = split_info_file_pointer.WriteString("file '" + splitfile_name + "'\n"); err != nil {
-					fmt.Println("")
-					fmt.Println("Error, could not write to split info file:", split_info_filename)
-					log.Fatal(err)
-					os.Exit(0)
+					split_info_file_pointer.Close()
+					file_processing_failed(errors.New("could not write to split info file: " + split_info_filename + ": " + err.Error()))
+					continue file_loop
 				}
 
-				if debug_option.is_turned_on == true || only_print_commands.is_turned_on == true {
-					fmt.Println(strings.Join(ffmpeg_file_split_commandline, " "), "\n")
+				if job.Debug == true || job.Only_print_commands == true {
+					fmt.Print(encode.Ffmpeg_commandline_to_shell_string(ffmpeg_file_split_commandline), " \n\n")
 				}
 
 				var file_split_output_temp []string
 				var file_split_error_output_temp []string
 				var error_code error
 
-				if only_print_commands.is_turned_on == false {
-					file_split_output_temp, file_split_error_output_temp, error_code = run_external_command(ffmpeg_file_split_commandline)
+				if job.Only_print_commands == false {
+					file_split_output_temp, file_split_error_output_temp, error_code = encode.Run_external_command(processing_context, encode.Render_ffmpeg_commandline(ffmpeg_file_split_commandline))
 				}
 
 				if error_code != nil {
-
-					fmt.Println("\n\nFFmpeg reported error:", "\n")
-
-					if len(file_split_output_temp) != 0 {
-						for _, textline := range file_split_output_temp {
-							fmt.Println(textline)
-						}
-					}
-
-					if len(file_split_error_output_temp) != 0 {
-						for _, textline := range file_split_error_output_temp {
-							fmt.Println(textline)
-						}
-					}
-
-					os.Exit(1)
+					split_info_file_pointer.Close()
+					file_processing_failed(encode.External_command_error("FFmpeg", file_split_output_temp, file_split_error_output_temp, error_code))
+					continue file_loop
 				}
 
 			}
 
-			audio_stream_number_int = 0
+			split_info_file_pointer.Close()
+
+			// Selected audio streams will now be numbered; 0, 1, 2... in the splitfiles as all other streams have been left out.
+			for counter := range audio_stream_numbers {
+				audio_stream_numbers[counter] = counter
+			}
 
 			if subtitle_burn_bool == true {
 				// Audio and subtitle stream numbers will now change to 0 in the splitfiles as all other streams have been left out.
@@ -3744,12 +2617,12 @@ func main() {
 
 			file_split_elapsed_time = time.Since(file_split_start_time)
 
-			if only_print_commands.is_turned_on == false {
+			if job.Only_print_commands == false {
 				fmt.Printf("\nSplitfile creation took %s\n", file_split_elapsed_time.Round(time.Millisecond))
 				fmt.Println()
 			}
 
-			if only_print_commands.is_turned_on == true {
+			if job.Only_print_commands == true {
 
 				fmt.Println("Contents of textfile:", split_info_file_absolute_path)
 
@@ -3762,337 +2635,210 @@ func main() {
 						fmt.Println(text_scanner.Text())
 					}
 					fmt.Println()
+					split_info_file_pointer.Close()
 				} else {
 					fmt.Println("Could not open texttile:", split_info_file_absolute_path)
 					fmt.Println()
 				}
-				defer split_info_file_pointer.Close()
 			}
 
 			log_messages_str_slice = append(log_messages_str_slice, "\nSplitfile creation took "+file_split_elapsed_time.Round(time.Millisecond).String())
 
 			// If user has not defined audio output codec use aac rather than flac
-			if audio_compression_flac.is_turned_on != true && audio_codec == "flac" {
-				audio_compression_aac.is_turned_on = true
+			if job.Audio_compression_flac != true && audio_codec == "flac" {
+				job.Audio_compression_aac = true
 				audio_codec = "aac"
 			}
 		}
 
-		/////////////////////////////////////////////////////////////
-		// Find out autocrop parameters by scanning the input file //
-		/////////////////////////////////////////////////////////////
-
-		// FFmpeg cropdetect scans the file and tries to guess where the black bars are.
-		// The command: cropdetect=24:8:250  means:
-		//
-		// Threshold for black is 24.
-		// The values returned by cropdetect must be divisible by 8.
-		// FFmpeg recommends using video sizes divisible by 16 for most video codecs.
-		// We use 8 here since in 1920x1080 the 1080 is not divisible by 16 still 1920x1080 is a stardard H.264 resolution, so the codec handles that resolution ok.
-		// Reset detected border values to zero after 250 frames and try to detect borders again.
-		//
-		// FFmpeg returns a bunch of measurements like this: crop=1472:1080:224:0
-		// Lets see what this means and replace the values by variables: crop=A:B:C:D
-		// The line tells us what part of the picture will be left over after cropping. The line means:
-		//
-		// The detected left border is at C pixels counting from the left of the picture.
-		// Take A pixels starting from C to the right and where we end at is the detected right border of the picture.
-		// Pixels on the right of this point will be cropped.
-		//
-		// The detected upper border is at D pixels from the top of the picture
-		// Take B pixels starting from D down and where we end at is the detected bottom border of the picture.
-		// Pixels below this point will be cropped.
-		//
-
-		if autocrop_option.is_turned_on == true {
-
-			// Create the FFmpeg commandline to scan for black areas at the borders of the video.
-			command_to_run_str_slice = nil
-			quick_scan_failed := false
-
-			// Clear crop value storage map by creating a new map with the same name.
-			var crop_value_map = make(map[string]int)
-			var crop_start_seconds_int int
-			var crop_scan_duration_int int
-			var crop_scan_stop_time_int int
-			var conversion_error string
-
-			var user_defined_search_start_str string
-			var user_defined_search_start_seconds_str string
-			var user_defined_search_start_seconds_int int
-
-			var user_defined_video_duration_str string
-			var user_defined_video_duration_seconds_str string
-			var user_defined_video_duration_seconds_int int
-
-			var atoi_error error
+		/////////////////////////////////////////////////////////////////////
+		// Find out the codec and number of channels of every audio stream //
+		/////////////////////////////////////////////////////////////////////
+		// Audio options (-aac, -opus, ...) apply to all audio streams, -amap may give a stream a codec and number of channels of its own.
+		// -add-stereo adds a stereo downmix after each surround stream, the downmix is mapped from the same source stream as the surround audio.
+		var audio_output_codecs []string
+		var audio_output_channels []int
+		var audio_downmix_filters []string
+		var audio_output_is_added_downmix []bool
+		var audio_output_stream_numbers []int
+		var audio_output_streams []probe.Audio_stream_struct
 
-			video_duration_int, _ := strconv.Atoi(strings.Split(video_duration, ".")[0])
+		for audio_stream_index, selected_audio_stream := range selected_audio_streams {
 
-			user_defined_search_start_str = search_start_option.user_string
+			audio_output_codec := "copy"
+			audio_output_number_of_channels := selected_audio_stream.Number_of_channels
+			user_defined_number_of_channels := false
 
-			if user_defined_search_start_str == "" {
-				user_defined_search_start_str ="0"
+			if job.Force_lossless == true || job.Audio_compression_flac == true {
+				audio_output_codec = "flac"
 			}
 
-			if strings.Contains(user_defined_search_start_str, ":") || strings.Contains(user_defined_search_start_str, ".") {
-
-				user_defined_search_start_seconds_str, conversion_error = convert_timecode_to_seconds(user_defined_search_start_str)
-
-				if conversion_error !="" {
-					fmt.Println("Error converting value:", user_defined_search_start_str, "to seconds.")
-					os.Exit(1)
-				}
-
-				user_defined_search_start_seconds_int, atoi_error = strconv.Atoi(user_defined_search_start_seconds_str)
-
-				if atoi_error != nil {
-					fmt.Println("Error converting value:", user_defined_search_start_seconds_str, "to seconds.")
-					os.Exit(1)
-				}
-
-				if user_defined_search_start_seconds_int >= video_duration_int {
-					fmt.Println("Option -st ", user_defined_search_start_seconds_int, "cannot start ouside video duration", video_duration_int)
-					os.Exit(1)
-				}
+			if job.Audio_compression_aac == true {
+				audio_output_codec = "aac"
 			}
 
-			user_defined_video_duration_str = processing_duration.user_string
-
-			if user_defined_video_duration_str == "" {
-				user_defined_video_duration_str = "0"
+			if job.Audio_compression_opus == true {
+				audio_output_codec = "opus"
 			}
 
-			if strings.Contains(user_defined_video_duration_str, ":") || strings.Contains(user_defined_video_duration_str, ".") {
-
-				user_defined_video_duration_seconds_str, conversion_error = convert_timecode_to_seconds(user_defined_video_duration_str)
-
-				if conversion_error !="" {
-					fmt.Println("Error converting value:", user_defined_video_duration_str, "to seconds.")
-					os.Exit(1)
-				}
+			if job.Audio_compression_ac3 == true {
+				audio_output_codec = "ac3"
+			}
 
-				user_defined_video_duration_seconds_int, atoi_error = strconv.Atoi(user_defined_video_duration_seconds_str)
+			// The -amap list is in the same order as the selected audio streams
+			if len(audio_map) > 0 {
 
-				if atoi_error != nil {
-					fmt.Println("Error converting value:", user_defined_video_duration_seconds_int, "to seconds.")
-					os.Exit(1)
+				if audio_map[audio_stream_index].Codec != "" {
+					audio_output_codec = audio_map[audio_stream_index].Codec
 				}
 
-				if user_defined_video_duration_seconds_int > video_duration_int {
-					fmt.Println("Option -d ", user_defined_video_duration_seconds_int, "cannot be longer than video duration", video_duration_int)
-					os.Exit(1)
+				if audio_map[audio_stream_index].Number_of_channels > 0 {
+					audio_output_number_of_channels = audio_map[audio_stream_index].Number_of_channels
+					user_defined_number_of_channels = true
 				}
 
-				if user_defined_search_start_seconds_int + user_defined_video_duration_seconds_int > video_duration_int {
-					fmt.Println("Times given with options -d and -st combined:", user_defined_search_start_seconds_int + user_defined_video_duration_seconds_int, "are outside video duration", video_duration_int)
-					os.Exit(1)
+				// Copied audio keeps its channels
+				if audio_map[audio_stream_index].Codec == "copy" {
+					user_defined_number_of_channels = true
 				}
 			}
 
-			crop_start_seconds_int = 0
-			crop_scan_duration_int = video_duration_int
-			crop_scan_stop_time_int = video_duration_int
-
-			if user_defined_search_start_seconds_int > 0 {
-				crop_start_seconds_int = user_defined_search_start_seconds_int
-				crop_scan_duration_int = video_duration_int - crop_start_seconds_int
+			if job.Downmix_to_stereo == true && audio_output_number_of_channels > 2 && user_defined_number_of_channels == false {
+				audio_output_number_of_channels = 2
 			}
 
-			if user_defined_video_duration_seconds_int > 0 {
-				crop_scan_duration_int = user_defined_video_duration_seconds_int
-				crop_scan_stop_time_int = user_defined_video_duration_seconds_int + user_defined_search_start_seconds_int
+			// AC3 can carry max 6 channels, 7.1 audio is remapped to 5.1
+			if audio_output_codec == "ac3" && audio_output_number_of_channels > 6 && user_defined_number_of_channels == false {
+				audio_output_number_of_channels = 6
 			}
 
-			if debug_option.is_turned_on == true {
-				fmt.Println("user_defined_search_start_seconds_str:", user_defined_search_start_seconds_str)
-				fmt.Println("user_defined_search_start_seconds_int:", user_defined_search_start_seconds_int)
-				fmt.Println("user_defined_video_duration_seconds_str:", user_defined_video_duration_seconds_str)
-				fmt.Println("user_defined_video_duration_seconds_int:", user_defined_video_duration_seconds_int)
-				fmt.Println("video_duration_int:", video_duration_int)
-				fmt.Println("crop_start_seconds_int:", crop_start_seconds_int)
-				fmt.Println("crop_scan_duration_int:", crop_scan_duration_int)
-				fmt.Println("crop_scan_stop_time_int:", crop_scan_stop_time_int)
-				fmt.Println("spotcheck_interval:", crop_scan_duration_int / 10)
+			// Audio in the splitfiles is flac, copying it to the processed file would keep it as flac.
+			// Audio with a changed number of channels or an offset can't be copied either.
+			if audio_output_codec == "copy" && (split_video == true || audio_output_number_of_channels != selected_audio_stream.Number_of_channels || job.Audio_offset != "") {
+				audio_output_codec = "aac"
 			}
 
-			// For long videos take short snapshots of crop values spanning the whole file. This is "quick scan mode".
-			if crop_scan_duration_int > 300 {
+			audio_output_codecs = append(audio_output_codecs, audio_output_codec)
+			audio_output_channels = append(audio_output_channels, audio_output_number_of_channels)
+			audio_downmix_filters = append(audio_downmix_filters, plan.Audio_downmix_filter(selected_audio_stream.Number_of_channels, audio_output_number_of_channels))
+			audio_output_is_added_downmix = append(audio_output_is_added_downmix, false)
+			audio_output_stream_numbers = append(audio_output_stream_numbers, audio_stream_numbers[audio_stream_index])
+			audio_output_streams = append(audio_output_streams, selected_audio_stream)
 
-				spotcheck_interval := crop_scan_duration_int / 10 // How many spot checks will be made across the duration of the video (default = 10)
-				scan_duration_str := "10"                     // How many seconds of video to scan for each spot (default = 10 seconds)
-				scan_duration_int, _ := strconv.Atoi(scan_duration_str)
+			if job.Add_stereo_downmix == true && selected_audio_stream.Number_of_channels > 2 {
 
-				if debug_option.is_turned_on == false {
-					fmt.Printf("Finding crop values for: " + inputfile_name + "   ")
+				if audio_output_codec == "copy" {
+					audio_output_codec = "aac"
 				}
 
-				// Repeat spot checks
-				for time_to_jump_to := crop_start_seconds_int + scan_duration_int ; time_to_jump_to + scan_duration_int < crop_scan_stop_time_int ; time_to_jump_to = time_to_jump_to + spotcheck_interval {
-
-					// Create the ffmpeg command to scan for crop values
-					command_to_run_str_slice = nil
-					command_to_run_str_slice = append(command_to_run_str_slice, "ffmpeg", "-ss", strconv.Itoa(time_to_jump_to), "-t", scan_duration_str, "-i", inputfile_full_path, "-f", "matroska", "-sn", "-an", "-filter_complex", "cropdetect=24:8:250", "-y", "-crf", "51", "-preset", "ultrafast", "/dev/null")
-
-					if debug_option.is_turned_on == true {
-						fmt.Println()
-						fmt.Println("FFmpeg crop command:", command_to_run_str_slice)
-						fmt.Println()
-					}
-
-					ffmpeg_crop_output, ffmpeg_crop_error_output, error_code := run_external_command(command_to_run_str_slice)
-
-					if error_code != nil {
-
-						fmt.Println("\n\nFFmpeg reported error:", "\n")
-
-						if len(ffmpeg_crop_output) != 0 {
-							for _, textline := range ffmpeg_crop_output {
-								fmt.Println(textline)
-							}
-						}
-
-						if len(ffmpeg_crop_error_output) != 0 {
-							for _, textline := range ffmpeg_crop_error_output {
-								fmt.Println(textline)
-							}
-						}
-
-						os.Exit(1)
-					}
-
-					// Parse the crop value list to find the value that is most frequent, that is the value that can be applied without cropping too much or too little.
-					if error_code == nil {
-
-						crop_value_counter := 0
-
-						for _, slice_item := range ffmpeg_crop_error_output {
-
-							for _, item := range strings.Split(slice_item, "\n") {
-
-								if strings.Contains(item, "crop=") {
-
-									crop_value := strings.Split(item, "crop=")[1]
-
-									if _, item_found := crop_value_map[crop_value]; item_found == true {
-										crop_value_counter = crop_value_map[crop_value]
-									}
-									crop_value_counter = crop_value_counter + 1
-									crop_value_map[crop_value] = crop_value_counter
-									crop_value_counter = 0
-								}
-							}
-						}
-					} else {
-						fmt.Println()
-						fmt.Println("Quick scan for crop failed, switching to the slow method")
-						fmt.Println()
-						quick_scan_failed = true
-						break
-					}
-				}
+				audio_output_codecs = append(audio_output_codecs, audio_output_codec)
+				audio_output_channels = append(audio_output_channels, 2)
+				audio_downmix_filters = append(audio_downmix_filters, plan.Audio_downmix_filter(selected_audio_stream.Number_of_channels, 2))
+				audio_output_is_added_downmix = append(audio_output_is_added_downmix, true)
+				audio_output_stream_numbers = append(audio_output_stream_numbers, audio_stream_numbers[audio_stream_index])
+				audio_output_streams = append(audio_output_streams, selected_audio_stream)
 			}
+		}
 
-			// Scan the file for crop values.
-			if crop_scan_duration_int < 300 || quick_scan_failed == true || len(crop_value_map) == 0 {
-
-				if quick_scan_failed == true {
-					crop_scan_duration_int = 1800
-				}
-
-				command_to_run_str_slice = nil
-
-				if crop_start_seconds_int == 0 {
-					command_to_run_str_slice = append(command_to_run_str_slice, "ffmpeg", "-t", strconv.Itoa(crop_scan_duration_int), "-i", inputfile_full_path, "-f", "matroska", "-sn", "-an", "-filter_complex", "cropdetect=24:8:250", "-y", "-crf", "51", "-preset", "ultrafast", "/dev/null")
-				} else {
-					command_to_run_str_slice = append(command_to_run_str_slice, "ffmpeg", "-ss", strconv.Itoa(crop_start_seconds_int), "-t", strconv.Itoa(crop_scan_duration_int), "-i", inputfile_full_path, "-f", "matroska", "-sn", "-an", "-filter_complex", "cropdetect=24:8:250", "-y", "-crf", "51", "-preset", "ultrafast", "/dev/null")
-				}
-
-				if debug_option.is_turned_on == false {
-					fmt.Printf("Finding crop values for: " + inputfile_name + "   ")
-				}
-
-				if debug_option.is_turned_on == true {
-					fmt.Println()
-					fmt.Println("FFmpeg crop command:", command_to_run_str_slice)
-					fmt.Println()
-				}
-
-				ffmpeg_crop_output, ffmpeg_crop_error_output, error_code := run_external_command(command_to_run_str_slice)
-
-				if error_code != nil {
+		audio_stream_numbers = audio_output_stream_numbers
+		selected_audio_streams = audio_output_streams
 
-					fmt.Println("\n\nFFmpeg reported error:", "\n")
+		//////////////////////////////////////////////////////////////////////////
+		// Measure the loudness of the audio while the file is scanned for crop //
+		//////////////////////////////////////////////////////////////////////////
+		// The analysis reads the same audio that goes in the processed file: the splitfiles or the part of the file selected with -st and -d.
+		// The channel is buffered so that the analysis goroutine can finish even when processing of the file stops before the result is read.
+		// The analysis runs with the context of the file, so it is stopped when processing of the file fails.
+		// With -print the analysis is not run, since with -sf it would read splitfiles that have not been created.
+		loudness_analysis_channel := make(chan plan.Loudness_analysis_result_struct, 1)
 
-					if len(ffmpeg_crop_output) != 0 {
-						for _, textline := range ffmpeg_crop_output {
-							fmt.Println(textline)
-						}
-					}
+		if job.Loudness_normalization != "" {
 
-					if len(ffmpeg_crop_error_output) != 0 {
-						for _, textline := range ffmpeg_crop_error_output {
-							fmt.Println(textline)
-						}
-					}
+			var loudness_analysis_input encode.Ffmpeg_input_struct
 
-					os.Exit(1)
-				}
+			if split_video == true {
+				loudness_analysis_input.Input_options = []string{"-f", "concat", "-safe", "0"}
+				loudness_analysis_input.File_path = split_info_file_absolute_path
+			} else {
+				loudness_analysis_input.Seek_start = search_start_str
+				loudness_analysis_input.File_path = inputfile_full_path
+			}
 
-				// FFmpeg collects possible crop values across the first 1800 seconds of the file and outputs a list of how many times each possible crop values exists.
-				// Parse the list to find the value that is most frequent, that is the value that can be applied without cropping too much or too little.
-				if error_code == nil {
+			// Analyze the audio with the same offset that is used in processing
+			if job.Audio_offset != "" && split_video == false {
+				loudness_analysis_input = plan.Audio_offset_input(inputfile_full_path, search_start_timestamp, audio_offset_milliseconds)
+			}
 
-					crop_value_counter := 0
+			go func() {
+				loudness_analysis_channel <- plan.Analyze_loudness(file_processing_context, loudness_analysis_input, processing_duration_str, audio_stream_numbers, audio_downmix_filters, audio_output_channels, job.Loudness_normalization, job.Only_print_commands)
+			}()
+		}
 
-					for _, slice_item := range ffmpeg_crop_error_output {
+		/////////////////////////////////////////////////////////////
+		// Find out autocrop parameters by scanning the input file //
+		/////////////////////////////////////////////////////////////
 
-						for _, item := range strings.Split(slice_item, "\n") {
+		// FFmpeg cropdetect scans the file and tries to guess where the black bars are.
+		// The command: cropdetect=24:8:250  means:
+		//
+		// Threshold for black is 24.
+		// The values returned by cropdetect must be divisible by 8.
+		// FFmpeg recommends using video sizes divisible by 16 for most video codecs.
+		// We use 8 here since in 1920x1080 the 1080 is not divisible by 16 still 1920x1080 is a stardard H.264 resolution, so the codec handles that resolution ok.
+		// Reset detected border values to zero after 250 frames and try to detect borders again.
+		//
+		// FFmpeg returns a bunch of measurements like this: crop=1472:1080:224:0
+		// Lets see what this means and replace the values by variables: crop=A:B:C:D
+		// The line tells us what part of the picture will be left over after cropping. The line means:
+		//
+		// The detected left border is at C pixels counting from the left of the picture.
+		// Take A pixels starting from C to the right and where we end at is the detected right border of the picture.
+		// Pixels on the right of this point will be cropped.
+		//
+		// The detected upper border is at D pixels from the top of the picture
+		// Take B pixels starting from D down and where we end at is the detected bottom border of the picture.
+		// Pixels below this point will be cropped.
+		//
 
-							if strings.Contains(item, "crop=") {
+		if job.Autocrop == true {
 
-								crop_value := strings.Split(item, "crop=")[1]
+			video_duration_int := int(video_duration)
 
-								if _, item_found := crop_value_map[crop_value]; item_found == true {
-									crop_value_counter = crop_value_map[crop_value]
-								}
-								crop_value_counter = crop_value_counter + 1
-								crop_value_map[crop_value] = crop_value_counter
-								crop_value_counter = 0
-							}
-						}
-					}
-				} else {
-					fmt.Println()
-					fmt.Println("Scanning inputfile with FFmpeg resulted in an error:")
+			// Crop scan is done with whole seconds
+			user_defined_search_start_seconds_int := int(search_start_timestamp.Microseconds / plan.Microseconds_per_second)
+			user_defined_video_duration_seconds_int := int(processing_duration_timestamp.Microseconds / plan.Microseconds_per_second)
 
-					if len(ffmpeg_crop_output) != 0 {
-						for _, textline := range ffmpeg_crop_output {
-							fmt.Println(textline)
-						}
-					}
+			if search_start_str != "" && user_defined_search_start_seconds_int >= video_duration_int {
+				file_processing_failed(errors.New("option -st " + strconv.Itoa(user_defined_search_start_seconds_int) + " cannot start ouside video duration " + strconv.Itoa(video_duration_int)))
+				continue file_loop
+			}
 
-					if len(ffmpeg_crop_error_output) != 0 {
-						for _, textline := range ffmpeg_crop_error_output {
-							fmt.Println(textline)
-						}
-					}
+			if user_defined_video_duration_seconds_int > video_duration_int {
+				file_processing_failed(errors.New("option -d " + strconv.Itoa(user_defined_video_duration_seconds_int) + " cannot be longer than video duration " + strconv.Itoa(video_duration_int)))
+				continue file_loop
+			}
 
-					os.Exit(1)
-				}
+			if user_defined_search_start_seconds_int + user_defined_video_duration_seconds_int > video_duration_int {
+				file_processing_failed(errors.New("times given with options -d and -st combined: " + strconv.Itoa(user_defined_search_start_seconds_int + user_defined_video_duration_seconds_int) + " are outside video duration " + strconv.Itoa(video_duration_int)))
+				continue file_loop
 			}
 
-			// Find the most frequent crop value
-			last_crop_value := 0
+			if job.Debug == true {
+				fmt.Println("search_start_timestamp:", plan.Format_timestamp(search_start_timestamp))
+				fmt.Println("processing_duration_timestamp:", plan.Format_timestamp(processing_duration_timestamp))
+			} else {
+				fmt.Printf("Finding crop values for: " + inputfile_name + "   ")
+			}
 
-			for crop_value := range crop_value_map {
+			crop_value_map, crop_error := crop.Detect_crop(processing_context, inputfile_full_path, video_duration_int, user_defined_search_start_seconds_int, user_defined_video_duration_seconds_int, job.Debug)
 
-				if crop_value_map[crop_value] > last_crop_value {
-					last_crop_value = crop_value_map[crop_value]
-					final_crop_string = crop_value
-				}
+			if crop_error != nil {
+				file_processing_failed(crop_error)
+				continue file_loop
 			}
 
+			final_crop_string = crop.Most_frequent_crop_value(crop_value_map)
+
 			// Store the crop values we will use in variables.
 			crop_values_picture_width, _ = strconv.Atoi(strings.Split(final_crop_string, ":")[0])
 			crop_values_picture_height, _ = strconv.Atoi(strings.Split(final_crop_string, ":")[1])
@@ -4102,7 +2848,7 @@ func main() {
 			/////////////////////////////////////////
 			// Print variable values in debug mode //
 			/////////////////////////////////////////
-			if debug_option.is_turned_on == true {
+			if job.Debug == true {
 
 				fmt.Println()
 				fmt.Println("Crop values are:")
@@ -4116,38 +2862,73 @@ func main() {
 				fmt.Println("Most frequent crop value is", final_crop_string)
 			}
 
-			video_height_int, _ := strconv.Atoi(video_height)
-			cropped_height := video_height_int - crop_values_picture_height - crop_values_height_offset
-
-			video_width_int, _ := strconv.Atoi(video_width)
-			cropped_width := video_width_int - crop_values_picture_width - crop_values_width_offset
+			cropped_height := video_height - crop_values_picture_height - crop_values_height_offset
+			cropped_width := video_width - crop_values_picture_width - crop_values_width_offset
 
 			// Prepare offset for possible subtitle burn in
 			// Subtitle placement is always relative to the left side of the picture,
 			// if left is cropped then the subtitle needs to be moved left the same amount of pixels
 			// Don't use subtitle offset if option -sp is active because it will center subtitles automatically.
-			if subtitle_burn_split.is_turned_on == false {
+			if job.Subtitle_burn_split == false {
 				subtitle_horizontal_offset_int = crop_values_width_offset * -1
 				subtitle_horizontal_offset_str = strconv.Itoa(subtitle_horizontal_offset_int)
 			}
 
 			fmt.Println("Top:", crop_values_height_offset, ", Bottom:", strconv.Itoa(cropped_height), ", Left:", crop_values_width_offset, ", Right:", strconv.Itoa(cropped_width))
 
-			log_messages_str_slice = append(log_messages_str_slice, "")
-			log_messages_str_slice = append(log_messages_str_slice, "Crop values are, Top: "+strconv.Itoa(crop_values_height_offset)+", Bottom: "+strconv.Itoa(cropped_height)+", Left: "+strconv.Itoa(crop_values_width_offset)+", Right: "+strconv.Itoa(cropped_width))
-			log_messages_str_slice = append(log_messages_str_slice, "After cropping video width is: "+strconv.Itoa(crop_values_picture_width)+", and height is: "+strconv.Itoa(crop_values_picture_height))
+			log_messages_str_slice = append(log_messages_str_slice, "")
+			log_messages_str_slice = append(log_messages_str_slice, "Crop values are, Top: "+strconv.Itoa(crop_values_height_offset)+", Bottom: "+strconv.Itoa(cropped_height)+", Left: "+strconv.Itoa(crop_values_width_offset)+", Right: "+strconv.Itoa(cropped_width))
+			log_messages_str_slice = append(log_messages_str_slice, "After cropping video width is: "+strconv.Itoa(crop_values_picture_width)+", and height is: "+strconv.Itoa(crop_values_picture_height))
+		}
+
+		// Wait for the loudness analysis that was started before the crop scan
+		var loudness_measurements []plan.Loudnorm_measurement_struct
+
+		if job.Loudness_normalization != "" {
+
+			loudness_analysis_result := <- loudness_analysis_channel
+
+			log_messages_str_slice = append(log_messages_str_slice, "")
+			log_messages_str_slice = append(log_messages_str_slice, "Loudness analysis:")
+			log_messages_str_slice = append(log_messages_str_slice, loudness_analysis_result.Commands...)
+
+			if job.Debug == true || job.Only_print_commands == true {
+
+				for _, loudness_analysis_command := range loudness_analysis_result.Commands {
+					fmt.Println(loudness_analysis_command)
+					fmt.Println()
+				}
+			}
+
+			if loudness_analysis_result.Error_code != nil {
+				file_processing_failed(loudness_analysis_result.Error_code)
+				continue file_loop
+			}
+
+			loudness_measurements = loudness_analysis_result.Measurements
+
+			for audio_stream_index, loudnorm_measurement := range loudness_measurements {
+
+				if job.Only_print_commands == true {
+					break
+				}
+
+				loudness_message := "Loudness of audio stream " + strconv.Itoa(audio_stream_index) + " is: " + loudnorm_measurement.Input_i + " LUFS, range: " + loudnorm_measurement.Input_lra + " LU, true peak: " + loudnorm_measurement.Input_tp + " dBTP. Normalizing to " + job.Loudness_normalization + " LUFS"
+				fmt.Println(loudness_message)
+				log_messages_str_slice = append(log_messages_str_slice, loudness_message)
+			}
 		}
 
 		////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
 		// Subtitle Split. Move subtitles that are above the center of the screen up to the top of the screen and subtitles below center down on the bottom of the screen //
 		////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
 
-		if subtitle_burn_split.is_turned_on == true && subtitle_burn_number > -1 {
+		if job.Subtitle_burn_split == true && subtitle_burn_number > -1 {
 
 			var subtitle_extract_output []string
 			var subtitle_extract_error_output []string
 
-			if only_print_commands.is_turned_on == false {
+			if job.Only_print_commands == false {
 				// Remove subtitle directories if they were left over from the previous run
 				if _, err := os.Stat(original_subtitles_absolute_path); err == nil {
 					fmt.Printf("Deleting original subtitle files left over from previous run. ")
@@ -4181,51 +2962,51 @@ func main() {
 			// Extract subtitle stream as separate images for every frame of the movie //
 			/////////////////////////////////////////////////////////////////////////////
 			subtitle_processing_start_time = time.Now()
-			ffmpeg_subtitle_extract_commandline = nil
-			ffmpeg_subtitle_extract_commandline = append(ffmpeg_subtitle_extract_commandline, ffmpeg_commandline_start...)
 
+			var subtitle_extract_input encode.Ffmpeg_input_struct
+			var subtitle_extract_target encode.Ffmpeg_output_struct
+
+			ffmpeg_subtitle_extract_commandline = encode.Ffmpeg_commandline_struct{Global_options: ffmpeg_global_options}
 
 			// If the user wants to use the fast and inaccurate search, place the -ss option before the first -i on ffmpeg commandline.
-			if search_start_option.user_string != "" {
-				if fast_search.is_turned_on == true || crf_option.is_turned_on == true {
-					ffmpeg_subtitle_extract_commandline = append(ffmpeg_subtitle_extract_commandline, "-ss", search_start_option.user_string)
+			// The slow and accurate search places the -ss option after the first -i on ffmpeg commandline.
+			if search_start_str != "" {
+				if job.Fast_search == true || job.Crf == true {
+					subtitle_extract_input.Seek_start = search_start_str
+				} else {
+					subtitle_extract_target.Seek_start = search_start_str
 				}
 			}
 
 			if split_video == true {
-
-				ffmpeg_subtitle_extract_commandline = append(ffmpeg_subtitle_extract_commandline, "-f", "concat", "-safe", "0", "-i", split_info_file_absolute_path)
-
+				subtitle_extract_input.Input_options = append(subtitle_extract_input.Input_options, "-f", "concat", "-safe", "0")
+				subtitle_extract_input.File_path = split_info_file_absolute_path
 			} else {
-				ffmpeg_subtitle_extract_commandline = append(ffmpeg_subtitle_extract_commandline, "-i", inputfile_full_path)
-			}
-
-			// The user wants to use the slow and accurate search, place the -ss option after the first -i on ffmpeg commandline.
-			if search_start_option.user_string != "" {
-				if fast_search.is_turned_on == false && crf_option.is_turned_on == false {
-					ffmpeg_subtitle_extract_commandline = append(ffmpeg_subtitle_extract_commandline, "-ss", search_start_option.user_string)
-				}
+				subtitle_extract_input.File_path = inputfile_full_path
 			}
 
-			if processing_duration.user_string != "" {
-				ffmpeg_subtitle_extract_commandline = append(ffmpeg_subtitle_extract_commandline, "-t", processing_duration.user_string)
-			}
+			subtitle_extract_target.Duration = processing_duration_str
+			subtitle_extract_target.Maps = append(subtitle_extract_target.Maps, "[subtitle_processing_stream]")
+			subtitle_extract_target.Codec_options = append(subtitle_extract_target.Codec_options, "-vn", "-an")
+			subtitle_extract_target.File_path = filepath.Join(original_subtitles_absolute_path, "subtitle-%10d." + subtitle_stream_image_format)
 
-			ffmpeg_subtitle_extract_commandline = append(ffmpeg_subtitle_extract_commandline, "-vn", "-an", "-filter_complex", "[0:s:" + strconv.Itoa(subtitle_burn_number)+"]copy[subtitle_processing_stream]", "-map", "[subtitle_processing_stream]", filepath.Join(original_subtitles_absolute_path, "subtitle-%10d." + subtitle_stream_image_format))
+			ffmpeg_subtitle_extract_commandline.Inputs = append(ffmpeg_subtitle_extract_commandline.Inputs, subtitle_extract_input)
+			ffmpeg_subtitle_extract_commandline.Filter_complex = append(ffmpeg_subtitle_extract_commandline.Filter_complex, "[0:s:" + strconv.Itoa(subtitle_burn_number) + "]copy[subtitle_processing_stream]")
+			ffmpeg_subtitle_extract_commandline.Outputs = append(ffmpeg_subtitle_extract_commandline.Outputs, subtitle_extract_target)
 
-			if debug_option.is_turned_on == true || only_print_commands.is_turned_on == true {
+			if job.Debug == true || job.Only_print_commands == true {
 				fmt.Println()
 				fmt.Println("FFmpeg Subtitle Extract Commandline:")
-				fmt.Println(strings.Join(ffmpeg_subtitle_extract_commandline, " "))
+				fmt.Println(encode.Ffmpeg_commandline_to_shell_string(ffmpeg_subtitle_extract_commandline))
 				fmt.Println()
 			}
 
 			log_messages_str_slice = append(log_messages_str_slice, "")
 			log_messages_str_slice = append(log_messages_str_slice, "FFmpeg Subtitle Extract Options:")
 			log_messages_str_slice = append(log_messages_str_slice, "--------------------------------")
-			log_messages_str_slice = append(log_messages_str_slice, strings.Join(ffmpeg_subtitle_extract_commandline, " "))
+			log_messages_str_slice = append(log_messages_str_slice, encode.Ffmpeg_commandline_to_shell_string(ffmpeg_subtitle_extract_commandline))
 
-			if only_print_commands.is_turned_on == false {
+			if job.Only_print_commands == false {
 				fmt.Printf("Extracting subtitle stream as %s - images ", subtitle_stream_image_format)
 			}
 
@@ -4234,36 +3015,22 @@ func main() {
 			////////////////
 			// Run FFmpeg //
 			////////////////
-			if only_print_commands.is_turned_on == false {
-				subtitle_extract_output, subtitle_extract_error_output, error_code = run_external_command(ffmpeg_subtitle_extract_commandline)
+			if job.Only_print_commands == false {
+				subtitle_extract_output, subtitle_extract_error_output, error_code = encode.Run_external_command(processing_context, encode.Render_ffmpeg_commandline(ffmpeg_subtitle_extract_commandline))
 			}
 
 			if error_code != nil {
-
-				fmt.Println("\n\nFFmpeg reported error:", "\n")
-
-				if len(subtitle_extract_output) != 0 {
-					for _, textline := range subtitle_extract_output {
-						fmt.Println(textline)
-					}
-				}
-
-				if len(subtitle_extract_error_output) != 0 {
-					for _, textline := range subtitle_extract_error_output {
-						fmt.Println(textline)
-					}
-				}
-
-				os.Exit(1)
+				file_processing_failed(encode.External_command_error("FFmpeg", subtitle_extract_output, subtitle_extract_error_output, error_code))
+				continue file_loop
 			}
 
 			if len(subtitle_extract_output) != 0 && strings.TrimSpace(subtitle_extract_output[0]) != "" {
-				fmt.Println("\n", subtitle_extract_output, "\n")
+				fmt.Print("\n ", subtitle_extract_output, " \n\n")
 			}
 
 			subtitle_extract_elapsed_time = time.Since(subtitle_extract_start_time)
 
-			if only_print_commands.is_turned_on == false {
+			if job.Only_print_commands == false {
 				fmt.Println("took", subtitle_extract_elapsed_time.Round(time.Millisecond))
 			}
 
@@ -4272,38 +3039,52 @@ func main() {
 			//////////////////////////////////////////////////////////////////////////////////////////
 
 			// Read in subtitle file names
-			files_str_slice := read_filenames_in_a_dir(original_subtitles_absolute_path)
+			var files_str_slice []string
+
+			if job.Only_print_commands == false {
+				files_str_slice, err = subtitle.Read_filenames_in_a_dir(original_subtitles_absolute_path)
+
+				if err != nil {
+					file_processing_failed(errors.New("could not read extracted subtitles: " + err.Error()))
+					continue file_loop
+				}
+			}
 
 			duplicate_removal_start_time := time.Now()
-			if only_print_commands.is_turned_on == false {
+			if job.Only_print_commands == false {
 				fmt.Printf("Removing duplicate subtitle slides ")
 			}
 
 			v_height := video_height
 			v_width := video_width
 
-			if autocrop_option.is_turned_on == true {
-				v_height = strconv.Itoa(crop_values_picture_height)
-				v_width = strconv.Itoa(crop_values_picture_width)
+			if job.Autocrop == true {
+				v_height = crop_values_picture_height
+				v_width = crop_values_picture_width
 			}
 
 			var files_remaining []string
 
-			if only_print_commands.is_turned_on == false {
-				files_remaining = remove_duplicate_subtitle_images (original_subtitles_absolute_path, fixed_subtitles_absolute_path, files_str_slice, v_width, v_height)
+			if job.Only_print_commands == false {
+				files_remaining, err = subtitle.Remove_duplicate_subtitle_images (processing_context, original_subtitles_absolute_path, fixed_subtitles_absolute_path, files_str_slice, v_width, v_height)
+
+				if err != nil {
+					file_processing_failed(errors.New("removing duplicate subtitle images failed: " + err.Error()))
+					continue file_loop
+				}
 			}
 
 			duplicate_removal_elapsed_time := time.Since(duplicate_removal_start_time)
 
-			if only_print_commands.is_turned_on == false {
+			if job.Only_print_commands == false {
 				fmt.Println("took", duplicate_removal_elapsed_time.Round(time.Millisecond))
 			}
 
 			subtitle_trimming_start_time := time.Now()
 
-			if only_print_commands.is_turned_on == false {
+			if job.Only_print_commands == false {
 
-				if subtitle_burn_resize.user_string != "" {
+				if job.Subtitle_burn_resize != "" {
 
 					fmt.Printf("Trimming and resizing subtitle images in " + strconv.Itoa(number_of_physical_processors) + " threads ")
 
@@ -4312,7 +3093,7 @@ func main() {
 					fmt.Printf("Trimming subtitle images in multiple threads ")
 				}
 
-				if debug_option.is_turned_on == true {
+				if job.Debug == true {
 					fmt.Println()
 				}
 			}
@@ -4338,9 +3119,9 @@ func main() {
 					subtitle_end_number = number_of_subtitle_files
 				}
 
-				go subtitle_trim(original_subtitles_absolute_path, fixed_subtitles_absolute_path, files_remaining[subtitle_start_number : subtitle_end_number], v_width, v_height, process_number, return_channel, subtitle_burn_resize.user_string, subtitle_burn_grayscale.is_turned_on)
+				go subtitle.Subtitle_trim(processing_context, original_subtitles_absolute_path, fixed_subtitles_absolute_path, files_remaining[subtitle_start_number : subtitle_end_number], v_width, v_height, process_number, return_channel, job.Subtitle_burn_resize, job.Subtitle_burn_grayscale)
 
-				if debug_option.is_turned_on == true {
+				if job.Debug == true {
 					fmt.Println("Process number:", process_number, "started. It processes subtitles:", subtitle_start_number + 1, "-", subtitle_end_number)
 				}
 
@@ -4351,35 +3132,41 @@ func main() {
 			// Wait for subtitle processing in goroutines to end
 			processes_stopped := 1
 
-			if debug_option.is_turned_on == true {
+			if job.Debug == true {
 				fmt.Println()
 			}
 
 			for processes_stopped < process_number {
 				return_message := <- return_channel
 
-				if debug_option.is_turned_on == true {
+				if job.Debug == true {
 					fmt.Println("Process number:", return_message, "ended.")
 				}
 
 				processes_stopped++
 			}
 
+			// Subtitle processing goroutines stop when processing is interrupted
+			if processing_context.Err() != nil {
+				file_processing_failed(processing_context.Err())
+				continue file_loop
+			}
+
 			subtitle_trimming_elapsed_time := time.Since(subtitle_trimming_start_time)
 
-			if only_print_commands.is_turned_on == false {
+			if job.Only_print_commands == false {
 				fmt.Println("took", subtitle_trimming_elapsed_time.Round(time.Millisecond))
 			}
 
 			subtitle_processing_elapsed_time = time.Since(subtitle_processing_start_time)
 
-			if only_print_commands.is_turned_on == false {
+			if job.Only_print_commands == false {
 				fmt.Printf("Complete subtitle processing took %s", subtitle_processing_elapsed_time.Round(time.Millisecond))
 				fmt.Println()
 			}
 
 
-			if debug_option.is_turned_on == false && only_print_commands.is_turned_on == false {
+			if job.Debug == false && job.Only_print_commands == false {
 
 				if _, err := os.Stat(original_subtitles_absolute_path); err == nil {
 					fmt.Printf("Deleting original subtitles to recover disk space. ")
@@ -4399,13 +3186,13 @@ func main() {
 		// Create the first part of FFmpeg commandline //
 		/////////////////////////////////////////////////
 
-		if scan_mode_only.is_turned_on == false {
+		if job.Scan_mode_only == false {
 
-			ffmpeg_pass_1_commandline = nil
-			ffmpeg_pass_2_commandline = nil
+			var main_output encode.Ffmpeg_output_struct
+			var sd_output encode.Ffmpeg_output_struct
 
 			// Set timecode burn font size
-			video_height_int, _ = strconv.Atoi(video_height)
+			video_height_int = video_height
 			timecode_font_size = 24
 
 			if video_height_int > 699 {
@@ -4413,55 +3200,64 @@ func main() {
 			}
 
 			// Create the start of ffmpeg commandline
-			ffmpeg_pass_2_commandline = append(ffmpeg_pass_2_commandline, ffmpeg_commandline_start...)
+			var main_input encode.Ffmpeg_input_struct
+
+			ffmpeg_pass_2_commandline = encode.Ffmpeg_commandline_struct{Global_options: ffmpeg_global_options}
 
 			// If the user wants to use the fast and inaccurate search, place the -ss option before the first -i on ffmpeg commandline.
-			if search_start_option.user_string != "" {
-				if fast_search.is_turned_on == true || crf_option.is_turned_on == true {
-					ffmpeg_pass_2_commandline = append(ffmpeg_pass_2_commandline, "-ss", search_start_option.user_string)
+			// The slow and accurate search places the -ss option after the first -i on ffmpeg commandline (on every output file).
+			if search_start_str != "" {
+				if job.Fast_search == true || job.Crf == true {
+					main_input.Seek_start = search_start_str
+				} else {
+					main_output.Seek_start = search_start_str
+					sd_output.Seek_start = search_start_str
 				}
 			}
 
+			main_output.Duration = processing_duration_str
+			sd_output.Duration = processing_duration_str
+
 			// Add possible dvd subtitle color palette hacking option to the FFmpeg commandline.
 			// It must be before the first input file to take effect for that file.
-			if subtitle_burn_palette.user_string != "" && subtitle_mux_bool == false {
-				ffmpeg_pass_2_commandline = append(ffmpeg_pass_2_commandline, "-palette", subtitle_burn_palette.user_string)
+			if job.Subtitle_burn_palette != "" && subtitle_mux_bool == false {
+				main_input.Input_options = append(main_input.Input_options, "-palette", job.Subtitle_burn_palette)
 			}
 
 			if split_video == true {
-				ffmpeg_pass_2_commandline = append(ffmpeg_pass_2_commandline, "-f", "concat", "-safe", "0", "-i", split_info_file_absolute_path)
+				main_input.Input_options = append(main_input.Input_options, "-f", "concat", "-safe", "0")
+				main_input.File_path = split_info_file_absolute_path
+			} else {
+				main_input.File_path = inputfile_full_path
+			}
 
-				 if subtitle_burn_split.is_turned_on == true {
-					ffmpeg_pass_2_commandline = append(ffmpeg_pass_2_commandline, "-thread_queue_size", "4096", "-f", "image2", "-i", filepath.Join(fixed_subtitles_absolute_path, "subtitle-%10d." + subtitle_stream_image_format))
-				}
+			ffmpeg_pass_2_commandline.Inputs = append(ffmpeg_pass_2_commandline.Inputs, main_input)
 
-			} else if subtitle_burn_split.is_turned_on == true {
+			if job.Subtitle_burn_split == true {
+				ffmpeg_pass_2_commandline.Inputs = append(ffmpeg_pass_2_commandline.Inputs, encode.Ffmpeg_input_struct{Input_options: []string{"-thread_queue_size", "4096", "-f", "image2"}, File_path: filepath.Join(fixed_subtitles_absolute_path, "subtitle-%10d." + subtitle_stream_image_format)})
+			}
 
-				ffmpeg_pass_2_commandline = append(ffmpeg_pass_2_commandline, "-i", inputfile_full_path, "-thread_queue_size", "4096", "-f", "image2", "-i", filepath.Join(fixed_subtitles_absolute_path, "subtitle-%10d." + subtitle_stream_image_format))
+			// With -ad the audio is read from a second input of the same file that has the offset. The offset is applied before
+			// the file is cut, so audio before the -st start point is used when audio is delayed. The splitfiles of -sf already have the offset.
+			audio_input_number := "0"
 
-			} else {
+			if job.Audio_offset != "" && job.No_audio == false && split_video == false {
 
-				ffmpeg_pass_2_commandline = append(ffmpeg_pass_2_commandline, "-i", inputfile_full_path)
-			}
+				audio_input_seek := plan.Timestamp_struct{}
 
-			// The user wants to use the slow and accurate search, place the -ss option after the first -i on ffmpeg commandline.
-			if search_start_option.user_string != "" {
-				if fast_search.is_turned_on == false && crf_option.is_turned_on == false {
-					ffmpeg_pass_2_commandline = append(ffmpeg_pass_2_commandline, "-ss", search_start_option.user_string)
+				if main_input.Seek_start != "" {
+					audio_input_seek = search_start_timestamp
 				}
-			}
 
-			if parallel_sd.is_turned_on == false && scale_to_sd.is_turned_on == false {
-				if processing_duration.user_string != "" {
-					ffmpeg_pass_2_commandline = append(ffmpeg_pass_2_commandline, "-t", processing_duration.user_string)
-				}
+				audio_input_number = strconv.Itoa(len(ffmpeg_pass_2_commandline.Inputs))
+				ffmpeg_pass_2_commandline.Inputs = append(ffmpeg_pass_2_commandline.Inputs, plan.Audio_offset_input(inputfile_full_path, audio_input_seek, audio_offset_milliseconds))
 			}
 
-			ffmpeg_filter_options := ""
-			ffmpeg_filter_options_2 := ""
+			var ffmpeg_filter_options []string
+			var ffmpeg_filter_options_2 []string
 
 			// Create grayscale FFmpeg - options
-			if grayscale_option.is_turned_on == false {
+			if job.Grayscale == false {
 
 				grayscale_options = ""
 
@@ -4471,7 +3267,7 @@ func main() {
 			}
 
 			// Timecode burn options
-			if burn_timecode.is_turned_on == true {
+			if job.Burn_timecode == true {
 				timecode_burn_options = "drawtext=/usr/share/fonts/TTF/LiberationMono-Regular.ttf:text=%{pts \\\\: hms}:fontcolor=#ffc400:fontsize=" +
 					strconv.Itoa(timecode_font_size) + ":box=1:boxcolor=black@0.7:boxborderw=10:x=(w-text_w)/2:y=(text_h/2)"
 			}
@@ -4483,20 +3279,20 @@ func main() {
 			var chroma_adjustment_command string
 
 			// First adjust black, then white, then gamma, then chroma.
-			if adjust_black_point.is_turned_on == true {
-				black_point_adjustment_command = "colorlevels=rimin=" + adjust_black_point.user_string + ":gimin=" + adjust_black_point.user_string + ":bimin=" + adjust_black_point.user_string
+			if job.Adjust_black_point != "" {
+				black_point_adjustment_command = "colorlevels=rimin=" + job.Adjust_black_point + ":gimin=" + job.Adjust_black_point + ":bimin=" + job.Adjust_black_point
 			}
 
-			if adjust_white_point.is_turned_on == true {
-				white_point_adjustment_command = "colorlevels=rimax=" + adjust_white_point.user_string + ":gimax=" + adjust_white_point.user_string + ":bimax=" + adjust_white_point.user_string
+			if job.Adjust_white_point != "" {
+				white_point_adjustment_command = "colorlevels=rimax=" + job.Adjust_white_point + ":gimax=" + job.Adjust_white_point + ":bimax=" + job.Adjust_white_point
 			}
 
-			if adjust_gamma.is_turned_on == true {
-				gamma_adjustment_command = "eq=gamma=" + adjust_gamma.user_string
+			if job.Adjust_gamma != "" {
+				gamma_adjustment_command = "eq=gamma=" + job.Adjust_gamma
 			}
 
-			if adjust_chroma.is_turned_on == true {
-				chroma_adjustment_command = "eq=saturation=" + adjust_chroma.user_string
+			if job.Adjust_chroma != "" {
+				chroma_adjustment_command = "eq=saturation=" + job.Adjust_chroma
 			}
 
 			/////////////////////////////////////////////////////
@@ -4504,76 +3300,55 @@ func main() {
 			/////////////////////////////////////////////////////
 
 			// Add pullup option on the ffmpeg commandline
-			if inverse_telecine.is_turned_on == true {
-				ffmpeg_filter_options = ffmpeg_filter_options + "pullup"
+			if job.Inverse_telecine == true {
+				ffmpeg_filter_options = append(ffmpeg_filter_options, "pullup")
 			}
 
 			// Add deinterlace commands to ffmpeg commandline
-			if ffmpeg_filter_options != "" {
-				ffmpeg_filter_options = ffmpeg_filter_options + ","
-			}
-			ffmpeg_filter_options = ffmpeg_filter_options + deinterlace_options
+			ffmpeg_filter_options = append(ffmpeg_filter_options, deinterlace_options)
 
 			// Add crop commands to ffmpeg commandline
-			if autocrop_option.is_turned_on == true {
-				if ffmpeg_filter_options != "" {
-					ffmpeg_filter_options = ffmpeg_filter_options + ","
-				}
-				ffmpeg_filter_options = ffmpeg_filter_options + "crop=" + final_crop_string
+			if job.Autocrop == true {
+				ffmpeg_filter_options = append(ffmpeg_filter_options, "crop=" + final_crop_string)
 			}
 
 			// Add denoise options to ffmpeg commandline
-			if denoise_option.is_turned_on == true {
-				if ffmpeg_filter_options != "" {
-					ffmpeg_filter_options = ffmpeg_filter_options + ","
-				}
-				ffmpeg_filter_options = ffmpeg_filter_options + strings.Join(denoise_options, "")
+			if job.Denoise == true {
+				ffmpeg_filter_options = append(ffmpeg_filter_options, strings.Join(denoise_options, ""))
 			}
 
 			// Add black point adjustment options to ffmpeg commandline
-			if adjust_black_point.is_turned_on == true {
-				if ffmpeg_filter_options != "" {
-					ffmpeg_filter_options = ffmpeg_filter_options + ","
-				}
-				ffmpeg_filter_options = ffmpeg_filter_options + black_point_adjustment_command
+			if job.Adjust_black_point != "" {
+				ffmpeg_filter_options = append(ffmpeg_filter_options, black_point_adjustment_command)
 			}
 
 			// Add white point adjustment options to ffmpeg commandline
-			if adjust_white_point.is_turned_on == true {
-				if ffmpeg_filter_options != "" {
-					ffmpeg_filter_options = ffmpeg_filter_options + ","
-				}
-				ffmpeg_filter_options = ffmpeg_filter_options + white_point_adjustment_command
+			if job.Adjust_white_point != "" {
+				ffmpeg_filter_options = append(ffmpeg_filter_options, white_point_adjustment_command)
 			}
 
-			if adjust_black_point.is_turned_on == true || adjust_white_point.is_turned_on == true {
-				ffmpeg_filter_options = ffmpeg_filter_options + ",format=yuv420p"
+			if job.Adjust_black_point != "" || job.Adjust_white_point != "" {
+				ffmpeg_filter_options = append(ffmpeg_filter_options, "format=yuv420p")
 			}
 
 			// Add gamma adjustment options to ffmpeg commandline
-			if adjust_gamma.is_turned_on == true {
-				if ffmpeg_filter_options != "" {
-					ffmpeg_filter_options = ffmpeg_filter_options + ","
-				}
-				ffmpeg_filter_options = ffmpeg_filter_options + gamma_adjustment_command
+			if job.Adjust_gamma != "" {
+				ffmpeg_filter_options = append(ffmpeg_filter_options, gamma_adjustment_command)
 			}
 
 			// Add chroma adjustment options to ffmpeg commandline
-			if adjust_chroma.is_turned_on == true {
-				if ffmpeg_filter_options != "" {
-					ffmpeg_filter_options = ffmpeg_filter_options + ","
-				}
-				ffmpeg_filter_options = ffmpeg_filter_options + chroma_adjustment_command
+			if job.Adjust_chroma != "" {
+				ffmpeg_filter_options = append(ffmpeg_filter_options, chroma_adjustment_command)
 			}
 
 			// Add timecode burn in options
-			if burn_timecode.is_turned_on == true {
-				ffmpeg_filter_options_2 = ffmpeg_filter_options_2 + "," + timecode_burn_options
+			if job.Burn_timecode == true {
+				ffmpeg_filter_options_2 = append(ffmpeg_filter_options_2, timecode_burn_options)
 			}
 
 			// Add grayscale options to ffmpeg commandline
-			if grayscale_option.is_turned_on == true {
-				ffmpeg_filter_options_2 = ffmpeg_filter_options_2 + "," + grayscale_options
+			if job.Grayscale == true {
+				ffmpeg_filter_options_2 = append(ffmpeg_filter_options_2, grayscale_options)
 			}
 
 			///////////////////////////////////////////////////////////////////////////////////
@@ -4584,20 +3359,20 @@ func main() {
 			v_width := 0
 			v_height := 0
 
-			if parallel_sd.is_turned_on == true || scale_to_sd.is_turned_on == true {
+			if job.Parallel_sd == true || job.Scale_to_sd == true {
 
 				// Use cropped video resolution if it is defined else use original wideo reso
-				if autocrop_option.is_turned_on == true {
+				if job.Autocrop == true {
 
 					v_width = crop_values_picture_width
 				} else {
-					v_width,_ = strconv.Atoi(video_width)
+					v_width = video_width
 				}
 
 				if crop_values_picture_height > 0 {
 					v_height = crop_values_picture_height
 				} else {
-					v_height,_ = strconv.Atoi(video_height)
+					v_height = video_height
 				}
 
 				// First try matching the standard HD - resolutions to SD, We only calculate SD x - resolution FFmpeg will automatically scale y based on x.
@@ -4621,452 +3396,833 @@ func main() {
 				}
 
 				if sd_width == 0 {
-					fmt.Println()
-					fmt.Println("Error: could not calculate width of the SD - version")
-					fmt.Println()
-					os.Exit(1)
+					file_processing_failed(errors.New("could not calculate width of the SD - version"))
+					continue file_loop
 				}
 			}
 
-			/////////////////////////////////////////
-			// No subtitle in any format is wanted //
-			/////////////////////////////////////////
+			///////////////////////////////////////////////////////////
+			// Choose the rungs of the adaptive bitrate ladder that  //
+			// are not higher than the video. Video is not upscaled. //
+			///////////////////////////////////////////////////////////
+			var abr_rung_heights_for_file []int
+			abr_source_width := video_width
+			abr_source_height := video_height
+
+			if job.Abr != "" {
+
+				if job.Autocrop == true {
+					abr_source_width = crop_values_picture_width
+					abr_source_height = crop_values_picture_height
+				}
+
+				for _, rung_height := range abr_rung_heights {
+
+					if rung_height <= abr_source_height {
+						abr_rung_heights_for_file = append(abr_rung_heights_for_file, rung_height)
+					}
+				}
+
+				if len(abr_rung_heights_for_file) == 0 {
+					file_processing_failed(errors.New("video height " + strconv.Itoa(abr_source_height) + " is smaller than all rungs of the adaptive bitrate ladder: " + job.Abr))
+					continue file_loop
+				}
+			}
+
+			///////////////////////////////////////
+			// Create the -filter_complex graph //
+			///////////////////////////////////////
+
+			// The last filter chain of the graph processes the video after the possible subtitle burn in.
+			last_filter_chain_input := "[0:v:0]"
+			var last_filter_chain_filters []string
+
+			if subtitle_burn_number >= 0 {
+
+				///////////////////
+				// Subtitle burn //
+				///////////////////
+				subtitle_processing_options = "copy"
+
+				// When cropping video widthwise shrink subtitles to fit on top of the cropped video.
+				// This results in smaller subtitle font.
+				if job.Autocrop == true && job.Subtitle_burn_downscale == true {
+					subtitle_processing_options = "scale=" + strconv.Itoa(crop_values_picture_width) + ":" + strconv.Itoa(crop_values_picture_height)
+				}
+
+				subtitle_source_file := "[0:s:"
+
+				if job.Subtitle_burn_split == true {
+
+					subtitle_source_file = "[1:v:"
+					subtitle_burn_number = 0
+				}
+
+				ffmpeg_pass_2_commandline.Filter_complex = append(ffmpeg_pass_2_commandline.Filter_complex, subtitle_source_file + strconv.Itoa(subtitle_burn_number) + "]" + subtitle_processing_options + "[subtitle_processing_stream]")
+				ffmpeg_pass_2_commandline.Filter_complex = append(ffmpeg_pass_2_commandline.Filter_complex, "[0:v:0]" + strings.Join(ffmpeg_filter_options, ",") + "[video_processing_stream]")
+
+				last_filter_chain_input = "[video_processing_stream][subtitle_processing_stream]"
+				last_filter_chain_filters = append(last_filter_chain_filters, "overlay=" + subtitle_horizontal_offset_str + ":main_h-overlay_h+" + strconv.Itoa(subtitle_burn_vertical_offset_int))
+				last_filter_chain_filters = append(last_filter_chain_filters, ffmpeg_filter_options_2...)
+
+			} else {
+				last_filter_chain_filters = append(last_filter_chain_filters, ffmpeg_filter_options...)
+				last_filter_chain_filters = append(last_filter_chain_filters, ffmpeg_filter_options_2...)
+			}
+
+			if job.Parallel_sd == true {
+
+				// Create a main (HD) and SD - video simultaneously
+				// FFmpeg scaling needs only resolution of one axis and it calculates the other automatically. For example for a 1920x1080 source video: scale=1024:-2 will scale the video to 1024x576. The -2 means calculate axis automatically so that it is divisible by 2
+				last_filter_chain_filters = append(last_filter_chain_filters, "split=2")
+				ffmpeg_pass_2_commandline.Filter_complex = append(ffmpeg_pass_2_commandline.Filter_complex, last_filter_chain_input + strings.Join(last_filter_chain_filters, ",") + "[main_processed_video_out][sd_input]")
+				ffmpeg_pass_2_commandline.Filter_complex = append(ffmpeg_pass_2_commandline.Filter_complex, "[sd_input]scale=" + strconv.Itoa(sd_width) + ":-2[sd_scaled_out]")
+
+			} else if job.Abr != "" {
+
+				// Split video to all rungs of the adaptive bitrate ladder and scale each to the rung height.
+				// The width is calculated automatically from the height so that the aspect ratio stays the same.
+				var abr_split_outputs string
+
+				for counter := range abr_rung_heights_for_file {
+					abr_split_outputs = abr_split_outputs + "[abr_input_" + strconv.Itoa(counter) + "]"
+				}
+
+				last_filter_chain_filters = append(last_filter_chain_filters, "split=" + strconv.Itoa(len(abr_rung_heights_for_file)))
+				ffmpeg_pass_2_commandline.Filter_complex = append(ffmpeg_pass_2_commandline.Filter_complex, last_filter_chain_input + strings.Join(last_filter_chain_filters, ",") + abr_split_outputs)
+
+				for counter, rung_height := range abr_rung_heights_for_file {
+					ffmpeg_pass_2_commandline.Filter_complex = append(ffmpeg_pass_2_commandline.Filter_complex, "[abr_input_" + strconv.Itoa(counter) + "]scale=-2:" + strconv.Itoa(rung_height) + ":flags=lanczos[abr_scaled_out_" + strconv.Itoa(counter) + "]")
+				}
+
+			} else if job.Scale_to_sd == true {
+
+				ffmpeg_pass_2_commandline.Filter_complex = append(ffmpeg_pass_2_commandline.Filter_complex, last_filter_chain_input + strings.Join(last_filter_chain_filters, ",") + "[sd_input]")
+				ffmpeg_pass_2_commandline.Filter_complex = append(ffmpeg_pass_2_commandline.Filter_complex, "[sd_input]scale=" + strconv.Itoa(sd_width) + ":-2[sd_scaled_out]")
+
+			} else {
+
+				// Create only one video version
+				ffmpeg_pass_2_commandline.Filter_complex = append(ffmpeg_pass_2_commandline.Filter_complex, last_filter_chain_input + strings.Join(last_filter_chain_filters, ",") + "[main_processed_video_out]")
+			}
+
+			main_output.Maps = append(main_output.Maps, "[main_processed_video_out]")
+			sd_output.Maps = append(sd_output.Maps, "[sd_scaled_out]")
+			sd_output.Options = append(sd_output.Options, "-sws_flags", "lanczos")
+
+			/////////////////////////////////////////////////////////
+			// No subtitle in any format is wanted or mux subtitle //
+			/////////////////////////////////////////////////////////
 			if subtitle_mux_bool == false && subtitle_burn_number == -1 {
 
 				// There is no subtitle to process add the "no subtitle" option to FFmpeg commandline.
-				if parallel_sd.is_turned_on == true {
+				main_output.Codec_options = append(main_output.Codec_options, "-sn")
+				sd_output.Codec_options = append(sd_output.Codec_options, "-sn")
+			}
+
+			if subtitle_mux_bool == true {
+
+				// There is a dvd, dvb or bluray bitmap subtitle to mux into the target file add the relevant options to FFmpeg commandline.
+				main_output.Codec_options = append(main_output.Codec_options, "-scodec", "copy")
+				sd_output.Codec_options = append(sd_output.Codec_options, "-scodec", "copy")
+
+				for _, subtitle_mux_number := range user_subtitle_mux_numbers_slice {
+					main_output.Maps = append(main_output.Maps, "0:s:"+ subtitle_mux_number)
+					sd_output.Maps = append(sd_output.Maps, "0:s:"+ subtitle_mux_number)
+				}
+			}
+
+			// Inverse telecine returns frame rate back to original 24 fps
+			if job.Inverse_telecine == true {
+				main_output.Codec_options = append(main_output.Codec_options, "-r", "24")
+				sd_output.Codec_options = append(sd_output.Codec_options, "-r", "24")
+			}
+
+			///////////////////////////////////////////////////////////////////
+			// Add video and audio compression options to FFmpeg commandline //
+			///////////////////////////////////////////////////////////////////
+
+			////////////////////////////////////////////////////////////////////////
+			// Calculate 2-pass bitrate based on the pixecount on the video frame //
+			////////////////////////////////////////////////////////////////////////
+
+			if job.Autocrop == true {
+
+				v_width = crop_values_picture_width
+				v_height = crop_values_picture_height
+			} else {
+				v_width = video_width
+				v_height = video_height
+			}
+
+			// The formula is (horizontal resolution * vertical resolution) / video_compression_bitrate_divider. For example: 1920 x 1080 = 2 073 600 pixels / 256 = bitrate 8100k
+			main_video_2_pass_bitrate_int := (v_width * v_height) / video_compression_bitrate_divider
+			main_video_2_pass_bitrate_str = strconv.Itoa(main_video_2_pass_bitrate_int) + "k"
+
+			// User overrides automatic bitrate calculation and defines one on the commandline
+			if user_main_bitrate_bool == true {
+				main_video_2_pass_bitrate_str = job.Main_bitrate
+			}
+
+			// Parallel SD video 2-pass bitrate is fixed to 720 x 576 / video_compression_bitrate_divider = 1620k (810k with HEVC)
+			sd_video_bitrate := strconv.Itoa((720 * 576) / video_compression_bitrate_divider) + "k"
+
+			// User overrides automatic bitrate calculation and defines one on the commandline
+			if user_sd_bitrate_bool == true {
+				sd_video_bitrate = job.Sd_bitrate
+			}
+
+			///////////////////////////////////////////////////////////
+			// Calculate 2-pass bitrate from the user given file size //
+			///////////////////////////////////////////////////////////
+			if job.Target_size != "" || job.Sd_target_size != "" {
+
+				// Find out the bitrate of the audio that goes in the processed file
+				var audio_bitrate_kbps int64
+
+				for audio_stream_index, selected_audio_stream := range selected_audio_streams {
+
+					var stream_bitrate_kbps int64
+
+					if job.No_audio == true {
+						break
+
+					} else if audio_output_codecs[audio_stream_index] == "aac" || audio_output_codecs[audio_stream_index] == "opus" {
+						stream_bitrate_kbps = int64(audio_output_channels[audio_stream_index] * audio_bitrate_multiplier)
+
+					} else if audio_output_codecs[audio_stream_index] == "ac3" {
+						stream_bitrate_kbps = int64(audio_output_channels[audio_stream_index] * audio_bitrate_multiplier)
+
+						if stream_bitrate_kbps > 640 {
+							stream_bitrate_kbps = 640
+						}
+
+					} else {
+						stream_bitrate_kbps = plan.Source_audio_bitrate(selected_audio_stream)
+
+						if stream_bitrate_kbps == 0 {
+							file_processing_failed(errors.New("can't find out the bitrate of the " + selected_audio_stream.Codec_name + " audio stream needed for the -size calculation. Use the -aac, -opus or -ac3 option to recompress audio"))
+							continue file_loop
+						}
+					}
+
+					// All selected audio streams go in the processed file
+					audio_bitrate_kbps = audio_bitrate_kbps + stream_bitrate_kbps
+				}
+
+				file_duration := plan.Timestamp_struct{Microseconds: int64(video_duration * float64(plan.Microseconds_per_second))}
+				duration_of_processed_video := plan.Processed_duration(file_duration, search_start_timestamp, processing_duration_timestamp, cut_list_timestamps)
+
+				if job.Target_size != "" {
+
+					target_size_bytes, _ := plan.Parse_file_size(job.Target_size)
+					video_bitrate_kbps, bitrate_error := plan.Target_size_video_bitrate(target_size_bytes, duration_of_processed_video, audio_bitrate_kbps)
+
+					if bitrate_error != nil {
+						file_processing_failed(errors.New("option -size " + job.Target_size + ": " + bitrate_error.Error()))
+						continue file_loop
+					}
+
+					// With the -ssd option there is only the SD video
+					if job.Scale_to_sd == true {
+						sd_video_bitrate = strconv.FormatInt(video_bitrate_kbps, 10) + "k"
+					} else {
+						main_video_2_pass_bitrate_str = strconv.FormatInt(video_bitrate_kbps, 10) + "k"
+					}
+
+					log_messages_str_slice = append(log_messages_str_slice, "\nVideo bitrate " + strconv.FormatInt(video_bitrate_kbps, 10) + "k was calculated from target size " + job.Target_size + ", duration " + plan.Format_timestamp(duration_of_processed_video) + " and audio bitrate " + strconv.FormatInt(audio_bitrate_kbps, 10) + "k")
+				}
+
+				if job.Sd_target_size != "" {
+
+					target_size_bytes, _ := plan.Parse_file_size(job.Sd_target_size)
+					video_bitrate_kbps, bitrate_error := plan.Target_size_video_bitrate(target_size_bytes, duration_of_processed_video, audio_bitrate_kbps)
+
+					if bitrate_error != nil {
+						file_processing_failed(errors.New("option -sdsize " + job.Sd_target_size + ": " + bitrate_error.Error()))
+						continue file_loop
+					}
+
+					sd_video_bitrate = strconv.FormatInt(video_bitrate_kbps, 10) + "k"
+
+					log_messages_str_slice = append(log_messages_str_slice, "\nSD video bitrate " + sd_video_bitrate + " was calculated from target size " + job.Sd_target_size + ", duration " + plan.Format_timestamp(duration_of_processed_video) + " and audio bitrate " + strconv.FormatInt(audio_bitrate_kbps, 10) + "k")
+				}
+			}
+
+			/////////////////////////////////////////////////////////////////
+			// Choose video compression profile by the vertical resolution //
+			/////////////////////////////////////////////////////////////////
+			main_video_compression_options := video_compression_options_sd
+
+			if v_height > 4191 {
+				main_video_compression_options = video_compression_options_ultra_hd_8k
+
+			} else if v_height > 2096 {
+				main_video_compression_options = video_compression_options_ultra_hd_4k
+
+			} else if v_height > 699 {
+				main_video_compression_options = video_compression_options_hd
+			}
+
+			////////////////////////////////////////////////////////////////
+			// Find the highest crf value that reaches the quality target //
+			////////////////////////////////////////////////////////////////
+			file_crf_value := crf_value
 
-					// Create a main (HD) and SD - video simultaneously
-					// FFmpeg scaling needs only resolution of one axis and it calculates the other automatically. For example for a 1920x1080 source video: scale=1024:-2 will scale the video to 1024x576. The -2 means calculate axis automatically so that it is divisible by 2
-					ffmpeg_pass_2_commandline = append(ffmpeg_pass_2_commandline, "-filter_complex", "[0:v:0]" + ffmpeg_filter_options + ffmpeg_filter_options_2 + ",split=2[main_processed_video_out][sd_input],[sd_input]scale=" + strconv.Itoa(sd_width) + ":-2[sd_scaled_out]", "-map", "[main_processed_video_out]", "-sn")
+			if job.Quality_target != "" && job.Only_print_commands == false {
+
+				quality_metric, quality_target, _ := plan.Parse_quality_target(job.Quality_target)
+
+				// Sample the same parts of the file that are processed. With -sf these are the parts the user selected.
+				file_duration := plan.Timestamp_struct{Microseconds: int64(video_duration * float64(plan.Microseconds_per_second))}
+				var processed_ranges []plan.Timestamp_struct
+
+				if split_video == true {
+					processed_ranges = append(processed_ranges, cut_list_timestamps...)
 
-				} else if scale_to_sd.is_turned_on == true {
-					ffmpeg_pass_2_commandline = append(ffmpeg_pass_2_commandline, "-filter_complex", "[0:v:0]" + ffmpeg_filter_options + ffmpeg_filter_options_2 + "[sd_input],[sd_input]scale=" + strconv.Itoa(sd_width) + ":-2[sd_scaled_out]")
+					if len(processed_ranges) % 2 != 0 {
+						processed_ranges = append(processed_ranges, plan.Timestamp_subtract(file_duration, processed_ranges[len(processed_ranges) - 1]))
+					}
 				} else {
+					processed_ranges = []plan.Timestamp_struct{search_start_timestamp, plan.Processed_duration(file_duration, search_start_timestamp, processing_duration_timestamp, nil)}
+				}
+
+				window_start_times, window_durations := plan.Quality_sample_windows(processed_ranges, Quality_search_number_of_samples, quality_search_sample_duration)
+
+				// Samples go through the same filters as the video, but without subtitles and timecode
+				sample_video_filters := append([]string{}, ffmpeg_filter_options...)
+				sample_codec_options := main_video_compression_options
+
+				if job.Scale_to_sd == true {
+					sample_video_filters = append(sample_video_filters, "scale=" + strconv.Itoa(sd_width) + ":-2")
+					sample_codec_options = video_compression_options_sd
+				}
+
+				if crf_needs_zero_bitrate == true {
+					sample_codec_options = append(append([]string{}, sample_codec_options...), "-b:v", "0")
+				}
+
+				fmt.Println("Searching crf value for quality target " + quality_metric + " " + strconv.FormatFloat(quality_target, 'f', -1, 64) + " using " + strconv.Itoa(len(window_start_times)) + " samples")
+				log_messages_str_slice = append(log_messages_str_slice, "", "Quality target search:", "----------------------")
+
+				// Quality gets better when crf gets smaller. Do a binary search for the highest crf that reaches the target.
+				search_min := quality_search_crf_min
+				search_max := quality_search_crf_max
+				best_crf := -1
+
+				for search_min <= search_max {
+
+					crf := (search_min + search_max) / 2
+					quality, commands_for_log, measure_error := plan.Measure_crf_quality(processing_context, inputfile_full_path, window_start_times, window_durations, sample_video_filters, color_subsampling_options[1], sample_codec_options, strconv.Itoa(crf), quality_metric, ffmpeg_2_pass_logfile_path + "-quality_sample.mkv")
+
+					if job.Debug == true {
+						fmt.Println(strings.Join(commands_for_log, "\n"))
+					}
+
+					if measure_error != nil {
+						file_processing_failed(measure_error)
+						continue file_loop
+					}
+
+					quality_str := strconv.FormatFloat(quality, 'f', 4, 64)
+					fmt.Println("crf " + strconv.Itoa(crf) + ": " + quality_metric + " " + quality_str)
+					log_messages_str_slice = append(log_messages_str_slice, "crf " + strconv.Itoa(crf) + ": " + quality_metric + " " + quality_str)
+
+					if quality >= quality_target {
+						best_crf = crf
+						search_min = crf + 1
+					} else {
+						search_max = crf - 1
+					}
+				}
+
+				if best_crf == -1 {
+					best_crf = quality_search_crf_min
+					fmt.Println("\033[7mWarning: Quality target was not reached with the smallest crf value " + strconv.Itoa(best_crf) + ", using it.\033[0m")
+					log_messages_str_slice = append(log_messages_str_slice, "Quality target was not reached with the smallest crf value " + strconv.Itoa(best_crf) + ", using it.")
+				}
+
+				file_crf_value = strconv.Itoa(best_crf)
+				fmt.Println("Using crf value:", file_crf_value)
+				log_messages_str_slice = append(log_messages_str_slice, "Using crf value: " + file_crf_value)
+			}
+
+			if job.Crf == true {
+				// Use constant quality instead of 2-pass encoding
+				main_video_compression_options = append(main_video_compression_options, "-crf", file_crf_value)
+				main_video_2_pass_bitrate_str = "Constant Quality: " + file_crf_value
+
+				if crf_needs_zero_bitrate == true {
+					main_video_compression_options = append(main_video_compression_options, "-b:v", "0")
+				}
+			} else {
+				// Add calculated 2-pass bitrate to video compression options
+				main_video_compression_options = append(main_video_compression_options, "-b:v", main_video_2_pass_bitrate_str)
+			}
+
+			if job.Force_lossless == true {
+				// Lossless video compression options
+				main_video_compression_options = video_compression_options_lossless
+				main_video_2_pass_bitrate_str = "Lossless"
+			}
+
+			//////////////////////////
+			// Choose audio options //
+			//////////////////////////
+			// Every audio stream gets its own options, since the number of channels and the codec may differ between streams.
+			// When there are several audio streams the options get a stream specifier: -c:a:1 aac -b:a:1 256k
+			var file_audio_compression_options []string
+			var sd_audio_compression_options []string
+			var audio_processing_messages []string
+
+			if job.No_audio == true {
+				file_audio_compression_options = append(file_audio_compression_options, "-an")
+				sd_audio_compression_options = append(sd_audio_compression_options, "-an")
+				audio_processing_messages = append(audio_processing_messages, "Audio processing is off.")
+			}
+
+			for audio_stream_index, selected_audio_stream := range selected_audio_streams {
+
+				if job.No_audio == true {
+					break
+				}
+
+				stream_audio_compression_options := audio_compression_options
+				stream_audio_codec := strings.ToLower(selected_audio_stream.Codec_name)
+				number_of_audio_channels_int := audio_output_channels[audio_stream_index]
+				bitrate_int := number_of_audio_channels_int * audio_bitrate_multiplier
+				bitrate_str := strconv.Itoa(bitrate_int) + "k"
+				audio_processing_message := "Copying " + stream_audio_codec + " audio to target."
+
+				if audio_output_codecs[audio_stream_index] == "flac" {
+					stream_audio_compression_options = audio_compression_options_lossless
+					audio_processing_message = "Encoding " + strconv.Itoa(number_of_audio_channels_int) + " channel audio to flac."
+				}
+
+				if audio_output_codecs[audio_stream_index] == "aac" {
+					stream_audio_compression_options = []string{"-c:a", "aac", "-b:a", bitrate_str}
+					audio_processing_message = "Encoding " + strconv.Itoa(number_of_audio_channels_int) + " channel audio to aac with bitrate: " + bitrate_str
+				}
+
+				// FIXME When FFmpeg opus support in mp4 is mainlined, remove "-strict", "-2" options from the couple of lines below
+				// If we are encoding audio to opus, then enable FFmpeg experimental features
+				// -strict -2 is needed for FFmpeg to use still experimental support for opus in mp4 container.
+				// 2020.11.14: FFmpeg 4.3.1 seems to support opus in mp4 withous strict 2, these can be removed from the following lines
+				if audio_output_codecs[audio_stream_index] == "opus" {
+
+					if number_of_audio_channels_int <= 2 {
+						stream_audio_compression_options = []string{"-c:a", "libopus", "-b:a", bitrate_str, "-vbr", "off", "-mapping_family", "0",  "-strict", "-2"}
+					} else if job.Use_webm_container == true {
+						// WebM players only support the standard surround channel layouts of mapping family 1
+						stream_audio_compression_options = []string{"-c:a", "libopus", "-b:a", bitrate_str, "-vbr", "off", "-mapping_family", "1", "-strict", "-2"}
+					} else {
+						stream_audio_compression_options = []string{"-c:a", "libopus", "-b:a", bitrate_str, "-vbr", "off", "-mapping_family", "255", "-strict", "-2"}
+					}
 
-					// Create only one video version
-					ffmpeg_pass_2_commandline = append(ffmpeg_pass_2_commandline, "-filter_complex", "[0:v:0]" + ffmpeg_filter_options + ffmpeg_filter_options_2 + "[main_processed_video_out]", "-map", "[main_processed_video_out]", "-sn")
+					audio_processing_message = "Encoding " + strconv.Itoa(number_of_audio_channels_int) + " channel audio to opus with bitrate: " + bitrate_str
 				}
-			}
-
-			////////////////////////////////
-			// User wants to mux subtitle //
-			////////////////////////////////
-			if subtitle_mux_bool == true {
 
-				// There is a dvd, dvb or bluray bitmap subtitle to mux into the target file add the relevant options to FFmpeg commandline.
-				if parallel_sd.is_turned_on == true {
+				// If we are copying opus audio, then enable FFmpeg experimental features
+				// -strict -2 is needed for FFmpeg to use still experimental support for opus in mp4 container.
+				if stream_audio_codec == "opus" && audio_output_codecs[audio_stream_index] == "copy" {
+					stream_audio_compression_options = append(append([]string{}, stream_audio_compression_options...), "-strict", "-2")
+				}
 
-					// Create a main (HD) and SD - video simultaneously
-					// FFmpeg scaling needs only resolution of one axis and it calculates the other automatically. For example for a 1920x1080 source video: scale=1024:-2 will scale the video to 1024x576. The -2 means calculate axis automatically so that it is divisible by 2
-					ffmpeg_pass_2_commandline = append(ffmpeg_pass_2_commandline, "-filter_complex", "[0:v:0]" + ffmpeg_filter_options + ffmpeg_filter_options_2 + ",split=2[main_processed_video_out][sd_input],[sd_input]scale=" + strconv.Itoa(sd_width) + ":-2[sd_scaled_out]", "-map", "[main_processed_video_out]")
+				if audio_output_codecs[audio_stream_index] == "ac3" {
 
-				} else if scale_to_sd.is_turned_on == true {
-					ffmpeg_pass_2_commandline = append(ffmpeg_pass_2_commandline, "-filter_complex", "[0:v:0]" + ffmpeg_filter_options + ffmpeg_filter_options_2 + "[sd_input],[sd_input]scale=" + strconv.Itoa(sd_width) + ":-2[sd_scaled_out]")
-				} else {
+					if bitrate_int > 640 {
+						bitrate_str = "640k"
+					}
 
-					// Create only one video version
-					ffmpeg_pass_2_commandline = append(ffmpeg_pass_2_commandline, "-filter_complex", "[0:v:0]" + ffmpeg_filter_options + ffmpeg_filter_options_2 + "[main_processed_video_out]", "-map", "[main_processed_video_out]")
+					stream_audio_compression_options = []string{"-c:a", "ac3", "-b:a", bitrate_str}
+					audio_processing_message = "Encoding " + strconv.Itoa(number_of_audio_channels_int) + " channel audio to ac3 with bitrate: " + bitrate_str
 				}
 
-				ffmpeg_pass_2_commandline = append(ffmpeg_pass_2_commandline, "-scodec", "copy")
+				// All audio filters of the stream must be given in one -filter:a option
+				var stream_audio_filters []string
+				var stream_channel_options []string
 
-				for _, subtitle_mux_number := range user_subtitle_mux_numbers_slice {
-					ffmpeg_pass_2_commandline = append(ffmpeg_pass_2_commandline, "-map", "0:s:"+ subtitle_mux_number)
+				// The offset has been applied to the audio input, so it is the same in the main video, the SD video and the adaptive bitrate streams.
+				if job.Audio_offset != "" {
+					audio_processing_message = audio_processing_message + ", offset " + job.Audio_offset + " ms"
 				}
 
-			}
+				// Number of channels changed with -stereo, -add-stereo, -amap 2:aac:2ch or by remapping 7.1 to 5.1 for AC3.
+				// 5.1 and 7.1 are downmixed with the pan filter, other layouts with -ac.
+				if number_of_audio_channels_int != selected_audio_stream.Number_of_channels && audio_downmix_filters[audio_stream_index] != "" {
+					stream_audio_filters = append(stream_audio_filters, audio_downmix_filters[audio_stream_index])
 
-			///////////////////
-			// Subtitle burn //
-			///////////////////
-			if subtitle_burn_number >= 0 {
+					if job.Loudness_normalization == "" {
+						stream_audio_filters = append(stream_audio_filters, plan.Audio_downmix_limiter)
+					}
+					audio_processing_message = audio_processing_message + " (downmixed from " + strconv.Itoa(selected_audio_stream.Number_of_channels) + " source channels)"
 
-				// Add video filter options to ffmpeg commanline
-				subtitle_processing_options = "copy"
+				} else if number_of_audio_channels_int != selected_audio_stream.Number_of_channels {
+					stream_channel_options = append(stream_channel_options, "-ac", strconv.Itoa(number_of_audio_channels_int))
+					audio_processing_message = audio_processing_message + " (" + strconv.Itoa(selected_audio_stream.Number_of_channels) + " source channels)"
+				}
 
-				// When cropping video widthwise shrink subtitles to fit on top of the cropped video.
-				// This results in smaller subtitle font.
-				if autocrop_option.is_turned_on == true && subtitle_burn_downscale.is_turned_on == true {
-					subtitle_processing_options = "scale=" + strconv.Itoa(crop_values_picture_width) + ":" + strconv.Itoa(crop_values_picture_height)
+				// Second pass of loudness normalization uses the values measured in the loudness analysis
+				if job.Loudness_normalization != "" {
+					stream_audio_filters = append(stream_audio_filters, plan.Loudnorm_filter(job.Loudness_normalization, loudness_measurements[audio_stream_index], selected_audio_stream.Sample_rate))
+					audio_processing_message = audio_processing_message + ", loudness normalized to " + job.Loudness_normalization + " LUFS"
 				}
 
-				subtitle_source_file := "[0:s:"
+				if len(stream_audio_filters) > 0 {
+					stream_channel_options = append(stream_channel_options, "-filter:a", strings.Join(stream_audio_filters, ","))
+				}
 
-				if subtitle_burn_split.is_turned_on == true {
+				// If main video audio is lossless use aac compression for the SD video
+				sd_stream_audio_compression_options := stream_audio_compression_options
 
-					subtitle_source_file = "[1:v:"
-					subtitle_burn_number = 0
+				if job.Force_lossless == true {
+					sd_stream_audio_compression_options = []string{"-c:a", "aac", "-b:a", strconv.Itoa(bitrate_int) + "k"}
 				}
 
-				if parallel_sd.is_turned_on == true {
+				stream_audio_compression_options = append(append([]string{}, stream_audio_compression_options...), stream_channel_options...)
+				sd_stream_audio_compression_options = append(append([]string{}, sd_stream_audio_compression_options...), stream_channel_options...)
 
-					// Create a main (HD) and SD - video simultaneously
-					// FFmpeg scaling needs only resolution of one axis and it calculates the other automatically. For example for a 1920x1080 source video: scale=1024:-2 will scale the video to 1024x576. The -2 means calculate axis automatically so that it is divisible by 2
-					ffmpeg_pass_2_commandline = append(ffmpeg_pass_2_commandline, "-filter_complex", subtitle_source_file + strconv.Itoa(subtitle_burn_number) +
-						"]" + subtitle_processing_options + "[subtitle_processing_stream];[0:v:0]" + ffmpeg_filter_options +
-						"[video_processing_stream];[video_processing_stream][subtitle_processing_stream]overlay=" + subtitle_horizontal_offset_str + ":main_h-overlay_h+" +
-						strconv.Itoa(subtitle_burn_vertical_offset_int) + ffmpeg_filter_options_2 +
-						",split=2[main_processed_video_out][sd_input],[sd_input]scale=" + strconv.Itoa(sd_width) + ":-2[sd_scaled_out]", "-map", "[main_processed_video_out]")
+				if len(selected_audio_streams) > 1 {
 
-				} else if scale_to_sd.is_turned_on == true {
-					ffmpeg_pass_2_commandline = append(ffmpeg_pass_2_commandline, "-filter_complex", subtitle_source_file + strconv.Itoa(subtitle_burn_number) +
-						"]" + subtitle_processing_options + "[subtitle_processing_stream];[0:v:0]" + ffmpeg_filter_options +
-						"[video_processing_stream];[video_processing_stream][subtitle_processing_stream]overlay=" + subtitle_horizontal_offset_str + ":main_h-overlay_h+" +
-						strconv.Itoa(subtitle_burn_vertical_offset_int) + ffmpeg_filter_options_2 +
-						"[sd_input],[sd_input]scale=" + strconv.Itoa(sd_width) + ":-2[sd_scaled_out]")
-				} else {
+					// The first audio stream is marked as the default audio, other dispositions (visual impaired, commentary) are kept as they are in the source
+					stream_disposition := "-default"
 
-					// Create only one video version
-					ffmpeg_pass_2_commandline = append(ffmpeg_pass_2_commandline, "-filter_complex", subtitle_source_file + strconv.Itoa(subtitle_burn_number) +
-						"]" + subtitle_processing_options + "[subtitle_processing_stream];[0:v:0]" + ffmpeg_filter_options +
-						"[video_processing_stream];[video_processing_stream][subtitle_processing_stream]overlay=" + subtitle_horizontal_offset_str + ":main_h-overlay_h+" +
-						strconv.Itoa(subtitle_burn_vertical_offset_int) + ffmpeg_filter_options_2 +
-						"[main_processed_video_out]", "-map", "[main_processed_video_out]")
+					if audio_stream_index == 0 {
+						stream_disposition = "+default"
 					}
-				}
 
-			// Inverse telecine returns frame rate back to original 24 fps
-			if inverse_telecine.is_turned_on == true {
-				ffmpeg_pass_2_commandline = append(ffmpeg_pass_2_commandline, "-r", "24")
-			}
+					stream_audio_compression_options = append(encode.Add_stream_specifier(stream_audio_compression_options, "a", audio_stream_index), "-disposition:a:" + strconv.Itoa(audio_stream_index), stream_disposition)
+					sd_stream_audio_compression_options = append(encode.Add_stream_specifier(sd_stream_audio_compression_options, "a", audio_stream_index), "-disposition:a:" + strconv.Itoa(audio_stream_index), stream_disposition)
 
-			///////////////////////////////////////////////////////////////////
-			// Add video and audio compression options to FFmpeg commandline //
-			///////////////////////////////////////////////////////////////////
+					// The added downmix is mapped from the surround stream and would get its title, name it so that it can be told apart in the player
+					if audio_output_is_added_downmix[audio_stream_index] == true {
+						stream_audio_compression_options = append(stream_audio_compression_options, "-metadata:s:a:" + strconv.Itoa(audio_stream_index), "title=Stereo")
+						sd_stream_audio_compression_options = append(sd_stream_audio_compression_options, "-metadata:s:a:" + strconv.Itoa(audio_stream_index), "title=Stereo")
+					}
 
-			sd_ffmpeg_pass_1_commandline := []string{}
-			sd_ffmpeg_pass_2_commandline := []string{"-map", "[sd_scaled_out]", "-sws_flags", "lanczos"}
+					audio_stream_description := "Audio stream " + strconv.Itoa(audio_stream_index)
 
-			////////////////////////////////////////////////////////////////////////
-			// Calculate 2-pass bitrate based on the pixecount on the video frame //
-			////////////////////////////////////////////////////////////////////////
+					if selected_audio_stream.Language != "" {
+						audio_stream_description = audio_stream_description + " (" + selected_audio_stream.Language + ")"
+					}
 
-			if autocrop_option.is_turned_on == true {
+					audio_processing_message = audio_stream_description + ": " + audio_processing_message
+				}
 
-				v_width = crop_values_picture_width
-				v_height = crop_values_picture_height
-			} else {
-				v_width,_ = strconv.Atoi(video_width)
-				v_height,_ = strconv.Atoi(video_height)
+				file_audio_compression_options = append(file_audio_compression_options, stream_audio_compression_options...)
+				sd_audio_compression_options = append(sd_audio_compression_options, sd_stream_audio_compression_options...)
+				audio_processing_messages = append(audio_processing_messages, audio_processing_message)
 			}
 
-			// The formula is (horizontal resolution * vertical resolution) / video_compression_bitrate_divider. For example: 1920 x 1080 = 2 073 600 pixels / 256 = bitrate 8100k
-			main_video_2_pass_bitrate_int := (v_width * v_height) / video_compression_bitrate_divider
-			main_video_2_pass_bitrate_str = strconv.Itoa(main_video_2_pass_bitrate_int) + "k"
-
-			// User overrides automatic bitrate calculation and defines one on the commandline
-			if user_main_bitrate_bool == true {
-				main_video_2_pass_bitrate_str = main_bitrate_option.user_string
-			}
+			if job.Audio_offset != "" && job.No_audio == false {
 
-			// Parallel SD video 2-pass bitrate is fixed to 1620k
-			sd_video_bitrate := "1620k"
+				audio_offset_message := "Audio is delayed by " + job.Audio_offset + " ms"
 
-			// User overrides automatic bitrate calculation and defines one on the commandline
-			if user_sd_bitrate_bool == true {
-				sd_video_bitrate = sd_bitrate_option.user_string
-			}
+				if audio_offset_milliseconds < 0 {
+					audio_offset_message = "Audio is advanced by " + strconv.Itoa(-audio_offset_milliseconds) + " ms"
+				}
 
-			/////////////////////////////////////////////////////////////////
-			// Choose video compression profile by the vertical resolution //
-			/////////////////////////////////////////////////////////////////
-			main_video_compression_options := video_compression_options_sd
+				if split_video == true {
+					audio_offset_message = audio_offset_message + " (-ad " + job.Audio_offset + "), the offset was applied to the audio input when creating the splitfiles."
+				} else {
+					audio_offset_message = audio_offset_message + " (-ad " + job.Audio_offset + "), the offset is applied to the audio input " + audio_input_number + " before the file is cut."
+				}
 
-			if v_height > 4191 {
-				main_video_compression_options = video_compression_options_ultra_hd_8k
+				log_messages_str_slice = append(log_messages_str_slice, "", audio_offset_message)
+			}
 
-			} else if v_height > 2096 {
-				main_video_compression_options = video_compression_options_ultra_hd_4k
+			// Add video compression options to ffmpeg commandline
+			main_output.Codec_options = append(main_output.Codec_options, main_video_compression_options...)
+			sd_output.Codec_options = append(sd_output.Codec_options, video_compression_options_sd...)
 
-			} else if v_height > 699 {
-				main_video_compression_options = video_compression_options_hd
-			}
+			if job.Crf == true {
+				sd_output.Codec_options = append(sd_output.Codec_options, "-crf", file_crf_value)
 
-			if crf_option.is_turned_on == true {
-				// Use constant quality instead of 2-pass encoding
-				main_video_compression_options = append(main_video_compression_options, "-crf", crf_value)
-				main_video_2_pass_bitrate_str = "Constant Quality: " + crf_value
+				if crf_needs_zero_bitrate == true {
+					sd_output.Codec_options = append(sd_output.Codec_options, "-b:v", "0")
+				}
 			} else {
-				// Add calculated 2-pass bitrate to video compression options
-				main_video_compression_options = append(main_video_compression_options, "-b:v", main_video_2_pass_bitrate_str)
+				sd_output.Codec_options = append(sd_output.Codec_options, "-b:v", sd_video_bitrate)
 			}
 
-			if force_lossless.is_turned_on == true {
-				// Lossless audio compression options
-				audio_compression_options = nil
-				audio_compression_options = audio_compression_options_lossless
-
-				// Lossless video compression options
-				main_video_compression_options = video_compression_options_lossless
-				main_video_2_pass_bitrate_str = "Lossless"
+			// Add hvc1 tag to HEVC video in mp4
+			if job.Hevc == true && output_video_format[1] == "mp4" {
+				main_output.Codec_options = append(main_output.Codec_options, video_hevc_mp4_tag_options...)
+				sd_output.Codec_options = append(sd_output.Codec_options, video_hevc_mp4_tag_options...)
 			}
 
-			if audio_compression_flac.is_turned_on == true {
-				audio_compression_options = nil
-				audio_compression_options = audio_compression_options_lossless
+			// Add color subsampling options if needed
+			if color_subsampling != color_subsampling_options[1] {
+				main_output.Codec_options = append(main_output.Codec_options, color_subsampling_options...)
 			}
 
-			//////////////////////////
-			// Choose audio options //
-			//////////////////////////
-			number_of_audio_channels_int, _ := strconv.Atoi(number_of_audio_channels)
-			bitrate_int := number_of_audio_channels_int * audio_bitrate_multiplier
-			bitrate_str := strconv.Itoa(bitrate_int) + "k"
-
-			if audio_compression_aac.is_turned_on == true {
+			// Add color subsampling options to SD commandline if needed
+			if color_subsampling != color_subsampling_options[1] && job.Parallel_sd == true || job.Scale_to_sd == true {
+				sd_output.Codec_options = append(sd_output.Codec_options, color_subsampling_options...)
+			}
 
-				audio_compression_options = nil
-				audio_compression_options = []string{"-c:a", "aac", "-b:a", bitrate_str}
+			// Add audio compression options to ffmpeg commandline
+			main_output.Codec_options = append(main_output.Codec_options, file_audio_compression_options...)
+			sd_output.Codec_options = append(sd_output.Codec_options, sd_audio_compression_options...)
 
+			if job.No_audio == false {
+				// Add audiomapping options on the commanline
+				for _, audio_stream_number := range audio_stream_numbers {
+					main_output.Maps = append(main_output.Maps, audio_input_number + ":a:" + strconv.Itoa(audio_stream_number))
+					sd_output.Maps = append(sd_output.Maps, audio_input_number + ":a:" + strconv.Itoa(audio_stream_number))
+				}
 			}
 
-			// FIXME When FFmpeg opus support in mp4 is mainlined, remove "-strict", "-2" options from the couple of lines below
-			// If we are encoding audio to opus, then enable FFmpeg experimental features
-			// -strict -2 is needed for FFmpeg to use still experimental support for opus in mp4 container.
-			// 2020.11.14: FFmpeg 4.3.1 seems to support opus in mp4 withous strict 2, these can be removed from the following lines
-			if audio_compression_opus.is_turned_on == true {
+			if job.Fast_encode == false && job.Crf == false {
 
-				if number_of_audio_channels_int <= 2 {
-					audio_compression_options = nil
-					audio_compression_options = []string{"-c:a", "libopus", "-b:a", bitrate_str, "-vbr", "off", "-mapping_family", "0",  "-strict", "-2"}
+				if job.Hevc == true {
+					// x265 does not use the FFmpeg 2-pass options, the stats file is defined in -x265-params
+					main_output.Codec_options = encode.Add_x265_parameters(main_output.Codec_options, "stats=" + ffmpeg_2_pass_logfile_path + "-x265.log")
+					sd_output.Codec_options = encode.Add_x265_parameters(sd_output.Codec_options, "stats=" + ffmpeg_sd_2_pass_logfile_path + "-x265.log")
 				} else {
-					audio_compression_options = nil
-					audio_compression_options = []string{"-c:a", "libopus", "-b:a", bitrate_str, "-vbr", "off", "-mapping_family", "255", "-strict", "-2"}
+					// Add 2 - pass logfile path to ffmpeg commandline
+					main_output.Options = append(main_output.Options, "-passlogfile", ffmpeg_2_pass_logfile_path)
+					sd_output.Options = append(sd_output.Options, "-passlogfile", ffmpeg_sd_2_pass_logfile_path)
 				}
 			}
 
-			// If we are copying opus audio, then enable FFmpeg experimental features
-			// -strict -2 is needed for FFmpeg to use still experimental support for opus in mp4 container.
-			if audio_codec == "opus" && audio_compression_options[1] == "copy" {
-				audio_compression_options = append(audio_compression_options, "-strict", "-2")
-			}
+			// Add video output format to ffmpeg commandline
+			main_output.Options = append(main_output.Options, output_video_format...)
+			sd_output.Options = append(sd_output.Options, output_video_format...)
 
-			if audio_compression_ac3.is_turned_on == true {
+			///////////////////////////////////////////////////////////////////
+			// Create the adaptive bitrate ladder output (HLS and / or DASH) //
+			///////////////////////////////////////////////////////////////////
+			var abr_output encode.Ffmpeg_output_struct
+			var abr_rung_descriptions []string
 
-				if bitrate_int > 640 {
+			if job.Abr != "" {
 
-					audio_compression_options = nil
-					audio_compression_options = []string{"-c:a", "ac3", "-b:a", "640k"}
-				} else {
+				abr_output.Seek_start = main_output.Seek_start
+				abr_output.Duration = main_output.Duration
 
-					audio_compression_options = nil
-					audio_compression_options = []string{"-c:a", "ac3", "-b:a", bitrate_str}
-				}
+				for counter, rung_height := range abr_rung_heights_for_file {
 
-			}
+					// The formula is the same as for the main video: (horizontal resolution * vertical resolution) / video_compression_bitrate_divider
+					rung_width := ((abr_source_width * rung_height / abr_source_height) / 2) * 2
+					rung_bitrate := strconv.Itoa((rung_width * rung_height) / video_compression_bitrate_divider) + "k"
+					rung_compression_options := video_compression_options_sd
 
-			if no_audio.is_turned_on == true {
-				audio_compression_options = nil
-				audio_compression_options = append(audio_compression_options, "-an")
-			}
+					if rung_height > 4191 {
+						rung_compression_options = video_compression_options_ultra_hd_8k
 
-			// Add subtitle options for parallel SD processing
-			if parallel_sd.is_turned_on == true || scale_to_sd.is_turned_on == true {
+					} else if rung_height > 2096 {
+						rung_compression_options = video_compression_options_ultra_hd_4k
 
-				if subtitle_mux_bool == false && subtitle_burn_number == -1 {
-					sd_ffmpeg_pass_2_commandline = append(sd_ffmpeg_pass_2_commandline, "-sn")
-				}
+					} else if rung_height > 699 {
+						rung_compression_options = video_compression_options_hd
+					}
 
-				if subtitle_mux_bool == true {
-					// There is a dvd, dvb or bluray bitmap subtitle to mux into the target file add the relevant options to FFmpeg commandline.
-					sd_ffmpeg_pass_2_commandline = append(sd_ffmpeg_pass_2_commandline, "-scodec", "copy")
+					rung_compression_options = append(append([]string{}, rung_compression_options...), "-b:v", rung_bitrate)
 
-					for _, subtitle_mux_number := range user_subtitle_mux_numbers_slice {
-						sd_ffmpeg_pass_2_commandline = append(sd_ffmpeg_pass_2_commandline, "-map", "0:s:"+ subtitle_mux_number)
+					// x265 does not use the FFmpeg 2-pass options, each rung has its own stats file defined in -x265-params
+					if job.Fast_encode == false && job.Hevc == true {
+						rung_compression_options = encode.Add_x265_parameters(rung_compression_options, "stats=" + ffmpeg_2_pass_logfile_path + "-" + strconv.Itoa(counter) + "-x265.log")
 					}
-				}
-			}
 
-			// Add video compression options to ffmpeg commandline
-			if scale_to_sd.is_turned_on == false {
-				ffmpeg_pass_2_commandline = append(ffmpeg_pass_2_commandline, main_video_compression_options...)
-			}
+					abr_output.Maps = append(abr_output.Maps, "[abr_scaled_out_" + strconv.Itoa(counter) + "]")
+					abr_output.Codec_options = append(abr_output.Codec_options, encode.Add_stream_specifier(rung_compression_options, "v", counter)...)
+					abr_rung_descriptions = append(abr_rung_descriptions, strconv.Itoa(rung_width) + "x" + strconv.Itoa(rung_height) + " " + rung_bitrate)
+				}
 
-			if parallel_sd.is_turned_on == true || scale_to_sd.is_turned_on == true {
+				// Force keyframes at segment boundaries so that GOPs are aligned in all rungs
+				abr_output.Codec_options = append(abr_output.Codec_options, "-force_key_frames", "expr:gte(t,n_forced*" + strconv.Itoa(Abr_segment_seconds) + ")")
 
-				sd_ffmpeg_pass_2_commandline = append(sd_ffmpeg_pass_2_commandline, video_compression_options_sd...)
+				if job.Hevc == true && job.Abr_format != "hlsts" {
+					abr_output.Codec_options = append(abr_output.Codec_options, video_hevc_mp4_tag_options...)
+				}
 
-				if crf_option.is_turned_on == true {
-					sd_ffmpeg_pass_2_commandline = append(sd_ffmpeg_pass_2_commandline, "-crf", crf_value)
-				} else {
-					sd_ffmpeg_pass_2_commandline = append(sd_ffmpeg_pass_2_commandline, "-b:v", sd_video_bitrate)
+				if color_subsampling != color_subsampling_options[1] {
+					abr_output.Codec_options = append(abr_output.Codec_options, color_subsampling_options...)
 				}
-			}
 
-			if scale_to_sd.is_turned_on == false {
-				// Add color subsampling options if needed
-				if color_subsampling != "yuv420p" {
-					ffmpeg_pass_2_commandline = append(ffmpeg_pass_2_commandline, color_subsampling_options...)
+				if job.Inverse_telecine == true {
+					abr_output.Codec_options = append(abr_output.Codec_options, "-r", "24")
 				}
 
-				// Add audio compression options to ffmpeg commandline
-				ffmpeg_pass_2_commandline = append(ffmpeg_pass_2_commandline, audio_compression_options...)
+				abr_output.Codec_options = append(abr_output.Codec_options, "-sn")
 
-				if no_audio.is_turned_on == false {
-					// Add audiomapping options on the commanline
-					ffmpeg_pass_2_commandline = append(ffmpeg_pass_2_commandline, "-map", "0:a:" + strconv.Itoa(audio_stream_number_int))
-				}
-			}
+				// Audio is stored once and shared by all rungs
+				hls_var_stream_map := make([]string, len(abr_rung_heights_for_file))
+				dash_adaptation_sets := "id=0,streams=v"
 
-			// Add color subsampling options to SD commandline if needed
-			if color_subsampling != "yuv420p" && parallel_sd.is_turned_on == true || scale_to_sd.is_turned_on == true {
-				sd_ffmpeg_pass_2_commandline = append(sd_ffmpeg_pass_2_commandline, color_subsampling_options...)
-			}
+				for counter := range abr_rung_heights_for_file {
+					hls_var_stream_map[counter] = "v:" + strconv.Itoa(counter)
+				}
 
-			// Add audio compression options to SD commandline
-			if parallel_sd.is_turned_on == true || scale_to_sd.is_turned_on == true {
+				if job.No_audio == false {
+					abr_output.Codec_options = append(abr_output.Codec_options, file_audio_compression_options...)
 
-				if force_lossless.is_turned_on == true {
+					for counter := range hls_var_stream_map {
+						hls_var_stream_map[counter] = hls_var_stream_map[counter] + ",agroup:audio"
+					}
 
-					// If main video audio is lossless use aac compression for the SD video
-					sd_ffmpeg_pass_2_commandline = append(sd_ffmpeg_pass_2_commandline, "-c:a", "aac", "-b:a", bitrate_str)
+					// Every audio stream is an alternative rendition in the audio group and has an adaptation set of its own.
+					// Dash adaptation sets refer to output stream numbers, audio streams come after the video rungs.
+					for audio_stream_index, audio_stream_number := range audio_stream_numbers {
 
-				} else {
-					sd_ffmpeg_pass_2_commandline = append(sd_ffmpeg_pass_2_commandline, audio_compression_options...)
-				}
-			}
+						abr_output.Maps = append(abr_output.Maps, audio_input_number + ":a:" + strconv.Itoa(audio_stream_number))
+						hls_audio_stream := "a:" + strconv.Itoa(audio_stream_index) + ",agroup:audio"
 
-			// Add audiomapping options on the SD commanline
-			if parallel_sd.is_turned_on == true || scale_to_sd.is_turned_on == true {
+						if selected_audio_streams[audio_stream_index].Language != "" {
+							hls_audio_stream = hls_audio_stream + ",language:" + selected_audio_streams[audio_stream_index].Language
+						}
 
-				if no_audio.is_turned_on == false {
-					sd_ffmpeg_pass_2_commandline = append(sd_ffmpeg_pass_2_commandline, "-map", "0:a:" + strconv.Itoa(audio_stream_number_int))
-				}
-			}
+						if len(audio_stream_numbers) > 1 && audio_stream_index == 0 {
+							hls_audio_stream = hls_audio_stream + ",default:yes"
+						}
 
-			if scale_to_sd.is_turned_on == false {
-				if processing_duration.user_string != "" {
-					ffmpeg_pass_2_commandline = append(ffmpeg_pass_2_commandline, "-t", processing_duration.user_string)
+						hls_var_stream_map = append(hls_var_stream_map, hls_audio_stream)
+						dash_adaptation_sets = dash_adaptation_sets + " id=" + strconv.Itoa(audio_stream_index + 1) + ",streams=" + strconv.Itoa(len(abr_rung_heights_for_file) + audio_stream_index)
+					}
 				}
-			}
 
-			if parallel_sd.is_turned_on == true || scale_to_sd.is_turned_on == true {
-				if processing_duration.user_string != "" {
-					sd_ffmpeg_pass_2_commandline = append(sd_ffmpeg_pass_2_commandline, "-t", processing_duration.user_string)
+				if job.Fast_encode == false && job.Hevc == false {
+					abr_output.Options = append(abr_output.Options, "-passlogfile", ffmpeg_2_pass_logfile_path)
 				}
-			}
 
-			ffmpeg_2_pass_logfile_path := filepath.Join(inputfile_path, output_directory_name, strings.TrimSuffix(inputfile_name, input_filename_extension))
-			ffmpeg_sd_2_pass_logfile_path := filepath.Join(inputfile_path, output_directory_name, strings.TrimSuffix(inputfile_name, input_filename_extension) + "-sd")
+				// The dash muxer writes the HLS playlists too when both formats are wanted, so both use the same segments
+				abr_formats := strings.Split(job.Abr_format, ",")
+				abr_dash_bool := false
+				abr_hls_bool := false
 
-			if temp_file_directory.user_string != "" {
-				ffmpeg_2_pass_logfile_path = filepath.Join(temp_file_directory.user_string, output_directory_name, strings.TrimSuffix(inputfile_name, input_filename_extension))
-				ffmpeg_sd_2_pass_logfile_path = filepath.Join(temp_file_directory.user_string, output_directory_name, strings.TrimSuffix(inputfile_name, input_filename_extension) + "-sd")
-			}
-
-			if fast_encode.is_turned_on == false && crf_option.is_turned_on == false && scale_to_sd.is_turned_on == false {
-				// Add 2 - pass logfile path to ffmpeg commandline
-				ffmpeg_pass_2_commandline = append(ffmpeg_pass_2_commandline, "-passlogfile")
-				ffmpeg_pass_2_commandline = append(ffmpeg_pass_2_commandline, ffmpeg_2_pass_logfile_path)
-			}
-
-			if parallel_sd.is_turned_on == true || scale_to_sd.is_turned_on == true {
+				for _, abr_format := range abr_formats {
 
-				if fast_encode.is_turned_on == false && crf_option.is_turned_on == false {
-					sd_ffmpeg_pass_2_commandline = append(sd_ffmpeg_pass_2_commandline, "-passlogfile")
-					sd_ffmpeg_pass_2_commandline = append(sd_ffmpeg_pass_2_commandline, ffmpeg_sd_2_pass_logfile_path)
+					if abr_format == "dash" {
+						abr_dash_bool = true
+					} else {
+						abr_hls_bool = true
+					}
 				}
-			}
 
-			if scale_to_sd.is_turned_on == false {
-				// Add video output format to ffmpeg commandline
-				ffmpeg_pass_2_commandline = append(ffmpeg_pass_2_commandline, output_video_format...)
-			}
+				if abr_dash_bool == true {
 
-			// Add video output format SD to ffmpeg commandline
-			if parallel_sd.is_turned_on == true || scale_to_sd.is_turned_on == true {
-				sd_ffmpeg_pass_2_commandline = append(sd_ffmpeg_pass_2_commandline, output_video_format...)
-			}
+					abr_output.Options = append(abr_output.Options, "-f", "dash", "-seg_duration", strconv.Itoa(Abr_segment_seconds), "-use_template", "1", "-use_timeline", "1", "-adaptation_sets", dash_adaptation_sets,
+						"-init_seg_name", "init-$RepresentationID$.m4s", "-media_seg_name", "segment-$RepresentationID$-$Number%05d$.m4s")
 
-			// Copy ffmpeg pass 2 commandline to ffmpeg pass 1 commandline
-			ffmpeg_pass_1_commandline = append(ffmpeg_pass_1_commandline, ffmpeg_pass_2_commandline...)
-			if parallel_sd.is_turned_on == true || scale_to_sd.is_turned_on == true {
-				sd_ffmpeg_pass_1_commandline = append(sd_ffmpeg_pass_1_commandline, sd_ffmpeg_pass_2_commandline...)
-			}
+					if abr_hls_bool == true {
+						abr_output.Options = append(abr_output.Options, "-hls_playlist", "1")
+					}
 
-			// Add pass 1/2 info on ffmpeg commandline
-			if fast_encode.is_turned_on == false && crf_option.is_turned_on == false && scale_to_sd.is_turned_on == false {
+					abr_output.File_path = filepath.Join(abr_directory_path, "manifest.mpd")
 
-				ffmpeg_pass_1_commandline = append(ffmpeg_pass_1_commandline, "-pass", "1")
-				ffmpeg_pass_2_commandline = append(ffmpeg_pass_2_commandline, "-pass", "2")
+				} else {
 
-				// Add /dev/null output option to ffmpeg pass 1 commandline
-				ffmpeg_pass_1_commandline = append(ffmpeg_pass_1_commandline, "/dev/null")
+					hls_segment_type := "fmp4"
+					hls_segment_extension := ".m4s"
 
-			}
+					if job.Abr_format == "hlsts" {
+						hls_segment_type = "mpegts"
+						hls_segment_extension = ".ts"
+					}
 
-			if parallel_sd.is_turned_on == true || scale_to_sd.is_turned_on == true {
+					abr_output.Options = append(abr_output.Options, "-f", "hls", "-hls_time", strconv.Itoa(Abr_segment_seconds), "-hls_playlist_type", "vod", "-hls_flags", "independent_segments",
+						"-hls_segment_type", hls_segment_type, "-hls_segment_filename", filepath.Join(abr_directory_path, "stream_%v", "segment_%05d" + hls_segment_extension),
+						"-master_pl_name", "master.m3u8", "-var_stream_map", strings.Join(hls_var_stream_map, " "))
 
-				if fast_encode.is_turned_on == false && crf_option.is_turned_on == false {
-					sd_ffmpeg_pass_1_commandline = append(sd_ffmpeg_pass_1_commandline, "-pass", "1")
-					sd_ffmpeg_pass_2_commandline = append(sd_ffmpeg_pass_2_commandline, "-pass", "2")
+					if hls_segment_type == "fmp4" {
+						abr_output.Options = append(abr_output.Options, "-hls_fmp4_init_filename", "init.mp4")
+					}
 
-					// Add /dev/null output option to ffmpeg pass 1 commandline
-					sd_ffmpeg_pass_1_commandline = append(sd_ffmpeg_pass_1_commandline, "/dev/null")
+					abr_output.File_path = filepath.Join(abr_directory_path, "stream_%v", "playlist.m3u8")
 				}
+
+				log_messages_str_slice = append(log_messages_str_slice, "", "Adaptive bitrate ladder: " + strings.Join(abr_rung_descriptions, ", "))
 			}
 
 			// Add outfile path to ffmpeg pass 2 commandline
-			if scale_to_sd.is_turned_on == false {
-				ffmpeg_pass_2_commandline = append(ffmpeg_pass_2_commandline, output_file_absolute_path)
+			main_output.File_path = output_file_absolute_path
+			sd_output.File_path = sd_output_file_absolute_path
+
+			if job.Abr != "" {
+				ffmpeg_pass_2_commandline.Outputs = append(ffmpeg_pass_2_commandline.Outputs, abr_output)
+
+			} else if job.Scale_to_sd == false {
+				ffmpeg_pass_2_commandline.Outputs = append(ffmpeg_pass_2_commandline.Outputs, main_output)
 			}
-			sd_ffmpeg_pass_2_commandline = append(sd_ffmpeg_pass_2_commandline, sd_output_file_absolute_path)
 
 			// Add parallel SD compression options to FFmpeg commandline.
-			if parallel_sd.is_turned_on == true || scale_to_sd.is_turned_on == true {
-				ffmpeg_pass_1_commandline = append(ffmpeg_pass_1_commandline, sd_ffmpeg_pass_1_commandline...)
-				ffmpeg_pass_2_commandline = append(ffmpeg_pass_2_commandline, sd_ffmpeg_pass_2_commandline...)
+			if job.Parallel_sd == true || job.Scale_to_sd == true {
+				ffmpeg_pass_2_commandline.Outputs = append(ffmpeg_pass_2_commandline.Outputs, sd_output)
 			}
 
+			// Create ffmpeg pass 1 commandline from a copy of pass 2 commandline and add pass 1/2 info on both.
+			// Pass 1 only writes the 2-pass logfile, video is written to /dev/null.
 			// If we have "fast" mode on then we will only do 1-pass encoding and the pass 1 commandline is the same as pass 2.
 			// In this case we won't do pass 2 at all.
-			if fast_encode.is_turned_on == true || crf_option.is_turned_on == true {
-				ffmpeg_pass_1_commandline = ffmpeg_pass_2_commandline
-				sd_ffmpeg_pass_1_commandline = sd_ffmpeg_pass_2_commandline
+			ffmpeg_pass_1_commandline = encode.Copy_ffmpeg_commandline(ffmpeg_pass_2_commandline)
+
+			if job.Fast_encode == false && job.Crf == false {
+
+				for counter := range ffmpeg_pass_2_commandline.Outputs {
+
+					// Pass 1 of the adaptive bitrate ladder only writes the 2-pass logfiles, the HLS and DASH muxers would write playlists
+					if job.Abr != "" {
+						ffmpeg_pass_1_commandline.Outputs[counter].Options = []string{"-f", "null"}
+
+						if job.Hevc == false {
+							ffmpeg_pass_1_commandline.Outputs[counter].Options = append([]string{"-passlogfile", ffmpeg_2_pass_logfile_path}, ffmpeg_pass_1_commandline.Outputs[counter].Options...)
+						}
+					}
+
+					if job.Hevc == true {
+						ffmpeg_pass_1_commandline.Outputs[counter].Codec_options = encode.Add_x265_parameters(ffmpeg_pass_1_commandline.Outputs[counter].Codec_options, "pass=1")
+						ffmpeg_pass_2_commandline.Outputs[counter].Codec_options = encode.Add_x265_parameters(ffmpeg_pass_2_commandline.Outputs[counter].Codec_options, "pass=2")
+					} else {
+						ffmpeg_pass_1_commandline.Outputs[counter].Options = append(ffmpeg_pass_1_commandline.Outputs[counter].Options, "-pass", "1")
+						ffmpeg_pass_2_commandline.Outputs[counter].Options = append(ffmpeg_pass_2_commandline.Outputs[counter].Options, "-pass", "2")
+					}
+
+					ffmpeg_pass_1_commandline.Outputs[counter].File_path = "/dev/null"
+				}
 			}
 
+
 			/////////////////////////////////////////
 			// Print variable values in debug mode //
 			/////////////////////////////////////////
-			if debug_option.is_turned_on == true {
+			if job.Debug == true {
 				fmt.Println()
 				fmt.Println("video_compression_options_sd:", video_compression_options_sd)
 				fmt.Println("video_compression_options_hd:", video_compression_options_hd)
 				fmt.Println("main_video_compression_options:", main_video_compression_options)
-				fmt.Println("audio_compression_options:", audio_compression_options)
+				fmt.Println("file_audio_compression_options:", file_audio_compression_options)
 				fmt.Println("denoise_options:", denoise_options)
 				fmt.Println("deinterlace_options:", deinterlace_options)
-				fmt.Println("ffmpeg_commandline_start:", ffmpeg_commandline_start)
+				fmt.Println("ffmpeg_global_options:", ffmpeg_global_options)
 				fmt.Println("subtitle_burn_number:", subtitle_burn_number)
-				fmt.Println("subtitle_language_option.user_string:", subtitle_language_option.user_string)
+				fmt.Println("subtitle_language_option.user_string:", job.Subtitle_language)
 				fmt.Println("subtitle_burn_vertical_offset_int:", subtitle_burn_vertical_offset_int)
-				fmt.Println("subtitle_burn_downscale.is_turned_on:", subtitle_burn_downscale.is_turned_on)
-				fmt.Println("subtitle_burn_palette.user_string:", subtitle_burn_palette.user_string)
-				fmt.Println("subtitle_burn_split.is_turned_on:", subtitle_burn_split.is_turned_on)
+				fmt.Println("subtitle_burn_downscale.is_turned_on:", job.Subtitle_burn_downscale)
+				fmt.Println("subtitle_burn_palette.user_string:", job.Subtitle_burn_palette)
+				fmt.Println("subtitle_burn_split.is_turned_on:", job.Subtitle_burn_split)
 				fmt.Println("subtitle_mux_bool:", subtitle_mux_bool)
 				fmt.Println("user_subtitle_mux_numbers_slice:", user_subtitle_mux_numbers_slice)
 				fmt.Println("user_subtitle_mux_languages_slice:", user_subtitle_mux_languages_slice)
-				fmt.Println("grayscale_option.is_turned_on:", grayscale_option.is_turned_on)
+				fmt.Println("grayscale_option.is_turned_on:", job.Grayscale)
 				fmt.Println("grayscale_options:", grayscale_options)
 				fmt.Println("color_subsampling_options", color_subsampling_options)
-				fmt.Println("autocrop_option.is_turned_on:", autocrop_option.is_turned_on)
+				fmt.Println("autocrop_option.is_turned_on:", job.Autocrop)
 				fmt.Println("subtitle_burn_number:", subtitle_burn_number)
-				fmt.Println("no_deinterlace.is_turned_on:", no_deinterlace.is_turned_on)
-				fmt.Println("denoise_option.is_turned_on:", denoise_option.is_turned_on)
-				fmt.Println("audio_stream_number_int:", audio_stream_number_int)
-				fmt.Println("scan_mode_only.is_turned_on:", scan_mode_only.is_turned_on)
-				fmt.Println("search_start_option.user_string", search_start_option.user_string)
-				fmt.Println("processing_stop_time.user_string", processing_stop_time.user_string)
-				fmt.Println("processing_duration.user_string", processing_duration.user_string)
-				fmt.Println("fast_encode_and_search.is_turned_on", fast_encode_and_search.is_turned_on)
-				fmt.Println("fast_search.is_turned_on", fast_search.is_turned_on)
-				fmt.Println("fast_encode.is_turned_on", fast_encode.is_turned_on)
-				fmt.Println("burn_timecode.is_turned_on", burn_timecode.is_turned_on)
+				fmt.Println("no_deinterlace.is_turned_on:", job.No_deinterlace)
+				fmt.Println("denoise_option.is_turned_on:", job.Denoise)
+				fmt.Println("audio_stream_numbers:", audio_stream_numbers)
+				fmt.Println("scan_mode_only.is_turned_on:", job.Scan_mode_only)
+				fmt.Println("search_start_option.user_string", job.Search_start)
+				fmt.Println("processing_stop_time.user_string", job.Processing_stop_time)
+				fmt.Println("processing_duration.user_string", job.Processing_duration)
+				fmt.Println("fast_search.is_turned_on", job.Fast_search)
+				fmt.Println("fast_encode.is_turned_on", job.Fast_encode)
+				fmt.Println("burn_timecode.is_turned_on", job.Burn_timecode)
 				fmt.Println("timecode_burn_options", timecode_burn_options)
-				fmt.Println("crf_option.is_turned_on", crf_option.is_turned_on)
+				fmt.Println("crf_option.is_turned_on", job.Crf)
 				fmt.Println("crf_value", crf_value)
-				fmt.Println("debug_option.is_turned_on", debug_option.is_turned_on)
+				fmt.Println("debug_option.is_turned_on", job.Debug)
 				fmt.Println()
 				fmt.Println("input_filenames:", input_filenames)
 			}
@@ -5075,24 +4231,10 @@ func main() {
 			// Run Pass 1 encoding with FFmpeg //
 			/////////////////////////////////////
 			// Add pass 1 messages to processing log.
-			pass_1_commandline_for_logfile := strings.Join(ffmpeg_pass_1_commandline, " ")
-
-			// Make a copy of the FFmpeg commandline for writing in the logfile.
-			// Modify commandline so that it works if the user wants to copy and paste it from the logfile and run it.
-			// The filter command needs to be in single quotes '
-			index := strings.Index(pass_1_commandline_for_logfile, "-filter_complex")
-			first_part_of_string := pass_1_commandline_for_logfile[:index + 16]
-			first_part_of_string = first_part_of_string + "'"
-
-			second_part_of_string := pass_1_commandline_for_logfile[index + 16:]
-			index = strings.Index(second_part_of_string, " -")
-			third_part_of_string := second_part_of_string[index:]
-			second_part_of_string = second_part_of_string[:index]
-			second_part_of_string = second_part_of_string + "'"
+			// The commandline in the logfile is shell quoted so that it works if the user wants to copy and paste it from the logfile and run it.
+			pass_1_commandline_for_logfile := encode.Ffmpeg_commandline_to_shell_string(ffmpeg_pass_1_commandline)
 
-			pass_1_commandline_for_logfile = first_part_of_string + second_part_of_string + third_part_of_string
-
-			if debug_option.is_turned_on == true || only_print_commands.is_turned_on == true {
+			if job.Debug == true || job.Only_print_commands == true {
 
 				fmt.Println()
 				fmt.Println("ffmpeg_pass_1_commandline:")
@@ -5101,25 +4243,39 @@ func main() {
 			} else {
 				fmt.Println()
 
-				if inverse_telecine.is_turned_on == true {
+				if job.Inverse_telecine == true {
 					fmt.Print("Performing Inverse Telecine on video.\n")
 				}
 
-				if frame_rate_str == "29.970" && inverse_telecine.is_turned_on == false {
+				if frame_rate_str == "29.970" && job.Inverse_telecine == false {
 					fmt.Println("\033[7mWarning: Video frame rate is 29.970. You may need to pullup (Inverse Telecine) this video with option -it\033[0m")
 				}
 
-				if parallel_sd.is_turned_on == true || scale_to_sd.is_turned_on == true {
+				if job.Hevc == true {
+					fmt.Println("Compressing video with HEVC (libx265).")
+				}
+
+				if job.Av1 == true {
+					fmt.Println("Compressing video with AV1 (" + video_encoder_name + ").")
+				}
+
+				if job.Abr != "" {
+
+					for _, rung_description := range abr_rung_descriptions {
+						fmt.Println("Encoding adaptive bitrate ladder rung:", rung_description)
+					}
+
+				} else if job.Parallel_sd == true || job.Scale_to_sd == true {
 
-					if scale_to_sd.is_turned_on == false && crf_option.is_turned_on == true {
+					if job.Scale_to_sd == false && job.Crf == true {
 
 						fmt.Println("Encoding main and SD video with", main_video_2_pass_bitrate_str)
 
-					} else if scale_to_sd.is_turned_on == true && crf_option.is_turned_on == true {
+					} else if job.Scale_to_sd == true && job.Crf == true {
 
 						fmt.Println("Encoding SD video with", main_video_2_pass_bitrate_str)
 
-					} else if scale_to_sd.is_turned_on == true && crf_option.is_turned_on == false {
+					} else if job.Scale_to_sd == true && job.Crf == false {
 
 						fmt.Println("Encoding SD-video with bitrate:", sd_video_bitrate)
 
@@ -5129,35 +4285,19 @@ func main() {
 					}
 				} else {
 
-					if crf_option.is_turned_on == true {
+					if job.Crf == true {
 						fmt.Println("Encoding video with", main_video_2_pass_bitrate_str)
 					} else {
 						fmt.Println("Encoding video with bitrate:", main_video_2_pass_bitrate_str)
 					}
 				}
 
-				if color_subsampling != "yuv420p" {
-					fmt.Println("Subsampling color:", color_subsampling, "---> yuv420p")
+				if color_subsampling != color_subsampling_options[1] {
+					fmt.Println("Subsampling color:", color_subsampling, "--->", color_subsampling_options[1])
 				}
 
-				if no_audio.is_turned_on == true {
-
-					fmt.Println("Audio processing is off.")
-
-				} else if audio_compression_ac3.is_turned_on == true {
-
-					fmt.Printf("Encoding %s channel audio to ac3 with bitrate: %s\n", strconv.Itoa(number_of_audio_channels_int), audio_compression_options[3])
-
-				} else if audio_compression_aac.is_turned_on == true {
-
-					fmt.Printf("Encoding %s channel audio to aac with bitrate: %s\n", strconv.Itoa(number_of_audio_channels_int), audio_compression_options[3])
-
-				} else if audio_compression_opus.is_turned_on == true {
-
-					fmt.Printf("Encoding %s channel audio to opus with bitrate: %s\n", strconv.Itoa(number_of_audio_channels_int), audio_compression_options[3])
-				} else {
-
-					fmt.Printf("Copying %s audio to target.\n", audio_codec)
+				for _, audio_processing_message := range audio_processing_messages {
+					fmt.Println(audio_processing_message)
 				}
 
 				fmt.Printf("Pass 1 encoding: " + inputfile_name + " ")
@@ -5172,57 +4312,31 @@ func main() {
 			var ffmpeg_pass_1_error_output_temp []string
 			var error_code error
 
-			if only_print_commands.is_turned_on == false {
-				ffmpeg_pass_1_output_temp, ffmpeg_pass_1_error_output_temp, error_code = run_external_command(ffmpeg_pass_1_commandline)
+			if job.Only_print_commands == false {
+				ffmpeg_pass_1_output_temp, ffmpeg_pass_1_error_output_temp, error_code = encode.Run_external_command(processing_context, encode.Render_ffmpeg_commandline(ffmpeg_pass_1_commandline))
 			}
 
 			if error_code != nil {
-
-				fmt.Println("\n\nFFmpeg reported error:", "\n")
-
-				if len(ffmpeg_pass_1_output_temp) != 0 {
-					for _, textline := range ffmpeg_pass_1_output_temp {
-						fmt.Println(textline)
-					}
-				}
-
-				if len(ffmpeg_pass_1_error_output_temp) != 0 {
-					for _, textline := range ffmpeg_pass_1_error_output_temp {
-						fmt.Println(textline)
-					}
-				}
-
-				os.Exit(1)
+				file_processing_failed(encode.External_command_error("FFmpeg", ffmpeg_pass_1_output_temp, ffmpeg_pass_1_error_output_temp, error_code))
+				continue file_loop
 			}
 
 			pass_1_elapsed_time = time.Since(pass_1_start_time)
 
-			if only_print_commands.is_turned_on == false {
+			if job.Only_print_commands == false {
 				fmt.Printf("took %s", pass_1_elapsed_time.Round(time.Millisecond))
 			}
 			fmt.Println()
 
 			// Add pass 2 messages to processing log.
-			pass_2_commandline_for_logfile := strings.Join(ffmpeg_pass_2_commandline, " ")
-
-			index = strings.Index(pass_2_commandline_for_logfile, "-filter_complex")
-			first_part_of_string = pass_2_commandline_for_logfile[:index + 16]
-			first_part_of_string = first_part_of_string + "'"
-
-			second_part_of_string = pass_2_commandline_for_logfile[index + 16:]
-			index = strings.Index(second_part_of_string, " -")
-			third_part_of_string = second_part_of_string[index:]
-			second_part_of_string = second_part_of_string[:index]
-			second_part_of_string = second_part_of_string + "'"
-
-			pass_2_commandline_for_logfile = first_part_of_string + second_part_of_string + third_part_of_string
+			pass_2_commandline_for_logfile := encode.Ffmpeg_commandline_to_shell_string(ffmpeg_pass_2_commandline)
 
 			log_messages_str_slice = append(log_messages_str_slice, "")
 			log_messages_str_slice = append(log_messages_str_slice, "FFmpeg Pass 1 Options:")
 			log_messages_str_slice = append(log_messages_str_slice, "-----------------------")
 			log_messages_str_slice = append(log_messages_str_slice, pass_1_commandline_for_logfile)
 
-			if debug_option.is_turned_on == true {
+			if job.Debug == true {
 
 				fmt.Println()
 
@@ -5241,9 +4355,9 @@ func main() {
 			/////////////////////////////////////
 			// Run Pass 2 encoding with FFmpeg //
 			/////////////////////////////////////
-			if fast_encode.is_turned_on == false && crf_option.is_turned_on == false {
+			if job.Fast_encode == false && job.Crf == false {
 
-				if debug_option.is_turned_on == true || only_print_commands.is_turned_on == true  {
+				if job.Debug == true || job.Only_print_commands == true  {
 
 					fmt.Println()
 					fmt.Println("ffmpeg_pass_2_commandline:")
@@ -5255,8 +4369,9 @@ func main() {
 					fmt.Printf("Pass 2 encoding: " + inputfile_name + " ")
 				}
 
-				if only_print_commands.is_turned_on == true {
-					os.Exit(0)
+				if job.Only_print_commands == true {
+					cancel_file_processing()
+					return nil
 				}
 
 				pass_2_start_time = time.Now()
@@ -5264,25 +4379,11 @@ func main() {
 				////////////////
 				// Run FFmpeg //
 				////////////////
-				ffmpeg_pass_2_output_temp, ffmpeg_pass_2_error_output_temp, error_code := run_external_command(ffmpeg_pass_2_commandline)
+				ffmpeg_pass_2_output_temp, ffmpeg_pass_2_error_output_temp, error_code := encode.Run_external_command(processing_context, encode.Render_ffmpeg_commandline(ffmpeg_pass_2_commandline))
 
 				if error_code != nil {
-
-					fmt.Println("\n\nFFmpeg reported error:", "\n")
-
-					if len(ffmpeg_pass_2_output_temp) != 0 {
-						for _, textline := range ffmpeg_pass_2_output_temp {
-							fmt.Println(textline)
-						}
-					}
-
-					if len(ffmpeg_pass_2_error_output_temp) != 0 {
-						for _, textline := range ffmpeg_pass_2_error_output_temp {
-							fmt.Println(textline)
-						}
-					}
-
-					os.Exit(1)
+					file_processing_failed(encode.External_command_error("FFmpeg", ffmpeg_pass_2_output_temp, ffmpeg_pass_2_error_output_temp, error_code))
+					continue file_loop
 				}
 
 				pass_2_elapsed_time = time.Since(pass_2_start_time)
@@ -5304,7 +4405,7 @@ func main() {
 				log_messages_str_slice = append(log_messages_str_slice, "-----------------------")
 				log_messages_str_slice = append(log_messages_str_slice, pass_2_commandline_for_logfile)
 
-				if debug_option.is_turned_on == true {
+				if job.Debug == true {
 
 					fmt.Println()
 
@@ -5324,87 +4425,147 @@ func main() {
 			}
 
 
-			if only_print_commands.is_turned_on == true {
-				os.Exit(0)
+			if job.Only_print_commands == true {
+				cancel_file_processing()
+				return nil
 			}
 
-			////////////////////////////
-			// Remove temporary files //
-			////////////////////////////
+			////////////////////////////////////////////////////
+			// Compare the processed video to the original //
+			////////////////////////////////////////////////////
+			if job.Quality_report == true {
 
-			if _, err := os.Stat(ffmpeg_2_pass_logfile_path + "-0.log"); err == nil {
-				os.Remove(ffmpeg_2_pass_logfile_path + "-0.log")
-			}
+				// Compare the SD file when it is the only output
+				processed_file_path := output_file_absolute_path
+				reference_filters := append([]string{}, ffmpeg_filter_options...)
+				reference_filters = append(reference_filters, ffmpeg_filter_options_2...)
+				report_frame_rate := media_file.Video_streams[0].Frame_rate
 
-			if _, err := os.Stat(ffmpeg_2_pass_logfile_path + "-0.log.mbtree"); err == nil {
-				os.Remove(ffmpeg_2_pass_logfile_path + "-0.log.mbtree")
-			}
+				if job.Scale_to_sd == true {
+					processed_file_path = sd_output_file_absolute_path
+					reference_filters = append(reference_filters, "scale=" + strconv.Itoa(sd_width) + ":-2:flags=lanczos")
+				}
 
-			if _, err := os.Stat(ffmpeg_sd_2_pass_logfile_path + "-0.log"); err == nil {
-				os.Remove(ffmpeg_sd_2_pass_logfile_path + "-0.log")
-			}
+				// Inverse telecine sets the output frame rate to 24 fps
+				if job.Inverse_telecine == true {
+					reference_filters = append(reference_filters, "fps=24")
+					report_frame_rate = probe.Frame_rate_struct{Numerator: 24, Denominator: 1}
+				}
 
-			if _, err := os.Stat(ffmpeg_sd_2_pass_logfile_path + "-0.log.mbtree"); err == nil {
-				os.Remove(ffmpeg_sd_2_pass_logfile_path + "-0.log.mbtree")
-			}
+				// The reference is read from the same input as the processed video. Seek and duration are always placed on the input,
+				// since the output of the comparison is discarded.
+				reference_input := main_input
+				reference_input.Input_options = append([]string{}, main_input.Input_options...)
 
-			if debug_option.is_turned_on == true {
+				if main_output.Seek_start != "" {
+					reference_input.Seek_start = main_output.Seek_start
+				}
 
-				fmt.Println("\nSplitfiles are not deleted in debug - mode.\n")
+				if main_output.Duration != "" {
+					reference_input.Input_options = append(reference_input.Input_options, "-t", main_output.Duration)
+				}
 
-			} else {
+				ssim_stats_file_path := ffmpeg_2_pass_logfile_path + "-ssim.log"
+				psnr_stats_file_path := ffmpeg_2_pass_logfile_path + "-psnr.log"
+				pixel_format := color_subsampling_options[1]
 
-				for _, splitfile_name := range list_of_splitfiles {
-					if _, err := os.Stat(splitfile_name); err == nil {
-						os.Remove(splitfile_name)
-					} else {
-						fmt.Println("Could not delete splitfile:", splitfile_name)
-					}
+				reference_filters = append(reference_filters, "format=" + pixel_format, "setpts=PTS-STARTPTS", "split=2")
+
+				quality_report_commandline := encode.Ffmpeg_commandline_struct{Global_options: []string{"-hide_banner", "-nostats", "-loglevel", "info", "-threads", number_of_threads_to_use_for_video_compression}}
+				quality_report_commandline.Inputs = append(quality_report_commandline.Inputs, encode.Ffmpeg_input_struct{File_path: processed_file_path}, reference_input)
+				quality_report_commandline.Filter_complex = append(quality_report_commandline.Filter_complex,
+					"[1:v:0]" + strings.Join(reference_filters, ",") + "[reference_1][reference_2]",
+					"[0:v:0]format=" + pixel_format + ",setpts=PTS-STARTPTS,split=2[processed_1][processed_2]",
+					"[processed_1][reference_1]ssim=stats_file=" + plan.Escape_ffmpeg_filter_value(ssim_stats_file_path) + "[ssim_out]",
+					"[processed_2][reference_2]psnr=stats_file=" + plan.Escape_ffmpeg_filter_value(psnr_stats_file_path) + "[psnr_out]")
+				quality_report_commandline.Outputs = append(quality_report_commandline.Outputs, encode.Ffmpeg_output_struct{Maps: []string{"[ssim_out]", "[psnr_out]"}, Options: []string{"-f", "null"}, File_path: "-"})
+
+				if job.Debug == true {
+					fmt.Println()
+					fmt.Println("quality_report_commandline:")
+					fmt.Println(encode.Ffmpeg_commandline_to_shell_string(quality_report_commandline))
 				}
 
-				if _, err := os.Stat(split_info_file_absolute_path); !os.IsNotExist(err) {
-					if err = os.Remove(split_info_file_absolute_path); err != nil {
-						fmt.Println("Could not delete split_info_file:", split_info_file_absolute_path)
-					}
+				fmt.Print("Comparing processed video to the original: " + inputfile_name + " ")
+				quality_report_start_time := time.Now()
+
+				quality_report_output, quality_report_error_output, error_code := encode.Run_external_command(processing_context, encode.Render_ffmpeg_commandline(quality_report_commandline))
+
+				if error_code != nil {
+					file_processing_failed(encode.External_command_error("FFmpeg", quality_report_output, quality_report_error_output, error_code))
+					continue file_loop
 				}
 
-			}
+				fmt.Printf("took %s\n", time.Since(quality_report_start_time).Round(time.Millisecond))
 
-			if subtitle_burn_split.is_turned_on == true {
-				if debug_option.is_turned_on == true {
-					fmt.Println("\nExtracted subtitle images are not deleted in debug - mode.\n")
-				} else {
+				var quality_report quality_report_struct
+				var quality_report_error error
+				var ssim_values, psnr_values []float64
 
-					// Remove subtitle directories.
-					if _, err := os.Stat(original_subtitles_absolute_path); err == nil {
-						os.RemoveAll(original_subtitles_absolute_path)
-					}
+				quality_report.File = processed_file_path
+				quality_report.Source = inputfile_full_path
+				quality_report.Segment_seconds = Quality_report_segment_seconds
+				quality_report.Ssim, quality_report_error = plan.Parse_quality_measurement("ssim", quality_report_error_output)
 
-					if _, err := os.Stat(fixed_subtitles_absolute_path); err == nil {
-						os.RemoveAll(fixed_subtitles_absolute_path)
-					}
+				if quality_report_error == nil {
+					quality_report.Psnr, quality_report_error = plan.Parse_quality_measurement("psnr", quality_report_error_output)
+				}
 
-					// Delete subtitle dir if it is empty
-					file_handle, err := os.Open(subtitle_extract_base_path)
-					defer file_handle.Close()
+				if quality_report_error == nil {
+					ssim_values, quality_report_error = plan.Read_quality_stats_file(ssim_stats_file_path, "ssim")
+				}
 
-					if err == nil {
-						_, dir_empty := file_handle.Readdirnames(1)
+				if quality_report_error == nil {
+					psnr_values, quality_report_error = plan.Read_quality_stats_file(psnr_stats_file_path, "psnr")
+				}
 
-						if dir_empty == io.EOF {
-							os.Remove(subtitle_extract_base_path)
-						}
-					}
+				if quality_report_error == nil {
+					quality_report.Worst_segments, quality_report_error = plan.Worst_quality_segments(ssim_values, psnr_values, report_frame_rate, Quality_report_segment_seconds, Quality_report_number_of_worst_segments)
+				}
+
+				if quality_report_error != nil {
+					file_processing_failed(errors.New("quality report: " + quality_report_error.Error()))
+					continue file_loop
+				}
+
+				// Write the report to a json file next to the processed file
+				quality_report_json, _ := json.MarshalIndent(quality_report, "", "  ")
+				quality_report_file_path := strings.TrimSuffix(processed_file_path, filepath.Ext(processed_file_path)) + "-quality_report.json"
+
+				if write_error := ioutil.WriteFile(quality_report_file_path, append(quality_report_json, '\n'), 0644); write_error != nil {
+					file_processing_failed(errors.New("could not write quality report: " + write_error.Error()))
+					continue file_loop
+				}
+
+				// Print the report and add it to the processing log
+				var quality_report_lines []string
+				quality_report_lines = append(quality_report_lines, "", "Quality report:", "---------------")
+				quality_report_lines = append(quality_report_lines, "SSIM: " + strconv.FormatFloat(quality_report.Ssim, 'f', 4, 64) + "  PSNR: " + strconv.FormatFloat(quality_report.Psnr, 'f', 2, 64) + " dB")
+				quality_report_lines = append(quality_report_lines, "Worst " + strconv.Itoa(Quality_report_segment_seconds) + " second segments:")
+
+				for _, segment := range quality_report.Worst_segments {
+					quality_report_lines = append(quality_report_lines, segment.Start + " - " + segment.End + "  SSIM: " + strconv.FormatFloat(segment.Ssim, 'f', 4, 64) + "  PSNR: " + strconv.FormatFloat(segment.Psnr, 'f', 2, 64) + " dB")
+				}
+
+				quality_report_lines = append(quality_report_lines, "Quality report was written to: " + quality_report_file_path)
+
+				for _, line := range quality_report_lines {
+					fmt.Println(line)
 				}
+
+				fmt.Println()
+				log_messages_str_slice = append(log_messages_str_slice, quality_report_lines...)
 			}
 
+			remove_temporary_files()
+
 			// Delete SD output directory if it is empty
-			if parallel_sd.is_turned_on == true {
+			if job.Parallel_sd == true {
 				file_handle, err := os.Open(sd_directory_path)
-				defer file_handle.Close()
 
 				if err == nil {
 					_, dir_empty := file_handle.Readdirnames(1)
+					file_handle.Close()
 
 					if dir_empty == io.EOF {
 						os.Remove(sd_directory_path)
@@ -5438,28 +4599,22 @@ func main() {
 			log_messages_str_slice = append(log_messages_str_slice, "")
 		}
 
-		// Open logfile for appending info about file processing to it.
-		log_file_name := "00-processing.log"
-		log_file_absolute_path := filepath.Join(inputfile_path, output_directory_name, log_file_name)
-
-		// Append to the logfile or if it does not exist create a new one.
-		logfile_pointer, err := os.OpenFile(log_file_absolute_path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0755)
-		defer logfile_pointer.Close()
+		cancel_file_processing()
 
-		if err != nil {
-			fmt.Println("")
-			fmt.Println("Error, could not open logfile:", log_file_name, "for writing.")
-			log.Fatal(err)
-			os.Exit(0)
+		// Write processing info to the logfile
+		if log_error := write_processing_log(log_file_absolute_path, log_messages_str_slice); log_error != nil {
+			fmt.Println()
+			fmt.Println("Error,", log_error)
+			processing_results = append(processing_results, file_processing_result_struct{file_name: inputfile_full_path, processing_error: log_error, elapsed_time: time.Since(start_time)})
+			continue
 		}
 
-		// Write processing info to the file
-		if _, err = logfile_pointer.WriteString(strings.Join(log_messages_str_slice, "\n")); err != nil {
-			fmt.Println("")
-			fmt.Println("Error, could not write to logfile:", log_file_name)
-			log.Fatal(err)
-			os.Exit(0)
-		}
+		processing_results = append(processing_results, file_processing_result_struct{file_name: inputfile_full_path, elapsed_time: time.Since(start_time)})
+	}
+
+	if number_of_failed_files := print_processing_summary(processing_results); number_of_failed_files > 0 {
+		return errors.New(strconv.Itoa(number_of_failed_files) + " files failed")
 	}
-}
 
+	return nil
+}
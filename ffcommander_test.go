@@ -0,0 +1,119 @@
+// (C) Mikael Hartzell 2018
+// This program is distributed under the GNU General Public License, version 3 (GPLv3)
+
+package ffcommander
+
+import (
+	"reflect"
+	"testing"
+)
+
+func Test_check_option_values(t *testing.T) {
+
+	// Values are stored in the form used in processing
+	job := Default_job()
+	job.Abr = "720,1080,360"
+	job.Adjust_gamma = "1.50"
+	job.Audio_offset = "+300"
+	job.Loudness_normalization = "broadcast"
+	job.Quality_target = "SSIM:0.98"
+
+	checked_job, job_errors := check_option_values(job)
+
+	if len(job_errors) > 0 {
+		t.Fatal(job_errors)
+	}
+
+	if checked_job.Abr != "1080,720,360" || checked_job.Adjust_gamma != "1.5" || checked_job.Audio_offset != "300" || checked_job.Loudness_normalization != "-23" || checked_job.Quality_target != "ssim:0.98" {
+		t.Errorf("values were not normalized: %q %q %q %q %q", checked_job.Abr, checked_job.Adjust_gamma, checked_job.Audio_offset, checked_job.Loudness_normalization, checked_job.Quality_target)
+	}
+
+	// The default values of Default_job are valid
+	if _, job_errors = check_option_values(Default_job()); len(job_errors) > 0 {
+		t.Errorf("default job has errors: %v", job_errors)
+	}
+}
+
+func Test_check_option_values_errors(t *testing.T) {
+
+	job := Default_job()
+	job.Audio_offset = "0.3"
+	job.Search_start = "1:xx"
+	job.Processing_duration = "-10"
+	job.Abr = "1080,1080"
+	job.Subtitle_stream_number = "first"
+
+	_, job_errors := check_option_values(job)
+
+	expected_errors := []string{
+		"option -abr: rung height 1080 is given twice",
+		"option -ad: '0.3' is not a whole number of milliseconds",
+		"option -d: seconds are not a number in time: -10",
+		"option -sn: 'first' is not a whole number",
+		"option -st: seconds are not a number in time: 1:xx",
+	}
+
+	if reflect.DeepEqual(job_errors, expected_errors) == false {
+		t.Errorf("wrong errors:\n got: %q\nwant: %q", job_errors, expected_errors)
+	}
+}
+
+func Test_run_does_not_process_a_job_with_errors(t *testing.T) {
+
+	job := new_test_job(t)
+	job.Audio_offset = "abc"
+
+	recorded_commands, error_code := run_job_with_recording_runner(t, job, movie_canned_outputs(t))
+
+	if error_code == nil || error_code.Error() != "option -ad: 'abc' is not a whole number of milliseconds" {
+		t.Errorf("wrong error: %v", error_code)
+	}
+
+	if len(recorded_commands) != 0 {
+		t.Errorf("commands were run: %q", recorded_commands)
+	}
+}
+
+func Test_apply_implied_options(t *testing.T) {
+
+	// A job turns on the same options as the commandline
+	test_cases := []struct {
+		name string
+		job Job
+		expected_job Job
+	}{
+		{"-ls", Job{Force_lossless: true}, Job{Force_lossless: true, Fast_encode: true}},
+		{"-psd", Job{Parallel_sd: true}, Job{Parallel_sd: true, Fast_search: true}},
+		{"-ssd", Job{Scale_to_sd: true}, Job{Scale_to_sd: true, Fast_search: true}},
+		{"-qt", Job{Quality_target: "ssim:0.98"}, Job{Quality_target: "ssim:0.98", Crf: true}},
+		{"-sf", Job{Split_times: "0-10:00"}, Job{Split_times: "0-10:00", Use_matroska_container: true}},
+		{"-webm", Job{Use_webm_container: true}, Job{Use_webm_container: true, Audio_compression_opus: true}},
+		{"-crf", Job{Crf: true}, Job{Crf: true}},
+	}
+
+	for _, test_case := range test_cases {
+
+		if job := apply_implied_options(test_case.job); reflect.DeepEqual(job, test_case.expected_job) == false {
+			t.Errorf("%s: got %+v, want %+v", test_case.name, job, test_case.expected_job)
+		}
+	}
+}
+
+func Test_run_lossless_is_encoded_in_one_pass(t *testing.T) {
+
+	// -ls turns on -fe, utvideo is never encoded in 2 passes
+	job := new_test_job(t)
+	job.Force_lossless = true
+	job.Use_matroska_container = true
+
+	recorded_commands, error_code := run_job_with_recording_runner(t, job, movie_canned_outputs(t))
+
+	if error_code != nil {
+		t.Fatal(error_code)
+	}
+
+	compare_commands(t, recorded_commands, []string{
+		ffprobe_test_command,
+		"ffmpeg -y -loglevel level+error -threads auto -i TMPDIR/movie.mkv -filter_complex [0:v:0]idet,yadif=0:deint=all[main_processed_video_out] -map [main_processed_video_out] -map 0:a:0 -sn -c:v utvideo -acodec flac -f matroska TMPDIR/00-processed_files/movie.mkv",
+	})
+}
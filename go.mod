@@ -0,0 +1,3 @@
+module github.com/mhartzel/ffcommander
+
+go 1.20
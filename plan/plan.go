@@ -0,0 +1,1266 @@
+// (C) Mikael Hartzell 2018
+// This program is distributed under the GNU General Public License, version 3 (GPLv3)
+
+// Package plan has the calculations processing is planned with: timestamps and split times, bitrates and file sizes,
+// audio filters and loudness normalization and the crf search of the quality target.
+package plan
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/mhartzel/ffcommander/encode"
+	"github.com/mhartzel/ffcommander/probe"
+)
+
+// When the video bitrate is calculated from the target file size (-size), this percentage of the file size
+// is reserved for the container (mp4, mkv) overhead.
+var Target_size_container_overhead_percent int64 = 2
+
+// Loudness normalization (-norm) true peak (dBTP) and loudness range (LU) targets for the FFmpeg loudnorm filter.
+// The integrated loudness target is given with the option: -23 LUFS is the EBU R128 broadcast target and -16 LUFS is used by streaming services.
+var loudness_true_peak_target = "-1.5"
+var loudness_range_target = "11"
+
+// Downmixed audio (-stereo, -add-stereo, 7.1 to 5.1 for AC3) goes through this limiter to prevent clipping, unless loudness normalization (-norm) limits the peaks.
+// The limit 0.891 is -1 dBFS, level=false keeps the limiter from raising the volume of the audio.
+var Audio_downmix_limiter = "alimiter=limit=0.891:level=false"
+
+type Audio_map_struct struct {
+	Stream_number int
+	Codec string
+	Number_of_channels int
+}
+
+// Times given by the user (-st, -et, -d, -sf) are stored as integer microseconds, so adding and subtracting them
+// never suffers from float rounding errors. Timestamps are parsed with Parse_timestamp and formatted with Format_timestamp.
+type Timestamp_struct struct {
+	Microseconds int64
+}
+
+const Microseconds_per_second int64 = 1000000
+
+// Quality report (-qr) is written to a json file next to the processed file
+type Quality_segment_struct struct {
+	Start string `json:"start"`
+	End string `json:"end"`
+	Ssim float64 `json:"ssim"`
+	Psnr float64 `json:"psnr"`
+}
+
+// Values measured by the first pass of the FFmpeg loudnorm filter (-norm). Loudnorm prints the values as strings.
+type Loudnorm_measurement_struct struct {
+	Input_i string `json:"input_i"`
+	Input_tp string `json:"input_tp"`
+	Input_lra string `json:"input_lra"`
+	Input_thresh string `json:"input_thresh"`
+	Target_offset string `json:"target_offset"`
+}
+
+type Loudness_analysis_result_struct struct {
+	Measurements []Loudnorm_measurement_struct
+	Commands []string
+	Error_code error
+}
+
+func Int_is_in_slice(number int, number_slice []int) bool {
+
+	for _, item := range number_slice {
+
+		if item == number {
+			return true
+		}
+	}
+
+	return false
+}
+
+func parse_seconds_and_fractions(seconds_str string) (int64, error) {
+
+	// Convert a seconds value like 25 or 25.120 to microseconds. Fractions beyond microseconds are truncated.
+	var whole_str, fractions_str string
+
+	whole_str = seconds_str
+
+	if strings.Contains(seconds_str, ".") {
+		whole_str = strings.SplitN(seconds_str, ".", 2)[0]
+		fractions_str = strings.SplitN(seconds_str, ".", 2)[1]
+	}
+
+	if whole_str == "" {
+		whole_str = "0"
+	}
+
+	if strings.Trim(whole_str, "0123456789") != "" || strings.Trim(fractions_str, "0123456789") != "" {
+		return 0, errors.New("'" + seconds_str + "' is not a number")
+	}
+
+	whole_int, atoi_error := strconv.ParseInt(whole_str, 10, 64)
+
+	if atoi_error != nil {
+		return 0, errors.New("'" + seconds_str + "' is not a number")
+	}
+
+	if len(fractions_str) > 6 {
+		fractions_str = fractions_str[0:6]
+	}
+
+	// Pad fractions to microseconds: .8 -> 800000
+	for len(fractions_str) < 6 {
+		fractions_str = fractions_str + "0"
+	}
+
+	fractions_int, _ := strconv.ParseInt(fractions_str, 10, 64)
+
+	return whole_int * Microseconds_per_second + fractions_int, nil
+}
+
+func Timestamp_needs_frame_rate(timestring string) bool {
+
+	// SMPTE timecodes 01:20:25:12 and 01:20:25;12 and frame numbers 120612f are converted to time with the frame rate of the video
+	timestring = strings.TrimSpace(timestring)
+
+	return strings.Contains(timestring, ";") || strings.Count(timestring, ":") == 3 || strings.HasSuffix(timestring, "f")
+}
+
+func parse_frame_number(frame_number_str string) (int64, error) {
+
+	// Frame number is given with the suffix f: 120612f
+	frame_number, atoi_error := strconv.ParseInt(strings.TrimSuffix(strings.TrimSpace(frame_number_str), "f"), 10, 64)
+
+	if atoi_error != nil || frame_number < 0 {
+		return 0, errors.New("frame number must be a whole number that is not negative in time: " + frame_number_str)
+	}
+
+	return frame_number, nil
+}
+
+func Check_timestamp(timestring string) error {
+
+	// Check time before the frame rate of the video is known. SMPTE timecodes and frame numbers are only checked for their format here,
+	// the frames are checked against the frame rate when Parse_timestamp converts them.
+	if Timestamp_needs_frame_rate(timestring) == false {
+		_, timestamp_error := Parse_timestamp(timestring, probe.Frame_rate_struct{})
+		return timestamp_error
+	}
+
+	if strings.HasSuffix(strings.TrimSpace(timestring), "f") {
+		_, frame_number_error := parse_frame_number(timestring)
+		return frame_number_error
+	}
+
+	_, _, timecode_error := split_smpte_timecode(strings.TrimSpace(timestring))
+
+	return timecode_error
+}
+
+func Parse_timestamp(timestring string, frame_rate probe.Frame_rate_struct) (Timestamp_struct, error) {
+
+	// Parse time in one of the formats: 01:20:25.500, 20:25.500, 4825.5 or 4825.
+	// SMPTE timecodes 01:20:25:12 (drop-frame 01:20:25;12) and frame numbers 120612f are converted with the frame rate of the video.
+	var timestamp Timestamp_struct
+	var hours_int, minutes_int int64
+	var atoi_error error
+
+	timestring = strings.TrimSpace(timestring)
+
+	if timestring == "" {
+		return timestamp, errors.New("time is empty")
+	}
+
+	if Timestamp_needs_frame_rate(timestring) == true {
+
+		if frame_rate.Numerator <= 0 || frame_rate.Denominator <= 0 {
+			return timestamp, errors.New("the frame rate of the video is needed to convert time: " + timestring)
+		}
+
+		if strings.HasSuffix(timestring, "f") {
+
+			frame_number, frame_number_error := parse_frame_number(timestring)
+
+			if frame_number_error != nil {
+				return timestamp, frame_number_error
+			}
+
+			return timestamp_from_frame_number(frame_number, frame_rate)
+		}
+
+		return parse_smpte_timecode(timestring, frame_rate)
+	}
+
+	temp_str_slice := strings.Split(timestring, ":")
+
+	if len(temp_str_slice) > 3 {
+		return timestamp, errors.New("could not interpret time: " + timestring)
+	}
+
+	seconds_str := temp_str_slice[len(temp_str_slice) - 1]
+
+	if len(temp_str_slice) == 3 {
+		hours_int, atoi_error = strconv.ParseInt(temp_str_slice[0], 10, 64)
+
+		if atoi_error != nil || strings.Trim(temp_str_slice[0], "0123456789") != "" {
+			return timestamp, errors.New("hours are not a number in time: " + timestring)
+		}
+	}
+
+	if len(temp_str_slice) >= 2 {
+		minutes_int, atoi_error = strconv.ParseInt(temp_str_slice[len(temp_str_slice) - 2], 10, 64)
+
+		// A sign is not accepted, -00:10 would otherwise be read as 10 seconds
+		if atoi_error != nil || strings.Trim(temp_str_slice[len(temp_str_slice) - 2], "0123456789") != "" {
+			return timestamp, errors.New("minutes are not a number in time: " + timestring)
+		}
+
+		if len(temp_str_slice) == 3 && minutes_int > 59 {
+			return timestamp, errors.New("minutes must be between 0 and 59 in time: " + timestring)
+		}
+	}
+
+	seconds_microseconds_int, seconds_error := parse_seconds_and_fractions(seconds_str)
+
+	if seconds_error != nil {
+		return timestamp, errors.New("seconds are not a number in time: " + timestring)
+	}
+
+	if len(temp_str_slice) >= 2 && seconds_microseconds_int >= 60 * Microseconds_per_second {
+		return timestamp, errors.New("seconds must be between 0 and 59 in time: " + timestring)
+	}
+
+	timestamp.Microseconds = ((hours_int * 60 * 60) + (minutes_int * 60)) * Microseconds_per_second + seconds_microseconds_int
+
+	return timestamp, nil
+}
+
+func timestamp_from_frame_number(frame_number int64, frame_rate probe.Frame_rate_struct) (Timestamp_struct, error) {
+
+	// Frame 0 starts at time 0. Frame rate 25/1 puts frame 50 at 2 seconds, frame rate 30000/1001 puts frame 30 at 1.001 seconds.
+	var timestamp Timestamp_struct
+
+	if frame_rate.Numerator <= 0 || frame_rate.Denominator <= 0 {
+		return timestamp, errors.New("frame rate " + probe.Format_frame_rate(frame_rate) + " is not valid")
+	}
+
+	if frame_number < 0 {
+		return timestamp, errors.New("frame number cannot be negative: " + strconv.FormatInt(frame_number, 10))
+	}
+
+	// Round up to the next whole microsecond so that the timestamp always falls inside the frame and converts back to the same frame number
+	timestamp.Microseconds = (frame_number * int64(frame_rate.Denominator) * Microseconds_per_second + int64(frame_rate.Numerator) - 1) / int64(frame_rate.Numerator)
+
+	return timestamp, nil
+}
+
+func timestamp_to_frame_number(timestamp Timestamp_struct, frame_rate probe.Frame_rate_struct) int64 {
+
+	// Return the number of the frame that is displayed at the timestamp.
+	if frame_rate.Numerator <= 0 || frame_rate.Denominator <= 0 {
+		return 0
+	}
+
+	return timestamp.Microseconds * int64(frame_rate.Numerator) / (int64(frame_rate.Denominator) * Microseconds_per_second)
+}
+
+func smpte_frame_counts(frame_rate probe.Frame_rate_struct) (int64, int64) {
+
+	// Return the nominal frame rate used in timecode counting (30 for 29.97 fps) and
+	// the number of frame numbers dropped at each minute in drop-frame timecode (2 for 29.97, 4 for 59.94).
+	nominal_frame_rate_int := (int64(frame_rate.Numerator) + int64(frame_rate.Denominator) / 2) / int64(frame_rate.Denominator)
+	dropped_frames_int := int64(0)
+
+	if frame_rate.Denominator == 1001 && nominal_frame_rate_int % 30 == 0 {
+		dropped_frames_int = nominal_frame_rate_int / 15
+	}
+
+	return nominal_frame_rate_int, dropped_frames_int
+}
+
+func split_smpte_timecode(timecode string) (time_fields_int [4]int64, drop_frame bool, error_code error) {
+
+	// Split SMPTE timecode HH:MM:SS:FF or HH:MM:SS;FF to hours, minutes, seconds and frames.
+	// The frames can only be checked when the frame rate is known.
+	drop_frame = strings.Contains(timecode, ";")
+	temp_str_slice := strings.Split(strings.Replace(timecode, ";", ":", -1), ":")
+
+	if len(temp_str_slice) != 4 {
+		return time_fields_int, drop_frame, errors.New("SMPTE timecode must be in format HH:MM:SS:FF or HH:MM:SS;FF, not: " + timecode)
+	}
+
+	for counter, item := range temp_str_slice {
+		value_int, atoi_error := strconv.ParseInt(item, 10, 64)
+
+		if atoi_error != nil || strings.Trim(item, "0123456789") != "" {
+			return time_fields_int, drop_frame, errors.New("could not interpret SMPTE timecode: " + timecode)
+		}
+		time_fields_int[counter] = value_int
+	}
+
+	if time_fields_int[1] > 59 || time_fields_int[2] > 59 {
+		return time_fields_int, drop_frame, errors.New("SMPTE timecode is out of range: " + timecode)
+	}
+
+	return time_fields_int, drop_frame, nil
+}
+
+func parse_smpte_timecode(timecode string, frame_rate probe.Frame_rate_struct) (Timestamp_struct, error) {
+
+	// Parse SMPTE timecode HH:MM:SS:FF (non-drop-frame) or HH:MM:SS;FF (drop-frame).
+	// Drop-frame timecode skips frame numbers 0 and 1 at the start of each minute, except every tenth minute,
+	// to keep the timecode in sync with the wall clock when the frame rate is 29.97 (30000/1001).
+	var timestamp Timestamp_struct
+
+	if frame_rate.Numerator <= 0 || frame_rate.Denominator <= 0 {
+		return timestamp, errors.New("frame rate " + probe.Format_frame_rate(frame_rate) + " is not valid")
+	}
+
+	time_fields_int, drop_frame, timecode_error := split_smpte_timecode(timecode)
+
+	if timecode_error != nil {
+		return timestamp, timecode_error
+	}
+
+	nominal_frame_rate_int, dropped_frames_int := smpte_frame_counts(frame_rate)
+
+	if drop_frame == true && dropped_frames_int == 0 {
+		return timestamp, errors.New("drop-frame timecode can only be used with frame rates 29.97 and 59.94, not: " + probe.Format_frame_rate(frame_rate))
+	}
+
+	hours_int, minutes_int, seconds_int, frames_int := time_fields_int[0], time_fields_int[1], time_fields_int[2], time_fields_int[3]
+
+	if frames_int >= nominal_frame_rate_int {
+		return timestamp, errors.New("SMPTE timecode is out of range: " + timecode)
+	}
+
+	total_minutes_int := hours_int * 60 + minutes_int
+	frame_number := (total_minutes_int * 60 + seconds_int) * nominal_frame_rate_int + frames_int
+
+	if drop_frame == true {
+
+		if seconds_int == 0 && frames_int < dropped_frames_int && minutes_int % 10 != 0 {
+			return timestamp, errors.New("frame number does not exist in drop-frame timecode: " + timecode)
+		}
+
+		frame_number = frame_number - dropped_frames_int * (total_minutes_int - total_minutes_int / 10)
+	}
+
+	return timestamp_from_frame_number(frame_number, frame_rate)
+}
+
+func format_smpte_timecode(timestamp Timestamp_struct, frame_rate probe.Frame_rate_struct, drop_frame bool) string {
+
+	// Convert timestamp to SMPTE timecode HH:MM:SS:FF or HH:MM:SS;FF (drop-frame).
+	frame_number := timestamp_to_frame_number(timestamp, frame_rate)
+	nominal_frame_rate_int, dropped_frames_int := smpte_frame_counts(frame_rate)
+	separator := ":"
+
+	if nominal_frame_rate_int <= 0 {
+		return "00:00:00:00"
+	}
+
+	if drop_frame == true && dropped_frames_int > 0 {
+
+		// Add back the frame numbers that were skipped so that the frame number can be split to fields as if no frames were dropped
+		frames_per_ten_minutes := nominal_frame_rate_int * 60 * 10 - dropped_frames_int * 9
+		frames_per_minute := nominal_frame_rate_int * 60 - dropped_frames_int
+		ten_minute_blocks := frame_number / frames_per_ten_minutes
+		remaining_frames := frame_number % frames_per_ten_minutes
+
+		frame_number = frame_number + dropped_frames_int * 9 * ten_minute_blocks
+
+		if remaining_frames > dropped_frames_int {
+			frame_number = frame_number + dropped_frames_int * ((remaining_frames - dropped_frames_int) / frames_per_minute)
+		}
+		separator = ";"
+	}
+
+	frames_int := frame_number % nominal_frame_rate_int
+	total_seconds_int := frame_number / nominal_frame_rate_int
+
+	return fmt.Sprintf("%02d:%02d:%02d%s%02d", total_seconds_int / 3600, (total_seconds_int / 60) % 60, total_seconds_int % 60, separator, frames_int)
+}
+
+func format_timestamp_fractions(timestamp Timestamp_struct) string {
+
+	// Return fractions of a second with at least millisecond precision: 500000 microseconds -> 500, 1500 microseconds -> 0015
+	microseconds_int := timestamp.Microseconds % Microseconds_per_second
+
+	if microseconds_int == 0 {
+		return ""
+	}
+
+	fractions_str := fmt.Sprintf("%06d", microseconds_int)
+
+	for len(fractions_str) > 3 && fractions_str[len(fractions_str) - 1] == '0' {
+		fractions_str = fractions_str[0:len(fractions_str) - 1]
+	}
+
+	return fractions_str
+}
+
+func Format_timestamp(timestamp Timestamp_struct) string {
+
+	// Convert timestamp to timecode HH:MM:SS or HH:MM:SS.mmm if there are fractions of a second
+	total_seconds_int := timestamp.Microseconds / Microseconds_per_second
+	timecode := fmt.Sprintf("%02d:%02d:%02d", total_seconds_int / 3600, (total_seconds_int / 60) % 60, total_seconds_int % 60)
+
+	if fractions_str := format_timestamp_fractions(timestamp); fractions_str != "" {
+		timecode = timecode + "." + fractions_str
+	}
+
+	return timecode
+}
+
+func Format_timestamp_seconds(timestamp Timestamp_struct) string {
+
+	// Convert timestamp to plain seconds 4825 or 4825.5 for the FFmpeg commandline
+	seconds_str := strconv.FormatInt(timestamp.Microseconds / Microseconds_per_second, 10)
+
+	if fractions_str := format_timestamp_fractions(timestamp); fractions_str != "" {
+		seconds_str = seconds_str + "." + strings.TrimRight(fractions_str, "0")
+	}
+
+	return seconds_str
+}
+
+func timestamp_add(timestamp_1 Timestamp_struct, timestamp_2 Timestamp_struct) Timestamp_struct {
+	return Timestamp_struct{Microseconds: timestamp_1.Microseconds + timestamp_2.Microseconds}
+}
+
+func Timestamp_subtract(timestamp_1 Timestamp_struct, timestamp_2 Timestamp_struct) Timestamp_struct {
+
+	// The result is negative if timestamp_2 is bigger than timestamp_1. Compare the result with Timestamp_compare if it matters.
+	return Timestamp_struct{Microseconds: timestamp_1.Microseconds - timestamp_2.Microseconds}
+}
+
+func Timestamp_compare(timestamp_1 Timestamp_struct, timestamp_2 Timestamp_struct) int {
+
+	// Return -1 if timestamp_1 is before timestamp_2, 0 if they are equal and 1 if timestamp_1 is after timestamp_2
+	if timestamp_1.Microseconds < timestamp_2.Microseconds {
+		return -1
+	}
+
+	if timestamp_1.Microseconds > timestamp_2.Microseconds {
+		return 1
+	}
+
+	return 0
+}
+
+func Processed_duration(file_duration Timestamp_struct, search_start Timestamp_struct, processing_duration Timestamp_struct, cut_list_positions_and_durations []Timestamp_struct) Timestamp_struct {
+
+	// Calculate the duration of the video that ends up in the processed file.
+	// With the -sf option the durations of all parts are added together. The cut list has start times and durations (see Process_split_times),
+	// an odd number of values means the last part is processed to the end of file.
+	// Without -sf the duration is the file duration minus the start time (-st), or the duration the user gave (-d, -et) if it is shorter.
+	if len(cut_list_positions_and_durations) > 0 {
+
+		var duration Timestamp_struct
+
+		for counter := 0; counter < len(cut_list_positions_and_durations); counter = counter + 2 {
+
+			if counter + 1 < len(cut_list_positions_and_durations) {
+				duration = timestamp_add(duration, cut_list_positions_and_durations[counter + 1])
+			} else {
+				duration = timestamp_add(duration, Timestamp_subtract(file_duration, cut_list_positions_and_durations[counter]))
+			}
+		}
+
+		return duration
+	}
+
+	duration := Timestamp_subtract(file_duration, search_start)
+
+	if processing_duration.Microseconds > 0 && Timestamp_compare(processing_duration, duration) < 0 {
+		duration = processing_duration
+	}
+
+	return duration
+}
+
+func Quality_sample_windows(ranges_positions_and_durations []Timestamp_struct, number_of_windows int, window_duration Timestamp_struct) (window_start_times []Timestamp_struct, window_durations []Timestamp_struct) {
+
+	// Spread sample windows evenly across the parts of the file that are processed. The ranges are given as start time and duration pairs
+	// (the same format Process_split_times uses). The middle point of each window is placed at an even distance from the previous one
+	// counted over the processed duration. If the whole processed video is shorter than all windows combined, the whole video is used as one sample.
+	var total_duration Timestamp_struct
+
+	for counter := 0; counter + 1 < len(ranges_positions_and_durations); counter = counter + 2 {
+		total_duration = timestamp_add(total_duration, ranges_positions_and_durations[counter + 1])
+	}
+
+	if total_duration.Microseconds <= window_duration.Microseconds * int64(number_of_windows) {
+
+		for counter := 0; counter + 1 < len(ranges_positions_and_durations); counter = counter + 2 {
+			window_start_times = append(window_start_times, ranges_positions_and_durations[counter])
+			window_durations = append(window_durations, ranges_positions_and_durations[counter + 1])
+		}
+
+		return window_start_times, window_durations
+	}
+
+	for window_number := 0; window_number < number_of_windows; window_number++ {
+
+		// Position of the window start counted over the processed duration
+		position := total_duration.Microseconds * int64(2 * window_number + 1) / int64(2 * number_of_windows) - window_duration.Microseconds / 2
+
+		// Find the part of the file where the position is and convert it to a time in the original file.
+		// Windows that would continue past the end of the part are moved back so that they end at the end of the part.
+		for counter := 0; counter + 1 < len(ranges_positions_and_durations); counter = counter + 2 {
+
+			range_start := ranges_positions_and_durations[counter]
+			range_duration := ranges_positions_and_durations[counter + 1]
+
+			if position >= range_duration.Microseconds && counter + 3 < len(ranges_positions_and_durations) {
+				position = position - range_duration.Microseconds
+				continue
+			}
+
+			if position > range_duration.Microseconds - window_duration.Microseconds {
+				position = range_duration.Microseconds - window_duration.Microseconds
+			}
+
+			if position < 0 {
+				position = 0
+			}
+
+			window_start_times = append(window_start_times, timestamp_add(range_start, Timestamp_struct{Microseconds: position}))
+			window_durations = append(window_durations, window_duration)
+			break
+		}
+	}
+
+	return window_start_times, window_durations
+}
+
+func Parse_quality_target(quality_target string) (metric string, target_value float64, error_code error) {
+
+	// Quality target is given as metric:value, for example ssim:0.98 or psnr:42
+	temp_slice := strings.SplitN(quality_target, ":", 2)
+
+	if len(temp_slice) != 2 || (temp_slice[0] != "ssim" && temp_slice[0] != "psnr") {
+		return "", 0, errors.New("quality target must be given in the form of: ssim:0.98 or psnr:42")
+	}
+
+	metric = temp_slice[0]
+	target_value, float_parse_error := strconv.ParseFloat(temp_slice[1], 64)
+
+	if float_parse_error != nil {
+		return "", 0, errors.New("'" + temp_slice[1] + "' is not a number")
+	}
+
+	if metric == "ssim" && (target_value <= 0 || target_value > 1) {
+		return "", 0, errors.New("ssim target must be between 0 and 1, for example: ssim:0.98")
+	}
+
+	if metric == "psnr" && (target_value <= 0 || target_value > 100) {
+		return "", 0, errors.New("psnr target must be between 0 and 100 dB, for example: psnr:42")
+	}
+
+	return metric, target_value, nil
+}
+
+func Parse_quality_measurement(metric string, ffmpeg_output []string) (float64, error) {
+
+	// FFmpeg ssim and psnr filters print a summary at the end of processing:
+	// [Parsed_ssim_4 @ 0x55d5] SSIM Y:0.985305 (18.327704) U:0.991370 (20.640540) V:0.990887 (20.403297) All:0.987224 (18.936713)
+	// [Parsed_psnr_4 @ 0x55d5] PSNR y:42.171347 u:46.843540 v:47.123215 average:43.433573 min:38.224815 max:49.781360
+	value_prefix := "All:"
+
+	if metric == "psnr" {
+		value_prefix = "average:"
+	}
+
+	summary_prefix := " " + strings.ToUpper(metric) + " "
+
+	for counter := len(ffmpeg_output) - 1; counter >= 0; counter-- {
+
+		line := ffmpeg_output[counter]
+
+		if strings.Contains(line, summary_prefix) == false || strings.Contains(line, value_prefix) == false {
+			continue
+		}
+
+		value_str := strings.Fields(strings.Split(line, value_prefix)[1])[0]
+
+		// PSNR of identical pictures is printed as 'inf'
+		if value_str == "inf" {
+			return 100, nil
+		}
+
+		return strconv.ParseFloat(value_str, 64)
+	}
+
+	return 0, errors.New("could not find " + metric + " value in FFmpeg output")
+}
+
+func quality_measurement_filter_graph(number_of_inputs int, first_input_number int, video_filters []string, pixel_format string, output_label string) string {
+
+	// Create a filter graph that runs each input through the video filters and joins them together.
+	// The same graph is used for creating the compressed sample and the reference it is compared to, so that both have the same picture.
+	var filter_chains []string
+	concat_inputs := ""
+
+	for counter := 0; counter < number_of_inputs; counter++ {
+
+		filters := append([]string{}, video_filters...)
+		filters = append(filters, "format=" + pixel_format, "setpts=PTS-STARTPTS")
+
+		filter_chains = append(filter_chains, "[" + strconv.Itoa(first_input_number + counter) + ":v:0]" + strings.Join(filters, ",") + "[part_" + strconv.Itoa(counter) + "]")
+		concat_inputs = concat_inputs + "[part_" + strconv.Itoa(counter) + "]"
+	}
+
+	filter_chains = append(filter_chains, concat_inputs + "concat=n=" + strconv.Itoa(number_of_inputs) + ":v=1:a=0" + output_label)
+
+	return strings.Join(filter_chains, ";")
+}
+
+func Measure_crf_quality(ctx context.Context, input_file string, window_start_times []Timestamp_struct, window_durations []Timestamp_struct, video_filters []string, pixel_format string, codec_options []string, crf string, metric string, sample_file_path string) (float64, []string, error) {
+
+	// Compress the sample windows with the crf value into a sample file and compare it to the original sample windows.
+	// Returns the measured quality and the FFmpeg commands used (for the processing log).
+	var sample_inputs []encode.Ffmpeg_input_struct
+
+	for counter := range window_start_times {
+		sample_inputs = append(sample_inputs, encode.Ffmpeg_input_struct{Seek_start: Format_timestamp_seconds(window_start_times[counter]), Input_options: []string{"-t", Format_timestamp_seconds(window_durations[counter])}, File_path: input_file})
+	}
+
+	sample_commandline := encode.Ffmpeg_commandline_struct{Global_options: []string{"-y", "-loglevel", "level+error"}}
+	sample_commandline.Inputs = sample_inputs
+	sample_commandline.Filter_complex = []string{quality_measurement_filter_graph(len(sample_inputs), 0, video_filters, pixel_format, "[sample_out]")}
+	sample_output := encode.Ffmpeg_output_struct{Maps: []string{"[sample_out]"}, File_path: sample_file_path}
+	sample_output.Codec_options = append(sample_output.Codec_options, codec_options...)
+	sample_output.Codec_options = append(sample_output.Codec_options, "-crf", crf, "-an", "-sn")
+	sample_output.Options = []string{"-f", "matroska"}
+	sample_commandline.Outputs = append(sample_commandline.Outputs, sample_output)
+
+	// The summary of the ssim and psnr filters is printed on info log level
+	measure_commandline := encode.Ffmpeg_commandline_struct{Global_options: []string{"-hide_banner", "-nostats", "-loglevel", "info"}}
+	measure_commandline.Inputs = append(measure_commandline.Inputs, encode.Ffmpeg_input_struct{File_path: sample_file_path})
+	measure_commandline.Inputs = append(measure_commandline.Inputs, sample_inputs...)
+	measure_commandline.Filter_complex = []string{quality_measurement_filter_graph(len(sample_inputs), 1, video_filters, pixel_format, "[reference]"), "[0:v:0]format=" + pixel_format + ",setpts=PTS-STARTPTS[sample];[sample][reference]" + metric}
+	measure_commandline.Outputs = append(measure_commandline.Outputs, encode.Ffmpeg_output_struct{Options: []string{"-f", "null"}, File_path: "-"})
+
+	commands_for_log := []string{encode.Ffmpeg_commandline_to_shell_string(sample_commandline), encode.Ffmpeg_commandline_to_shell_string(measure_commandline)}
+
+	defer os.Remove(sample_file_path)
+
+	stdout_output, stderr_output, error_code := encode.Run_external_command(ctx, encode.Render_ffmpeg_commandline(sample_commandline))
+
+	if error_code != nil {
+		return 0, commands_for_log, encode.External_command_error("FFmpeg", stdout_output, stderr_output, error_code)
+	}
+
+	stdout_output, stderr_output, error_code = encode.Run_external_command(ctx, encode.Render_ffmpeg_commandline(measure_commandline))
+
+	if error_code != nil {
+		return 0, commands_for_log, encode.External_command_error("FFmpeg", stdout_output, stderr_output, error_code)
+	}
+
+	quality, parse_error := Parse_quality_measurement(metric, stderr_output)
+
+	return quality, commands_for_log, parse_error
+}
+
+func parse_loudnorm_measurement(ffmpeg_output []string) (Loudnorm_measurement_struct, error) {
+
+	// FFmpeg loudnorm filter prints the measured values as json at the end of processing:
+	// [Parsed_loudnorm_0 @ 0x55d5]
+	// {
+	// 	"input_i" : "-27.61",
+	// 	"input_tp" : "-4.47",
+	// 	...
+	// }
+	var loudnorm_measurement Loudnorm_measurement_struct
+	json_start := -1
+	json_end := -1
+
+	for counter := len(ffmpeg_output) - 1; counter >= 0; counter-- {
+
+		line := strings.TrimSpace(ffmpeg_output[counter])
+
+		if line == "}" && json_end == -1 {
+			json_end = counter
+		}
+
+		if line == "{" && json_end != -1 {
+			json_start = counter
+			break
+		}
+	}
+
+	if json_start == -1 {
+		return loudnorm_measurement, errors.New("could not find loudness measurement in FFmpeg output")
+	}
+
+	if json_error := json.Unmarshal([]byte(strings.Join(ffmpeg_output[json_start:json_end + 1], "\n")), &loudnorm_measurement); json_error != nil {
+		return loudnorm_measurement, errors.New("could not read loudness measurement from FFmpeg output: " + json_error.Error())
+	}
+
+	for _, measured_value := range []string{loudnorm_measurement.Input_i, loudnorm_measurement.Input_tp, loudnorm_measurement.Input_lra, loudnorm_measurement.Input_thresh, loudnorm_measurement.Target_offset} {
+
+		// Silent audio is measured as -inf
+		if float_value, float_parse_error := strconv.ParseFloat(measured_value, 64); float_parse_error != nil || math.IsInf(float_value, 0) == true || math.IsNaN(float_value) == true {
+			return loudnorm_measurement, errors.New("loudness can't be measured, the audio may be silent. Measured value: " + measured_value)
+		}
+	}
+
+	return loudnorm_measurement, nil
+}
+
+func Loudnorm_filter(loudness_target string, loudnorm_measurement Loudnorm_measurement_struct, sample_rate int) string {
+
+	// Second pass loudnorm filter uses the measured values and linear mode, which changes the volume of the whole file by the same amount.
+	// Linear mode is only possible if the target loudness range is as wide as the measured range, otherwise loudnorm falls back to dynamic mode that compresses the audio.
+	// Loudnorm upsamples audio to 192 kHz, resample back to the sample rate of the source.
+	// A measurement is missing only when commands are printed with -print, then the filter is printed without the measured values.
+	loudness_range := loudness_range_target
+	measured_range, _ := strconv.ParseFloat(loudnorm_measurement.Input_lra, 64)
+	target_range, _ := strconv.ParseFloat(loudness_range_target, 64)
+
+	if measured_range > target_range {
+		loudness_range = loudnorm_measurement.Input_lra
+	}
+
+	if sample_rate == 0 {
+		sample_rate = 48000
+	}
+
+	if loudnorm_measurement.Input_i == "" {
+		return "loudnorm=I=" + loudness_target + ":TP=" + loudness_true_peak_target + ":LRA=" + loudness_range + ":linear=true,aresample=" + strconv.Itoa(sample_rate)
+	}
+
+	return "loudnorm=I=" + loudness_target + ":TP=" + loudness_true_peak_target + ":LRA=" + loudness_range +
+		":measured_I=" + loudnorm_measurement.Input_i + ":measured_TP=" + loudnorm_measurement.Input_tp + ":measured_LRA=" + loudnorm_measurement.Input_lra +
+		":measured_thresh=" + loudnorm_measurement.Input_thresh + ":offset=" + loudnorm_measurement.Target_offset + ":linear=true,aresample=" + strconv.Itoa(sample_rate)
+}
+
+func Audio_offset_input(file_path string, input_seek Timestamp_struct, offset_milliseconds int) encode.Ffmpeg_input_struct {
+
+	// The audio is read from a second input of the same file, so that the offset is applied before the file is cut with -ss, -t or -sf.
+	// input_seek is the -ss of the video input, audio at source time t is then at t - input_seek + offset in the output.
+	// The audio input is seeked to input_seek - offset, so that a positive offset uses the audio before the start point of the video.
+	// When there is no audio before the start point the rest of the offset is added to the audio timestamps with -itsoffset.
+	audio_input := encode.Ffmpeg_input_struct{File_path: file_path}
+	audio_seek := Timestamp_subtract(input_seek, Timestamp_struct{Microseconds: int64(offset_milliseconds) * 1000})
+
+	if Timestamp_compare(audio_seek, Timestamp_struct{}) < 0 {
+		audio_input.Input_options = []string{"-itsoffset", Format_timestamp_seconds(Timestamp_subtract(Timestamp_struct{}, audio_seek))}
+	} else if Timestamp_compare(audio_seek, Timestamp_struct{}) > 0 {
+		audio_input.Seek_start = Format_timestamp_seconds(audio_seek)
+	}
+
+	return audio_input
+}
+
+func Audio_downmix_filter(input_channels int, output_channels int) string {
+
+	// Downmix 5.1 and 7.1 audio to stereo with the ITU-R BS.775 coefficients: center and surround channels are mixed to the front channels at -3 dB (0.707),
+	// so that dialog in the center channel keeps its level compared to the front channels. The LFE channel is left out as the standard recommends.
+	// 7.1 is remapped to 5.1 (for AC3) by mixing the side and back channels together at -3 dB.
+	// The gains are used as they are ('='), scaling them down with '<' would make the downmix about 7.7 dB quieter than the original.
+	// Loud passages may go over full scale, Audio_downmix_limiter or the loudnorm filter of -norm takes care of the peaks.
+	// Channels are referred by their number, since the surround channels of 5.1 may be named BL, BR or SL, SR.
+	// An empty string is returned for other channel counts, FFmpeg then remaps the channels with -ac.
+	if input_channels == 6 && output_channels == 2 {
+		return "pan=stereo|FL=c0+0.707*c2+0.707*c4|FR=c1+0.707*c2+0.707*c5"
+	}
+
+	if input_channels == 8 && output_channels == 2 {
+		return "pan=stereo|FL=c0+0.707*c2+0.707*c4+0.707*c6|FR=c1+0.707*c2+0.707*c5+0.707*c7"
+	}
+
+	if input_channels == 8 && output_channels == 6 {
+		return "pan=5.1|FL=c0|FR=c1|FC=c2|LFE=c3|BL=0.707*c4+0.707*c6|BR=0.707*c5+0.707*c7"
+	}
+
+	return ""
+}
+
+func loudness_analysis_commandline(input encode.Ffmpeg_input_struct, duration string, audio_stream_number int, audio_downmix_filter string, audio_output_channels int, loudness_target string) encode.Ffmpeg_commandline_struct {
+
+	// The loudness is measured with the loudnorm filter, the measurement is printed on info log level.
+	// A downmixed stream is measured after the downmix, since it does not have the same loudness as the original.
+	analysis_filters := "loudnorm=I=" + loudness_target + ":TP=" + loudness_true_peak_target + ":LRA=" + loudness_range_target + ":print_format=json"
+
+	if audio_downmix_filter != "" {
+		analysis_filters = audio_downmix_filter + "," + analysis_filters
+	}
+
+	analysis_commandline := encode.Ffmpeg_commandline_struct{Global_options: []string{"-hide_banner", "-nostats", "-loglevel", "info"}}
+	analysis_commandline.Inputs = append(analysis_commandline.Inputs, input)
+
+	analysis_output := encode.Ffmpeg_output_struct{Duration: duration, File_path: "-"}
+	analysis_output.Maps = []string{"0:a:" + strconv.Itoa(audio_stream_number)}
+	analysis_output.Codec_options = []string{"-filter:a", analysis_filters, "-ac", strconv.Itoa(audio_output_channels)}
+	analysis_output.Options = []string{"-f", "null"}
+	analysis_commandline.Outputs = append(analysis_commandline.Outputs, analysis_output)
+
+	return analysis_commandline
+}
+
+func Analyze_loudness(ctx context.Context, input encode.Ffmpeg_input_struct, duration string, audio_stream_numbers []int, audio_downmix_filters []string, audio_output_channels []int, loudness_target string, only_print_commands bool) (loudness_analysis_result Loudness_analysis_result_struct) {
+
+	// First pass of loudness normalization. Measure the loudness of each audio stream,
+	// the measured values are used in the second pass (the encode).
+	// When only printing commands the analysis is not run and the measurements are left empty.
+	for audio_stream_index, audio_stream_number := range audio_stream_numbers {
+
+		analysis_commandline := loudness_analysis_commandline(input, duration, audio_stream_number, audio_downmix_filters[audio_stream_index], audio_output_channels[audio_stream_index], loudness_target)
+
+		loudness_analysis_result.Commands = append(loudness_analysis_result.Commands, encode.Ffmpeg_commandline_to_shell_string(analysis_commandline))
+
+		if only_print_commands == true {
+			loudness_analysis_result.Measurements = append(loudness_analysis_result.Measurements, Loudnorm_measurement_struct{})
+			continue
+		}
+
+		stdout_output, stderr_output, error_code := encode.Run_external_command(ctx, encode.Render_ffmpeg_commandline(analysis_commandline))
+
+		if error_code != nil {
+			loudness_analysis_result.Error_code = encode.External_command_error("FFmpeg loudness analysis", stdout_output, stderr_output, error_code)
+			return loudness_analysis_result
+		}
+
+		loudnorm_measurement, parse_error := parse_loudnorm_measurement(stderr_output)
+
+		if parse_error != nil {
+			loudness_analysis_result.Error_code = errors.New("audio stream " + strconv.Itoa(audio_stream_number) + ": " + parse_error.Error())
+			return loudness_analysis_result
+		}
+
+		loudness_analysis_result.Measurements = append(loudness_analysis_result.Measurements, loudnorm_measurement)
+	}
+
+	return loudness_analysis_result
+}
+
+func Escape_ffmpeg_filter_value(value string) string {
+
+	// Filter option values are escaped twice, first for the filter option parser and then for the filter graph parser.
+	// This is needed for file paths given to filters, since paths may contain characters like ':' and ','.
+	option_escaper := strings.NewReplacer("\\", "\\\\", "'", "\\'", ":", "\\:")
+	graph_escaper := strings.NewReplacer("\\", "\\\\", "'", "\\'", "[", "\\[", "]", "\\]", ",", "\\,", ";", "\\;")
+
+	return graph_escaper.Replace(option_escaper.Replace(value))
+}
+
+func Read_quality_stats_file(stats_file_path string, metric string) (frame_values []float64, error_code error) {
+
+	// FFmpeg ssim and psnr filters write one line per frame to the stats file:
+	// n:1 Y:0.993451 U:0.995830 V:0.995527 All:0.994265 (22.414618)
+	// n:1 mse_avg:2.54 mse_y:3.03 mse_u:1.48 mse_v:1.63 psnr_avg:44.08 psnr_y:43.31 psnr_u:46.42 psnr_v:46.00
+	value_prefix := "All:"
+
+	if metric == "psnr" {
+		value_prefix = "psnr_avg:"
+	}
+
+	file_content, read_error := ioutil.ReadFile(stats_file_path)
+
+	if read_error != nil {
+		return frame_values, read_error
+	}
+
+	for _, line := range strings.Split(string(file_content), "\n") {
+
+		if strings.Contains(line, value_prefix) == false {
+			continue
+		}
+
+		value_str := strings.Fields(strings.Split(line, value_prefix)[1])[0]
+
+		// PSNR of identical frames is written as 'inf'
+		if value_str == "inf" {
+			frame_values = append(frame_values, 100)
+			continue
+		}
+
+		value, parse_error := strconv.ParseFloat(value_str, 64)
+
+		if parse_error != nil {
+			return frame_values, errors.New("could not read " + metric + " value from line: " + line + " in file: " + stats_file_path)
+		}
+
+		frame_values = append(frame_values, value)
+	}
+
+	return frame_values, nil
+}
+
+func Worst_quality_segments(ssim_values []float64, psnr_values []float64, frame_rate probe.Frame_rate_struct, segment_seconds int, number_of_segments int) (worst_segments []Quality_segment_struct, error_code error) {
+
+	// Group frame values to segments of segment_seconds and return the segments with the lowest average ssim, the worst segment first.
+	// Segment times are counted from the start of the processed file.
+	if frame_rate.Numerator <= 0 || frame_rate.Denominator <= 0 {
+		return worst_segments, errors.New("frame rate " + probe.Format_frame_rate(frame_rate) + " is not valid")
+	}
+
+	segment_duration := Timestamp_struct{Microseconds: int64(segment_seconds) * Microseconds_per_second}
+	video_end, _ := timestamp_from_frame_number(int64(len(ssim_values)), frame_rate)
+
+	var segments []Quality_segment_struct
+	var ssim_sum, psnr_sum float64
+	number_of_frames := 0
+	segment_number := int64(0)
+
+	for frame := 0; frame <= len(ssim_values); frame++ {
+
+		frame_segment_number := int64(-1)
+
+		if frame < len(ssim_values) {
+			frame_time, _ := timestamp_from_frame_number(int64(frame), frame_rate)
+			frame_segment_number = frame_time.Microseconds / segment_duration.Microseconds
+		}
+
+		// Store the segment when a frame belonging to the next segment is found or all frames have been processed
+		if frame_segment_number != segment_number && number_of_frames > 0 {
+
+			segment_start := Timestamp_struct{Microseconds: segment_number * segment_duration.Microseconds}
+			segment_end := timestamp_add(segment_start, segment_duration)
+
+			if Timestamp_compare(segment_end, video_end) > 0 {
+				segment_end = video_end
+			}
+
+			segments = append(segments, Quality_segment_struct{
+				Start: Format_timestamp(segment_start),
+				End: Format_timestamp(segment_end),
+				Ssim: ssim_sum / float64(number_of_frames),
+				Psnr: psnr_sum / float64(number_of_frames),
+			})
+
+			ssim_sum = 0
+			psnr_sum = 0
+			number_of_frames = 0
+		}
+
+		if frame == len(ssim_values) {
+			break
+		}
+
+		segment_number = frame_segment_number
+		ssim_sum = ssim_sum + ssim_values[frame]
+
+		if frame < len(psnr_values) {
+			psnr_sum = psnr_sum + psnr_values[frame]
+		}
+
+		number_of_frames++
+	}
+
+	sort.SliceStable(segments, func(i, j int) bool { return segments[i].Ssim < segments[j].Ssim })
+
+	if len(segments) > number_of_segments {
+		segments = segments[:number_of_segments]
+	}
+
+	return segments, nil
+}
+
+func Parse_file_size(size_str string) (int64, error) {
+
+	// Parse file size given in the form: 4.3G, 700M or 500K. Multiples are binary: 1K = 1024 bytes.
+	multipliers := map[string]float64{"K": 1024, "M": 1024 * 1024, "G": 1024 * 1024 * 1024, "T": 1024 * 1024 * 1024 * 1024}
+
+	if len(size_str) < 2 {
+		return 0, errors.New("size must be given in the form of: 4.3G or 700M")
+	}
+
+	multiplier, multiplier_found := multipliers[strings.ToUpper(size_str[len(size_str)-1:])]
+
+	if multiplier_found == false {
+		return 0, errors.New("size '" + size_str + "' must end with one of the letters K, M, G or T. Example: 4.3G or 700M")
+	}
+
+	size_float, float_parse_error := strconv.ParseFloat(size_str[:len(size_str)-1], 64)
+
+	if float_parse_error != nil || size_float <= 0 {
+		return 0, errors.New("'" + size_str + "' is not a valid size. Example: 4.3G or 700M")
+	}
+
+	return int64(size_float * multiplier), nil
+}
+
+func Source_audio_bitrate(audio_stream probe.Audio_stream_struct) int64 {
+
+	// Return the bitrate of the audio stream in kilobits. Matroska files don't store the bitrate in the stream info,
+	// but mkvmerge writes it to the BPS tag. Zero is returned when the bitrate is not known.
+	if audio_stream.Bit_rate > 0 {
+		return audio_stream.Bit_rate / 1000
+	}
+
+	for _, tag_name := range []string{"BPS", "BPS-eng"} {
+
+		if bits_per_second, parse_error := strconv.ParseInt(audio_stream.Tags[tag_name], 10, 64); parse_error == nil && bits_per_second > 0 {
+			return bits_per_second / 1000
+		}
+	}
+
+	return 0
+}
+
+func Target_size_video_bitrate(target_size_bytes int64, duration Timestamp_struct, audio_bitrate_kbps int64) (int64, error) {
+
+	// Calculate video bitrate (kilobits) needed to produce a file of the target size.
+	// Container overhead and audio bitrate are subtracted from the bitrate available for the whole file.
+	if duration.Microseconds <= 0 {
+		return 0, errors.New("can't calculate bitrate for the target size, the duration of processed video is zero")
+	}
+
+	available_kilobits := target_size_bytes * 8 * (100 - Target_size_container_overhead_percent) / 100 / 1000
+	total_bitrate_kbps := available_kilobits * Microseconds_per_second / duration.Microseconds
+	video_bitrate_kbps := total_bitrate_kbps - audio_bitrate_kbps
+
+	// Anything less than 100k is not watchable
+	if video_bitrate_kbps < 100 {
+		return 0, errors.New("target size is too small for " + Format_timestamp(duration) + " of video with " + strconv.FormatInt(audio_bitrate_kbps, 10) + "k audio, the video bitrate would be " + strconv.FormatInt(video_bitrate_kbps, 10) + "k")
+	}
+
+	return video_bitrate_kbps, nil
+}
+
+func convert_cut_positions_to_timecode(cut_positions_after_processing []Timestamp_struct) []string {
+
+	var cut_positions_as_timecodes []string
+
+	for counter, item := range cut_positions_after_processing {
+
+		// Remove the first edit point if it is zero, as this really is no edit point
+		if counter == 0 && item.Microseconds == 0 {
+			continue
+		}
+
+		cut_positions_as_timecodes = append(cut_positions_as_timecodes, Format_timestamp(item))
+	}
+
+	return cut_positions_as_timecodes
+}
+
+func split_time_list(split_times string) ([]string, error) {
+
+	// Split times are given in pairs of start and stop times: 0-10:00,20:00-end
+	cut_list_string_slice := strings.Split(strings.ReplaceAll(split_times, "-", ","), ",")
+
+	if len(cut_list_string_slice)%2 != 0 {
+		return nil, errors.New("split times must be given in pairs (start_time, stop_time). There are: " + strconv.Itoa(len(cut_list_string_slice)) + " times on the commandline")
+	}
+
+	return cut_list_string_slice, nil
+}
+
+func Check_split_times(split_times string) error {
+
+	// Check the split times before the frame rate of the video is known. When SMPTE timecodes or frame numbers are used
+	// only the format of the times is checked here, Process_split_times checks the order of the times with the frame rate of each file.
+	cut_list_string_slice, split_times_error := split_time_list(split_times)
+
+	if split_times_error != nil {
+		return split_times_error
+	}
+
+	times_need_frame_rate := false
+
+	for _, temp_string := range cut_list_string_slice {
+
+		if strings.ToLower(temp_string) == "start" || strings.ToLower(temp_string) == "end" {
+			continue
+		}
+
+		if timestamp_error := Check_timestamp(temp_string); timestamp_error != nil {
+			return errors.New("can't convert split time '" + temp_string + "' to seconds: " + timestamp_error.Error())
+		}
+
+		if Timestamp_needs_frame_rate(temp_string) == true {
+			times_need_frame_rate = true
+		}
+	}
+
+	if times_need_frame_rate == true {
+		return nil
+	}
+
+	_, _, split_times_error = Process_split_times(split_times, probe.Frame_rate_struct{}, false)
+
+	return split_times_error
+}
+
+func Process_split_times(split_times string, frame_rate probe.Frame_rate_struct, debug_option bool) ([]Timestamp_struct, []string, error) {
+
+	// SMPTE timecodes and frame numbers in the split times are converted with frame_rate, the frame rate of the video
+	var cut_list_timestamps, cut_list_positions_and_durations, cut_positions_after_processing []Timestamp_struct
+	var cut_positions_as_timecodes []string
+	process_to_end_of_file := false
+
+	cut_list_string_slice, split_times_error := split_time_list(split_times)
+
+	if split_times_error != nil {
+		return nil, nil, split_times_error
+	}
+
+	if debug_option == true {
+		fmt.Println("")
+		fmt.Println("process_split_times: cut_list_string_slice:", cut_list_string_slice, "len(cut_list_string_slice):", len(cut_list_string_slice))
+	}
+
+	//////////////////////////////////////////////////////////////////
+	// Convert time values (01:20:25) to timestamps (4825 seconds)  //
+	//////////////////////////////////////////////////////////////////
+	for _, temp_string := range cut_list_string_slice {
+
+		if strings.ToLower(temp_string) == "start" {
+			temp_string = "0"
+		}
+
+		// The word 'end' marks the end of file. FFmpeg automatically processes to the end of file if the last duration is left out
+		if strings.ToLower(temp_string) == "end" {
+			process_to_end_of_file = true
+			break
+		}
+
+		timestamp, timestamp_error := Parse_timestamp(temp_string, frame_rate)
+
+		if timestamp_error != nil {
+			return nil, nil, errors.New("can't convert split time '" + temp_string + "' to seconds: " + timestamp_error.Error())
+		}
+		cut_list_timestamps = append(cut_list_timestamps, timestamp)
+	}
+
+	///////////////////////////////////////////////////////////
+	// Test that all times are ascending and not overlapping //
+	///////////////////////////////////////////////////////////
+
+	var previous_item Timestamp_struct
+
+	if debug_option == true {
+		fmt.Println("")
+		fmt.Println("process_split_times: cut_list_timestamps:", cut_list_timestamps)
+	}
+
+	for _, current_item := range cut_list_timestamps {
+
+		if Timestamp_compare(current_item, previous_item) < 0 {
+			return nil, nil, errors.New("split times " + Format_timestamp(previous_item) + " and " + Format_timestamp(current_item) + " are not in ascending order. Times must be ascending and not overlap")
+		}
+		previous_item = current_item
+	}
+
+	///////////////////////////////////////////////////////////////////////////////////////////
+	// Convert odd time values to duration. Even values are start times and used as they are //
+	///////////////////////////////////////////////////////////////////////////////////////////
+
+	for counter := 0; counter < len(cut_list_timestamps); counter = counter + 2 {
+
+		// Store the start time as it is
+		cut_list_positions_and_durations = append(cut_list_positions_and_durations, cut_list_timestamps[counter])
+
+		// If word 'end' was used to mark the end of file there is no stop time for the last part
+		if counter + 1 >= len(cut_list_timestamps) {
+			break
+		}
+
+		// Store the duration
+		cut_list_positions_and_durations = append(cut_list_positions_and_durations, Timestamp_subtract(cut_list_timestamps[counter+1], cut_list_timestamps[counter]))
+	}
+
+	//////////////////////////////////////////////////////////////////////////////////////////////////
+	// Calculate where edit points are in the processed file so that the user can check them easily //
+	//////////////////////////////////////////////////////////////////////////////////////////////////
+
+	if len(cut_list_string_slice) > 2 {
+		var duration_of_all_removed_file_parts, previous_stop_time Timestamp_struct
+
+		for counter := 0; counter < len(cut_list_timestamps); counter = counter + 2 {
+			start_time := cut_list_timestamps[counter]
+			duration_of_all_removed_file_parts = timestamp_add(duration_of_all_removed_file_parts, Timestamp_subtract(start_time, previous_stop_time))
+
+			if counter+1 < len(cut_list_timestamps) || process_to_end_of_file == true {
+				cut_positions_after_processing = append(cut_positions_after_processing, Timestamp_subtract(start_time, duration_of_all_removed_file_parts))
+			}
+
+			if counter+1 < len(cut_list_timestamps) {
+				previous_stop_time = cut_list_timestamps[counter+1]
+			}
+		}
+	}
+
+	// Convert edit positions to timecode values
+	cut_positions_as_timecodes = convert_cut_positions_to_timecode(cut_positions_after_processing)
+
+	if debug_option == true {
+		fmt.Println("process_split_times: split_times:", split_times)
+		fmt.Println("process_split_times: cut_list_positions_and_durations:", cut_list_positions_and_durations)
+		fmt.Println("process_split_times: cut_positions_after_processing:", cut_positions_after_processing)
+		fmt.Println("process_split_times: cut_positions_as_timecodes:", cut_positions_as_timecodes)
+	}
+
+	return cut_list_positions_and_durations, cut_positions_as_timecodes, nil
+}
+
+func Parse_audio_map(value string) ([]Audio_map_struct, error) {
+
+	// Comma separated list of audio stream numbers with an optional codec and number of channels for each stream: 0:copy,2:aac:2ch
+	// Streams without a codec are processed with the audio options that apply to all streams (-aac, -opus, ...).
+	var audio_map []Audio_map_struct
+
+	for _, audio_map_item := range strings.Split(value, ",") {
+
+		var audio_map_entry Audio_map_struct
+		audio_map_fields := strings.Split(audio_map_item, ":")
+
+		if len(audio_map_fields) > 3 {
+			return audio_map, errors.New("'" + audio_map_item + "' has too many fields. Use the form: stream number:codec:channels, example: 2:aac:2ch")
+		}
+
+		stream_number, atoi_error := strconv.Atoi(audio_map_fields[0])
+
+		if atoi_error != nil || stream_number < 0 {
+			return audio_map, errors.New("audio stream number '" + audio_map_fields[0] + "' in '" + audio_map_item + "' is not a whole number")
+		}
+
+		for _, previous_audio_map_entry := range audio_map {
+
+			if stream_number == previous_audio_map_entry.Stream_number {
+				return audio_map, errors.New("audio stream " + audio_map_fields[0] + " is given twice")
+			}
+		}
+
+		audio_map_entry.Stream_number = stream_number
+
+		if len(audio_map_fields) > 1 {
+
+			audio_map_entry.Codec = strings.ToLower(audio_map_fields[1])
+
+			if audio_map_entry.Codec != "copy" && audio_map_entry.Codec != "aac" && audio_map_entry.Codec != "ac3" && audio_map_entry.Codec != "opus" && audio_map_entry.Codec != "flac" {
+				return audio_map, errors.New("'" + audio_map_fields[1] + "' in '" + audio_map_item + "' is not an audio codec. Use copy, aac, ac3, opus or flac")
+			}
+		}
+
+		if len(audio_map_fields) > 2 {
+
+			channels_str := strings.ToLower(audio_map_fields[2])
+			number_of_channels, atoi_error := strconv.Atoi(strings.TrimSuffix(channels_str, "ch"))
+
+			if strings.HasSuffix(channels_str, "ch") == false || atoi_error != nil || number_of_channels < 1 || number_of_channels > 255 {
+				return audio_map, errors.New("'" + audio_map_fields[2] + "' in '" + audio_map_item + "' is not a number of channels. Use a number between 1 and 255 followed by ch, example: 2ch")
+			}
+
+			if audio_map_entry.Codec == "copy" {
+				return audio_map, errors.New("the number of channels can't be changed when audio stream " + audio_map_fields[0] + " is copied")
+			}
+
+			audio_map_entry.Number_of_channels = number_of_channels
+		}
+
+		audio_map = append(audio_map, audio_map_entry)
+	}
+
+	return audio_map, nil
+}
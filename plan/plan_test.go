@@ -0,0 +1,323 @@
+// (C) Mikael Hartzell 2018
+// This program is distributed under the GNU General Public License, version 3 (GPLv3)
+
+package plan
+
+import (
+	"testing"
+
+	"github.com/mhartzel/ffcommander/probe"
+)
+
+var ntsc_frame_rate = probe.Frame_rate_struct{Numerator: 30000, Denominator: 1001}
+var ntsc_double_frame_rate = probe.Frame_rate_struct{Numerator: 60000, Denominator: 1001}
+var pal_frame_rate = probe.Frame_rate_struct{Numerator: 25, Denominator: 1}
+
+func Test_smpte_timecode(t *testing.T) {
+
+	// Drop-frame timecode skips frame numbers 0 and 1 (0 - 3 at 59.94) at the start of each minute, except every tenth minute.
+	test_cases := []struct {
+		frame_rate probe.Frame_rate_struct
+		frame_number int64
+		timecode string
+	}{
+		{ntsc_frame_rate, 0, "00:00:00;00"},
+		{ntsc_frame_rate, 29, "00:00:00;29"},
+		{ntsc_frame_rate, 30, "00:00:01;00"},
+		{ntsc_frame_rate, 1799, "00:00:59;29"},
+		{ntsc_frame_rate, 1800, "00:01:00;02"},
+		{ntsc_frame_rate, 1801, "00:01:00;03"},
+		{ntsc_frame_rate, 3597, "00:01:59;29"},
+		{ntsc_frame_rate, 3598, "00:02:00;02"},
+		{ntsc_frame_rate, 17981, "00:09:59;29"},
+		{ntsc_frame_rate, 17982, "00:10:00;00"},
+		{ntsc_frame_rate, 17983, "00:10:00;01"},
+		{ntsc_frame_rate, 19782, "00:11:00;02"},
+		{ntsc_frame_rate, 107892, "01:00:00;00"},
+		{ntsc_double_frame_rate, 3599, "00:00:59;59"},
+		{ntsc_double_frame_rate, 3600, "00:01:00;04"},
+		{ntsc_double_frame_rate, 35964, "00:10:00;00"},
+		{pal_frame_rate, 0, "00:00:00:00"},
+		{pal_frame_rate, 24, "00:00:00:24"},
+		{pal_frame_rate, 1500, "00:01:00:00"},
+		{pal_frame_rate, 90000, "01:00:00:00"},
+	}
+
+	for _, test_case := range test_cases {
+
+		drop_frame := test_case.frame_rate.Denominator == 1001
+
+		timestamp, error_code := timestamp_from_frame_number(test_case.frame_number, test_case.frame_rate)
+
+		if error_code != nil {
+			t.Fatalf("frame %d: %v", test_case.frame_number, error_code)
+		}
+
+		if timecode := format_smpte_timecode(timestamp, test_case.frame_rate, drop_frame); timecode != test_case.timecode {
+			t.Errorf("frame %d at %s: format_smpte_timecode returned %s, want %s", test_case.frame_number, probe.Format_frame_rate(test_case.frame_rate), timecode, test_case.timecode)
+		}
+
+		parsed_timestamp, error_code := parse_smpte_timecode(test_case.timecode, test_case.frame_rate)
+
+		if error_code != nil {
+			t.Errorf("%s: %v", test_case.timecode, error_code)
+			continue
+		}
+
+		if parsed_timestamp != timestamp {
+			t.Errorf("%s: parse_smpte_timecode returned %d microseconds, want %d", test_case.timecode, parsed_timestamp.Microseconds, timestamp.Microseconds)
+		}
+
+		if frame_number := timestamp_to_frame_number(parsed_timestamp, test_case.frame_rate); frame_number != test_case.frame_number {
+			t.Errorf("%s: timestamp_to_frame_number returned %d, want %d", test_case.timecode, frame_number, test_case.frame_number)
+		}
+	}
+}
+
+func Test_smpte_timecode_round_trip(t *testing.T) {
+
+	// Every frame in the first two hours must survive frame number -> timestamp -> timecode -> timestamp -> frame number.
+	for _, frame_rate := range []probe.Frame_rate_struct{ntsc_frame_rate, ntsc_double_frame_rate, pal_frame_rate, {Numerator: 24000, Denominator: 1001}} {
+
+		_, dropped_frames_int := smpte_frame_counts(frame_rate)
+		last_frame_number := 2 * 60 * 60 * int64(frame_rate.Numerator) / int64(frame_rate.Denominator)
+
+		for frame_number := int64(0); frame_number < last_frame_number; frame_number++ {
+
+			timestamp, error_code := timestamp_from_frame_number(frame_number, frame_rate)
+
+			if error_code != nil {
+				t.Fatalf("frame %d: %v", frame_number, error_code)
+			}
+
+			if timestamp_to_frame_number(timestamp, frame_rate) != frame_number {
+				t.Fatalf("frame %d at %s: timestamp %d converts back to frame %d", frame_number, probe.Format_frame_rate(frame_rate), timestamp.Microseconds, timestamp_to_frame_number(timestamp, frame_rate))
+			}
+
+			timecode := format_smpte_timecode(timestamp, frame_rate, dropped_frames_int > 0)
+			parsed_timestamp, error_code := parse_smpte_timecode(timecode, frame_rate)
+
+			if error_code != nil {
+				t.Fatalf("frame %d at %s: %v", frame_number, probe.Format_frame_rate(frame_rate), error_code)
+			}
+
+			if parsed_timestamp != timestamp {
+				t.Fatalf("frame %d at %s: timecode %s parses to %d microseconds, want %d", frame_number, probe.Format_frame_rate(frame_rate), timecode, parsed_timestamp.Microseconds, timestamp.Microseconds)
+			}
+		}
+	}
+}
+
+func Test_smpte_timecode_errors(t *testing.T) {
+
+	test_cases := []struct {
+		frame_rate probe.Frame_rate_struct
+		timecode string
+	}{
+		{ntsc_frame_rate, "00:01:00;00"},         // Dropped frame numbers
+		{ntsc_frame_rate, "00:01:00;01"},
+		{ntsc_double_frame_rate, "00:01:00;03"},
+		{pal_frame_rate, "00:00:10;00"},          // Drop-frame is only defined for 29.97 and 59.94
+		{ntsc_frame_rate, "00:00:00:30"},         // Frames must be less than the nominal frame rate
+		{pal_frame_rate, "00:00:60:00"},
+		{pal_frame_rate, "00:60:00:00"},
+		{pal_frame_rate, "00:00:10"},
+		{pal_frame_rate, "00:00:aa:00"},
+		{pal_frame_rate, "00:00:-1:00"},
+		{probe.Frame_rate_struct{}, "00:00:10:00"},
+	}
+
+	for _, test_case := range test_cases {
+
+		if _, error_code := parse_smpte_timecode(test_case.timecode, test_case.frame_rate); error_code == nil {
+			t.Errorf("%s at %s did not return an error", test_case.timecode, probe.Format_frame_rate(test_case.frame_rate))
+		}
+	}
+
+	// Frame numbers 0 and 1 exist at every tenth minute.
+	for _, timecode := range []string{"00:00:00;00", "00:10:00;00", "00:20:00;01", "01:00:00;00"} {
+
+		if _, error_code := parse_smpte_timecode(timecode, ntsc_frame_rate); error_code != nil {
+			t.Errorf("%s: %v", timecode, error_code)
+		}
+	}
+}
+
+func Test_non_drop_frame_timecode_at_ntsc_frame_rate(t *testing.T) {
+
+	// Non-drop-frame timecode at 29.97 counts 30 frames per timecode second and drifts from the wall clock.
+	timestamp, error_code := parse_smpte_timecode("00:01:00:00", ntsc_frame_rate)
+
+	if error_code != nil {
+		t.Fatal(error_code)
+	}
+
+	if frame_number := timestamp_to_frame_number(timestamp, ntsc_frame_rate); frame_number != 1800 {
+		t.Errorf("00:01:00:00 is frame %d, want 1800", frame_number)
+	}
+
+	if Format_timestamp(timestamp) != "00:01:00.060" {
+		t.Errorf("00:01:00:00 is at %s, want 00:01:00.060", Format_timestamp(timestamp))
+	}
+
+	if timecode := format_smpte_timecode(timestamp, ntsc_frame_rate, false); timecode != "00:01:00:00" {
+		t.Errorf("format_smpte_timecode returned %s, want 00:01:00:00", timecode)
+	}
+}
+
+func Test_parse_timestamp(t *testing.T) {
+
+	test_cases := []struct {
+		timestring string
+		microseconds int64
+	}{
+		{"01:20:25.500", 4825500000},
+		{"01:20:25", 4825000000},
+		{"00:00:00.001", 1000},
+		{"00:00:00.0015", 1500},
+		{"1:2:3", 3723000000},
+		{"20:25.500", 1225500000},
+		{"20:25", 1225000000},
+		{"0:59", 59000000},
+		{"4825.5", 4825500000},
+		{"4825", 4825000000},
+		{".25", 250000},
+		{"0", 0},
+		{" 10 ", 10000000},
+		{"90:00", 5400000000},               // Minutes can be over 59 when hours are not given
+		{"0.1234567", 123456},               // Fractions beyond microseconds are truncated
+		{"00:00:01:00", 1001000},            // SMPTE timecode at 29.97
+		{"00:01:00;02", 60060000},           // Drop-frame timecode, frames 0 and 1 don't exist at the start of the minute
+		{"1800f", 60060000},                 // Frame number
+		{"0f", 0},
+	}
+
+	for _, test_case := range test_cases {
+
+		timestamp, error_code := Parse_timestamp(test_case.timestring, ntsc_frame_rate)
+
+		if error_code != nil {
+			t.Errorf("%q: %v", test_case.timestring, error_code)
+			continue
+		}
+
+		if timestamp.Microseconds != test_case.microseconds {
+			t.Errorf("%q: got %d microseconds, want %d", test_case.timestring, timestamp.Microseconds, test_case.microseconds)
+		}
+
+		if error_code = Check_timestamp(test_case.timestring); error_code != nil {
+			t.Errorf("Check_timestamp(%q): %v", test_case.timestring, error_code)
+		}
+	}
+}
+
+func Test_parse_timestamp_errors(t *testing.T) {
+
+	for _, timestring := range []string{"", " ", "-1", "-00:10", "00:-10:00", "-10f", "1:xx", "abc", "1.2.3", "10s", "1:2:3:4:5", "01:60:00", "01:00:60", "00:60", "00:60:00:00", "00:00:-1:00", "+1:00", "1e3", "+10", "10:00,5", "f", "1.5f"} {
+
+		if timestamp, error_code := Parse_timestamp(timestring, ntsc_frame_rate); error_code == nil {
+			t.Errorf("%q did not return an error, got %d microseconds", timestring, timestamp.Microseconds)
+		}
+
+		if error_code := Check_timestamp(timestring); error_code == nil {
+			t.Errorf("Check_timestamp(%q) did not return an error", timestring)
+		}
+	}
+
+	// SMPTE timecodes and frame numbers can only be converted when the frame rate is known, but their format can be checked without it
+	for _, timestring := range []string{"00:10:00:00", "00:10:00;00", "17982f"} {
+
+		if _, error_code := Parse_timestamp(timestring, probe.Frame_rate_struct{}); error_code == nil {
+			t.Errorf("%q was converted without a frame rate", timestring)
+		}
+
+		if error_code := Check_timestamp(timestring); error_code != nil {
+			t.Errorf("Check_timestamp(%q): %v", timestring, error_code)
+		}
+	}
+}
+
+func Test_timestamp_subtract_and_compare(t *testing.T) {
+
+	test_cases := []struct {
+		timestamp_1 string
+		timestamp_2 string
+		difference string
+		comparison int
+	}{
+		{"01:22:49.500", "10:09", "01:12:40.500", 1},
+		{"10:30.500", "10:00", "00:00:30.500", 1},
+		{"00:00:00.001", "0", "00:00:00.001", 1},
+		{"1.1", "1.1", "00:00:00", 0},
+		{"0.3", "0.1", "00:00:00.200", 1},      // Float subtraction would give 0.19999999999999998
+		{"59.999", "1:00", "", -1},
+	}
+
+	for _, test_case := range test_cases {
+
+		timestamp_1, _ := Parse_timestamp(test_case.timestamp_1, probe.Frame_rate_struct{})
+		timestamp_2, _ := Parse_timestamp(test_case.timestamp_2, probe.Frame_rate_struct{})
+		difference := Timestamp_subtract(timestamp_1, timestamp_2)
+
+		if comparison := Timestamp_compare(timestamp_1, timestamp_2); comparison != test_case.comparison {
+			t.Errorf("Timestamp_compare(%s, %s) returned %d, want %d", test_case.timestamp_1, test_case.timestamp_2, comparison, test_case.comparison)
+		}
+
+		if Timestamp_compare(timestamp_2, timestamp_1) != -test_case.comparison {
+			t.Errorf("Timestamp_compare(%s, %s) returned %d, want %d", test_case.timestamp_2, test_case.timestamp_1, Timestamp_compare(timestamp_2, timestamp_1), -test_case.comparison)
+		}
+
+		// The difference is negative when the second timestamp is bigger
+		if test_case.comparison < 0 {
+
+			if difference.Microseconds != -1000 {
+				t.Errorf("%s - %s is %d microseconds, want -1000", test_case.timestamp_1, test_case.timestamp_2, difference.Microseconds)
+			}
+			continue
+		}
+
+		if Format_timestamp(difference) != test_case.difference {
+			t.Errorf("%s - %s is %s, want %s", test_case.timestamp_1, test_case.timestamp_2, Format_timestamp(difference), test_case.difference)
+		}
+	}
+}
+
+func Test_end_time_milliseconds_are_kept(t *testing.T) {
+
+	// -st 10:09 -et 01:22:49.500 used to lose the milliseconds of the end time, since the end time was converted to whole seconds
+	search_start, _ := Parse_timestamp("10:09", probe.Frame_rate_struct{})
+	processing_stop_time, _ := Parse_timestamp("01:22:49.500", probe.Frame_rate_struct{})
+	processing_duration := Timestamp_subtract(processing_stop_time, search_start)
+
+	if Format_timestamp(processing_duration) != "01:12:40.500" || Format_timestamp_seconds(processing_duration) != "4360.5" {
+		t.Errorf("duration is %s (%s seconds), want 01:12:40.500 (4360.5 seconds)", Format_timestamp(processing_duration), Format_timestamp_seconds(processing_duration))
+	}
+}
+
+func Test_check_split_times(t *testing.T) {
+
+	test_cases := []struct {
+		split_times string
+		error_expected bool
+	}{
+		{"0-10:00,20:00-end", false},
+		{"start-10:00", false},
+		{"0f-17982f,00:20:00;00-end", false},
+		{"00:20:00;00-0f", false},              // The order of SMPTE timecodes and frame numbers is checked with the frame rate of each file
+		{"10:00-5:00", true},
+		{"0-10:00,20:00", true},
+		{"0-10:xx", true},
+		{"0-00:60:00:00", true},
+	}
+
+	for _, test_case := range test_cases {
+
+		if error_code := Check_split_times(test_case.split_times); (error_code != nil) != test_case.error_expected {
+			t.Errorf("%q: got error %v, error expected: %t", test_case.split_times, error_code, test_case.error_expected)
+		}
+	}
+
+	if _, _, error_code := Process_split_times("00:20:00;00-0f", ntsc_frame_rate, false); error_code == nil {
+		t.Errorf("split times that are not in ascending order did not return an error")
+	}
+}
@@ -0,0 +1,314 @@
+// (C) Mikael Hartzell 2018
+// This program is distributed under the GNU General Public License, version 3 (GPLv3)
+
+// Package probe reads the video, audio, subtitle and chapter information of a file with ffprobe.
+package probe
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/mhartzel/ffcommander/encode"
+)
+
+// FFprobe json output is decoded into these structs.
+// Ffprobe is run with: ffprobe -loglevel level+error -print_format json -show_streams -show_format -show_chapters -i InputFile
+// Ffprobe prints numbers that may not fit in 32 bits (bit_rate, nb_frames, sample_rate, duration) as strings.
+type Ffprobe_stream_struct struct {
+	Index int `json:"index"`
+	Codec_name string `json:"codec_name"`
+	Codec_long_name string `json:"codec_long_name"`
+	Profile string `json:"profile"`
+	Codec_type string `json:"codec_type"`
+	Codec_tag_string string `json:"codec_tag_string"`
+	Width int `json:"width"`
+	Height int `json:"height"`
+	Coded_width int `json:"coded_width"`
+	Coded_height int `json:"coded_height"`
+	Sample_aspect_ratio string `json:"sample_aspect_ratio"`
+	Display_aspect_ratio string `json:"display_aspect_ratio"`
+	Pix_fmt string `json:"pix_fmt"`
+	Level int `json:"level"`
+	Color_range string `json:"color_range"`
+	Color_space string `json:"color_space"`
+	Color_transfer string `json:"color_transfer"`
+	Color_primaries string `json:"color_primaries"`
+	Chroma_location string `json:"chroma_location"`
+	Field_order string `json:"field_order"`
+	Sample_fmt string `json:"sample_fmt"`
+	Sample_rate string `json:"sample_rate"`
+	Channels int `json:"channels"`
+	Channel_layout string `json:"channel_layout"`
+	Bits_per_raw_sample string `json:"bits_per_raw_sample"`
+	R_frame_rate string `json:"r_frame_rate"`
+	Avg_frame_rate string `json:"avg_frame_rate"`
+	Time_base string `json:"time_base"`
+	Start_time string `json:"start_time"`
+	Duration string `json:"duration"`
+	Bit_rate string `json:"bit_rate"`
+	Max_bit_rate string `json:"max_bit_rate"`
+	Nb_frames string `json:"nb_frames"`
+	Disposition map[string]int `json:"disposition"`
+	Tags map[string]string `json:"tags"`
+	Side_data_list []map[string]interface{} `json:"side_data_list"` // Side data like HDR mastering display and content light level metadata
+}
+
+type Ffprobe_format_struct struct {
+	Filename string `json:"filename"`
+	Nb_streams int `json:"nb_streams"`
+	Nb_programs int `json:"nb_programs"`
+	Format_name string `json:"format_name"`
+	Format_long_name string `json:"format_long_name"`
+	Start_time string `json:"start_time"`
+	Duration string `json:"duration"`
+	Size string `json:"size"`
+	Bit_rate string `json:"bit_rate"`
+	Probe_score int `json:"probe_score"`
+	Tags map[string]string `json:"tags"`
+}
+
+type Ffprobe_chapter_struct struct {
+	Id int64 `json:"id"`
+	Time_base string `json:"time_base"`
+	Start int64 `json:"start"`
+	Start_time string `json:"start_time"`
+	End int64 `json:"end"`
+	End_time string `json:"end_time"`
+	Tags map[string]string `json:"tags"`
+}
+
+type Ffprobe_output_struct struct {
+	Streams []Ffprobe_stream_struct `json:"streams"`
+	Format Ffprobe_format_struct `json:"format"`
+	Chapters []Ffprobe_chapter_struct `json:"chapters"`
+}
+
+// Stream information of one input file is stored in these structs.
+// There can be many video, audio and subtitle streams in a file.
+// The complete ffprobe information of each stream is kept in ffprobe_info.
+type Frame_rate_struct struct {
+	Numerator int
+	Denominator int
+}
+
+type Video_stream_struct struct {
+	Stream_index int
+	Width int
+	Height int
+	Codec_name string
+	Color_subsampling string
+	Color_space string
+	Color_transfer string
+	Color_primaries string
+	Color_range string
+	Frame_rate Frame_rate_struct
+	Average_frame_rate Frame_rate_struct
+	Bit_rate int64
+	Number_of_frames int64
+	Title string
+	Disposition map[string]int
+	Tags map[string]string
+	Side_data_list []map[string]interface{}
+	Ffprobe_info Ffprobe_stream_struct
+}
+
+type Audio_stream_struct struct {
+	Stream_index int
+	Language string
+	Title string
+	Number_of_channels int
+	Channel_layout string
+	Sample_rate int
+	Bit_rate int64
+	Codec_name string
+	Disposition map[string]int
+	Tags map[string]string
+	Ffprobe_info Ffprobe_stream_struct
+}
+
+type Subtitle_stream_struct struct {
+	Stream_index int
+	Language string
+	Title string
+	Codec_name string
+	Disposition map[string]int
+	Tags map[string]string
+	Ffprobe_info Ffprobe_stream_struct
+}
+
+type Chapter_struct struct {
+	Start_time float64
+	End_time float64
+	Title string
+}
+
+type Media_file_struct struct {
+	File_name string
+	Duration float64
+	Format_name string
+	Bit_rate int64
+	Title string
+	Video_streams []Video_stream_struct
+	Audio_streams []Audio_stream_struct
+	Subtitle_streams []Subtitle_stream_struct
+	Chapters []Chapter_struct
+	Ffprobe_format Ffprobe_format_struct
+}
+
+func parse_frame_rate(frame_rate_str string) (frame_rate Frame_rate_struct) {
+
+	// Frame rate may be displayed by ffprobe in the form of a division like: 30000/1001.
+	// Store dividend and divisor separately so that no precision is lost.
+	temp_slice := strings.SplitN(frame_rate_str, "/", 2)
+
+	if numerator, atoi_error := strconv.Atoi(strings.TrimSpace(temp_slice[0])); atoi_error == nil {
+		frame_rate.Numerator = numerator
+		frame_rate.Denominator = 1
+	}
+
+	if len(temp_slice) == 2 {
+
+		if denominator, atoi_error := strconv.Atoi(strings.TrimSpace(temp_slice[1])); atoi_error == nil {
+			frame_rate.Denominator = denominator
+		}
+	}
+
+	return frame_rate
+}
+
+func Format_frame_rate(frame_rate Frame_rate_struct) (frame_rate_str string) {
+
+	// Do the division to get the human redable frame rate, for example: 30000/1001 = 29.970
+	if frame_rate.Denominator == 0 {
+		return "0.000"
+	}
+
+	return strconv.FormatFloat(float64(frame_rate.Numerator) / float64(frame_rate.Denominator), 'f', 3, 64)
+}
+
+func Get_video_and_audio_stream_information(file_name string, ffprobe_json_output []byte) (media_file Media_file_struct, error_code error) {
+
+	// Decode ffprobe json output and store video, audio and subtitle stream information in a Media_file_struct.
+	// Discard info about streams that are not audio, video or subtitle (attachments, data streams).
+	var ffprobe_output Ffprobe_output_struct
+
+	if error_code = json.Unmarshal(ffprobe_json_output, &ffprobe_output); error_code != nil {
+		return media_file, error_code
+	}
+
+	media_file.File_name = file_name
+	media_file.Duration, _ = strconv.ParseFloat(ffprobe_output.Format.Duration, 64)
+	media_file.Format_name = ffprobe_output.Format.Format_name
+	media_file.Bit_rate, _ = strconv.ParseInt(ffprobe_output.Format.Bit_rate, 10, 64)
+	media_file.Title = ffprobe_output.Format.Tags["title"]
+	media_file.Ffprobe_format = ffprobe_output.Format
+
+	for _, ffprobe_stream := range ffprobe_output.Streams {
+
+		// Ffprobe leaves out disposition and tags if the stream does not have them, use empty maps so that lookups are always safe.
+		if ffprobe_stream.Disposition == nil {
+			ffprobe_stream.Disposition = make(map[string]int)
+		}
+
+		if ffprobe_stream.Tags == nil {
+			ffprobe_stream.Tags = make(map[string]string)
+		}
+
+		switch ffprobe_stream.Codec_type {
+
+		case "video":
+			var video_stream Video_stream_struct
+			video_stream.Stream_index = ffprobe_stream.Index
+			video_stream.Width = ffprobe_stream.Width
+			video_stream.Height = ffprobe_stream.Height
+			video_stream.Codec_name = ffprobe_stream.Codec_name
+			video_stream.Color_subsampling = ffprobe_stream.Pix_fmt
+			video_stream.Color_space = ffprobe_stream.Color_space
+			video_stream.Color_transfer = ffprobe_stream.Color_transfer
+			video_stream.Color_primaries = ffprobe_stream.Color_primaries
+			video_stream.Color_range = ffprobe_stream.Color_range
+			video_stream.Frame_rate = parse_frame_rate(ffprobe_stream.R_frame_rate)
+			video_stream.Average_frame_rate = parse_frame_rate(ffprobe_stream.Avg_frame_rate)
+			video_stream.Bit_rate, _ = strconv.ParseInt(ffprobe_stream.Bit_rate, 10, 64)
+			video_stream.Number_of_frames, _ = strconv.ParseInt(ffprobe_stream.Nb_frames, 10, 64)
+			video_stream.Title = ffprobe_stream.Tags["title"]
+			video_stream.Disposition = ffprobe_stream.Disposition
+			video_stream.Tags = ffprobe_stream.Tags
+			video_stream.Side_data_list = ffprobe_stream.Side_data_list
+			video_stream.Ffprobe_info = ffprobe_stream
+			media_file.Video_streams = append(media_file.Video_streams, video_stream)
+
+		case "audio":
+			var audio_stream Audio_stream_struct
+			audio_stream.Stream_index = ffprobe_stream.Index
+			audio_stream.Language = ffprobe_stream.Tags["language"]
+			audio_stream.Title = ffprobe_stream.Tags["title"]
+			audio_stream.Number_of_channels = ffprobe_stream.Channels
+			audio_stream.Channel_layout = ffprobe_stream.Channel_layout
+			audio_stream.Sample_rate, _ = strconv.Atoi(ffprobe_stream.Sample_rate)
+			audio_stream.Bit_rate, _ = strconv.ParseInt(ffprobe_stream.Bit_rate, 10, 64)
+			audio_stream.Codec_name = ffprobe_stream.Codec_name
+			audio_stream.Disposition = ffprobe_stream.Disposition
+			audio_stream.Tags = ffprobe_stream.Tags
+			audio_stream.Ffprobe_info = ffprobe_stream
+			media_file.Audio_streams = append(media_file.Audio_streams, audio_stream)
+
+		case "subtitle":
+			var subtitle_stream Subtitle_stream_struct
+			subtitle_stream.Stream_index = ffprobe_stream.Index
+			subtitle_stream.Language = ffprobe_stream.Tags["language"]
+			subtitle_stream.Title = ffprobe_stream.Tags["title"]
+			subtitle_stream.Codec_name = ffprobe_stream.Codec_name
+			subtitle_stream.Disposition = ffprobe_stream.Disposition
+			subtitle_stream.Tags = ffprobe_stream.Tags
+			subtitle_stream.Ffprobe_info = ffprobe_stream
+			media_file.Subtitle_streams = append(media_file.Subtitle_streams, subtitle_stream)
+		}
+	}
+
+	for _, ffprobe_chapter := range ffprobe_output.Chapters {
+		var chapter Chapter_struct
+		chapter.Start_time, _ = strconv.ParseFloat(ffprobe_chapter.Start_time, 64)
+		chapter.End_time, _ = strconv.ParseFloat(ffprobe_chapter.End_time, 64)
+		chapter.Title = ffprobe_chapter.Tags["title"]
+		media_file.Chapters = append(media_file.Chapters, chapter)
+	}
+
+	// The Media_file_struct for a file that has one video, two audio and two subtitle streams looks like this:
+	//
+	// file_name: /home/mika/Downloads/dvb_stream.ts, duration: 64.123411
+	// video_streams: [ {width: 720, height: 576, codec_name: h264, color_subsampling: yuv420p, color_space: bt709, frame_rate: 25/1, average_frame_rate: 24/1} ]
+	// audio_streams: [ {language: eng, number_of_channels: 2, sample_rate: 48000, codec_name: ac3, disposition: {visual_impaired: 0}},
+	//                  {language: dut, number_of_channels: 2, sample_rate: 48000, codec_name: pcm_s16le, disposition: {visual_impaired: 1}} ]
+	// subtitle_streams: [ {language: fin, codec_name: dvb_subtitle, disposition: {hearing_impaired: 0}},
+	//                     {language: fin, codec_name: dvb_teletext, disposition: {hearing_impaired: 0}} ]
+	//
+	// Audio stream 1 is for visually impared and subtitle stream 0 is a bitmap subtitle.
+	// A file without video streams has an empty video_streams slice. This triggers an error message about the file in the main routine (we can't process a file without video)
+
+	return media_file, error_code
+}
+
+func Probe(ctx context.Context, file_name string) (media_file Media_file_struct, error_code error) {
+
+	// Get video info with: ffprobe -loglevel level+error -print_format json -show_streams -show_format -show_chapters -i InputFile
+	command_to_run_str_slice := []string{"ffprobe", "-loglevel", "level+error", "-print_format", "json", "-show_streams", "-show_format", "-show_chapters", "-i", file_name}
+
+	ffprobe_json_output, ffprobe_error_message, error_code := encode.Run_external_command(ctx, command_to_run_str_slice)
+
+	if error_code != nil {
+		return media_file, encode.External_command_error("FFprobe", ffprobe_json_output, ffprobe_error_message, error_code)
+	}
+
+	// Get specific video, audio and subtitle stream information
+	media_file, error_code = Get_video_and_audio_stream_information(file_name, []byte(strings.Join(ffprobe_json_output, "\n")))
+
+	if error_code != nil {
+		return media_file, errors.New("could not understand ffprobe output: " + error_code.Error())
+	}
+
+	return media_file, nil
+}
@@ -0,0 +1,223 @@
+// (C) Mikael Hartzell 2018
+// This program is distributed under the GNU General Public License, version 3 (GPLv3)
+
+package probe
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// The testdata directory has ffprobe json output captured from a DVD vob, a Blu-ray remux and a DVB transport stream.
+// The files were captured with: ffprobe -loglevel level+error -print_format json -show_streams -show_format -show_chapters -i InputFile
+func read_ffprobe_fixture(t *testing.T, fixture_name string) (media_file Media_file_struct) {
+
+	t.Helper()
+
+	ffprobe_json_output, error_code := os.ReadFile(filepath.Join("testdata", fixture_name))
+
+	if error_code != nil {
+		t.Fatal(error_code)
+	}
+
+	media_file, error_code = Get_video_and_audio_stream_information(fixture_name, ffprobe_json_output)
+
+	if error_code != nil {
+		t.Fatalf("%s: %v", fixture_name, error_code)
+	}
+
+	return media_file
+}
+
+func Test_dvd_vob(t *testing.T) {
+
+	media_file := read_ffprobe_fixture(t, "dvd_vob.json")
+
+	if media_file.Format_name != "mpeg" || media_file.Duration != 5842 || media_file.Bit_rate != 1470131 || media_file.Title != "" {
+		t.Errorf("wrong format information: %q %v %v %q", media_file.Format_name, media_file.Duration, media_file.Bit_rate, media_file.Title)
+	}
+
+	if len(media_file.Video_streams) != 1 || len(media_file.Audio_streams) != 2 || len(media_file.Subtitle_streams) != 1 || len(media_file.Chapters) != 0 {
+		t.Fatalf("wrong number of streams: video %d, audio %d, subtitle %d, chapters %d", len(media_file.Video_streams), len(media_file.Audio_streams), len(media_file.Subtitle_streams), len(media_file.Chapters))
+	}
+
+	video_stream := media_file.Video_streams[0]
+
+	if video_stream.Codec_name != "mpeg2video" || video_stream.Width != 720 || video_stream.Height != 576 || video_stream.Color_subsampling != "yuv420p" {
+		t.Errorf("wrong video stream: %+v", video_stream)
+	}
+
+	if video_stream.Frame_rate != (Frame_rate_struct{25, 1}) || video_stream.Average_frame_rate != (Frame_rate_struct{25, 1}) {
+		t.Errorf("wrong frame rates: %v %v", video_stream.Frame_rate, video_stream.Average_frame_rate)
+	}
+
+	// Dvd streams have no bit rate, color information or tags, those must be left empty.
+	if video_stream.Bit_rate != 0 || video_stream.Color_space != "" || video_stream.Title != "" || len(video_stream.Tags) != 0 {
+		t.Errorf("missing values are not empty: %+v", video_stream)
+	}
+
+	if video_stream.Ffprobe_info.Field_order != "tt" || video_stream.Ffprobe_info.Max_bit_rate != "9800000" || len(video_stream.Side_data_list) != 1 {
+		t.Errorf("wrong ffprobe info: %+v", video_stream.Ffprobe_info)
+	}
+
+	audio_stream := media_file.Audio_streams[1]
+
+	if audio_stream.Stream_index != 2 || audio_stream.Codec_name != "ac3" || audio_stream.Number_of_channels != 2 || audio_stream.Channel_layout != "stereo" || audio_stream.Sample_rate != 48000 || audio_stream.Bit_rate != 192000 {
+		t.Errorf("wrong audio stream: %+v", audio_stream)
+	}
+
+	// Dvd streams have no language tags, lookups in the empty map must still work.
+	if audio_stream.Language != "" || audio_stream.Tags == nil || audio_stream.Disposition["default"] != 0 {
+		t.Errorf("wrong audio language, tags or disposition: %+v", audio_stream)
+	}
+
+	subtitle_stream := media_file.Subtitle_streams[0]
+
+	if subtitle_stream.Stream_index != 3 || subtitle_stream.Codec_name != "dvd_subtitle" || subtitle_stream.Ffprobe_info.Width != 720 || subtitle_stream.Ffprobe_info.Height != 576 {
+		t.Errorf("wrong subtitle stream: %+v", subtitle_stream)
+	}
+}
+
+func Test_bluray_remux(t *testing.T) {
+
+	media_file := read_ffprobe_fixture(t, "bluray_remux.json")
+
+	if media_file.Format_name != "matroska,webm" || media_file.Duration != 6853.137 || media_file.Bit_rate != 36741830 || media_file.Title != "Blu-ray remux" {
+		t.Errorf("wrong format information: %q %v %v %q", media_file.Format_name, media_file.Duration, media_file.Bit_rate, media_file.Title)
+	}
+
+	if media_file.Ffprobe_format.Tags["creation_time"] != "2017-11-04T09:12:44.000000Z" || media_file.Ffprobe_format.Nb_streams != 5 {
+		t.Errorf("wrong format tags: %+v", media_file.Ffprobe_format)
+	}
+
+	if len(media_file.Video_streams) != 1 || len(media_file.Audio_streams) != 2 || len(media_file.Subtitle_streams) != 2 {
+		t.Fatalf("wrong number of streams: video %d, audio %d, subtitle %d", len(media_file.Video_streams), len(media_file.Audio_streams), len(media_file.Subtitle_streams))
+	}
+
+	video_stream := media_file.Video_streams[0]
+
+	if video_stream.Codec_name != "h264" || video_stream.Width != 1920 || video_stream.Height != 1080 || video_stream.Color_space != "bt709" || video_stream.Color_transfer != "bt709" || video_stream.Color_primaries != "bt709" || video_stream.Color_range != "tv" {
+		t.Errorf("wrong video stream: %+v", video_stream)
+	}
+
+	if video_stream.Frame_rate != (Frame_rate_struct{24000, 1001}) || Format_frame_rate(video_stream.Frame_rate) != "23.976" {
+		t.Errorf("wrong frame rate: %v", video_stream.Frame_rate)
+	}
+
+	if video_stream.Tags["NUMBER_OF_FRAMES-eng"] != "164331" || video_stream.Ffprobe_info.Bits_per_raw_sample != "8" || video_stream.Disposition["default"] != 1 {
+		t.Errorf("wrong video tags or disposition: %v %v", video_stream.Tags, video_stream.Disposition)
+	}
+
+	truehd_stream := media_file.Audio_streams[0]
+
+	if truehd_stream.Codec_name != "truehd" || truehd_stream.Number_of_channels != 8 || truehd_stream.Channel_layout != "7.1" || truehd_stream.Language != "eng" || truehd_stream.Title != "TrueHD 7.1" {
+		t.Errorf("wrong truehd stream: %+v", truehd_stream)
+	}
+
+	if truehd_stream.Disposition["default"] != 1 || truehd_stream.Disposition["original"] != 1 || truehd_stream.Disposition["dub"] != 0 {
+		t.Errorf("wrong truehd disposition: %v", truehd_stream.Disposition)
+	}
+
+	dts_stream := media_file.Audio_streams[1]
+
+	if dts_stream.Stream_index != 2 || dts_stream.Codec_name != "dts" || dts_stream.Ffprobe_info.Profile != "DTS-HD MA" || dts_stream.Language != "ger" || dts_stream.Title != "" || dts_stream.Disposition["dub"] != 1 {
+		t.Errorf("wrong dts stream: %+v", dts_stream)
+	}
+
+	forced_subtitle := media_file.Subtitle_streams[0]
+	hearing_impaired_subtitle := media_file.Subtitle_streams[1]
+
+	if forced_subtitle.Codec_name != "hdmv_pgs_subtitle" || forced_subtitle.Language != "eng" || forced_subtitle.Title != "Forced" || forced_subtitle.Disposition["forced"] != 1 {
+		t.Errorf("wrong forced subtitle stream: %+v", forced_subtitle)
+	}
+
+	if hearing_impaired_subtitle.Stream_index != 4 || hearing_impaired_subtitle.Language != "fin" || hearing_impaired_subtitle.Disposition["hearing_impaired"] != 1 || hearing_impaired_subtitle.Disposition["forced"] != 0 {
+		t.Errorf("wrong hearing impaired subtitle stream: %+v", hearing_impaired_subtitle)
+	}
+
+	// The last chapter has no title tag.
+	chapters := []Chapter_struct{
+		{Start_time: 0, End_time: 402.318583, Title: "Chapter 01"},
+		{Start_time: 402.318583, End_time: 1011.886875, Title: "Chapter 02"},
+		{Start_time: 1011.886875, End_time: 6853.137, Title: ""},
+	}
+
+	if reflect.DeepEqual(media_file.Chapters, chapters) == false {
+		t.Errorf("wrong chapters:\n got: %+v\nwant: %+v", media_file.Chapters, chapters)
+	}
+}
+
+func Test_dvb_transport_stream(t *testing.T) {
+
+	media_file := read_ffprobe_fixture(t, "dvb_ts.json")
+
+	if media_file.Format_name != "mpegts" || media_file.Duration != 64.123411 || media_file.Ffprobe_format.Start_time != "68294.670156" || media_file.Ffprobe_format.Nb_programs != 1 {
+		t.Errorf("wrong format information: %+v", media_file.Ffprobe_format)
+	}
+
+	// The data stream must be discarded.
+	if len(media_file.Video_streams) != 1 || len(media_file.Audio_streams) != 2 || len(media_file.Subtitle_streams) != 2 || len(media_file.Chapters) != 0 {
+		t.Fatalf("wrong number of streams: video %d, audio %d, subtitle %d, chapters %d", len(media_file.Video_streams), len(media_file.Audio_streams), len(media_file.Subtitle_streams), len(media_file.Chapters))
+	}
+
+	video_stream := media_file.Video_streams[0]
+
+	if video_stream.Codec_name != "h264" || video_stream.Width != 720 || video_stream.Height != 576 || video_stream.Color_space != "bt470bg" {
+		t.Errorf("wrong video stream: %+v", video_stream)
+	}
+
+	if video_stream.Frame_rate != (Frame_rate_struct{25, 1}) || video_stream.Average_frame_rate != (Frame_rate_struct{24, 1}) {
+		t.Errorf("wrong frame rates: %v %v", video_stream.Frame_rate, video_stream.Average_frame_rate)
+	}
+
+	english_audio := media_file.Audio_streams[0]
+	dutch_audio := media_file.Audio_streams[1]
+
+	if english_audio.Codec_name != "ac3" || english_audio.Language != "eng" || english_audio.Bit_rate != 256000 || english_audio.Disposition["visual_impaired"] != 0 {
+		t.Errorf("wrong english audio stream: %+v", english_audio)
+	}
+
+	if dutch_audio.Stream_index != 2 || dutch_audio.Codec_name != "mp2" || dutch_audio.Language != "dut" || dutch_audio.Disposition["visual_impaired"] != 1 {
+		t.Errorf("wrong dutch audio stream: %+v", dutch_audio)
+	}
+
+	dvb_subtitle := media_file.Subtitle_streams[0]
+	teletext_subtitle := media_file.Subtitle_streams[1]
+
+	if dvb_subtitle.Stream_index != 3 || dvb_subtitle.Codec_name != "dvb_subtitle" || dvb_subtitle.Language != "fin" || dvb_subtitle.Disposition["hearing_impaired"] != 1 {
+		t.Errorf("wrong dvb subtitle stream: %+v", dvb_subtitle)
+	}
+
+	if teletext_subtitle.Stream_index != 4 || teletext_subtitle.Codec_name != "dvb_teletext" || teletext_subtitle.Disposition["hearing_impaired"] != 0 {
+		t.Errorf("wrong teletext subtitle stream: %+v", teletext_subtitle)
+	}
+}
+
+func Test_missing_disposition_and_tags(t *testing.T) {
+
+	// Ffprobe leaves out disposition and tags when a stream does not have them.
+	ffprobe_json_output := []byte(`{"streams": [{"index": 0, "codec_type": "audio", "codec_name": "pcm_s16le", "channels": 2, "sample_rate": "44100"}], "format": {"duration": "1.500000"}}`)
+
+	media_file, error_code := Get_video_and_audio_stream_information("test.wav", ffprobe_json_output)
+
+	if error_code != nil {
+		t.Fatal(error_code)
+	}
+
+	if len(media_file.Audio_streams) != 1 || media_file.Audio_streams[0].Disposition == nil || media_file.Audio_streams[0].Tags == nil {
+		t.Fatalf("disposition and tags must be empty maps: %+v", media_file.Audio_streams)
+	}
+
+	if media_file.Audio_streams[0].Sample_rate != 44100 || media_file.Duration != 1.5 || media_file.File_name != "test.wav" {
+		t.Errorf("wrong audio stream: %+v", media_file)
+	}
+}
+
+func Test_broken_ffprobe_output(t *testing.T) {
+
+	if _, error_code := Get_video_and_audio_stream_information("broken.mkv", []byte(`{"streams": [`)); error_code == nil {
+		t.Error("truncated json did not return an error")
+	}
+}
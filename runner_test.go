@@ -0,0 +1,317 @@
+// (C) Mikael Hartzell 2018
+// This program is distributed under the GNU General Public License, version 3 (GPLv3)
+
+package ffcommander
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/mhartzel/ffcommander/encode"
+)
+
+func run_job_with_recording_runner(t *testing.T, job Job, canned_outputs map[string]encode.Canned_command_output_struct) (recorded_commands []string, error_code error) {
+
+	// Run the job with the recording runner and return the recorded commandlines with the temporary directory replaced by TMPDIR
+	t.Helper()
+
+	runner := &encode.Recording_command_runner{Canned_outputs: canned_outputs}
+	original_runner := encode.External_command_runner
+	encode.External_command_runner = runner
+	defer func() { encode.External_command_runner = original_runner }()
+
+	error_code = Run(context.Background(), job)
+
+	temp_dir := filepath.Dir(job.Input_files[0])
+
+	for _, command := range runner.Recorded_commands {
+		recorded_commands = append(recorded_commands, strings.Replace(strings.Join(command, " "), temp_dir, "TMPDIR", -1))
+	}
+
+	return recorded_commands, error_code
+}
+
+func new_test_job(t *testing.T) Job {
+
+	// Return a job that processes an empty movie.mkv in a temporary directory. Ffprobe output for movie.mkv is in testdata/movie.json:
+	// 1920x1080 h264 video, audio 0: ac3 5.1 eng, audio 1: dts stereo fin, subtitle 0: pgs eng, subtitle 1: dvd fin
+	t.Helper()
+
+	input_file := filepath.Join(t.TempDir(), "movie.mkv")
+
+	if error_code := os.WriteFile(input_file, nil, 0644); error_code != nil {
+		t.Fatal(error_code)
+	}
+
+	job := Default_job()
+	job.Input_files = []string{input_file}
+
+	return job
+}
+
+func movie_canned_outputs(t *testing.T) map[string]encode.Canned_command_output_struct {
+
+	t.Helper()
+
+	ffprobe_json_output, error_code := os.ReadFile(filepath.Join("testdata", "movie.json"))
+
+	if error_code != nil {
+		t.Fatal(error_code)
+	}
+
+	// Every FFmpeg run prints a cropdetect line, only the crop detection reads it
+	return map[string]encode.Canned_command_output_struct{
+		"ffprobe": {Stdout: string(ffprobe_json_output)},
+		"ffmpeg": {Stderr: "[Parsed_cropdetect_0 @ 0x1] x1:0 x2:1919 y1:140 y2:939 w:1920 h:800 x:0 y:140 pts:1 t:0.04 crop=1920:800:0:140"},
+	}
+}
+
+func compare_commands(t *testing.T, recorded_commands []string, expected_commands []string) {
+
+	t.Helper()
+
+	if reflect.DeepEqual(recorded_commands, expected_commands) == true {
+		return
+	}
+
+	if len(recorded_commands) != len(expected_commands) {
+		t.Errorf("got %d commands, want %d", len(recorded_commands), len(expected_commands))
+	}
+
+	for counter := 0; counter < len(recorded_commands) || counter < len(expected_commands); counter++ {
+		var recorded_command, expected_command string
+
+		if counter < len(recorded_commands) {
+			recorded_command = recorded_commands[counter]
+		}
+
+		if counter < len(expected_commands) {
+			expected_command = expected_commands[counter]
+		}
+
+		if recorded_command != expected_command {
+			t.Errorf("command %d:\n got: %s\nwant: %s", counter, recorded_command, expected_command)
+		}
+	}
+}
+
+const ffprobe_test_command = "ffprobe -loglevel level+error -print_format json -show_streams -show_format -show_chapters -i TMPDIR/movie.mkv"
+
+func Test_run_two_pass_with_autocrop(t *testing.T) {
+
+	// -a eng -ac
+	job := new_test_job(t)
+	job.Audio_language = "eng"
+	job.Autocrop = true
+
+	recorded_commands, error_code := run_job_with_recording_runner(t, job, movie_canned_outputs(t))
+
+	if error_code != nil {
+		t.Fatal(error_code)
+	}
+
+	expected_commands := []string{ffprobe_test_command}
+
+	// Crop is detected from 10 samples spread over the file
+	for _, search_start := range []string{"10", "552", "1094", "1636", "2178", "2720", "3262", "3804", "4346", "4888"} {
+		expected_commands = append(expected_commands, "ffmpeg -ss " + search_start + " -t 10 -i TMPDIR/movie.mkv -f matroska -sn -an -filter_complex cropdetect=24:8:250 -y -crf 51 -preset ultrafast /dev/null")
+	}
+
+	expected_commands = append(expected_commands,
+		"ffmpeg -y -loglevel level+error -threads auto -i TMPDIR/movie.mkv -filter_complex [0:v:0]idet,yadif=0:deint=all,crop=1920:800:0:140[main_processed_video_out] -map [main_processed_video_out] -map 0:a:0 -sn -c:v libx264 -preset medium -profile:v high -level 4.1 -b:v 6000k -acodec copy -passlogfile TMPDIR/00-processed_files/movie -f mp4 -pass 1 /dev/null",
+		"ffmpeg -y -loglevel level+error -threads auto -i TMPDIR/movie.mkv -filter_complex [0:v:0]idet,yadif=0:deint=all,crop=1920:800:0:140[main_processed_video_out] -map [main_processed_video_out] -map 0:a:0 -sn -c:v libx264 -preset medium -profile:v high -level 4.1 -b:v 6000k -acodec copy -passlogfile TMPDIR/00-processed_files/movie -f mp4 -pass 2 TMPDIR/00-processed_files/movie.mp4",
+	)
+
+	compare_commands(t, recorded_commands, expected_commands)
+}
+
+func Test_run_crf_with_time_limits(t *testing.T) {
+
+	// -a fin -crf -aac -st 10:00 -d 5:00 -mkv
+	job := new_test_job(t)
+	job.Audio_language = "fin"
+	job.Crf = true
+	job.Audio_compression_aac = true
+	job.Search_start = "10:00"
+	job.Processing_duration = "5:00"
+	job.Use_matroska_container = true
+
+	recorded_commands, error_code := run_job_with_recording_runner(t, job, movie_canned_outputs(t))
+
+	if error_code != nil {
+		t.Fatal(error_code)
+	}
+
+	compare_commands(t, recorded_commands, []string{
+		ffprobe_test_command,
+		"ffmpeg -y -loglevel level+error -threads auto -ss 00:10:00 -i TMPDIR/movie.mkv -filter_complex [0:v:0]idet,yadif=0:deint=all[main_processed_video_out] -t 00:05:00 -map [main_processed_video_out] -map 0:a:1 -sn -c:v libx264 -preset medium -profile:v high -level 4.1 -crf 18 -c:a aac -b:a 256k -f matroska TMPDIR/00-processed_files/movie.mkv",
+	})
+}
+
+func Test_run_split_times_with_audio_offset(t *testing.T) {
+
+	// -sf 0-10:00,20:00-end -ad 300. The offset is applied to the audio input when the splitfiles are cut,
+	// so the audio at the cut points is the same as in the plain encode. The joined splitfiles are encoded without an offset.
+	job := new_test_job(t)
+	job.Split_times = "0-10:00,20:00-end"
+	job.Audio_offset = "300"
+
+	recorded_commands, error_code := run_job_with_recording_runner(t, job, movie_canned_outputs(t))
+
+	if error_code != nil {
+		t.Fatal(error_code)
+	}
+
+	compare_commands(t, recorded_commands, []string{
+		ffprobe_test_command,
+		"ffmpeg -y -loglevel level+error -threads auto -i TMPDIR/movie.mkv -itsoffset 0.3 -i TMPDIR/movie.mkv -ss 0 -t 600 -map 0:v:0 -map 1:a:0 -vcodec utvideo -sn -acodec flac TMPDIR/00-processed_files/movie-splitfile-1.mkv",
+		"ffmpeg -y -loglevel level+error -threads auto -i TMPDIR/movie.mkv -itsoffset 0.3 -i TMPDIR/movie.mkv -ss 1200 -map 0:v:0 -map 1:a:0 -vcodec utvideo -sn -acodec flac TMPDIR/00-processed_files/movie-splitfile-2.mkv",
+		"ffmpeg -y -loglevel level+error -threads auto -f concat -safe 0 -i TMPDIR/00-processed_files/00-movie-splitfile_info.txt -filter_complex [0:v:0]idet,yadif=0:deint=all[main_processed_video_out] -map [main_processed_video_out] -map 0:a:0 -sn -c:v libx264 -preset medium -profile:v high -level 4.1 -b:v 8100k -c:a aac -b:a 768k -passlogfile TMPDIR/00-processed_files/movie -f matroska -pass 1 /dev/null",
+		"ffmpeg -y -loglevel level+error -threads auto -f concat -safe 0 -i TMPDIR/00-processed_files/00-movie-splitfile_info.txt -filter_complex [0:v:0]idet,yadif=0:deint=all[main_processed_video_out] -map [main_processed_video_out] -map 0:a:0 -sn -c:v libx264 -preset medium -profile:v high -level 4.1 -b:v 8100k -c:a aac -b:a 768k -passlogfile TMPDIR/00-processed_files/movie -f matroska -pass 2 TMPDIR/00-processed_files/movie.mkv",
+	})
+}
+
+func Test_run_audio_offset_with_fast_search(t *testing.T) {
+
+	// The audio input is seeked to the start point minus the offset, so that delayed audio starts with the audio before the start point
+	// and advanced audio with the audio after it. Without -st there is no audio before the start of the file and -itsoffset adds silence.
+	test_cases := []struct {
+		search_start string
+		audio_offset string
+		expected_inputs string
+	}{
+		{"10:00", "300", "-ss 00:10:00 -i TMPDIR/movie.mkv -ss 599.7 -i TMPDIR/movie.mkv"},
+		{"10:00", "-250", "-ss 00:10:00 -i TMPDIR/movie.mkv -ss 600.25 -i TMPDIR/movie.mkv"},
+		{"0.1", "300", "-ss 00:00:00.100 -i TMPDIR/movie.mkv -itsoffset 0.2 -i TMPDIR/movie.mkv"},
+		{"", "300", "-i TMPDIR/movie.mkv -itsoffset 0.3 -i TMPDIR/movie.mkv"},
+		{"", "-250", "-i TMPDIR/movie.mkv -ss 0.25 -i TMPDIR/movie.mkv"},
+	}
+
+	for _, test_case := range test_cases {
+
+		// -crf -fs -st <start> -ad <offset>
+		job := new_test_job(t)
+		job.Crf = true
+		job.Fast_search = true
+		job.Search_start = test_case.search_start
+		job.Audio_offset = test_case.audio_offset
+
+		recorded_commands, error_code := run_job_with_recording_runner(t, job, movie_canned_outputs(t))
+
+		if error_code != nil {
+			t.Fatal(error_code)
+		}
+
+		compare_commands(t, recorded_commands, []string{
+			ffprobe_test_command,
+			"ffmpeg -y -loglevel level+error -threads auto " + test_case.expected_inputs + " -filter_complex [0:v:0]idet,yadif=0:deint=all[main_processed_video_out] -map [main_processed_video_out] -map 1:a:0 -sn -c:v libx264 -preset medium -profile:v high -level 4.1 -crf 18 -c:a aac -b:a 768k -f mp4 TMPDIR/00-processed_files/movie.mp4",
+		})
+	}
+}
+
+func Test_run_end_time_keeps_milliseconds(t *testing.T) {
+
+	// -crf -st 10:00 -et 10:30.500. The duration given to FFmpeg keeps the milliseconds of the end time.
+	job := new_test_job(t)
+	job.Crf = true
+	job.Search_start = "10:00"
+	job.Processing_stop_time = "10:30.500"
+
+	recorded_commands, error_code := run_job_with_recording_runner(t, job, movie_canned_outputs(t))
+
+	if error_code != nil {
+		t.Fatal(error_code)
+	}
+
+	compare_commands(t, recorded_commands, []string{
+		ffprobe_test_command,
+		"ffmpeg -y -loglevel level+error -threads auto -ss 00:10:00 -i TMPDIR/movie.mkv -filter_complex [0:v:0]idet,yadif=0:deint=all[main_processed_video_out] -t 00:00:30.500 -map [main_processed_video_out] -map 0:a:0 -sn -c:v libx264 -preset medium -profile:v high -level 4.1 -crf 18 -acodec copy -f mp4 TMPDIR/00-processed_files/movie.mp4",
+	})
+}
+
+func Test_run_end_time_before_start_time_in_frames(t *testing.T) {
+
+	// Frame numbers are compared with the frame rate of the file, the file fails when the end time is not after the start time
+	job := new_test_job(t)
+	job.Crf = true
+	job.Search_start = "00:10:00;00"
+	job.Processing_stop_time = "17982f"
+
+	recorded_commands, error_code := run_job_with_recording_runner(t, job, movie_canned_outputs(t))
+
+	if error_code == nil || error_code.Error() != "1 files failed" {
+		t.Errorf("wrong error: %v", error_code)
+	}
+
+	compare_commands(t, recorded_commands, []string{ffprobe_test_command})
+}
+
+func Test_run_only_print_commands(t *testing.T) {
+
+	// -print -ac -st 10:00 -d 5:00. Crop detection is run, encoding commands are only printed.
+	job := new_test_job(t)
+	job.Only_print_commands = true
+	job.Autocrop = true
+	job.Search_start = "10:00"
+	job.Processing_duration = "5:00"
+
+	recorded_commands, error_code := run_job_with_recording_runner(t, job, movie_canned_outputs(t))
+
+	if error_code != nil {
+		t.Fatal(error_code)
+	}
+
+	compare_commands(t, recorded_commands, []string{
+		ffprobe_test_command,
+		"ffmpeg -ss 600 -t 300 -i TMPDIR/movie.mkv -f matroska -sn -an -filter_complex cropdetect=24:8:250 -y -crf 51 -preset ultrafast /dev/null",
+	})
+}
+
+func Test_run_ffprobe_error(t *testing.T) {
+
+	job := new_test_job(t)
+
+	recorded_commands, error_code := run_job_with_recording_runner(t, job, map[string]encode.Canned_command_output_struct{
+		"ffprobe": {Stderr: "movie.mkv: Invalid data found when processing input", Error_code: errors.New("exit status 1")},
+	})
+
+	if error_code == nil || error_code.Error() != "1 files failed" {
+		t.Errorf("wrong error: %v", error_code)
+	}
+
+	compare_commands(t, recorded_commands, []string{ffprobe_test_command})
+}
+
+func Test_run_av1_two_pass_with_svt_av1(t *testing.T) {
+
+	// -av1 -a eng. FFmpeg lists both AV1 encoders, SVT-AV1 is selected. The encoder list is read once for the whole run.
+	job := new_test_job(t)
+	job.Av1 = true
+	job.Audio_language = "eng"
+
+	canned_outputs := movie_canned_outputs(t)
+	canned_outputs["ffmpeg -hide_banner -encoders"] = encode.Canned_command_output_struct{Stdout: "Encoders:\n" +
+		" V..... = Video\n" +
+		" ------\n" +
+		" V....D libx264              libx264 H.264 / AVC / MPEG-4 AVC / MPEG-4 part 10 (codec h264)\n" +
+		" V....D libaom-av1           libaom AV1 (codec av1)\n" +
+		" V....D libsvtav1            SVT-AV1(Scalable Video Technology for AV1) encoder (codec av1)\n"}
+
+	recorded_commands, error_code := run_job_with_recording_runner(t, job, canned_outputs)
+
+	if error_code != nil {
+		t.Fatal(error_code)
+	}
+
+	compare_commands(t, recorded_commands, []string{
+		"ffmpeg -hide_banner -encoders",
+		ffprobe_test_command,
+		"ffmpeg -y -loglevel level+error -threads auto -i TMPDIR/movie.mkv -filter_complex [0:v:0]idet,yadif=0:deint=all[main_processed_video_out] -map [main_processed_video_out] -map 0:a:0 -sn -c:v libsvtav1 -preset 6 -b:v 3240k -pix_fmt yuv420p10le -acodec copy -passlogfile TMPDIR/00-processed_files/movie -f mp4 -pass 1 /dev/null",
+		"ffmpeg -y -loglevel level+error -threads auto -i TMPDIR/movie.mkv -filter_complex [0:v:0]idet,yadif=0:deint=all[main_processed_video_out] -map [main_processed_video_out] -map 0:a:0 -sn -c:v libsvtav1 -preset 6 -b:v 3240k -pix_fmt yuv420p10le -acodec copy -passlogfile TMPDIR/00-processed_files/movie -f mp4 -pass 2 TMPDIR/00-processed_files/movie.mp4",
+	})
+}
@@ -0,0 +1,338 @@
+// (C) Mikael Hartzell 2018
+// This program is distributed under the GNU General Public License, version 3 (GPLv3)
+
+// Package subtitle repositions and resizes bitmap subtitles (-sp) with ImageMagick.
+package subtitle
+
+import (
+	"context"
+	"crypto/md5"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/mhartzel/ffcommander/encode"
+)
+
+func Read_filenames_in_a_dir(source_dir string) (files_str_slice []string, error_code error) {
+
+	files, err := ioutil.ReadDir(source_dir)
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, entry := range files {
+
+		if entry.IsDir() == false {
+			files_str_slice = append(files_str_slice, entry.Name())
+		}
+	}
+
+	return files_str_slice, nil
+}
+
+func Subtitle_trim(ctx context.Context, original_subtitles_absolute_path string, fixed_subtitles_absolute_path string, files_str_slice []string, video_width int, video_height int, process_number int, return_channel chan int, subtitle_burn_resize string, subtitle_burn_grayscale bool) {
+
+	var subtitle_dimension_info []string
+	var subtitle_resize_info []string
+	var subtitles_dimension_map = make(map[string][]string)
+	var subtitle_resize_commandline []string
+	var subtitle_trim_commandline []string
+
+	///////////////////////////////////////////////////////////////////
+	// Trim subtitles, removing empty space around the subtitle text //
+	///////////////////////////////////////////////////////////////////
+
+	for _, subtitle_name := range files_str_slice {
+
+		// Stop processing if the user interrupted the program
+		if ctx.Err() != nil {
+			break
+		}
+
+		subtitle_trim_commandline = nil
+
+		if subtitle_burn_grayscale == true {
+			// Convert subtitle to grayscale and trim
+			subtitle_trim_commandline = append(subtitle_trim_commandline, "magick", filepath.Join(original_subtitles_absolute_path, subtitle_name), "-trim", "-print", "%[W],%[H],%[fx:w],%[fx:h],%[fx:page.x],%[fx:page.y]", "-compress", "rle", "-set", "colorspace", "Gray", filepath.Join(fixed_subtitles_absolute_path, subtitle_name))
+		} else {
+			// Trim subtitle
+			subtitle_trim_commandline = append(subtitle_trim_commandline, "magick", filepath.Join(original_subtitles_absolute_path, subtitle_name), "-trim", "-print", "%[W],%[H],%[fx:w],%[fx:h],%[fx:page.x],%[fx:page.y]", "-compress", "rle", filepath.Join(fixed_subtitles_absolute_path, subtitle_name))
+		}
+
+		subtitle_trim_output, subtitle_trim_error, trim_error_code := encode.Run_external_command(ctx, subtitle_trim_commandline)
+
+		///////////////////////////////////////////////////////////////////////////////////////////////////
+		// If there is no subtitle in the image, then create a subtitle file with an empty alpha channel //
+		///////////////////////////////////////////////////////////////////////////////////////////////////
+		if trim_error_code != nil {
+
+			fmt.Println()
+			fmt.Println("ImageMagick trim reported error: ", trim_error_code, subtitle_trim_error)
+			fmt.Println()
+
+			continue
+		}
+
+		subtitle_resize_commandline = nil
+
+		subtitle_resize_commandline = append(subtitle_resize_commandline, "mogrify", "+distort", "SRT", subtitle_burn_resize + ",0", "+repage", "-print", "%[fx:w],%[fx:h]", "-compress", "rle", filepath.Join(fixed_subtitles_absolute_path, subtitle_name))
+		subtitle_resize_output, subtitle_resize_error, resize_error_code := encode.Run_external_command(ctx, subtitle_resize_commandline)
+
+		if resize_error_code != nil {
+			fmt.Println("Subtitle resize reported error:", subtitle_resize_error)
+		}
+
+		// Take image properties before and after crop and store them in a map.
+		//
+		// Image info in 'subtitle_dimension_info' is:
+		// Original width before crop (not used at the moment)
+		// Original height before crop (not used at the moment)
+		// Cropped width
+		// Cropped height
+		// Start of crop on x axis
+		// Start of crop on y axis (not used at the moment)
+		// Subtitle width after resize
+		// Subtitle height after resize
+
+		subtitle_dimension_info = strings.Split(subtitle_trim_output[0], ",")
+
+		if subtitle_burn_resize != "" {
+
+			subtitle_resize_info = strings.Split(subtitle_resize_output[0], ",")
+			subtitle_dimension_info = append(subtitle_dimension_info, subtitle_resize_info...)
+
+		} else {
+
+			subtitle_dimension_info = append(subtitle_dimension_info, "0", "0")
+		}
+
+		subtitles_dimension_map[subtitle_name] = subtitle_dimension_info
+	}
+
+	/////////////////////////////////////////////////////////////////////////
+	// Overlay cropped subtitles on a new position on a transparent canvas //
+	/////////////////////////////////////////////////////////////////////////
+
+	var subtitle_adjust_commandline []string
+	var subtitle_new_y int
+	counter := 0
+
+	// Define the position of the subtitle to be 5 - 20 pixels from the top / bottom of picture depending on the video height.
+	var subtitle_margin int = video_height / 100
+
+	if subtitle_margin < 5 {
+		subtitle_margin = 5
+	}
+
+	if subtitle_margin > 20 {
+		subtitle_margin = 20
+	}
+
+	for subtitle_name := range subtitles_dimension_map {
+
+		if ctx.Err() != nil {
+			break
+		}
+
+		counter++
+		// orig_width ,_ := strconv.Atoi(subtitles_dimension_map[subtitle_name][0])
+		// orig_height ,_:= strconv.Atoi(subtitles_dimension_map[subtitle_name][1])
+		cropped_width, _ := strconv.Atoi(subtitles_dimension_map[subtitle_name][2])
+		cropped_height, _ := strconv.Atoi(subtitles_dimension_map[subtitle_name][3])
+		// cropped_start_x ,_:= strconv.Atoi(subtitles_dimension_map[subtitle_name][4])
+		cropped_start_y, _ := strconv.Atoi(subtitles_dimension_map[subtitle_name][5])
+
+		if subtitle_burn_resize != "" {
+			cropped_width, _ = strconv.Atoi(subtitles_dimension_map[subtitle_name][6])
+			cropped_height, _ = strconv.Atoi(subtitles_dimension_map[subtitle_name][7])
+		}
+
+		picture_center := video_height / 2 // Divider to find out if the subtitle is located above or below this line at the center of the picture
+		subtitle_new_x := (video_width / 2) - (cropped_width / 2) // This centers cropped subtitle on the x axis
+
+		if cropped_start_y > picture_center {
+			// Center subtitle on the bottom of the picure
+			subtitle_new_y = video_height - cropped_height - subtitle_margin
+
+		} else {
+			// Center subtitle on top of the picture
+			subtitle_new_y = subtitle_margin
+		}
+
+		subtitle_adjust_commandline = nil
+		subtitle_adjust_commandline = append(subtitle_adjust_commandline, "magick", "-size", strconv.Itoa(video_width) + "x" + strconv.Itoa(video_height), "canvas:transparent", filepath.Join(fixed_subtitles_absolute_path, subtitle_name), "-geometry", "+" + strconv.Itoa(subtitle_new_x) + "+" + strconv.Itoa(subtitle_new_y), "-composite", "-compose", "over", "-compress", "rle", filepath.Join(fixed_subtitles_absolute_path, subtitle_name))
+
+		_, subtitle_trim_error, error_code := encode.Run_external_command(ctx, subtitle_adjust_commandline)
+
+		if error_code != nil {
+			fmt.Println("Repositioning subtitle generated an error:", subtitle_trim_error)
+		}
+	}
+	return_channel <- process_number
+}
+
+func Remove_duplicate_subtitle_images (ctx context.Context, original_subtitles_absolute_path string, fixed_subtitles_absolute_path string, files_str_slice []string, video_width int, video_height int) (files_remaining []string, error_code error) {
+
+	var subtitle_md5sum_map  = make(map[string][]string)
+	var subtitle_copies []string
+
+	// Calculate md5 for each file
+	for _, subtitle_name := range files_str_slice {
+
+		subtitle_path := filepath.Join(original_subtitles_absolute_path, subtitle_name)
+		filehandle, err := os.Open(subtitle_path)
+
+		if err != nil {
+			return nil, err
+		}
+
+		md5_handler := md5.New()
+
+		if _, err := io.Copy(md5_handler, filehandle); err != nil {
+			filehandle.Close()
+			return nil, err
+		}
+
+		// Caculate md5 for the subtitle file
+		md5sum := fmt.Sprintf("%x", md5_handler.Sum(nil))
+		filehandle.Close()
+
+		// If we have not stored this md5 before then store it and the name of the picture to map.
+		// If we have stored the md5 before, add the picture name to list for this md5.
+		if _, val := subtitle_md5sum_map[md5sum] ; val == false {
+			subtitle_copies = nil
+			subtitle_copies = append(subtitle_copies, subtitle_name)
+			subtitle_md5sum_map[md5sum] = subtitle_copies
+
+		} else {
+			subtitle_copies = nil
+			subtitle_copies = subtitle_md5sum_map[md5sum]
+			subtitle_copies = append(subtitle_copies, subtitle_name)
+			subtitle_md5sum_map[md5sum] = subtitle_copies
+		}
+	}
+
+	// Trim images until we find one where there is no subtitle.
+	// Create temp directory for trimmed images
+	var empty_subtitle_creation_commandline_start []string
+	empty_subtitle_creation_commandline_start = append(empty_subtitle_creation_commandline_start, "magick", "-size", strconv.Itoa(video_width) + "x" + strconv.Itoa(video_height), "canvas:transparent", "-alpha", "on", "-compress", "rle")
+	var empty_subtitle_creation_commandline []string
+
+	var subtitle_trim_commandline []string
+	var empty_subtitle_path string
+	var empty_subtitle_md5 string
+
+	temp_path := filepath.Join(original_subtitles_absolute_path, "00-temp_path")
+
+	if _, err := os.Stat(temp_path); os.IsNotExist(err) {
+		os.MkdirAll(temp_path, 0777)
+	}
+
+	for _, subtitle_name := range files_str_slice {
+
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+
+		subtitle_trim_commandline = nil
+		subtitle_trim_commandline = append(subtitle_trim_commandline, "magick", filepath.Join(original_subtitles_absolute_path, subtitle_name), "-trim", "-print", "%[W],%[H],%[fx:w],%[fx:h],%[fx:page.x],%[fx:page.y]", "-compress", "rle", filepath.Join(temp_path, subtitle_name))
+		_, subtitle_trim_error, trim_error_code := encode.Run_external_command(ctx, subtitle_trim_commandline)
+
+		///////////////////////////////////////////////////////////////////////////////////////////////////
+		// If there is no subtitle in the image, then create a subtitle file with an empty alpha channel //
+		///////////////////////////////////////////////////////////////////////////////////////////////////
+		// Imagemagick telling that there is no image is only a warning so the error code will be 0,
+		// but there is a warning string: "geometry does not contain image".
+
+		if strings.Contains(subtitle_trim_error[0], "does not contain image") == true && trim_error_code == nil {
+
+			// Get md5 of the empty image
+			subtitle_path := filepath.Join(original_subtitles_absolute_path, subtitle_name)
+			filehandle, err := os.Open(subtitle_path)
+
+			if err != nil {
+				return nil, err
+			}
+
+			md5_handler := md5.New()
+			_, err = io.Copy(md5_handler, filehandle)
+			filehandle.Close()
+
+			if err != nil {
+				return nil, err
+			}
+
+			// Caculate md5 for the subtitle file
+			empty_subtitle_md5 = fmt.Sprintf("%x", md5_handler.Sum(nil))
+
+			// Create an empty picture with nothing but transparency in it and write it overwrinting the original.
+			// This is needed to get this image and the ones later manipulated with ImageMagick to have the same bit depth and other properties.
+			empty_subtitle_path = filepath.Join(fixed_subtitles_absolute_path, subtitle_name)
+			empty_subtitle_creation_commandline = append(empty_subtitle_creation_commandline_start, empty_subtitle_path)
+			_, _, error_code := encode.Run_external_command(ctx, empty_subtitle_creation_commandline)
+
+			if error_code != nil {
+				fmt.Println("\n\nCreating an empty subtitle image generated an error:", subtitle_trim_error)
+			}
+
+			break // Jump out of the loop when the first image without subtitle has been found
+		}
+
+		if trim_error_code != nil {
+
+			fmt.Println()
+			fmt.Println("ImageMagick trim reported error: ", trim_error_code, subtitle_trim_error)
+			fmt.Println()
+
+			continue
+		}
+	}
+
+	// Create soft links for empty image duplicates
+	var new_empty_subtitle string
+	subtitle_copies = nil
+	subtitle_copies = subtitle_md5sum_map[empty_subtitle_md5]
+
+	for counter, filename := range subtitle_copies {
+
+		if counter == 0 {
+			new_empty_subtitle = filename
+			continue
+		}
+
+		err := os.Symlink(filepath.Join(fixed_subtitles_absolute_path, new_empty_subtitle), filepath.Join(fixed_subtitles_absolute_path, filename))
+
+		if err != nil {
+			return nil, err
+		}
+
+	}
+	delete (subtitle_md5sum_map, empty_subtitle_md5)
+
+	// Create soft links for the rest of subtitle image duplicates
+	for _, subtitle_copies := range subtitle_md5sum_map {
+
+		for counter, filename := range subtitle_copies {
+
+			if counter == 0 {
+				new_empty_subtitle = filename
+				files_remaining = append(files_remaining, filename)
+				continue
+			}
+
+			err := os.Symlink(filepath.Join(fixed_subtitles_absolute_path, new_empty_subtitle), filepath.Join(fixed_subtitles_absolute_path, filename))
+
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return files_remaining, nil
+}